@@ -0,0 +1,250 @@
+package sand
+
+import (
+	"github.com/pkg/errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseArgs populates the fields of the struct pointed to by into
+// from args, e.g. the fields of a line without the command itself.
+// Fields are described by their "arg" tag and untagged fields are
+// left as is:
+//
+//	type pingArgs struct {
+//		Count   int      `arg:"--count" default:"3"`
+//		Verbose bool     `arg:"--verbose"`
+//		Host    string   `arg:"host,required"`
+//		Rest    []string `arg:"rest"`
+//	}
+//
+// A name starting with "--" is a flag, given as "--count 5" or
+// "--count=5", except for bool flags which take no value unless given
+// as "--verbose=false". Repeating a slice flag appends to it. Any
+// other name is a positional argument, which are filled in the order
+// of the fields, with a slice taking all remaining ones. Everything
+// after a "--" is positional.
+//
+// The "default" tag is used for fields which aren't given and the
+// "required" option makes it an error to not give one. Strings, bools,
+// ints, uints, floats, time.Durations and slices of them are supported.
+//
+func ParseArgs(args []string, into interface{}) error {
+	v := reflect.ValueOf(into)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("sand: ParseArgs needs a pointer to a struct")
+	}
+	v = v.Elem()
+
+	flags := make(map[string]*argField)
+	var positional []*argField
+	for i := 0; i < v.NumField(); i++ {
+		f, err := newArgField(v.Type().Field(i), v.Field(i))
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		if strings.HasPrefix(f.name, "--") {
+			flags[f.name] = f
+		} else {
+			positional = append(positional, f)
+		}
+	}
+
+	var pos []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			pos = append(pos, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			pos = append(pos, arg)
+			continue
+		}
+
+		name, val := arg, ""
+		hasVal := false
+		if j := strings.IndexByte(arg, '='); j >= 0 {
+			name, val, hasVal = arg[:j], arg[j+1:], true
+		}
+		f, ok := flags[name]
+		if !ok {
+			return errors.Errorf("sand: unknown flag %s", name)
+		}
+		if !hasVal && f.elemKind() == reflect.Bool {
+			val, hasVal = "true", true
+		}
+		if !hasVal {
+			if i+1 >= len(args) {
+				return errors.Errorf("sand: flag %s needs a value", name)
+			}
+			i++
+			val = args[i]
+		}
+		if err := f.set(val); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range positional {
+		if len(pos) == 0 {
+			break
+		}
+		n := 1
+		if f.value.Kind() == reflect.Slice {
+			n = len(pos)
+		}
+		for _, val := range pos[:n] {
+			if err := f.set(val); err != nil {
+				return err
+			}
+		}
+		pos = pos[n:]
+	}
+	if len(pos) > 0 {
+		return errors.Errorf("sand: too many arguments: %s", strings.Join(pos, " "))
+	}
+
+	for _, f := range positional {
+		if err := f.finish(); err != nil {
+			return err
+		}
+	}
+	for _, f := range flags {
+		if err := f.finish(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// argField is a struct field populated by ParseArgs.
+type argField struct {
+	name     string
+	value    reflect.Value
+	def      string
+	hasDef   bool
+	required bool
+	isSet    bool
+}
+
+// newArgField returns the argField of a struct field, or nil if
+// the field isn't tagged.
+//
+func newArgField(sf reflect.StructField, v reflect.Value) (*argField, error) {
+	tag, ok := sf.Tag.Lookup("arg")
+	if !ok {
+		return nil, nil
+	}
+	if !v.CanSet() {
+		return nil, errors.Errorf("sand: field %s is unexported", sf.Name)
+	}
+
+	opts := strings.Split(tag, ",")
+	f := &argField{name: opts[0], value: v}
+	if f.name == "" {
+		f.name = strings.ToLower(sf.Name)
+	}
+	for _, opt := range opts[1:] {
+		if opt != "required" {
+			return nil, errors.Errorf("sand: field %s has unknown option %q", sf.Name, opt)
+		}
+		f.required = true
+	}
+	f.def, f.hasDef = sf.Tag.Lookup("default")
+	return f, nil
+}
+
+// elemKind returns the kind of the field, or its elements for slices.
+func (f *argField) elemKind() reflect.Kind {
+	if f.value.Kind() == reflect.Slice {
+		return f.value.Type().Elem().Kind()
+	}
+	return f.value.Kind()
+}
+
+// set parses val into the field, appending to slices.
+func (f *argField) set(val string) error {
+	if f.value.Kind() != reflect.Slice {
+		f.isSet = true
+		return parseArgValue(f.name, val, f.value)
+	}
+
+	elem := reflect.New(f.value.Type().Elem()).Elem()
+	if err := parseArgValue(f.name, val, elem); err != nil {
+		return err
+	}
+	if !f.isSet {
+		f.value.Set(f.value.Slice(0, 0))
+	}
+	f.isSet = true
+	f.value.Set(reflect.Append(f.value, elem))
+	return nil
+}
+
+// finish applies the default of a field which wasn't given, or
+// fails if it's required.
+//
+func (f *argField) finish() error {
+	switch {
+	case f.isSet:
+		return nil
+	case f.required:
+		return errors.Errorf("sand: missing required argument %s", f.name)
+	case !f.hasDef:
+		return nil
+	}
+
+	if f.value.Kind() != reflect.Slice {
+		return f.set(f.def)
+	}
+	for _, val := range strings.Split(f.def, ",") {
+		if err := f.set(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// durationType is the type of time.Duration, which is parsed by
+// time.ParseDuration instead of as an int64.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parseArgValue parses val into v according to its kind.
+func parseArgValue(name, val string, v reflect.Value) (err error) {
+	switch {
+	case v.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(val)
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(val)
+	case v.Kind() == reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(val)
+		v.SetBool(b)
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(val, 0, v.Type().Bits())
+		v.SetInt(n)
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(val, 0, v.Type().Bits())
+		v.SetUint(n)
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		var n float64
+		n, err = strconv.ParseFloat(val, v.Type().Bits())
+		v.SetFloat(n)
+	default:
+		return errors.Errorf("sand: argument %s has unsupported type %s", name, v.Type())
+	}
+	if err != nil {
+		return errors.Errorf("sand: invalid value %q for %s", val, name)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package sand
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testPingArgs struct {
+	Count   int      `arg:"--count" default:"3"`
+	Verbose bool     `arg:"--verbose"`
+	Host    string   `arg:"host,required"`
+	Rest    []string `arg:"rest"`
+	ignored string
+}
+
+type testServeArgs struct {
+	Addr    string        `arg:"--addr" default:"localhost:8080"`
+	Timeout time.Duration `arg:"--timeout" default:"5s"`
+	Ratio   float64       `arg:"--ratio"`
+	Ports   []uint16      `arg:"--port" default:"80,443"`
+}
+
+func TestParseArgs(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Args []string
+		Into interface{}
+		Ex   interface{}
+		Err  string
+	}{
+		{
+			Name: "Positional",
+			Args: []string{"example.com"},
+			Into: new(testPingArgs),
+			Ex:   &testPingArgs{Count: 3, Host: "example.com"},
+		},
+		{
+			Name: "Flags",
+			Args: []string{"--count", "5", "--verbose", "example.com", "a", "b"},
+			Into: new(testPingArgs),
+			Ex:   &testPingArgs{Count: 5, Verbose: true, Host: "example.com", Rest: []string{"a", "b"}},
+		},
+		{
+			Name: "EqualsAndDashes",
+			Args: []string{"--count=0x10", "--verbose=false", "--", "--host"},
+			Into: new(testPingArgs),
+			Ex:   &testPingArgs{Count: 16, Host: "--host"},
+		},
+		{
+			Name: "Defaults",
+			Into: new(testServeArgs),
+			Ex:   &testServeArgs{Addr: "localhost:8080", Timeout: 5 * time.Second, Ports: []uint16{80, 443}},
+		},
+		{
+			Name: "Repeated",
+			Args: []string{"--port", "8080", "--port=8443", "--timeout", "1m", "--ratio", "0.5"},
+			Into: new(testServeArgs),
+			Ex:   &testServeArgs{Addr: "localhost:8080", Timeout: time.Minute, Ratio: 0.5, Ports: []uint16{8080, 8443}},
+		},
+		{Name: "Required", Into: new(testPingArgs), Err: "sand: missing required argument host"},
+		{Name: "UnknownFlag", Args: []string{"--size", "1", "x"}, Into: new(testPingArgs), Err: "sand: unknown flag --size"},
+		{Name: "MissingValue", Args: []string{"x", "--count"}, Into: new(testPingArgs), Err: "sand: flag --count needs a value"},
+		{Name: "InvalidValue", Args: []string{"--port", "70000"}, Into: new(testServeArgs), Err: `sand: invalid value "70000" for --port`},
+		{Name: "TooMany", Args: []string{"x"}, Into: new(testServeArgs), Err: "sand: too many arguments: x"},
+		{Name: "NotStruct", Into: new(string), Err: "sand: ParseArgs needs a pointer to a struct"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			err := ParseArgs(tc.Args, tc.Into)
+			if tc.Err != "" {
+				if err == nil || err.Error() != tc.Err {
+					subT.Errorf("expected error %q but instead received: %v", tc.Err, err)
+				}
+				return
+			}
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if !reflect.DeepEqual(tc.Into, tc.Ex) {
+				subT.Errorf("expected %+v but instead received: %+v", tc.Ex, tc.Into)
+			}
+		})
+	}
+}
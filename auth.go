@@ -0,0 +1,40 @@
+package sand
+
+import "context"
+
+// Authorizer decides whether a line may be executed, e.g. to limit
+// the commands available to a user in a shell served over the
+// network, who is identified through the context.
+//
+type Authorizer interface {
+	// Authorize returns an error if the line mustn't be executed.
+	Authorize(ctx context.Context, line string) error
+}
+
+// WithAuthorizer checks every line with auth before it reaches the
+// Engine, including lines from TryExec and background jobs. A denied
+// line is never executed; the error is written instead and the
+// status is 1, which, like any other failure, ends the session.
+// Builtins aren't checked.
+//
+func WithAuthorizer(auth Authorizer) Option {
+	return func(ui *UI) {
+		ui.auth = auth
+	}
+}
+
+// authorize checks the line with the Authorizer, if any, and writes
+// the error if it's denied.
+//
+func (ui *UI) authorize(ctx context.Context, line string) bool {
+	if ui.auth == nil {
+		return true
+	}
+
+	err := ui.auth.Authorize(ctx, line)
+	if err == nil {
+		return true
+	}
+	ui.writePrompt([]byte(ui.Theme().Error.Paint(err.Error()) + "\n"))
+	return false
+}
@@ -0,0 +1,72 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testAuthorizer denies every line starting with "rm".
+type testAuthorizer struct{}
+
+func (testAuthorizer) Authorize(ctx context.Context, line string) error {
+	if strings.HasPrefix(line, "rm") {
+		return errors.New("sand: permission denied")
+	}
+	return nil
+}
+
+func TestWithAuthorizer(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Lines   []string
+		ExExecs int
+		ExOut   string
+	}{
+		{Name: "Allowed", Lines: []string{"ls\n", "cat\n"}, ExExecs: 2, ExOut: "ls\ncat\n\n"},
+		{Name: "Denied", Lines: []string{"ls\n", "rm -rf /\n", "cat\n"}, ExExecs: 1, ExOut: "ls\nsand: permission denied\n\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: tc.Lines}
+			var out bytes.Buffer
+			eng := new(testEchoEngine)
+
+			err := Run(nil, eng, WithIO(in, &out), WithAuthorizer(testAuthorizer{}))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if eng.execs != tc.ExExecs {
+				subT.Errorf("expected %d execs but instead received: %d", tc.ExExecs, eng.execs)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestWithAuthorizer_Middleware(t *testing.T) {
+	var seen []string
+	record := func(next Engine) Engine {
+		return EngineFunc(func(ctx context.Context, line string, rw io.ReadWriter) int {
+			seen = append(seen, line)
+			return next.Exec(ctx, line, rw)
+		})
+	}
+
+	in := &testLineReader{lines: []string{"ls\n", "rm -rf /\n"}}
+	err := Run(nil, new(testEchoEngine), WithIO(in, ioutil.Discard), WithAuthorizer(testAuthorizer{}), WithMiddleware(record))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if len(seen) != 1 || seen[0] != "ls" {
+		t.Errorf("expected the middleware to only see %q but instead received: %q", "ls", seen)
+	}
+}
@@ -0,0 +1,150 @@
+package sand
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"sync"
+)
+
+// boundedChunkSize is the size of the chunks a bounded output
+// queues writes in, see WithBoundedOutput.
+const boundedChunkSize = 512
+
+// errOutputClosed is returned by writes to a bounded output after
+// the session it belongs to has ended.
+var errOutputClosed = errors.New("sand: output is closed")
+
+// WithBoundedOutput queues up to n bytes of output, which is written
+// on a goroutine of its own, so an Engine producing a lot of output
+// can keep going while a slow consumer, e.g. a remote terminal, catches
+// up. Once n bytes are queued, Write blocks until there's room again,
+// or the session ends, instead of queueing more. Before the prompt is
+// written again, the UI waits for the queued output to be written.
+// Write errors are returned by the next Write once they occur.
+//
+func WithBoundedOutput(n int) Option {
+	return func(ui *UI) {
+		ui.outBound = n
+	}
+}
+
+// boundedChunk is a queued chunk of output, or a flush marker.
+type boundedChunk struct {
+	b       []byte
+	flushed chan struct{}
+}
+
+// boundedWriter writes to w on its own goroutine, queueing a bounded
+// number of chunks.
+//
+type boundedWriter struct {
+	w      io.Writer
+	chunks chan boundedChunk
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func newBoundedWriter(w io.Writer, n int) *boundedWriter {
+	size := n / boundedChunkSize
+	if size < 1 {
+		size = 1
+	}
+	bw := &boundedWriter{
+		w:      w,
+		chunks: make(chan boundedChunk, size),
+		done:   make(chan struct{}),
+	}
+	go bw.run()
+	return bw
+}
+
+// run writes the queued chunks until the writer is closed.
+func (bw *boundedWriter) run() {
+	for {
+		select {
+		case <-bw.done:
+			return
+		case c := <-bw.chunks:
+			if c.flushed != nil {
+				close(c.flushed)
+				continue
+			}
+			if bw.error() != nil {
+				continue
+			}
+			if _, err := bw.w.Write(c.b); err != nil {
+				bw.mu.Lock()
+				bw.err = err
+				bw.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (bw *boundedWriter) error() error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.err
+}
+
+// Write queues a copy of b, blocking while the queue is full.
+func (bw *boundedWriter) Write(b []byte) (n int, err error) {
+	if err = bw.error(); err != nil {
+		return
+	}
+
+	for len(b) > 0 {
+		size := len(b)
+		if size > boundedChunkSize {
+			size = boundedChunkSize
+		}
+		c := boundedChunk{b: append([]byte(nil), b[:size]...)}
+		select {
+		case <-bw.done:
+			return n, errOutputClosed
+		case bw.chunks <- c:
+		}
+		n += size
+		b = b[size:]
+	}
+	return
+}
+
+// flush waits for everything queued so far to be written, or for
+// ctx to be done, and returns the first write error.
+//
+func (bw *boundedWriter) flush(ctx context.Context) error {
+	c := boundedChunk{flushed: make(chan struct{})}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-bw.done:
+		return errOutputClosed
+	case bw.chunks <- c:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.flushed:
+	}
+	return bw.error()
+}
+
+// close stops writing, dropping anything still queued, and
+// unblocks pending writes.
+//
+func (bw *boundedWriter) close() {
+	close(bw.done)
+}
+
+// flushBounded waits for the bounded output, if any, to be written.
+func (ui *UI) flushBounded() error {
+	if ui.bounded == nil {
+		return nil
+	}
+	return ui.bounded.flush(ui.ctx)
+}
@@ -0,0 +1,105 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSlowWriter sleeps before every write, like a slow consumer.
+type testSlowWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	delay time.Duration
+}
+
+func (w *testSlowWriter) Write(b []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(b)
+}
+
+func (w *testSlowWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// testFloodEngine writes n chunks of output for every line.
+type testFloodEngine struct {
+	n     int
+	chunk []byte
+}
+
+func (eng *testFloodEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	for i := 0; i < eng.n; i++ {
+		if _, err := ui.Write(eng.chunk); err != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+func TestWithBoundedOutput(t *testing.T) {
+	in := &testLineReader{lines: []string{"a\n", "b\n"}}
+	out := &testSlowWriter{delay: time.Millisecond}
+	eng := &testFloodEngine{n: 20, chunk: []byte("0123456789\n")}
+
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, out), WithBoundedOutput(64))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	// Everything is written, in order, before the next prompt
+	cmd := strings.Repeat(">0123456789\n", eng.n)
+	if ex := ">" + cmd + ">" + cmd + ">\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
+
+func TestBoundedWriter(t *testing.T) {
+	// The consumer is blocked, so writes block once the queue is full
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	bw := newBoundedWriter(pw, boundedChunkSize)
+	defer bw.close()
+
+	written := make(chan int, 1)
+	go func() {
+		n, _ := bw.Write(make([]byte, 4*boundedChunkSize))
+		written <- n
+	}()
+	select {
+	case <-written:
+		t.Fatal("expected write to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	go io.Copy(ioutil.Discard, pr)
+	if n := <-written; n != 4*boundedChunkSize {
+		t.Errorf("expected %d bytes written but instead received: %d", 4*boundedChunkSize, n)
+	}
+	if err := bw.flush(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func benchmarkOutput(b *testing.B, opts ...Option) {
+	chunk := bytes.Repeat([]byte("x"), 4096)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		in := &testLineReader{lines: []string{"a\n"}}
+		eng := &testFloodEngine{n: 256, chunk: chunk}
+		Run(nil, eng, append([]Option{WithIO(in, ioutil.Discard)}, opts...)...)
+	}
+}
+
+func BenchmarkOutput(b *testing.B) { benchmarkOutput(b) }
+
+func BenchmarkBoundedOutput(b *testing.B) { benchmarkOutput(b, WithBoundedOutput(64*1024)) }
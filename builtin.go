@@ -0,0 +1,240 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Commander is implemented by Engines which want to report the
+// commands they handle. Commands reported by an Engine take
+// precedence over the UI builtins of the same name and are
+// listed by the help builtin.
+//
+type Commander interface {
+	// Commands returns the names of the commands the Engine handles.
+	Commands() []string
+}
+
+// DefaultCategory is the category the help builtin lists commands
+// without one under, see Categorizer.
+const DefaultCategory = "Misc"
+
+// Categorizer is implemented by Engines which group the commands
+// they report into categories, e.g. "File" and "Network", for the
+// help builtin to list them by. Commands without a category, as well
+// as the builtins, are listed under DefaultCategory.
+//
+type Categorizer interface {
+	Commander
+
+	// Category returns the category of the command, or an empty
+	// string if it has none.
+	Category(cmd string) string
+}
+
+// builtinFunc is the implementation of a builtin command.
+type builtinFunc func(ctx context.Context, args []string, ui *UI) int
+
+// builtin represents a command implemented by the UI itself.
+type builtin struct {
+	usage string
+	fn    builtinFunc
+}
+
+// WithVersion installs a "version" builtin, which prints the given
+// version. If version is empty, the version of the main module, as
+// reported by runtime/debug.ReadBuildInfo, is used instead.
+//
+func WithVersion(version string) Option {
+	return func(ui *UI) {
+		ui.addBuiltin("version", "print version information", versionBuiltin(version))
+	}
+}
+
+// clearScreen is the ANSI sequence for clearing the screen and
+// moving the cursor to the top left.
+const clearScreen = "\x1b[2J\x1b[H"
+
+// WithClear installs a "clear" builtin, which clears the screen if
+// the output is a terminal and does nothing otherwise. A line with
+// only a Ctrl-L also clears the screen.
+//
+func WithClear() Option {
+	return func(ui *UI) {
+		ui.addBuiltin("clear", "clear the screen", clearBuiltin)
+	}
+}
+
+// addBuiltin registers a builtin command with the UI. The help
+// builtin is registered along with the first builtin.
+//
+func (ui *UI) addBuiltin(name, usage string, fn builtinFunc) {
+	if ui.builtins == nil {
+		ui.builtins = map[string]builtin{
+			"help": {usage: "list available commands", fn: helpBuiltin},
+		}
+	}
+	ui.builtins[name] = builtin{usage: usage, fn: fn}
+}
+
+// engineCommands returns the set of commands the engine reports handling.
+func (ui *UI) engineCommands() map[string]bool {
+	cmdr, ok := ui.eng.(Commander)
+	if !ok {
+		return nil
+	}
+
+	cmds := make(map[string]bool)
+	for _, cmd := range cmdr.Commands() {
+		cmds[cmd] = true
+	}
+	return cmds
+}
+
+// execBuiltin executes the line if it names a builtin that
+// isn't overridden by the engine.
+//
+func (ui *UI) execBuiltin(ctx context.Context, line string) (status int, ok bool) {
+	if len(ui.builtins) == 0 {
+		return
+	}
+
+	// Ctrl-L is read as a form feed if the terminal isn't in raw mode
+	if strings.TrimRight(line, "\r\n") == "\f" {
+		line = "clear"
+	}
+
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return
+	}
+
+	b, exists := ui.builtins[args[0]]
+	if !exists || ui.engineCommands()[args[0]] {
+		return
+	}
+	ui.tracef("builtin", "%s", args[0])
+	return b.fn(ctx, args[1:], ui), true
+}
+
+// helpBuiltin lists the builtins and any engine commands, grouped by
+// category if the engine categorizes any of its commands.
+//
+func helpBuiltin(ctx context.Context, args []string, ui *UI) int {
+	cmds := ui.engineCommands()
+
+	var rows [][]string
+	for name, b := range ui.builtins {
+		if !cmds[name] {
+			rows = append(rows, []string{name, b.usage})
+		}
+	}
+	for name := range cmds {
+		rows = append(rows, []string{name, ""})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+
+	var buf bytes.Buffer
+	if groups := ui.categorize(rows, cmds); groups != nil {
+		writeCategories(&buf, groups, ui.Theme())
+	} else {
+		header := ui.Theme().Header
+		for _, row := range rows {
+			row[0] = header.Paint(row[0])
+		}
+		writeTable(&buf, rows)
+	}
+	if _, err := ui.write(buf.Bytes()); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// commandGroup is the rows of the help builtin in a category.
+type commandGroup struct {
+	category string
+	rows     [][]string
+}
+
+// categorize groups the rows by the category of their command, sorted
+// by category, with DefaultCategory last. It returns nil unless the
+// engine categorizes any of its commands.
+//
+func (ui *UI) categorize(rows [][]string, cmds map[string]bool) []commandGroup {
+	c, ok := ui.eng.(Categorizer)
+	if !ok {
+		return nil
+	}
+
+	byCategory := make(map[string][][]string)
+	for _, row := range rows {
+		category := ""
+		if cmds[row[0]] {
+			category = c.Category(row[0])
+		}
+		if category == "" {
+			category = DefaultCategory
+		}
+		byCategory[category] = append(byCategory[category], row)
+	}
+	if _, ok := byCategory[DefaultCategory]; ok && len(byCategory) == 1 {
+		return nil
+	}
+
+	groups := make([]commandGroup, 0, len(byCategory))
+	for category, rows := range byCategory {
+		groups = append(groups, commandGroup{category: category, rows: rows})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].category == DefaultCategory || groups[j].category == DefaultCategory {
+			return groups[j].category == DefaultCategory
+		}
+		return groups[i].category < groups[j].category
+	})
+	return groups
+}
+
+// writeCategories writes each group as a table, indented below its
+// category.
+//
+func writeCategories(buf *bytes.Buffer, groups []commandGroup, theme Theme) {
+	for _, g := range groups {
+		buf.WriteString(theme.Header.Paint(g.category+":") + "\n")
+		for _, row := range g.rows {
+			row[0] = "  " + row[0]
+		}
+		writeTable(buf, g.rows)
+	}
+}
+
+// versionBuiltin returns the builtin for printing the given version.
+func versionBuiltin(version string) builtinFunc {
+	return func(ctx context.Context, args []string, ui *UI) int {
+		v := version
+		if bi, ok := debug.ReadBuildInfo(); ok && v == "" {
+			v = bi.Main.Version
+		}
+
+		_, err := ui.write([]byte(fmt.Sprintf("%s (%s)\n", v, runtime.Version())))
+		if err != nil {
+			return 1
+		}
+		return 0
+	}
+}
+
+// clearBuiltin clears the screen, the prompt is then redrawn by Run.
+func clearBuiltin(ctx context.Context, args []string, ui *UI) int {
+	if !isTerminal(ui.output()) {
+		return 0
+	}
+	if _, err := ui.writePrompt([]byte(clearScreen)); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// testCommanderEngine echos lines and reports handling the given commands
+type testCommanderEngine struct {
+	testEchoEngine
+	cmds []string
+}
+
+func (eng *testCommanderEngine) Commands() []string { return eng.cmds }
+
+func runBuiltinTest(t *testing.T, eng Engine, input string, opts ...Option) string {
+	in := &testLineReader{lines: []string{input}}
+	var out bytes.Buffer
+
+	opts = append([]Option{WithPrefix(">"), WithIO(in, &out)}, opts...)
+	err := Run(nil, eng, opts...)
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	return out.String()
+}
+
+func TestWithVersion(t *testing.T) {
+	eng := new(testEchoEngine)
+	out := runBuiltinTest(t, eng, "version\n", WithVersion("v1.2.3"))
+
+	ex := ">v1.2.3 (" + runtime.Version() + ")\n>\n"
+	if out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+	if eng.execs != 0 {
+		t.Errorf("expected builtin to not be dispatched to engine")
+	}
+}
+
+func TestHelpBuiltin(t *testing.T) {
+	eng := &testCommanderEngine{cmds: []string{"ls"}}
+	out := runBuiltinTest(t, eng, "help\n", WithVersion("v1.2.3"))
+
+	ex := ">help     list available commands\nls\nversion  print version information\n>\n"
+	if out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+}
+
+func TestHelpBuiltin_Categories(t *testing.T) {
+	m := NewMux()
+	for _, verb := range []string{"open", "save", "ping", "misc"} {
+		m.Handle(verb, new(testRecordEngine))
+	}
+	m.SetCategory("File", "open", "save")
+	m.SetCategory("Network", "ping")
+	out := runBuiltinTest(t, m, "help\n", WithVersion("v1.2.3"), WithTheme(MonochromeTheme))
+
+	ex := ">File:\n  open\n  save\nNetwork:\n  ping\nMisc:\n  help     list available commands\n  misc\n  version  print version information\n>\n"
+	if out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+}
+
+func TestBuiltinOverride(t *testing.T) {
+	eng := &testCommanderEngine{cmds: []string{"version"}}
+	out := runBuiltinTest(t, eng, "version\n", WithVersion("v1.2.3"))
+
+	ex := ">>version\n>\n"
+	if out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+}
+
+func TestVersionBuiltinBuildInfo(t *testing.T) {
+	var out bytes.Buffer
+	ui := &UI{out: &out, ctx: context.Background()}
+
+	status := versionBuiltin("")(ui.ctx, nil, ui)
+	if status != 0 || out.Len() == 0 {
+		t.Errorf("expected version to be printed but instead received: %q", out.String())
+	}
+}
+
+func TestWithClear(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		TTY   bool
+		Input string
+		Ex    string
+	}{
+		{Name: "NotTerminal", Input: "clear\n", Ex: ">>\n"},
+		{Name: "Terminal", TTY: true, Input: "clear\n", Ex: ">" + clearScreen + ">\n"},
+		{Name: "CtrlL", TTY: true, Input: "\f\n", Ex: ">" + clearScreen + ">\n"},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.TTY }
+
+			eng := new(testEchoEngine)
+			out := runBuiltinTest(subT, eng, tc.Input, WithClear(), WithTheme(MonochromeTheme))
+			if out != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out)
+			}
+			if eng.execs != 0 {
+				subT.Errorf("expected builtin to not be dispatched to engine")
+			}
+		})
+	}
+}
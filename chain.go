@@ -0,0 +1,42 @@
+package sand
+
+import (
+	"context"
+	"io"
+)
+
+// EngineChain is an Engine which tries each of its Engines in
+// order, until one of them handles the line. See Chain.
+//
+type EngineChain struct {
+	engines []Engine
+
+	// Unhandled is the status returned when none of the
+	// Engines handle a line. It defaults to 0.
+	Unhandled int
+}
+
+// Chain returns an Engine which passes each line to the given Engines,
+// in order, until one of them returns a status other than StatusNotHandled.
+// That status is then returned. If every Engine returns StatusNotHandled,
+// the chains Unhandled status is returned instead.
+//
+// This allows for layering Engines, e.g. a set of common commands in
+// front of an application specific Engine and a catch-all Engine which
+// reports unknown commands.
+//
+func Chain(engines ...Engine) *EngineChain {
+	return &EngineChain{engines: engines}
+}
+
+// Exec passes the line to each Engine until one handles it.
+func (c *EngineChain) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	for _, eng := range c.engines {
+		status := eng.Exec(ctx, line, ui)
+		if status != StatusNotHandled {
+			return status
+		}
+		tracef(ui, "chain", "%T didn't handle the line", eng)
+	}
+	return c.Unhandled
+}
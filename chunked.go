@@ -0,0 +1,72 @@
+package sand
+
+import (
+	"context"
+	"io"
+)
+
+// DefaultChunkSize is the chunk size of a ChunkedWriter, unless another is given.
+const DefaultChunkSize = 4096
+
+// chunkedWriter writes to a UI in chunks, see ChunkedWriter.
+type chunkedWriter struct {
+	ui   *UI
+	ctx  context.Context
+	size int
+}
+
+// ChunkedWriter returns a Writer which writes to the UI, the same as
+// Write does, except that every Write is split into chunks of the
+// given size, or DefaultChunkSize if it isn't positive. The context
+// is checked before every chunk, so a large Write to a slow Writer
+// returns promptly once the context is done, e.g. on Ctrl-C. Pass
+// the context given to Exec.
+//
+func (ui *UI) ChunkedWriter(ctx context.Context, size int) io.Writer {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return &chunkedWriter{ui: ui, ctx: ctx, size: size}
+}
+
+func (w *chunkedWriter) Write(b []byte) (n int, err error) {
+	limited, truncated := w.ui.limitOutput(&w.ui.cmdOut, b)
+	if truncated && limited == nil {
+		return len(b), nil
+	}
+	out, ok := w.ui.engineOutput(limited)
+	if !ok {
+		return 0, nil
+	}
+	out = w.ui.wrapOutput(w.ui.wrap, out)
+
+	written := 0
+	for written < len(out) {
+		if err = w.ctx.Err(); err != nil {
+			break
+		}
+
+		end := written + w.size
+		if end > len(out) {
+			end = len(out)
+		}
+
+		var m int
+		m, err = w.ui.write(out[written:end])
+		written += m
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		return len(b), nil
+	}
+
+	// Report progress in terms of b, which is unknown if it was filtered or truncated
+	n = written - (len(out) - len(b))
+	if n < 0 || truncated || w.ui.outFilter != nil {
+		n = 0
+	}
+	return n, err
+}
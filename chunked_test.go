@@ -0,0 +1,51 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// testCancelWriter cancels the context after its first Write.
+type testCancelWriter struct {
+	bytes.Buffer
+	cancel context.CancelFunc
+	writes int
+}
+
+func (w *testCancelWriter) Write(b []byte) (int, error) {
+	w.writes++
+	w.cancel()
+	return w.Buffer.Write(b)
+}
+
+func TestUI_ChunkedWriter(t *testing.T) {
+	t.Run("Chunks", func(subT *testing.T) {
+		w := &testCancelWriter{cancel: func() {}}
+		ui := &UI{prefix: []byte(">"), ctx: context.Background()}
+		ui.out = w
+
+		n, err := ui.ChunkedWriter(context.Background(), 4).Write([]byte("0123456789"))
+		if n != 10 || err != nil {
+			subT.Errorf("expected 10 bytes written but instead received: %d %v", n, err)
+		}
+		if w.String() != ">0123456789" || w.writes != 3 {
+			subT.Errorf("expected prefixed output in 3 chunks but instead received: %q in %d", w.String(), w.writes)
+		}
+	})
+
+	t.Run("Canceled", func(subT *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := &testCancelWriter{cancel: cancel}
+		ui := &UI{prefix: []byte(">"), ctx: context.Background()}
+		ui.out = w
+
+		n, err := ui.ChunkedWriter(ctx, 4).Write([]byte("0123456789"))
+		if err != context.Canceled {
+			subT.Errorf("expected %s but instead received: %v", context.Canceled, err)
+		}
+		if n != 3 || w.String() != ">012" {
+			subT.Errorf("expected only the first chunk to be written but instead received: %d %q", n, w.String())
+		}
+	})
+}
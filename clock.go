@@ -0,0 +1,92 @@
+package sand
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for the time based features of the UI,
+// e.g. the first input timeout, idle callbacks and history timestamps,
+// see WithClock. Deadlines of contexts, e.g. of WithSessionTimeout,
+// ReadLineTimeout or Mux.HandleWithTimeout, always use the real clock.
+//
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel receiving the current time once d has
+	// passed.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer returns a Timer firing once d has passed.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer of a Clock, the same as a time.Timer.
+//
+type Timer interface {
+	// C returns the channel receiving the current time once the
+	// Timer fires.
+	C() <-chan time.Time
+
+	// Stop is the same as time.Timer.Stop.
+	Stop() bool
+
+	// Reset is the same as time.Timer.Reset.
+	Reset(d time.Duration) bool
+}
+
+// WithClock specifies the Clock to use instead of the real one, e.g.
+// for tests to control the passage of time, so time based features
+// behave deterministically.
+//
+func WithClock(c Clock) Option {
+	return func(ui *UI) {
+		ui.clk = c
+	}
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+
+// realTimer is a Timer of the time package.
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// clock returns the Clock of the UI.
+func (ui *UI) clock() Clock {
+	if ui.clk == nil {
+		return realClock{}
+	}
+	return ui.clk
+}
+
+// afterFunc calls f on a goroutine of its own once d has passed,
+// unless the returned func is called before.
+//
+func (ui *UI) afterFunc(d time.Duration, f func()) (stop func()) {
+	t := ui.clock().NewTimer(d)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-t.C():
+			f()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.Stop()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package sand
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a Clock whose time only passes by calling Advance.
+type testClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*testTimer
+}
+
+type testTimer struct {
+	clk  *testClock
+	c    chan time.Time
+	when time.Time
+	live bool
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *testClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &testTimer{clk: c, c: make(chan time.Time, 1), when: c.now.Add(d), live: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// armed returns how many timers are yet to fire.
+func (c *testClock) armed() (n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.timers {
+		if t.live {
+			n++
+		}
+	}
+	return
+}
+
+// Advance moves the time forward by d, firing any timers due.
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.live && !t.when.After(c.now) {
+			t.live = false
+			t.c <- c.now
+		}
+	}
+}
+
+func (t *testTimer) C() <-chan time.Time { return t.c }
+
+func (t *testTimer) Stop() bool {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+	live := t.live
+	t.live = false
+	return live
+}
+
+func (t *testTimer) Reset(d time.Duration) bool {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+	live := t.live
+	t.live = true
+	t.when = t.clk.now.Add(d)
+	return live
+}
+
+func TestRunWithClock(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	clk := newTestClock()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(nil, new(testEchoEngine), WithIO(pr, ioutil.Discard), WithFirstInputTimeout(time.Hour), WithClock(clk))
+	}()
+
+	for clk.armed() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("expected session to wait on the clock but instead received: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clk.Advance(time.Hour)
+	select {
+	case err := <-errCh:
+		if err != ErrNoInput {
+			t.Errorf("expected ErrNoInput but instead received: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected session to end once the clock passed the timeout")
+	}
+}
+
+func TestHistoryWithClock(t *testing.T) {
+	clk := newTestClock()
+	ui := new(UI)
+	err := ui.Run(nil, new(testEchoEngine), WithIO(strings.NewReader("a\n"), ioutil.Discard), WithHistoryExpansion(), WithClock(clk))
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	if when := ui.history.timeOf(0); !when.Equal(clk.Now()) {
+		t.Errorf("expected %s but instead received: %s", clk.Now(), when)
+	}
+}
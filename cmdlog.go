@@ -0,0 +1,81 @@
+package sand
+
+import (
+	"bufio"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithCommandLog appends every command the Engine executes
+// successfully, i.e. with a status of 0, to w, so the state of an
+// interpreter can be reconstructed after a crash, see ReplayLog.
+// Unlike the history, which is for recalling commands, the log is
+// an operation log: builtins, failed and incomplete commands aren't
+// recorded and a command of several lines, see StatusNeedMore, is
+// recorded once it's complete.
+//
+// Each entry is a line holding the time the command finished, in
+// RFC 3339 format with nanoseconds, a space and the command, exactly
+// as it was passed to Exec, quoted as a Go string literal:
+//
+//	2006-01-02T15:04:05.999999999Z "set x 1\n"
+//
+// Run ends the session with an error if writing an entry fails, since
+// the log can't be relied on after that.
+//
+func WithCommandLog(w io.Writer) Option {
+	return func(ui *UI) {
+		ui.cmdLog = w
+	}
+}
+
+// logCommand appends the line to the command log, if any.
+func (ui *UI) logCommand(line string) error {
+	if ui.cmdLog == nil {
+		return nil
+	}
+	entry := ui.clock().Now().Format(time.RFC3339Nano) + " " + strconv.Quote(line) + "\n"
+	if _, err := io.WriteString(ui.cmdLog, entry); err != nil {
+		return errors.Wrap(err, "sand: encountered error while writing command log")
+	}
+	return nil
+}
+
+// ReplayLog executes the commands of a command log read from r with
+// the Engine, in order, see WithCommandLog. Like Drive, it skips the
+// UI entirely, so the io.ReadWriter passed to Exec reads as empty and
+// discards what is written to it. Replaying stops at the first command
+// which doesn't return a status of 0, or once ctx is done.
+//
+func ReplayLog(ctx context.Context, eng Engine, r io.Reader) error {
+	rw := driveIO{Reader: strings.NewReader(""), Writer: ioutil.Discard}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry := s.Text()
+		i := strings.IndexByte(entry, ' ')
+		if i < 0 {
+			return errors.Errorf("sand: malformed command log entry %d", n)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, entry[:i]); err != nil {
+			return errors.Errorf("sand: malformed timestamp in command log entry %d", n)
+		}
+		line, err := strconv.Unquote(entry[i+1:])
+		if err != nil {
+			return errors.Errorf("sand: malformed command in command log entry %d", n)
+		}
+
+		if status := eng.Exec(ctx, line, rw); status != 0 {
+			return errors.Errorf("sand: command log entry %d failed with status %d", n, status)
+		}
+	}
+	return errors.Wrap(s.Err(), "sand: encountered error while reading command log")
+}
@@ -0,0 +1,76 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testSumEngine adds the numbers it's given to its sum.
+type testSumEngine struct {
+	sum int
+}
+
+func (eng *testSumEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return StatusNotHandled
+	}
+	eng.sum += n
+	return 0
+}
+
+func TestCommandLog_RoundTrip(t *testing.T) {
+	in := &testLineReader{lines: []string{"1\n", "nope\n", "help\n", "2\n", "3\n"}}
+	var out, log bytes.Buffer
+
+	eng := new(testSumEngine)
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithVersion("v1"), WithCommandLog(&log))
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if eng.sum != 6 {
+		t.Errorf("expected sum of 6 but instead received: %d", eng.sum)
+	}
+
+	entries := strings.Split(strings.TrimSuffix(log.String(), "\n"), "\n")
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 log entries but instead received: %q", log.String())
+	}
+	if !strings.HasSuffix(entries[0], ` "1"`) {
+		t.Errorf("expected entry for %q but instead received: %q", "1", entries[0])
+	}
+
+	replayed := new(testSumEngine)
+	if err := ReplayLog(context.Background(), replayed, &log); err != nil {
+		t.Error(err)
+	}
+	if replayed.sum != eng.sum {
+		t.Errorf("expected replayed sum of %d but instead received: %d", eng.sum, replayed.sum)
+	}
+}
+
+func TestReplayLog_Malformed(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Log  string
+	}{
+		{Name: "NoCommand", Log: "2020-01-02T03:04:05Z\n"},
+		{Name: "BadTimestamp", Log: "yesterday \"1\"\n"},
+		{Name: "Unquoted", Log: "2020-01-02T03:04:05Z 1\n"},
+		{Name: "Failed", Log: "2020-01-02T03:04:05Z \"nope\"\n"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(subT *testing.T) {
+			err := ReplayLog(context.Background(), new(testSumEngine), strings.NewReader(testCase.Log))
+			if err == nil {
+				subT.Error("expected an error but instead received none")
+			}
+		})
+	}
+}
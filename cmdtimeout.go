@@ -0,0 +1,136 @@
+package sand
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithCommandTimeout cancels every command executed by the Engine
+// which hasn't returned within d, the same as CancelCurrent, e.g. for
+// a shared console where a stuck command mustn't hold on to it. The
+// deadline is kept with the Clock of the UI, see WithClock, rather
+// than the context, so Remaining doesn't report it. A non-positive d
+// is the same as no timeout.
+//
+// While a command runs, and the prompt is written to a terminal, a
+// status line counts down the time left, e.g. "[3s left]", redrawn
+// every second. It's erased before any output is written, the same as
+// the spinner, see WithSpinner, and drawn again on the next second.
+// Once a command is cancelled for timing out, this is written before
+// the next prompt.
+//
+func WithCommandTimeout(d time.Duration) Option {
+	return func(ui *UI) {
+		if d <= 0 {
+			ui.cmdTimeout = nil
+			return
+		}
+		ui.cmdTimeout = &cmdTimeout{d: d}
+	}
+}
+
+// cmdTimeout draws the countdown of the running command, see
+// WithCommandTimeout.
+//
+type cmdTimeout struct {
+	d time.Duration
+
+	mu    sync.Mutex
+	stop  chan struct{} // set while counting down
+	shown bool
+}
+
+// startCmdTimeout starts the timeout of the line, if enabled, and
+// returns a func for stopping it, once the command has returned,
+// which reports the command if it timed out.
+//
+func (ui *UI) startCmdTimeout(line string) (stop func()) {
+	ct := ui.cmdTimeout
+	if ct == nil {
+		return func() {}
+	}
+
+	var timedOut int32
+	stopKill := ui.afterFunc(ct.d, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		ui.CancelCurrent()
+	})
+
+	w := ui.promptDest()
+	if isTerminal(w) {
+		ct.mu.Lock()
+		ct.stop = make(chan struct{})
+		ct.shown = false
+		go ui.countdownCmd(ct, w, ui.clock().Now().Add(ct.d), ct.stop)
+		ct.mu.Unlock()
+	}
+
+	return func() {
+		stopKill()
+		ct.mu.Lock()
+		if ct.stop != nil {
+			close(ct.stop)
+			ct.stop = nil
+		}
+		ct.mu.Unlock()
+		ui.hideCountdown()
+
+		if atomic.LoadInt32(&timedOut) == 1 {
+			msg := fmt.Sprintf("sand: cancelled %q, since it didn't return within %s", strings.TrimSpace(line), ct.d)
+			ui.writePrompt([]byte(ui.Theme().Error.Paint(msg) + "\n"))
+		}
+	}
+}
+
+// countdownCmd draws the time left until the deadline on w every
+// second until stop is closed, or the deadline has passed.
+//
+func (ui *UI) countdownCmd(ct *cmdTimeout, w io.Writer, deadline time.Time, stop chan struct{}) {
+	clk := ui.clock()
+	for {
+		t := clk.NewTimer(time.Second)
+		select {
+		case <-stop:
+			t.Stop()
+			return
+		case <-t.C():
+		}
+
+		left := deadline.Sub(clk.Now())
+		if left <= 0 {
+			return // the command is being cancelled
+		}
+
+		ct.mu.Lock()
+		select {
+		case <-stop:
+			ct.mu.Unlock()
+			return
+		default:
+		}
+		io.WriteString(w, "\r"+fmt.Sprintf("[%s left]", formatRemaining(left)))
+		ct.shown = true
+		ct.mu.Unlock()
+	}
+}
+
+// hideCountdown erases the countdown, if it's shown, before anything
+// else is written.
+//
+func (ui *UI) hideCountdown() {
+	ct := ui.cmdTimeout
+	if ct == nil {
+		return
+	}
+
+	ct.mu.Lock()
+	if ct.shown {
+		io.WriteString(ui.promptDest(), eraseLine)
+		ct.shown = false
+	}
+	ct.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTimeoutEngine signals every line it starts executing, then
+// waits for its context to be done.
+//
+type testTimeoutEngine struct {
+	started chan string
+}
+
+func (eng *testTimeoutEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.started <- line
+	<-ctx.Done()
+	return 1
+}
+
+func TestWithCommandTimeout(t *testing.T) {
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	isTerminal = func(interface{}) bool { return true }
+
+	clk := newTestClock()
+	eng := &testTimeoutEngine{started: make(chan string, 1)}
+	in := &testLineReader{lines: []string{"wait\n"}}
+	var out bytes.Buffer
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithTheme(MonochromeTheme), WithClock(clk), WithCommandTimeout(3*time.Second))
+	}()
+
+	<-eng.started
+	// Both the timeout and the countdown are waiting on the clock
+	for clk.armed() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(time.Second)
+	for clk.armed() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(2 * time.Second)
+
+	select {
+	case err := <-errCh:
+		if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the command to be cancelled once it timed out")
+	}
+
+	// The status of a cancelled command is ignored, so the session
+	// carries on after it
+	ex := ">\r[2s left]\r\x1b[Ksand: cancelled \"wait\", since it didn't return within 3s\n>\n"
+	if s := out.String(); !strings.HasSuffix(s, ex) {
+		t.Errorf("expected %q but instead received: %q", ex, s)
+	}
+}
@@ -0,0 +1,280 @@
+package sand
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Completer completes partial input, e.g. for an Engine which reads
+// keys itself in raw mode, see EnterRawMode and ReadKey, to offer
+// candidates when Tab is pressed.
+//
+type Completer interface {
+	// Complete returns the candidates for completing line, which
+	// is the input up to the cursor.
+	Complete(line string) []string
+}
+
+// Candidate is a candidate of a completion along with a description
+// of it, e.g. the summary of a command, which is listed beside it when
+// there are several candidates, the same as fish and zsh do.
+//
+type Candidate struct {
+	Text        string
+	Description string
+}
+
+// CandidateCompleter is a Completer whose candidates carry
+// descriptions, which Prompt lists along with them.
+//
+type CandidateCompleter interface {
+	Completer
+
+	// CompleteCandidates returns the same candidates as Complete,
+	// along with their descriptions, which may be empty.
+	CompleteCandidates(line string) []Candidate
+}
+
+// completeCandidates returns the candidates of c for the line, along
+// with their descriptions if c is a CandidateCompleter.
+//
+func completeCandidates(c Completer, line string) []Candidate {
+	if cc, ok := c.(CandidateCompleter); ok {
+		return cc.CompleteCandidates(line)
+	}
+	return textCandidates(c.Complete(line))
+}
+
+// textCandidates returns the texts as candidates without descriptions.
+func textCandidates(texts []string) []Candidate {
+	if texts == nil {
+		return nil
+	}
+	cands := make([]Candidate, len(texts))
+	for i, text := range texts {
+		cands[i] = Candidate{Text: text}
+	}
+	return cands
+}
+
+// candidateTexts returns the texts of the candidates.
+func candidateTexts(cands []Candidate) []string {
+	if cands == nil {
+		return nil
+	}
+	texts := make([]string, len(cands))
+	for i, c := range cands {
+		texts[i] = c.Text
+	}
+	return texts
+}
+
+// CachedCompleter returns a Completer which memoizes the candidates of
+// c for each line for up to ttl, so repeated Tab presses don't repeat
+// expensive work, e.g. listing a directory or querying a server. The
+// candidates of a line are forgotten as soon as the input no longer
+// starts with it, e.g. after it was edited, along with any which
+// expired. It is safe for concurrent use if c is. The returned
+// Completer is a CandidateCompleter, whose candidates carry the
+// descriptions of c, if it's one as well.
+//
+func CachedCompleter(c Completer, ttl time.Duration) CandidateCompleter {
+	return &cachedCompleter{
+		c:       c,
+		ttl:     ttl,
+		entries: make(map[string]cachedCompletion),
+	}
+}
+
+// cachedCompletion is the memoized candidates of a line.
+type cachedCompletion struct {
+	candidates []Candidate
+	expires    time.Time
+}
+
+type cachedCompleter struct {
+	c   Completer
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedCompletion
+}
+
+func (cc *cachedCompleter) Complete(line string) []string {
+	return candidateTexts(cc.CompleteCandidates(line))
+}
+
+func (cc *cachedCompleter) CompleteCandidates(line string) []Candidate {
+	now := time.Now()
+
+	cc.mu.Lock()
+	for prefix, e := range cc.entries {
+		if !strings.HasPrefix(line, prefix) || !now.Before(e.expires) {
+			delete(cc.entries, prefix)
+		}
+	}
+	e, ok := cc.entries[line]
+	cc.mu.Unlock()
+	if ok {
+		return append([]Candidate(nil), e.candidates...)
+	}
+
+	candidates := completeCandidates(cc.c, line)
+
+	cc.mu.Lock()
+	cc.entries[line] = cachedCompletion{
+		candidates: append([]Candidate(nil), candidates...),
+		expires:    now.Add(cc.ttl),
+	}
+	cc.mu.Unlock()
+	return candidates
+}
+
+// LineCompleter completes the line read by Run at a Tab, see
+// WithCompleter. It's given the line, up to the Tab, and the position
+// of the cursor, i.e. where the Tab was, and returns the candidates
+// for what's between replaceFrom and the cursor.
+//
+type LineCompleter func(line string, pos int) (candidates []string, replaceFrom int)
+
+// CandidateLineCompleter is a LineCompleter whose candidates carry
+// descriptions, see WithCandidateCompleter.
+//
+type CandidateLineCompleter func(line string, pos int) (candidates []Candidate, replaceFrom int)
+
+// WithCompleter makes Run complete lines containing a Tab with c,
+// instead of passing the Tab on to the Engine. A single candidate is
+// substituted into the line, while several are listed in columns. If
+// nothing but the line terminator follows the Tab, e.g. it was pressed
+// right before Enter, the completed line isn't executed, but written
+// after the next prompt and continued by the next line read.
+//
+func WithCompleter(c LineCompleter) Option {
+	return WithCandidateCompleter(func(line string, pos int) ([]Candidate, int) {
+		cands, from := c(line, pos)
+		return textCandidates(cands), from
+	})
+}
+
+// WithCandidateCompleter is the same as WithCompleter, except that
+// several candidates are listed one per row, each beside its
+// description, unless none of them has one, see FileCompleter and
+// CompleteLine.
+//
+func WithCandidateCompleter(c CandidateLineCompleter) Option {
+	return func(ui *UI) {
+		ui.completeFn = c
+	}
+}
+
+// CompleteLine returns a CandidateLineCompleter completing the line
+// up to the cursor with c, e.g. a Mux, whose candidates complete the
+// whole of it, the same as for Prompt.
+//
+func CompleteLine(c Completer) CandidateLineCompleter {
+	return func(line string, pos int) ([]Candidate, int) {
+		return completeCandidates(c, line[:pos]), 0
+	}
+}
+
+// completeTabs completes the chunk at each of its Tabs, after the
+// line held by the previous call, and reports whether it should be
+// executed. If the last Tab is only followed by the line terminator,
+// the completed line is held instead, see WithCompleter.
+//
+func (ui *UI) completeTabs(chunk string) (string, bool) {
+	chunk, ui.completion = ui.completion+chunk, ""
+	for {
+		i := strings.IndexByte(chunk, '\t')
+		if i < 0 {
+			return chunk, true
+		}
+
+		line, rest := chunk[:i], chunk[i+1:]
+		cands, from := ui.completeFn(line, i)
+		if from < 0 || from > i {
+			from = i
+		}
+		switch {
+		case len(cands) == 1:
+			line = line[:from] + cands[0].Text
+		case len(cands) > 1:
+			ui.writeCandidates(cands)
+		}
+
+		if strings.TrimRight(rest, "\r\n") == "" {
+			ui.completion = line
+			return "", false
+		}
+		chunk = line + rest
+	}
+}
+
+// writeCandidates lists the candidates of a completion, see
+// renderCandidates, at the width of the output, or 80 if it isn't
+// known.
+//
+func (ui *UI) writeCandidates(cands []Candidate) {
+	width := termWidth(ui.output())
+	if width <= 0 {
+		width = 80
+	}
+	ui.writePrompt(renderCandidates(cands, width))
+}
+
+// renderCandidates renders the candidates of a completion one per
+// row, each beside its description, unless none of them has one, in
+// which case they're rendered in as many columns as fit the width.
+//
+func renderCandidates(cands []Candidate, width int) []byte {
+	var rows [][]string
+	if described(cands) {
+		for _, c := range cands {
+			rows = append(rows, []string{c.Text, c.Description})
+		}
+	} else {
+		rows = candidateColumns(candidateTexts(cands), width)
+	}
+
+	var buf bytes.Buffer
+	writeTable(&buf, rows)
+	return buf.Bytes()
+}
+
+// described reports whether any of the candidates has a description.
+func described(cands []Candidate) bool {
+	for _, c := range cands {
+		if c.Description != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// candidateColumns splits the texts into rows of as many columns as
+// fit the width.
+//
+func candidateColumns(texts []string, width int) [][]string {
+	var max int
+	for _, t := range texts {
+		if w := displayWidth(t); w > max {
+			max = w
+		}
+	}
+	cols := (width + 2) / (max + 2)
+	if cols < 1 {
+		cols = 1
+	}
+
+	var rows [][]string
+	for i := 0; i < len(texts); i += cols {
+		end := i + cols
+		if end > len(texts) {
+			end = len(texts)
+		}
+		rows = append(rows, texts[i:end])
+	}
+	return rows
+}
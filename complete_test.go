@@ -0,0 +1,159 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCountCompleter completes words and counts its calls per line.
+type testCountCompleter struct {
+	calls map[string]int
+}
+
+func (c *testCountCompleter) Complete(line string) []string {
+	c.calls[line]++
+	return []string{line + "a", line + "b"}
+}
+
+func TestCachedCompleter(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		TTL   time.Duration
+		Sleep time.Duration
+		Lines []string
+		Calls map[string]int
+	}{
+		{
+			Name:  "WithinTTL",
+			TTL:   time.Minute,
+			Lines: []string{"ls f", "ls f", "ls f"},
+			Calls: map[string]int{"ls f": 1},
+		},
+		{
+			Name:  "Expired",
+			TTL:   time.Millisecond,
+			Sleep: 5 * time.Millisecond,
+			Lines: []string{"ls f", "ls f", "ls f"},
+			Calls: map[string]int{"ls f": 3},
+		},
+		{
+			Name:  "Extended",
+			TTL:   time.Minute,
+			Lines: []string{"ls f", "ls fo", "ls f"},
+			Calls: map[string]int{"ls f": 1, "ls fo": 1},
+		},
+		{
+			Name:  "Edited",
+			TTL:   time.Minute,
+			Lines: []string{"ls fo", "ls b", "ls fo"},
+			Calls: map[string]int{"ls fo": 2, "ls b": 1},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			c := &testCountCompleter{calls: make(map[string]int)}
+			cc := CachedCompleter(c, tc.TTL)
+			for _, line := range tc.Lines {
+				ex := []string{line + "a", line + "b"}
+				if got := cc.Complete(line); !reflect.DeepEqual(got, ex) {
+					subT.Errorf("expected %q but instead received: %q", ex, got)
+				}
+				time.Sleep(tc.Sleep)
+			}
+			if !reflect.DeepEqual(c.calls, tc.Calls) {
+				subT.Errorf("expected %v but instead received: %v", tc.Calls, c.calls)
+			}
+		})
+	}
+}
+
+// testLineCompleter completes the last word of the line from words.
+func testLineCompleter(words ...string) LineCompleter {
+	return func(line string, pos int) ([]string, int) {
+		from := strings.LastIndexByte(line[:pos], ' ') + 1
+		var cands []string
+		for _, w := range words {
+			if strings.HasPrefix(w, line[from:pos]) {
+				cands = append(cands, w)
+			}
+		}
+		return cands, from
+	}
+}
+
+func TestRunWithCompleter(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Lines []string
+		Opts  []Option
+		Ex    string
+	}{
+		{
+			Name:  "Single",
+			Lines: []string{"gi\t\n", " status\n"},
+			Opts:  []Option{WithCompleter(testLineCompleter("git", "go", "grep"))},
+			Ex:    ">>git>git status\n>\n",
+		},
+		{
+			Name:  "SingleInline",
+			Lines: []string{"echo gi\tx\n"},
+			Opts:  []Option{WithCompleter(testLineCompleter("git", "go", "grep"))},
+			Ex:    ">>echo gitx\n>\n",
+		},
+		{
+			Name:  "Several",
+			Lines: []string{"g\t\n", "o\n"},
+			Opts:  []Option{WithCompleter(testLineCompleter("git", "go", "grep"))},
+			Ex:    ">git   go\ngrep\n>g>go\n>\n",
+		},
+		{
+			Name:  "None",
+			Lines: []string{"x\t\n", "y\n"},
+			Opts:  []Option{WithCompleter(testLineCompleter("git", "go", "grep"))},
+			Ex:    ">>x>xy\n>\n",
+		},
+		{
+			Name:  "Described",
+			Lines: []string{"s\t\n", "tatus\n"},
+			Opts: []Option{WithCandidateCompleter(func(line string, pos int) ([]Candidate, int) {
+				return []Candidate{{"start", "starts it"}, {"status", ""}, {"stop", "stops it"}}, 0
+			})},
+			Ex: ">start   starts it\nstatus\nstop    stops it\n>s>status\n>\n",
+		},
+		{
+			Name:  "CompleteLine",
+			Lines: []string{"  sto\t\n", "\n"},
+			Opts:  []Option{WithCandidateCompleter(CompleteLine(testWordCompleter{"  stop", "  start"}))},
+			Ex:    ">>  stop>  stop\n>\n",
+		},
+		{
+			Name:  "NoCompleter",
+			Lines: []string{"gi\t\n"},
+			Ex:    ">>gi\t\n>\n",
+		},
+	}
+
+	defer func(f func(interface{}) int) { termWidth = f }(termWidth)
+	termWidth = func(interface{}) int { return 10 }
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			in := &testLineReader{lines: tc.Lines}
+			opts := append([]Option{WithPrefix(">"), WithIO(in, &out)}, tc.Opts...)
+			err := Run(nil, new(testEchoEngine), opts...)
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Fatal(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// redacted replaces sensitive values reported by Config.
+const redacted = "<redacted>"
+
+// WithConfigBuiltin installs a "config" builtin, which lists the
+// effective configuration of the session, see Config. This is meant
+// for users to understand how the session behaves and for including
+// in bug reports.
+//
+func WithConfigBuiltin() Option {
+	return func(ui *UI) {
+		ui.addBuiltin("config", "show the session configuration", configBuiltin)
+	}
+}
+
+// WithRedactedConfig redacts file paths, e.g. of a transcript, from
+// what Config reports, so it can be shared without revealing them.
+//
+func WithRedactedConfig() Option {
+	return func(ui *UI) {
+		ui.redactCfg = true
+	}
+}
+
+// Config returns the effective configuration of the UI, i.e. the
+// values of the options applied to it, or their defaults, by name.
+//
+func (ui *UI) Config() map[string]string {
+	fanout := ui.fanout
+	if fanout <= 0 {
+		fanout = DefaultFanoutLimit
+	}
+	format := "text"
+	if ui.format == FormatJSON {
+		format = "json"
+	}
+	var transcript io.Writer
+	if ui.transcript != nil {
+		transcript = ui.transcript.w
+	}
+
+	histFile := ui.histFile
+	if histFile == "" {
+		histFile = "none"
+	} else if ui.redactCfg {
+		histFile = redacted
+	}
+
+	return map[string]string{
+		"prefix":            strconv.Quote(string(ui.linePrefix())),
+		"input":             ui.describe(ui.input()),
+		"output":            ui.describe(ui.output()),
+		"prompt-writer":     ui.describe(ui.promptDest()),
+		"error-writer":      ui.describe(ui.errOutput()),
+		"transcript":        ui.describe(transcript),
+		"tees":              strconv.Itoa(len(ui.tees)),
+		"ignore-eof":        strconv.Itoa(ui.ignoreEOF),
+		"max-bytes":         strconv.FormatInt(ui.maxBytes, 10),
+		"session-timeout":   ui.sessTimeout.String(),
+		"drain-timeout":     ui.drainTimeout.String(),
+		"output-format":     format,
+		"fanout-limit":      strconv.Itoa(fanout),
+		"isolated-engine":   strconv.FormatBool(!ui.shared),
+		"panic-recovery":    strconv.FormatBool(!ui.noRecover),
+		"auto-newline":      strconv.FormatBool(ui.autoNewline),
+		"echo-input":        strconv.FormatBool(ui.echoInput),
+		"strip-cr":          strconv.FormatBool(!ui.keepCR),
+		"countdown":         strconv.FormatBool(ui.countdown),
+		"spinner":           strconv.FormatBool(ui.spinner != nil),
+		"history-expansion": strconv.FormatBool(ui.expandHist),
+		"var-expansion":     strconv.FormatBool(ui.expansion != nil),
+		"jobs":              strconv.FormatBool(ui.jobs != nil),
+		"history-file":      histFile,
+		"history-file-size": strconv.Itoa(ui.histMax),
+	}
+}
+
+// describe describes where r or w reads from or writes to, which is
+// the path of a file, unless paths are redacted.
+//
+func (ui *UI) describe(v interface{}) string {
+	switch v {
+	case nil:
+		return "none"
+	case os.Stdin:
+		return "stdin"
+	case os.Stdout:
+		return "stdout"
+	case os.Stderr:
+		return "stderr"
+	}
+	if f, ok := v.(*os.File); ok {
+		if ui.redactCfg {
+			return redacted
+		}
+		return f.Name()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// configBuiltin lists the configuration, sorted by name.
+func configBuiltin(ctx context.Context, args []string, ui *UI) int {
+	cfg := ui.Config()
+	names := make([]string, 0, len(cfg))
+	for name := range cfg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([][]string, len(names))
+	for i, name := range names {
+		rows[i] = []string{ui.Theme().Header.Paint(name), cfg[name]}
+	}
+
+	var buf bytes.Buffer
+	writeTable(&buf, rows)
+	if _, err := ui.write(buf.Bytes()); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package sand
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUI_Config(t *testing.T) {
+	f, err := ioutil.TempFile("", "transcript")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	testCases := []struct {
+		Name string
+		Opts []Option
+		Ex   map[string]string
+	}{
+		{
+			Name: "Defaults",
+			Ex:   map[string]string{"prefix": `""`, "session-timeout": "0s", "panic-recovery": "true", "transcript": "none", "error-writer": "stderr"},
+		},
+		{
+			Name: "Options",
+			Opts: []Option{WithPrefix("> "), WithSessionTimeout(time.Minute), WithoutPanicRecovery(), WithTranscript(f), WithStderr(new(strings.Builder))},
+			Ex:   map[string]string{"prefix": `"> "`, "session-timeout": "1m0s", "panic-recovery": "false", "transcript": f.Name(), "error-writer": "*strings.Builder"},
+		},
+		{
+			Name: "Redacted",
+			Opts: []Option{WithTranscript(f), WithPromptToStderr(), WithRedactedConfig()},
+			Ex:   map[string]string{"transcript": redacted, "prompt-writer": "stderr"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := new(UI)
+			for _, opt := range tc.Opts {
+				opt(ui)
+			}
+
+			cfg := ui.Config()
+			for name, ex := range tc.Ex {
+				if cfg[name] != ex {
+					subT.Errorf("expected %s to be %q but instead received: %q", name, ex, cfg[name])
+				}
+			}
+		})
+	}
+}
+
+func TestWithConfigBuiltin(t *testing.T) {
+	eng := new(testEchoEngine)
+	out := runBuiltinTest(t, eng, "config\n", WithConfigBuiltin(), WithIgnoreEOF(3))
+
+	for _, ex := range []string{"\nignore-eof         3\n", "\nprefix             \">\"\n"} {
+		if !strings.Contains(out, ex) {
+			t.Errorf("expected %q in output but instead received: %q", ex, out)
+		}
+	}
+	if eng.execs != 0 {
+		t.Errorf("expected builtin to not be dispatched to engine")
+	}
+}
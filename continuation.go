@@ -0,0 +1,52 @@
+package sand
+
+// WithContinuation specifies a predicate reporting whether the input
+// accumulated so far is incomplete, e.g. has an unclosed bracket, in
+// which case the UI reads another line, the same as if the Engine had
+// returned StatusNeedMore, without calling Exec. The input passed to
+// incomplete is every line read since the last command, separated by
+// newlines. See BracketContinuation for a common predicate.
+//
+func WithContinuation(incomplete func(input string) bool) Option {
+	return func(ui *UI) {
+		ui.incomplete = incomplete
+	}
+}
+
+// BracketContinuation returns a predicate for WithContinuation which
+// reports the input as incomplete while an open bracket isn't closed
+// yet, e.g. '(' and ')', or a quoted string, in either single, double
+// or back quotes, isn't terminated yet. Brackets within quotes aren't
+// counted and a backslash escapes the following character, except
+// within single quotes. Input closing more brackets than it opens is
+// never incomplete, since no more input can balance it.
+//
+func BracketContinuation(open, close rune) func(string) bool {
+	return func(input string) bool {
+		var depth int
+		var quote rune
+		var escaped bool
+		for _, r := range input {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\' && quote != '\'':
+				escaped = true
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '\'' || r == '"' || r == '`':
+				quote = r
+			case r == open:
+				depth++
+			case r == close:
+				depth--
+				if depth < 0 {
+					return false
+				}
+			}
+		}
+		return depth > 0 || quote != 0
+	}
+}
@@ -0,0 +1,58 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBracketContinuation(t *testing.T) {
+	testCases := []struct {
+		Name       string
+		Input      string
+		Incomplete bool
+	}{
+		{Name: "Balanced", Input: "f(a, b)\n"},
+		{Name: "Open", Input: "f(a,\n", Incomplete: true},
+		{Name: "Nested", Input: "f(g(a),\nh(b)\n", Incomplete: true},
+		{Name: "NestedClosed", Input: "f(g(a),\nh(b))\n"},
+		{Name: "QuotedOpen", Input: "f(\"(\")\n"},
+		{Name: "QuotedClose", Input: "f(')'\n", Incomplete: true},
+		{Name: "BackQuoted", Input: "f(`)`)\n"},
+		{Name: "Escaped", Input: "f(\\()\n"},
+		{Name: "EscapedQuote", Input: "f(\"\\\")\")\n"},
+		{Name: "SingleQuoteNoEscape", Input: "f('\\')\n"},
+		{Name: "UnterminatedQuote", Input: "f(\"a)\n", Incomplete: true},
+		{Name: "UnterminatedNoBracket", Input: "'a\n", Incomplete: true},
+		{Name: "TooManyClosed", Input: "f(a))(\n"},
+	}
+
+	incomplete := BracketContinuation('(', ')')
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			if got := incomplete(tc.Input); got != tc.Incomplete {
+				subT.Errorf("expected %v but instead received: %v", tc.Incomplete, got)
+			}
+		})
+	}
+}
+
+func TestWithContinuation(t *testing.T) {
+	in := &testLineReader{lines: []string{"{a\n", "{b}\n", "}\n", "c\n"}}
+	var out bytes.Buffer
+
+	eng := new(testEchoEngine)
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithContinuation(BracketContinuation('{', '}')))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	ex := ">>>>{a\n{b}\n}\n>>c\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if eng.execs != 2 {
+		t.Errorf("expected %d execs but instead received: %d", 2, eng.execs)
+	}
+}
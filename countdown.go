@@ -0,0 +1,90 @@
+package sand
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// WithSessionTimeout ends the session once the given duration has
+// passed since Run was called, as if its context had a deadline.
+//
+func WithSessionTimeout(d time.Duration) Option {
+	return func(ui *UI) {
+		ui.sessTimeout = d
+	}
+}
+
+// ErrNoInput is returned by Run when no input arrived in time, see
+// WithFirstInputTimeout.
+//
+var ErrNoInput = errors.New("sand: no input received before the first input timeout")
+
+// WithFirstInputTimeout ends the session with ErrNoInput unless input
+// arrives within the given duration since Run was called, e.g. for a
+// kiosk closing an abandoned session quickly. Unlike WithSessionTimeout,
+// it no longer applies once any input has arrived, so a session which
+// is in use isn't cut short.
+//
+func WithFirstInputTimeout(d time.Duration) Option {
+	return func(ui *UI) {
+		ui.firstTimeout = d
+	}
+}
+
+// WithCountdown shows the time left in the session before the
+// prompt, e.g. "[2m left] >", if the session has a deadline, see
+// WithSessionTimeout.
+//
+func WithCountdown() Option {
+	return func(ui *UI) {
+		ui.countdown = true
+	}
+}
+
+// Countdown formats the time left until the deadline of ctx, e.g.
+// "[2m left] ", for use in prompts. It returns an empty string if
+// ctx doesn't have a deadline.
+//
+func Countdown(ctx context.Context) string {
+	d, ok := Remaining(ctx)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("[%s left] ", formatRemaining(d))
+}
+
+// Remaining returns the time left until the deadline of ctx, which
+// is negative once it's passed, or false if ctx doesn't have one.
+// Engines can use it to decide whether expensive work is worth
+// starting. The context given to Exec is derived from the session's
+// and the UI doesn't add a deadline per command, so it's the earlier
+// of the session timeout, see WithSessionTimeout, and the deadline of
+// the context given to Run. An Engine adding its own deadline for a
+// command, e.g. with context.WithTimeout, only ever shortens it.
+//
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// formatRemaining formats d in its largest unit, rounding up, so
+// the countdown doesn't show 0 until the time is actually up.
+//
+func formatRemaining(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "0s"
+	case d <= time.Minute:
+		return fmt.Sprintf("%ds", (d+time.Second-1)/time.Second)
+	case d <= time.Hour:
+		return fmt.Sprintf("%dm", (d+time.Minute-1)/time.Minute)
+	default:
+		d = (d + time.Minute - 1).Truncate(time.Minute)
+		return fmt.Sprintf("%dh%dm", d/time.Hour, d%time.Hour/time.Minute)
+	}
+}
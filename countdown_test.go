@@ -0,0 +1,132 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestFormatRemaining(t *testing.T) {
+	testCases := []struct {
+		D  time.Duration
+		Ex string
+	}{
+		{D: -time.Second, Ex: "0s"},
+		{D: 1500 * time.Millisecond, Ex: "2s"},
+		{D: time.Minute, Ex: "60s"},
+		{D: 90 * time.Second, Ex: "2m"},
+		{D: time.Hour + time.Second, Ex: "1h1m"},
+		{D: 3 * time.Hour, Ex: "3h0m"},
+	}
+
+	for _, tc := range testCases {
+		if s := formatRemaining(tc.D); s != tc.Ex {
+			t.Errorf("expected %q for %s but instead received: %q", tc.Ex, tc.D, s)
+		}
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	if s := Countdown(context.Background()); s != "" {
+		t.Errorf("expected no countdown without a deadline but instead received: %q", s)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+	if s := Countdown(ctx); s != "[2m left] " {
+		t.Errorf("expected %q but instead received: %q", "[2m left] ", s)
+	}
+}
+
+// testSleepEngine sleeps for every line.
+type testSleepEngine struct {
+	d time.Duration
+}
+
+func (eng *testSleepEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	time.Sleep(eng.d)
+	return 0
+}
+
+func TestRunWithCountdown(t *testing.T) {
+	in := &testLineReader{lines: []string{"a\n", "b\n"}}
+	var prompt bytes.Buffer
+
+	err := Run(nil, &testSleepEngine{d: 1100 * time.Millisecond},
+		WithPrefix(">"),
+		WithIO(in, &bytes.Buffer{}),
+		WithPromptWriter(&prompt),
+		WithSessionTimeout(3*time.Second),
+		WithCountdown(),
+	)
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := "[3s left] >[2s left] >[1s left] >\n"
+	if prompt.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, prompt.String())
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	if _, ok := Remaining(context.Background()); ok {
+		t.Error("expected no deadline for background context")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	d, ok := Remaining(ctx)
+	if !ok || d <= 0 || d > time.Minute {
+		t.Errorf("expected up to a minute remaining but instead received: %s, %v", d, ok)
+	}
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if d, ok := Remaining(ctx); !ok || d >= 0 {
+		t.Errorf("expected a negative duration once passed but instead received: %s, %v", d, ok)
+	}
+}
+
+func TestRunWithFirstInputTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	start := time.Now()
+	err := Run(nil, new(testEchoEngine), WithIO(pr, ioutil.Discard), WithFirstInputTimeout(20*time.Millisecond))
+	if err != ErrNoInput {
+		t.Errorf("expected ErrNoInput but instead received: %v", err)
+	}
+	if _, ok := IsRecoverable(err); !ok {
+		t.Errorf("expected %v to be recoverable", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected session to end after the timeout but instead took: %s", d)
+	}
+}
+
+func TestRunWithFirstInputTimeout_Active(t *testing.T) {
+	pr, pw := io.Pipe()
+	eng := new(testEchoEngine)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(nil, eng, WithIO(pr, ioutil.Discard), WithFirstInputTimeout(20*time.Millisecond))
+	}()
+
+	// Input after the timeout is still read once the session is in use
+	pw.Write([]byte("a\n"))
+	time.Sleep(50 * time.Millisecond)
+	pw.Write([]byte("b\n"))
+	pw.Close()
+
+	err := <-errCh
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if eng.execs != 2 {
+		t.Errorf("expected 2 commands to be executed but instead received: %d", eng.execs)
+	}
+}
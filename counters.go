@@ -0,0 +1,69 @@
+package sand
+
+import (
+	"github.com/pkg/errors"
+	"sync/atomic"
+)
+
+// ErrMaxBytes is returned by Run when the session has read and
+// written more bytes than allowed, see WithMaxBytes.
+var ErrMaxBytes = errors.New("sand: session exceeded its byte quota")
+
+// WithMaxBytes specifies the maximum number of bytes a session may
+// read and write, combined. Once exceeded, every Read and Write
+// fails with ErrMaxBytes and Run ends the session with it.
+//
+func WithMaxBytes(n int64) Option {
+	return func(ui *UI) {
+		ui.maxBytes = n
+	}
+}
+
+// ErrMaxCommands is returned by Run when the session has executed
+// as many commands as allowed, see WithMaxCommandsPerSession.
+var ErrMaxCommands = errors.New("sand: session exceeded its command quota")
+
+// WithMaxCommandsPerSession specifies the maximum number of commands
+// a session may execute, e.g. to limit abuse of a shell served over
+// the network. Once the last one allowed is done, Run writes the
+// error and ends the session with ErrMaxCommands. Builtins aren't
+// counted.
+//
+func WithMaxCommandsPerSession(n int64) Option {
+	return func(ui *UI) {
+		ui.maxCmds = n
+	}
+}
+
+// BytesRead returns the total number of bytes read from the
+// underlying input Reader. It is safe to call concurrently.
+//
+func (ui *UI) BytesRead() int64 {
+	return atomic.LoadInt64(&ui.nRead)
+}
+
+// BytesWritten returns the total number of bytes written to the
+// underlying output Writer. It is safe to call concurrently.
+//
+func (ui *UI) BytesWritten() int64 {
+	return atomic.LoadInt64(&ui.nWritten)
+}
+
+// overQuota reports whether the session has exceeded its byte quota.
+func (ui *UI) overQuota() bool {
+	return ui.maxBytes > 0 && ui.BytesRead()+ui.BytesWritten() > ui.maxBytes
+}
+
+// CommandsExecuted returns the number of commands executed by the
+// Engine so far. It is safe to call concurrently.
+//
+func (ui *UI) CommandsExecuted() int64 {
+	return atomic.LoadInt64(&ui.nCmds)
+}
+
+// overCommandQuota reports whether the session has executed as many
+// commands as allowed.
+//
+func (ui *UI) overCommandQuota() bool {
+	return ui.maxCmds > 0 && ui.CommandsExecuted() >= ui.maxCmds
+}
@@ -0,0 +1,61 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestUI_ByteCounters(t *testing.T) {
+	in := &testLineReader{lines: []string{"hello\n", "sand\n"}}
+	var out bytes.Buffer
+
+	ui := new(UI)
+	err := ui.Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(in, &out))
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	if n := ui.BytesRead(); n != int64(len("hello\nsand\n")) {
+		t.Errorf("expected %d bytes read but instead received: %d", len("hello\nsand\n"), n)
+	}
+	if n := ui.BytesWritten(); n != int64(out.Len()) {
+		t.Errorf("expected %d bytes written but instead received: %d", out.Len(), n)
+	}
+}
+
+func TestRunWithMaxBytes(t *testing.T) {
+	in := &testLineReader{lines: []string{"hello\n", "sand\n", "more\n"}}
+	var out bytes.Buffer
+
+	eng := new(testEchoEngine)
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithMaxBytes(10))
+	if err != ErrMaxBytes {
+		t.Errorf("expected ErrMaxBytes but instead received: %v", err)
+	}
+	if eng.execs != 1 {
+		t.Errorf("expected session to end after first command but instead executed: %d", eng.execs)
+	}
+}
+
+func TestRunWithMaxCommandsPerSession(t *testing.T) {
+	in := &testLineReader{lines: []string{"a\n", "help\n", "b\n", "c\n"}}
+	var out bytes.Buffer
+
+	eng := new(testEchoEngine)
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithMaxCommandsPerSession(2), WithVersion("v1"))
+	if err != ErrMaxCommands {
+		t.Errorf("expected ErrMaxCommands but instead received: %v", err)
+	}
+	if _, ok := IsRecoverable(err); !ok {
+		t.Errorf("expected %v to be recoverable", err)
+	}
+	if eng.execs != 2 {
+		t.Errorf("expected session to end after second command but instead executed: %d", eng.execs)
+	}
+	if ex := ErrMaxCommands.Error() + "\n"; !strings.HasSuffix(out.String(), ex) {
+		t.Errorf("expected output to end with %q but instead received: %q", ex, out.String())
+	}
+}
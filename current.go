@@ -0,0 +1,123 @@
+package sand
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Current returns the line of the command being executed by the
+// Engine, if any, e.g. for a monitor or a signal handler to show what
+// the UI is busy with. Builtins and background jobs, see WithJobs,
+// aren't reported.
+//
+func (ui *UI) Current() (line string, running bool) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	return ui.current, ui.cancelCur != nil
+}
+
+// CancelCurrent cancels the context of the command being executed
+// by the Engine, if any, without ending the session. The Engine is
+// expected to return once its context is done, whereupon the UI
+// ignores the status returned and prompts for the next line, the
+// same as after a status of 0. It is safe to call from any goroutine.
+//
+func (ui *UI) CancelCurrent() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if ui.cancelCur == nil {
+		return
+	}
+	ui.curCanceled = true
+	ui.cancelCur()
+}
+
+// WithInterruptCancelsCommand makes an interrupt signal, e.g. Ctrl-C,
+// cancel the command being executed by the Engine, see CancelCurrent,
+// instead of ending the session. An interrupt while no command is
+// executing still ends the session.
+//
+func WithInterruptCancelsCommand() Option {
+	return func(ui *UI) {
+		ui.intCancels = true
+	}
+}
+
+// WithExecGracePeriod specifies how long the UI waits for Exec to
+// return once the context of the command is done, e.g. cancelled by
+// CancelCurrent or the end of the session. An Engine which ignores its
+// context, or is deadlocked, is then abandoned: a warning is written,
+// the command fails and the UI carries on as if it had returned.
+//
+// Abandoning can't stop the Exec call, whose goroutine is leaked until
+// it returns, and which may still be running while the Engine executes
+// the next command, see AbandonedCommands. By default, the UI waits
+// for Exec to return, however long it takes.
+//
+func WithExecGracePeriod(d time.Duration) Option {
+	return func(ui *UI) {
+		ui.execGrace = d
+	}
+}
+
+// AbandonedCommands returns the number of commands abandoned since
+// their Engine didn't return in time, see WithExecGracePeriod, each
+// of which leaked a goroutine. It is safe to call concurrently.
+//
+func (ui *UI) AbandonedCommands() int64 {
+	return atomic.LoadInt64(&ui.nAbandoned)
+}
+
+// awaitExec waits for the status of a command sent to the Engine,
+// abandoning it once its context has been done for longer than the
+// grace period, see WithExecGracePeriod.
+//
+func (ui *UI) awaitExec(ctx context.Context, line string, respCh chan int) int {
+	if ui.execGrace <= 0 {
+		return <-respCh
+	}
+
+	select {
+	case status := <-respCh:
+		return status
+	case <-ctx.Done():
+	}
+
+	t := ui.clock().NewTimer(ui.execGrace)
+	defer t.Stop()
+	select {
+	case status := <-respCh:
+		return status
+	case <-t.C():
+	}
+
+	atomic.AddInt64(&ui.nAbandoned, 1)
+	ui.mu.Lock()
+	ui.abandoned = true
+	ui.mu.Unlock()
+	msg := fmt.Sprintf("sand: abandoned %q, since its Engine didn't return within %s of being cancelled", strings.TrimSpace(line), ui.execGrace)
+	ui.writePrompt([]byte(ui.Theme().Error.Paint(msg) + "\n"))
+	return 1
+}
+
+// reattachAbandoned attaches the current Engine anew, if a command it
+// executed was abandoned, since the abandoned Exec call still holds on
+// to its attachment, which is detached once the call returns, if ever.
+//
+func (ui *UI) reattachAbandoned(s *engineStack) {
+	ui.mu.Lock()
+	abandoned := ui.abandoned
+	ui.abandoned = false
+	ui.mu.Unlock()
+	if !abandoned {
+		return
+	}
+
+	f := s.top()
+	*s = (*s)[:len(*s)-1]
+	ui.pushEngine(s, f.eng)
+	close(f.reqCh)
+}
@@ -0,0 +1,109 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUI_CancelCurrent(t *testing.T) {
+	eng := &testWaitEngine{started: make(chan string, 1)}
+	var out bytes.Buffer
+	ui := new(UI)
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ui.Run(context.Background(), eng, WithIO(pr, &out))
+	}()
+
+	if line, running := ui.Current(); running {
+		t.Errorf("expected no command to be running but instead received: %q", line)
+	}
+
+	// Every line blocks until cancelled, so the second one is
+	// only executed if the session continues after the first.
+	for _, l := range []string{"a", "b"} {
+		pw.Write([]byte(l + "\n"))
+		<-eng.started
+
+		line, running := ui.Current()
+		if !running || line != l {
+			t.Errorf("expected %q to be running but instead received: %q", l, line)
+		}
+		ui.CancelCurrent()
+	}
+
+	pw.Close()
+	if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if line, running := ui.Current(); running {
+		t.Errorf("expected no command to be running but instead received: %q", line)
+	}
+	if status, _ := ui.Get(StatusVar); status != "0" {
+		t.Errorf("expected status %q but instead received: %q", "0", status)
+	}
+}
+
+// testStuckEngine blocks on "stuck" until released, ignoring its
+// context, and echos anything else.
+type testStuckEngine struct {
+	testEchoEngine
+	started chan struct{}
+	release chan struct{}
+}
+
+func (eng *testStuckEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	if strings.TrimSpace(line) == "stuck" {
+		eng.started <- struct{}{}
+		<-eng.release
+		return 0
+	}
+	return eng.testEchoEngine.Exec(ctx, line, ui)
+}
+
+func TestWithExecGracePeriod(t *testing.T) {
+	chs, inject := injectSignals()
+
+	eng := &testStuckEngine{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(eng.release)
+	var out bytes.Buffer
+	ui := new(UI)
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ui.Run(nil, eng, WithPrefix(">"), WithIO(pr, &out), WithTheme(MonochromeTheme),
+			WithInterruptCancelsCommand(), WithExecGracePeriod(20*time.Millisecond), inject)
+	}()
+	sigCh := <-chs
+
+	pw.Write([]byte("stuck\n"))
+	<-eng.started
+	sigCh <- os.Interrupt
+
+	// The session continues with the Engine, while its stuck Exec leaks
+	pw.Write([]byte("a\n"))
+	pw.Close()
+	select {
+	case err := <-errCh:
+		if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected session to end")
+	}
+
+	ex := ">sand: abandoned \"stuck\", since its Engine didn't return within 20ms of being cancelled\n>>a\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if n := ui.AbandonedCommands(); n != 1 {
+		t.Errorf("expected 1 abandoned command but instead received: %d", n)
+	}
+}
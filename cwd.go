@@ -0,0 +1,102 @@
+package sand
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+)
+
+// WithWorkingDir installs the "cd" and "pwd" builtins, for changing
+// and printing the working directory of the session, see Chdir. The
+// session starts out in dir, or the working directory of the process
+// if dir is empty. "cd" without an argument changes to the home
+// directory.
+//
+func WithWorkingDir(dir string) Option {
+	return func(ui *UI) {
+		if dir != "" {
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
+			}
+		}
+		ui.mu.Lock()
+		ui.cwd = dir
+		ui.mu.Unlock()
+		ui.addBuiltin("cd", "change the working directory, cd [DIR]", cdBuiltin)
+		ui.addBuiltin("pwd", "print the working directory", pwdBuiltin)
+	}
+}
+
+// Cwd returns the working directory of the session. Unlike
+// os.Getwd, it's kept per UI, so sessions run concurrently, e.g.
+// one per connection, each have their own. It defaults to the
+// working directory of the process. It is safe to call concurrently.
+//
+func (ui *UI) Cwd() string {
+	ui.mu.Lock()
+	dir := ui.cwd
+	ui.mu.Unlock()
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	return dir
+}
+
+// Chdir changes the working directory of the session to path, which
+// is relative to the current one unless it's absolute. The process
+// working directory is left as is. It is safe to call concurrently.
+//
+func (ui *UI) Chdir(path string) error {
+	dir := ui.ResolvePath(path)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrap(err, "sand: cd")
+	}
+	if !fi.IsDir() {
+		return errors.Errorf("sand: cd: %s: not a directory", path)
+	}
+
+	ui.mu.Lock()
+	ui.cwd = dir
+	ui.mu.Unlock()
+	return nil
+}
+
+// ResolvePath returns path joined to the working directory of the
+// session, unless it's absolute, e.g. for engines opening files
+// named by the user. The result is cleaned, see filepath.Clean.
+//
+func (ui *UI) ResolvePath(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ui.Cwd(), path)
+	}
+	return filepath.Clean(path)
+}
+
+func cdBuiltin(ctx context.Context, args []string, ui *UI) int {
+	dir := ""
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			ui.writePrompt([]byte(ui.Theme().Error.Paint("sand: cd: "+err.Error()) + "\n"))
+			return 0
+		}
+		dir = home
+	}
+
+	if err := ui.Chdir(dir); err != nil {
+		ui.writePrompt([]byte(ui.Theme().Error.Paint(err.Error()) + "\n"))
+	}
+	return 0
+}
+
+func pwdBuiltin(ctx context.Context, args []string, ui *UI) int {
+	if _, err := ui.write([]byte(ui.Cwd() + "\n")); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUI_Chdir(t *testing.T) {
+	root, err := ioutil.TempDir("", "sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err = os.MkdirAll(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(root, "f"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	ui := new(UI)
+	if ui.Cwd() != wd {
+		t.Errorf("expected process working directory %q but instead received: %q", wd, ui.Cwd())
+	}
+
+	testCases := []struct {
+		Name  string
+		Path  string
+		Ex    string
+		IsErr bool
+	}{
+		{Name: "Absolute", Path: root, Ex: root},
+		{Name: "Relative", Path: "a/b", Ex: filepath.Join(root, "a", "b")},
+		{Name: "Parent", Path: "..", Ex: filepath.Join(root, "a")},
+		{Name: "Missing", Path: "nope", Ex: filepath.Join(root, "a"), IsErr: true},
+		{Name: "NotDir", Path: "../f", Ex: filepath.Join(root, "a"), IsErr: true},
+	}
+
+	for _, tc := range testCases {
+		err := ui.Chdir(tc.Path)
+		if (err != nil) != tc.IsErr {
+			t.Errorf("%s: unexpected error: %v", tc.Name, err)
+		}
+		if ui.Cwd() != tc.Ex {
+			t.Errorf("%s: expected %q but instead received: %q", tc.Name, tc.Ex, ui.Cwd())
+		}
+	}
+
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("expected process working directory to be left as is but instead received: %q", now)
+	}
+	if p := ui.ResolvePath("c.txt"); p != filepath.Join(root, "a", "c.txt") {
+		t.Errorf("expected path relative to the session but instead received: %q", p)
+	}
+}
+
+func TestWithWorkingDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err = os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &testLineReader{lines: []string{"pwd\n", "cd sub\n", "pwd\n", "cd " + root + "\n", "pwd\n"}}
+	var out bytes.Buffer
+
+	eng := new(testEchoEngine)
+	err = Run(nil, eng, WithIO(in, &out), WithWorkingDir(root))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := root + "\n" + filepath.Join(root, "sub") + "\n" + root + "\n\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if eng.execs != 0 {
+		t.Errorf("expected builtins to not be dispatched to engine")
+	}
+}
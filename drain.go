@@ -0,0 +1,55 @@
+package sand
+
+import (
+	"context"
+	"time"
+)
+
+// WithDrainTimeout specifies how long the UI waits, on shutdown,
+// for a command that is still executing to finish. During that
+// time, the Engine can still read and write through the UI, so
+// the output of the last command isn't cut off. Once the command
+// finishes, or the timeout passes, the UI shuts down as usual.
+// By default, the UI shuts down immediately.
+//
+func WithDrainTimeout(d time.Duration) Option {
+	return func(ui *UI) {
+		ui.drainTimeout = d
+	}
+}
+
+// detachedContext carries the values of its parent
+// but is never cancelled along with it.
+//
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+// drain waits for the session to be done and then cancels
+// the IO context, once any in-flight command has finished
+// or the drain timeout has passed.
+//
+func (ui *UI) drain(sess context.Context, cancelIO context.CancelFunc) {
+	defer cancelIO()
+	<-sess.Done()
+
+	ui.mu.Lock()
+	running := ui.running
+	ui.mu.Unlock()
+	if running == nil {
+		return
+	}
+
+	t := ui.clock().NewTimer(ui.drainTimeout)
+	defer t.Stop()
+	select {
+	case <-running:
+	case <-t.C():
+	}
+}
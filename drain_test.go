@@ -0,0 +1,85 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// testSlowEngine writes its output in two parts with a delay in
+// between, unless its context is cancelled during the delay.
+type testSlowEngine struct {
+	started chan struct{}
+	delay   time.Duration
+}
+
+func (eng *testSlowEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	close(eng.started)
+	ui.Write([]byte("start\n"))
+	select {
+	case <-ctx.Done():
+		return 1
+	case <-time.After(eng.delay):
+	}
+	ui.Write([]byte("end\n"))
+	return 0
+}
+
+func TestRunWithDrainTimeout(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Drain time.Duration
+		ExOut string
+		Max   time.Duration
+	}{
+		{
+			Name:  "Drained",
+			Drain: 5 * time.Second,
+			ExOut: "start\nend\n",
+			Max:   5 * time.Second,
+		},
+		{
+			Name:  "TimedOut",
+			Drain: 50 * time.Millisecond,
+			ExOut: "start\n",
+			Max:   250 * time.Millisecond,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			pr, pw := io.Pipe()
+			defer pr.Close()
+			go pw.Write([]byte("slow\n"))
+
+			var buf bytes.Buffer
+			out := &lockedWriter{w: &buf}
+
+			eng := &testSlowEngine{started: make(chan struct{}), delay: 500 * time.Millisecond}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				<-eng.started
+				cancel()
+			}()
+
+			start := time.Now()
+			err := Run(ctx, eng, WithIO(pr, out), WithDrainTimeout(tc.Drain))
+			if err != context.Canceled {
+				subT.Errorf("expected context.Canceled but instead received: %v", err)
+			}
+			if d := time.Since(start); d > tc.Max {
+				subT.Errorf("expected Run to return within %s but instead took: %s", tc.Max, d)
+			}
+
+			out.mu.Lock()
+			defer out.mu.Unlock()
+			if buf.String() != tc.ExOut {
+				subT.Errorf("expected %q but instead received: %q", tc.ExOut, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// Drive executes the lines with the Engine, one after another, on
+// the calling goroutine and returns the status of each one. It is
+// meant for testing and benchmarking Exec in isolation, so it skips
+// the UI entirely, on purpose: there are no goroutines, prompts,
+// builtins or signal handling and statuses are returned as is, even
+// StatusNeedMore, instead of affecting how the lines are executed.
+//
+// The io.ReadWriter passed to Exec is backed by in-memory buffers,
+// which read as empty and keep what is written, see DriveIO for
+// supplying input and observing output. Engines which need a *UI
+// can't be driven.
+//
+func Drive(eng Engine, lines []string) []int {
+	return DriveIO(eng, lines, new(bytes.Buffer), new(bytes.Buffer))
+}
+
+// DriveIO is the same as Drive, except that the io.ReadWriter passed
+// to Exec reads from in and writes to out, e.g. bytes.Buffers for
+// feeding an Engine which reads input and checking its output. Both
+// are shared by every line, so a line reads on where the one before
+// stopped.
+//
+func DriveIO(eng Engine, lines []string, in io.Reader, out io.Writer) []int {
+	rw := driveIO{Reader: in, Writer: out}
+	statuses := make([]int, len(lines))
+	for i, line := range lines {
+		statuses[i] = eng.Exec(context.Background(), line, rw)
+	}
+	return statuses
+}
+
+// driveIO is the io.ReadWriter Drive passes to Exec.
+type driveIO struct {
+	io.Reader
+	io.Writer
+}
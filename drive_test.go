@@ -0,0 +1,62 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDrive(t *testing.T) {
+	eng := new(testBlockEngine)
+	statuses := Drive(eng, []string{"a\n", "b;\n", "c;\n"})
+
+	ex := []int{StatusNeedMore, 0, 0}
+	if !reflect.DeepEqual(statuses, ex) {
+		t.Errorf("expected %v but instead received: %v", ex, statuses)
+	}
+	if len(eng.lines) != 3 {
+		t.Errorf("expected 3 Exec calls but instead received: %d", len(eng.lines))
+	}
+}
+
+// testReplyEngine reads a letter and a newline of input for every line
+// it's given and writes both, failing once the input ends.
+//
+type testReplyEngine struct{}
+
+func (testReplyEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	answer := make([]byte, 2)
+	if _, err := io.ReadFull(ui, answer); err != nil {
+		return 1
+	}
+	fmt.Fprintf(ui, "%s: %s", line, answer)
+	return 0
+}
+
+func TestDriveIO(t *testing.T) {
+	in := strings.NewReader("x\ny\n")
+	var out bytes.Buffer
+	statuses := DriveIO(testReplyEngine{}, []string{"a", "b", "c"}, in, &out)
+
+	ex := []int{0, 0, 1}
+	if !reflect.DeepEqual(statuses, ex) {
+		t.Errorf("expected %v but instead received: %v", ex, statuses)
+	}
+	if exOut := "a: x\nb: y\n"; out.String() != exOut {
+		t.Errorf("expected %q but instead received: %q", exOut, out.String())
+	}
+}
+
+func BenchmarkDrive(b *testing.B) {
+	eng := new(testEchoEngine)
+	lines := []string{"hello\n", "world\n"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Drive(eng, lines)
+	}
+}
@@ -0,0 +1,63 @@
+package sand
+
+import "strings"
+
+// EmptyLineAction represents what the UI does with an empty line,
+// i.e. one with nothing but spaces and tabs.
+type EmptyLineAction int
+
+const (
+	// EmptyLineIgnore skips empty lines, so the prompt is written
+	// again without the Engine ever seeing them.
+	EmptyLineIgnore EmptyLineAction = iota
+
+	// EmptyLineRepeat executes the most recent command again, like
+	// gdb and pdb, e.g. for stepping through something by just
+	// pressing enter. Nothing happens if there is no command yet.
+	EmptyLineRepeat
+
+	// EmptyLineDispatch passes empty lines to the Engine, like
+	// any other line.
+	EmptyLineDispatch
+)
+
+// WithEmptyLineAction specifies what to do with empty lines, the
+// default being EmptyLineIgnore. Lines continuing an incomplete one,
+// see StatusNeedMore, are always passed on. EmptyLineRepeat records
+// the commands of the session, see History, in order to repeat the
+// most recent one, which is itself not recorded again.
+//
+func WithEmptyLineAction(action EmptyLineAction) Option {
+	return func(ui *UI) {
+		ui.emptyLine = action
+		if action == EmptyLineRepeat && ui.history == nil {
+			ui.history = new(history)
+		}
+	}
+}
+
+// handleEmptyLine returns the line to execute in place of chunk, if
+// it's empty, and reports whether to execute anything at all.
+// Lines which aren't empty are returned as is.
+//
+func (ui *UI) handleEmptyLine(chunk string) (line string, repeated, ok bool) {
+	if strings.Trim(chunk, " \t\r\n") != "" {
+		return chunk, false, true
+	}
+
+	switch ui.emptyLine {
+	case EmptyLineDispatch:
+		return chunk, false, true
+	case EmptyLineRepeat:
+		if ui.history == nil {
+			return chunk, false, false
+		}
+		ui.history.RLock()
+		defer ui.history.RUnlock()
+		if len(ui.history.entries) == 0 {
+			return chunk, false, false
+		}
+		return ui.history.entries[len(ui.history.entries)-1], true, true
+	}
+	return chunk, false, false
+}
@@ -0,0 +1,83 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWithEmptyLineAction(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Opts    []Option
+		In      []string
+		ExOut   string
+		ExExecs int
+	}{
+		{
+			Name:    "Default",
+			In:      []string{"\n", "step\n", "  \n"},
+			ExOut:   ">>>step\n>>\n",
+			ExExecs: 1,
+		},
+		{
+			Name:    "Ignore",
+			Opts:    []Option{WithEmptyLineAction(EmptyLineIgnore)},
+			In:      []string{"\n", "step\n", "\n"},
+			ExOut:   ">>>step\n>>\n",
+			ExExecs: 1,
+		},
+		{
+			Name:    "Repeat",
+			Opts:    []Option{WithEmptyLineAction(EmptyLineRepeat)},
+			In:      []string{"\n", "step\n", "\n", "\r\n"},
+			ExOut:   ">>>step\n>>step\n>>step\n>\n",
+			ExExecs: 3,
+		},
+		{
+			Name:    "Dispatch",
+			Opts:    []Option{WithEmptyLineAction(EmptyLineDispatch)},
+			In:      []string{"\n", "step\n"},
+			ExOut:   ">>\n>>step\n>\n",
+			ExExecs: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: tc.In}
+			var out bytes.Buffer
+
+			eng := new(testEchoEngine)
+			opts := append([]Option{WithPrefix(">"), WithIO(in, &out)}, tc.Opts...)
+			err := Run(nil, eng, opts...)
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected %q but instead received: %q", tc.ExOut, out.String())
+			}
+			if eng.execs != tc.ExExecs {
+				subT.Errorf("expected %d execs but instead received: %d", tc.ExExecs, eng.execs)
+			}
+		})
+	}
+}
+
+func TestWithEmptyLineAction_RepeatNotRecorded(t *testing.T) {
+	in := &testLineReader{lines: []string{"step\n", "\n", "next\n"}}
+	var out bytes.Buffer
+
+	ui := new(UI)
+	err := ui.Run(nil, new(testEchoEngine), WithIO(in, &out), WithEmptyLineAction(EmptyLineRepeat))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	hist := ui.History()
+	if len(hist) != 2 || hist[0] != "step" || hist[1] != "next" {
+		t.Errorf("expected repeated command to not be recorded but instead received: %q", hist)
+	}
+}
@@ -3,70 +3,378 @@ package sand
 import (
 	"context"
 	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
 )
 
 // Engine represents the command processor for the interpreter.
-// The underlying type of the Engine implementation must be a
-// hashable type (e.g. int, string, struct) in order for the UI
-// to be able to use it. Sadly, this means a type EngineFunc
-// can not be used due to funcs not being hashable.
+// Under WithSharedEngine, the underlying type of the Engine
+// implementation must be a hashable type (e.g. int, string, struct)
+// for UIs to share it; an Engine which isn't, like an EngineFunc, is
+// silently run on its own instead, and shut down by every UI running
+// it, see NewFuncEngine otherwise.
 //
 type Engine interface {
 	// Exec should take the given line and execute the corresponding functionality.
 	Exec(ctx context.Context, line string, ui io.ReadWriter) (status int)
 }
 
+// EngineFunc is a func used as an Engine. Since funcs aren't
+// hashable, it can't be shared by UIs as is, see NewFuncEngine, but
+// it can be routed to by a Mux, see Mux.HandleFunc.
+//
+type EngineFunc func(ctx context.Context, line string, ui io.ReadWriter) int
+
+// Exec calls f.
+func (f EngineFunc) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	return f(ctx, line, ui)
+}
+
+// NewFuncEngine returns an Engine calling fn, which, unlike fn
+// itself, can be shared by UIs, see WithSharedEngine, e.g. for a
+// trivial Engine which doesn't need a type of its own:
+//
+//	sand.Run(ctx, sand.NewFuncEngine(func(ctx context.Context, line string, ui io.ReadWriter) int {
+//		...
+//	}))
+//
+// Every call returns a distinct Engine, even for the same fn, so
+// Engines returned by separate calls are never shared by UIs.
+//
+func NewFuncEngine(fn EngineFunc) Engine {
+	if fn == nil {
+		panic(errNoEngine)
+	}
+	return &funcEngine{fn: fn}
+}
+
+// funcEngine is a hashable Engine calling an EngineFunc, see NewFuncEngine.
+type funcEngine struct {
+	fn EngineFunc
+}
+
+func (e *funcEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	return e.fn(ctx, line, ui)
+}
+
+// Statuses reserved by the UI. An Engine returning one of these
+// from Exec changes how the UI continues. Positive statuses are never
+// reserved and, like any other status besides 0, end Run.
+//
+const (
+	// StatusNeedMore signals that the line is incomplete, e.g. an
+	// unterminated string or block. The UI reads another line and
+	// calls Exec again with all of the lines accumulated so far,
+	// separated by newlines. This is meant for engines which can
+	// only tell that their input is incomplete by parsing it.
+	StatusNeedMore = -1
+
+	// StatusNotHandled signals that the Engine doesn't handle the
+	// line, see Chain. The UI treats it the same as a status of 0.
+	StatusNotHandled = -2
+
+	// StatusClear signals that the screen should be cleared, if the
+	// output is a terminal, before the prompt is written again. The
+	// UI otherwise treats it the same as a status of 0.
+	StatusClear = -3
+
+	// StatusExit signals that the session should end, e.g. for a
+	// "quit" command. Unlike other statuses ending Run, it's not
+	// treated as a failure, e.g. the command isn't recorded for
+	// retry, and it ends a sequence of commands even if the
+	// sequence continues on errors, see WithCommandSeparator.
+	StatusExit = -4
+)
+
+// settleStatus applies the effect of a reserved status and returns
+// the status the UI continues with.
+//
+func (ui *UI) settleStatus(status int) int {
+	switch status {
+	case StatusNotHandled:
+		return 0
+	case StatusClear:
+		clearBuiltin(ui.ctx, nil, ui)
+		return 0
+	}
+	return status
+}
+
 // execReq represents the parameters passed to an Engine.Exec call
 type execReq struct {
 	ctx    context.Context
 	line   string
-	ui     io.ReadWriter
+	ui     *UI
 	respCh chan int
 }
 
+// respond delivers the status returned by the Engine for the request
+// and closes its response channel. The status is delivered however
+// the context of the request ended, since the UI always awaits it, so
+// a status racing a cancellation is never lost or turned into 0.
+//
+func (req execReq) respond(status int) {
+	req.respCh <- status
+	close(req.respCh)
+}
+
 // exec sends the given line to the backing engine and awaits the results.
-// this is a blocking call.
-func (ui *UI) exec(ctx context.Context, line string, reqCh chan execReq) int {
+// this is a blocking call. A line is never sent once the context is done,
+// in which case 0 is returned, but once sent, the status returned by the
+// engine is always delivered, even if the context is canceled in the
+// meantime, see execReq.respond. If the command is cancelled by
+// CancelCurrent, its status is ignored and 0 returned. A line which isn't
+// authorized is never sent, see WithAuthorizer.
+func (ui *UI) exec(ctx context.Context, line string, reqCh chan execReq) (status int) {
+	if !ui.authorize(ctx, line) {
+		return 1
+	}
+	atomic.AddInt64(&ui.nCmds, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	ui.mu.Lock()
+	ui.running = done
+	ui.current = line
+	ui.cancelCur = cancel
+	ui.curCanceled = false
+	ui.mu.Unlock()
+	defer func() {
+		ui.mu.Lock()
+		if ui.curCanceled {
+			status = 0
+		}
+		ui.running = nil
+		ui.current = ""
+		ui.cancelCur = nil
+		ui.mu.Unlock()
+		cancel()
+		close(done)
+	}()
+	defer ui.startCmdTimeout(line)()
+
 	req := execReq{
 		ctx:    ctx,
 		line:   line,
 		ui:     ui,
-		respCh: make(chan int),
+		respCh: make(chan int, 1), // never blocks an abandoned Exec, see awaitExec
+	}
+	if ctx.Err() != nil {
+		return 0
 	}
 	select {
 	case <-ctx.Done():
 		return 0
 	case reqCh <- req:
 	}
-	return <-req.respCh
+	return ui.awaitExec(ctx, line, req.respCh)
 }
 
-// runEngine provides a container for an engine to run inside.
-func runEngine(ctx context.Context, eng Engine, reqChs chan chan execReq) {
-	defer func() {
-		close(reqChs)
-		engines.Lock()
-		delete(engines.engs, eng)
-		engines.Unlock()
-	}()
+// TryExec executes the line, unless the UI isn't running or its
+// Engine is busy executing another line, in which case it returns
+// immediately with ok being false. Otherwise, it waits for the
+// line to be executed and returns its status, which is never
+// treated as ending the session. This is meant for embedding the
+// UI into an event loop, which mustn't block on a busy Engine.
+//
+func (ui *UI) TryExec(line string) (status int, ok bool) {
+	ui.mu.Lock()
+	running := ui.reqCh != nil
+	ui.mu.Unlock()
+	if !running {
+		return 0, false
+	}
+	if !ui.authorize(ui.ctx, line) {
+		return 1, true
+	}
+
+	// Run closes reqCh under the lock, so it's never sent on once closed
+	var respCh chan int
+	ui.mu.Lock()
+	if ui.reqCh != nil {
+		req := execReq{
+			ctx:    ui.ctx,
+			line:   line,
+			ui:     ui,
+			respCh: make(chan int),
+		}
+		select {
+		case ui.reqCh <- req:
+			respCh = req.respCh
+		default:
+		}
+	}
+	ui.mu.Unlock()
 
+	if respCh == nil {
+		return 0, false
+	}
+	return <-respCh, true
+}
+
+// Shutdowner is implemented by Engines which hold state, or
+// resources, that must be saved, or released, once no UI uses them
+// anymore. Shutdown is called once the last UI using the Engine
+// exits, before Run returns, and again only if the Engine is used
+// by another UI after that. An error is returned by Run, unless the
+// session ended with another error.
+//
+// Engines implementing io.Closer, e.g. for holding open files, are
+// closed the same way, once the last Exec call has returned, after
+// Shutdown if they implement both. An error of Shutdown takes
+// precedence over one of Close.
+//
+type Shutdowner interface {
+	Engine
+
+	// Shutdown should save or release whatever the Engine holds.
+	Shutdown(ctx context.Context) error
+}
+
+// hasIdentity reports whether eng is only ever equal to itself, i.e.
+// it's a pointer to a type which isn't empty, see
+// WithStrictEngineIdentity.
+//
+func hasIdentity(eng Engine) bool {
+	t := reflect.TypeOf(eng)
+	return t.Kind() == reflect.Ptr && t.Elem().Size() > 0
+}
+
+// isHashable reports whether eng can be used as a map key, i.e.
+// whether it can be shared by UIs and counted, see WithSharedEngine.
+//
+func isHashable(eng Engine) bool {
+	return reflect.TypeOf(eng).Comparable()
+}
+
+// engineRefs counts the UIs attached to every Engine, whichever
+// runner they're attached to, so an Engine shared by isolated runners
+// is still only shut down once its last UI detaches, see Shutdowner.
+//
+var engineRefs = struct {
+	sync.Mutex
+	refs map[Engine]*engineRef
+}{
+	refs: make(map[Engine]*engineRef),
+}
+
+// engineRef counts the UIs attached to an Engine.
+type engineRef struct {
+	n    int
+	done chan struct{} // closed once shut down, after n dropped to 0
+}
+
+// retainEngine counts another UI attached to eng. If the last UI
+// attached to it just detached, it waits for eng to be shut down
+// first. Engines which can't be compared are never counted, since
+// every use of them is a different Engine anyway.
+//
+func retainEngine(eng Engine) {
+	if !isHashable(eng) {
+		return
+	}
+	for {
+		engineRefs.Lock()
+		ref, exists := engineRefs.refs[eng]
+		if !exists {
+			ref = &engineRef{done: make(chan struct{})}
+			engineRefs.refs[eng] = ref
+		}
+		if exists && ref.n == 0 {
+			engineRefs.Unlock()
+			<-ref.done
+			continue
+		}
+		ref.n++
+		engineRefs.Unlock()
+		return
+	}
+}
+
+// releaseEngine uncounts a UI attached to eng, shutting eng down, and
+// closing it, if it was the last one, see Shutdowner.
+//
+func releaseEngine(eng Engine) error {
+	var ref *engineRef
+	if isHashable(eng) {
+		engineRefs.Lock()
+		ref = engineRefs.refs[eng]
+		ref.n--
+		last := ref.n == 0
+		engineRefs.Unlock()
+		if !last {
+			return nil
+		}
+		defer func() {
+			engineRefs.Lock()
+			delete(engineRefs.refs, eng)
+			engineRefs.Unlock()
+			close(ref.done)
+		}()
+	}
+
+	var err error
+	if s, ok := eng.(Shutdowner); ok {
+		err = s.Shutdown(context.Background())
+	}
+	if c, ok := eng.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// exec executes the request with wrapped, waiting for the Exec calls
+// of other UIs attached to the runner to return first, if it's shared.
+//
+func (r *engineRunner) exec(req execReq, wrapped Engine) int {
+	if r.shared {
+		r.execMu.Lock()
+		defer r.execMu.Unlock()
+	}
+	return req.ui.execEngine(req.ctx, wrapped, req.line)
+}
+
+// runEngine provides a container for an engine to run inside, for
+// as long as any UI is attached to it.
+//
+func runEngine(eng Engine, r *engineRunner) {
+	var attached int
 	for {
 		select {
-		case <-ctx.Done():
-			return
-		case reqCh := <-reqChs:
-			go func(rc chan execReq) {
-				for req := range rc {
-					resp := eng.Exec(req.ctx, req.line, req.ui)
-					select {
-					case <-ctx.Done():
-						close(req.respCh)
-						return
-					case req.respCh <- resp:
-					}
-					close(req.respCh)
+		case a := <-r.attach:
+			attached++
+			retainEngine(eng)
+			go func(a attachment) {
+				// The UI always awaits the response and closes
+				// the channel once it's done, so this runs for
+				// as long as the UI does.
+				for req := range a.reqCh {
+					req.respond(r.exec(req, a.wrapped))
 				}
-			}(reqCh)
+				r.detach <- a
+			}(a)
+		case a := <-r.detach:
+			attached--
+
+			// UIs trying to attach meanwhile wait for the
+			// shutdown, before starting a new runner.
+			err := releaseEngine(eng)
+			if attached > 0 {
+				a.detached <- err
+				continue
+			}
+			if r.shared {
+				engines.Lock()
+				if engines.engs[eng] == r {
+					delete(engines.engs, eng)
+				}
+				engines.Unlock()
+			}
+			close(r.done)
+			a.detached <- err
+			return
 		}
 	}
 }
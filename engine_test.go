@@ -0,0 +1,891 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// noSharingFlag runs the tests with DisableEngineSharing, so they
+// can be run in both modes.
+var noSharingFlag = flag.Bool("nosharing", false, "run with engine sharing disabled")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	DisableEngineSharing(*noSharingFlag)
+	code := m.Run()
+	if testPlugins.dir != "" {
+		os.RemoveAll(testPlugins.dir)
+	}
+	os.Exit(code)
+}
+
+// skipUnlessSharing skips tests of how engines are shared between UIs,
+// when sharing is disabled.
+func skipUnlessSharing(t *testing.T) {
+	if atomic.LoadInt32(&noSharing) == 1 {
+		t.Skip("engine sharing is disabled")
+	}
+}
+
+// testEchoEngine writes every line it receives back to the ui.
+// A new instance should be used per test, since engines are
+// shared between UIs by value. It is not zero sized, so that
+// separate instances never share the same address.
+type testEchoEngine struct {
+	execs int
+}
+
+func (eng *testEchoEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.execs++
+	_, err := ui.Write([]byte(line + "\n"))
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// testBlockEngine needs more input until it receives a line
+// ending with a semicolon, it then echos the whole block.
+type testBlockEngine struct {
+	lines []string
+}
+
+func (eng *testBlockEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.lines = append(eng.lines, line)
+	if !strings.HasSuffix(strings.TrimSpace(line), ";") {
+		return StatusNeedMore
+	}
+	ui.Write([]byte(line + "\n"))
+	return 0
+}
+
+func TestStatusNeedMore(t *testing.T) {
+	in := &testLineReader{lines: []string{"select *\n", "from t\n", "where x;\n", "end"}}
+	var out bytes.Buffer
+
+	eng := new(testBlockEngine)
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out))
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := []string{
+		"select *",
+		"select *\nfrom t",
+		"select *\nfrom t\nwhere x;",
+		"end",
+	}
+	if !reflect.DeepEqual(eng.lines, ex) {
+		t.Errorf("expected Exec calls %q but instead received: %q", ex, eng.lines)
+	}
+
+	exOut := ">>>>select *\nfrom t\nwhere x;\n>>\n"
+	if out.String() != exOut {
+		t.Errorf("expected %q but instead received: %q", exOut, out.String())
+	}
+}
+
+// testPrefixEngine only handles lines starting with its prefix
+type testPrefixEngine struct {
+	prefix string
+	status int
+	execs  int
+}
+
+func (eng *testPrefixEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.execs++
+	if !strings.HasPrefix(line, eng.prefix) {
+		return StatusNotHandled
+	}
+	return eng.status
+}
+
+func TestChain(t *testing.T) {
+	a := &testPrefixEngine{prefix: "a", status: 1}
+	b := &testPrefixEngine{prefix: "b", status: 2}
+	c := Chain(a, b)
+	c.Unhandled = 3
+
+	ctx := context.Background()
+	testCases := []struct {
+		Line   string
+		Status int
+		Execs  [2]int
+	}{
+		{Line: "apple", Status: 1, Execs: [2]int{1, 0}},
+		{Line: "banana", Status: 2, Execs: [2]int{2, 1}},
+		{Line: "cherry", Status: 3, Execs: [2]int{3, 2}},
+	}
+
+	for _, tc := range testCases {
+		status := c.Exec(ctx, tc.Line, nil)
+		if status != tc.Status {
+			t.Errorf("expected status %d for %q but instead received: %d", tc.Status, tc.Line, status)
+		}
+		if execs := [2]int{a.execs, b.execs}; execs != tc.Execs {
+			t.Errorf("expected exec counts %v after %q but instead received: %v", tc.Execs, tc.Line, execs)
+		}
+	}
+
+	if status := Chain().Exec(ctx, "", nil); status != 0 {
+		t.Errorf("expected empty chain to return 0 but instead received: %d", status)
+	}
+}
+
+func TestRunFactory(t *testing.T) {
+	var created []*testEchoEngine
+	factory := func() Engine {
+		eng := new(testEchoEngine)
+		created = append(created, eng)
+		return eng
+	}
+
+	for i := 0; i < 2; i++ {
+		in := &testLineReader{lines: []string{"a\n", "b\n"}}
+		err := RunFactory(nil, factory, WithIO(in, ioutil.Discard))
+		if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			t.Error(err)
+		}
+	}
+
+	if len(created) != 2 {
+		t.Fatalf("expected an engine per run but instead received: %d", len(created))
+	}
+	for i, eng := range created {
+		if eng.execs != 2 {
+			t.Errorf("expected engine %d to only execute its own lines but instead received: %d", i, eng.execs)
+		}
+	}
+}
+
+func TestRunDoesNotLeak(t *testing.T) {
+	waitGoroutines := func(n int) int {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > n && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		return runtime.NumGoroutine()
+	}
+
+	// The first Run starts the os/signal goroutine, which never exits
+	Run(nil, new(testEchoEngine), WithIO(&testLineReader{}, ioutil.Discard))
+
+	testCases := []struct {
+		Name string
+		Ctx  context.Context
+	}{
+		{Name: "NilContext", Ctx: nil},
+		{Name: "Context", Ctx: context.Background()},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			base := runtime.NumGoroutine()
+			eng := new(testEchoEngine)
+			for i := 0; i < 10; i++ {
+				in := &testLineReader{lines: []string{"a\n"}}
+				err := Run(tc.Ctx, eng, WithIO(in, ioutil.Discard))
+				if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+					subT.Error(err)
+				}
+			}
+
+			// The runner removes itself once it has stopped
+			exists := true
+			for deadline := time.Now().Add(time.Second); exists && time.Now().Before(deadline); {
+				engines.Lock()
+				_, exists = engines.engs[eng]
+				engines.Unlock()
+				time.Sleep(time.Millisecond)
+			}
+			if exists {
+				subT.Errorf("expected engine to be removed from the registry")
+			}
+
+			if n := waitGoroutines(base); n > base {
+				subT.Errorf("expected %d goroutines but instead received: %d", base, n)
+			}
+		})
+	}
+}
+
+// testCancelEngine signals on started once it received a line and
+// returns its status once its context is canceled.
+type testCancelEngine struct {
+	status  int
+	started chan struct{}
+}
+
+func (eng *testCancelEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.started <- struct{}{}
+	<-ctx.Done()
+	return eng.status
+}
+
+func TestExecStatusRacingCancel(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		ui := &UI{ctx: ctx, o: ioutil.Discard}
+		ui.out = ui.o
+		eng := &testCancelEngine{status: 7, started: make(chan struct{})}
+
+		reqCh := make(chan execReq)
+		ui.startEngine(eng, ui.wrapEngine(eng), reqCh)
+
+		statusCh := make(chan int, 1)
+		go func() { statusCh <- ui.exec(ctx, "a", reqCh) }()
+		<-eng.started
+		cancel()
+
+		if status := <-statusCh; status != 7 {
+			t.Fatalf("expected status 7 but instead received: %d", status)
+		}
+		close(reqCh)
+	}
+}
+
+func TestExecCanceledBeforeSend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ui := &UI{ctx: ctx, o: ioutil.Discard}
+	ui.out = ui.o
+	var execs int32
+	eng := EngineFunc(func(ctx context.Context, line string, ui io.ReadWriter) int {
+		atomic.AddInt32(&execs, 1)
+		return 7
+	})
+
+	reqCh := make(chan execReq)
+	detached := ui.startEngine(eng, ui.wrapEngine(eng), reqCh)
+	for i := 0; i < 50; i++ {
+		if status := ui.exec(ctx, "a", reqCh); status != 0 {
+			t.Fatalf("expected status 0 but instead received: %d", status)
+		}
+	}
+	close(reqCh)
+	<-detached
+
+	if n := atomic.LoadInt32(&execs); n != 0 {
+		t.Errorf("expected no line to be sent once canceled but instead %d were", n)
+	}
+}
+
+func TestRunWithIsolatedEngine(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Opts []Option
+	}{
+		{Name: "Default"},
+		{Name: "Option", Opts: []Option{WithSharedEngine(), WithIsolatedEngine()}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			eng := &testWaitEngine{started: make(chan string, 2)}
+
+			// Both UIs block in Exec at the same time, which is only
+			// possible if they don't share the engine goroutine.
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				in := &testLineReader{lines: []string{"a\n"}}
+				opts := append([]Option{WithIO(in, ioutil.Discard)}, tc.Opts...)
+				go func() { errCh <- Run(ctx, eng, opts...) }()
+			}
+			for i := 0; i < 2; i++ {
+				select {
+				case <-eng.started:
+				case <-time.After(5 * time.Second):
+					subT.Fatal("expected both UIs to execute concurrently")
+				}
+			}
+
+			engines.Lock()
+			_, exists := engines.engs[eng]
+			engines.Unlock()
+			if exists {
+				subT.Errorf("expected isolated engine to not be registered")
+			}
+
+			cancel()
+			for i := 0; i < 2; i++ {
+				<-errCh
+			}
+		})
+	}
+}
+
+func TestRunWithSharedEngine(t *testing.T) {
+	skipUnlessSharing(t)
+
+	eng := &testWaitEngine{started: make(chan string, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	in := &testLineReader{lines: []string{"a\n"}}
+	go func() { errCh <- Run(ctx, eng, WithIO(in, ioutil.Discard), WithSharedEngine()) }()
+	<-eng.started
+
+	engines.Lock()
+	_, exists := engines.engs[eng]
+	engines.Unlock()
+	if !exists {
+		t.Errorf("expected shared engine to be registered")
+	}
+
+	cancel()
+	<-errCh
+}
+
+func TestRun_EngineFunc(t *testing.T) {
+	// An isolated EngineFunc is never used as a map key, so it
+	// doesn't need to be wrapped by NewFuncEngine.
+	echo := EngineFunc(func(ctx context.Context, line string, ui io.ReadWriter) int {
+		ui.Write([]byte(line + "\n"))
+		return 0
+	})
+
+	in := &testLineReader{lines: []string{"hello\n"}}
+	var out bytes.Buffer
+	err := Run(nil, echo, WithPrefix(">"), WithIO(in, &out))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if ex := ">>hello\n>\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
+
+// testValueEngine is the same as testWaitEngine, except that it's
+// used by value, so equal instances are the same Engine to UIs.
+type testValueEngine struct {
+	started chan string
+}
+
+func (eng testValueEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.started <- line
+	<-ctx.Done()
+	return 3
+}
+
+func TestEngineValueCollision(t *testing.T) {
+	skipUnlessSharing(t)
+
+	started := make(chan string, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	in := &testLineReader{lines: []string{"a\n"}}
+	go func() {
+		errCh <- Run(ctx, testValueEngine{started: started}, WithIO(in, ioutil.Discard), WithSharedEngine())
+	}()
+	<-started
+
+	// Another, equal, instance finds the runner of the first one
+	engines.Lock()
+	_, exists := engines.engs[testValueEngine{started: started}]
+	engines.Unlock()
+	if !exists {
+		t.Errorf("expected equal engine values to collide")
+	}
+
+	cancel()
+	<-errCh
+}
+
+func TestRunWithStrictEngineIdentity(t *testing.T) {
+	skipUnlessSharing(t)
+
+	testCases := []struct {
+		Name     string
+		Eng      func(started chan string) Engine
+		Warnings int
+	}{
+		{
+			Name:     "Value",
+			Eng:      func(started chan string) Engine { return testValueEngine{started: started} },
+			Warnings: 1,
+		},
+		{
+			Name:     "Pointer",
+			Eng:      func(started chan string) Engine { return &testWaitEngine{started: started} },
+			Warnings: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(subT *testing.T) {
+			started := make(chan string, 2)
+
+			// Both UIs block in Exec at the same time, which is
+			// only possible if they don't share the engine goroutine.
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error, 2)
+			outs := []*bytes.Buffer{new(bytes.Buffer), new(bytes.Buffer)}
+			for _, out := range outs {
+				in := &testLineReader{lines: []string{"a\n"}}
+				eng := testCase.Eng(started)
+				go func(out io.Writer) {
+					errCh <- Run(ctx, eng, WithIO(in, out), WithSharedEngine(), WithStrictEngineIdentity())
+				}(out)
+			}
+			for i := 0; i < 2; i++ {
+				select {
+				case <-started:
+				case <-time.After(5 * time.Second):
+					subT.Fatal("expected both UIs to execute concurrently")
+				}
+			}
+			cancel()
+			for i := 0; i < 2; i++ {
+				<-errCh
+			}
+
+			warnings := 0
+			for _, out := range outs {
+				if strings.Contains(out.String(), "equals one already running") {
+					warnings++
+				}
+			}
+			if warnings != testCase.Warnings {
+				subT.Errorf("expected %d warnings but instead received: %d", testCase.Warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestHasIdentity(t *testing.T) {
+	testCases := []struct {
+		Eng Engine
+		Ex  bool
+	}{
+		{Eng: new(testEchoEngine), Ex: true},
+		{Eng: testValueEngine{}, Ex: false},
+		{Eng: new(testEmptyEngine), Ex: false},
+	}
+
+	for _, tc := range testCases {
+		if ok := hasIdentity(tc.Eng); ok != tc.Ex {
+			t.Errorf("expected %v for %T but instead received: %v", tc.Ex, tc.Eng, ok)
+		}
+	}
+}
+
+// testEmptyEngine is zero sized, so its instances may share an address.
+type testEmptyEngine struct{}
+
+func (eng *testEmptyEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	return 0
+}
+
+func TestUI_TryExec(t *testing.T) {
+	ui := new(UI)
+	if _, ok := ui.TryExec("a\n"); ok {
+		t.Errorf("expected TryExec to fail when not running")
+	}
+
+	eng := &testWaitEngine{started: make(chan string, 2)}
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { errCh <- ui.Run(ctx, eng, WithIO(pr, ioutil.Discard)) }()
+
+	// Keep the engine busy with a line from the input
+	pw.Write([]byte("busy\n"))
+	<-eng.started
+	if _, ok := ui.TryExec("a\n"); ok {
+		t.Errorf("expected TryExec to fail while the engine is busy")
+	}
+
+	cancel()
+	<-errCh
+	pw.Close()
+	if _, ok := ui.TryExec("a\n"); ok {
+		t.Errorf("expected TryExec to fail once Run has returned")
+	}
+}
+
+func TestUI_TryExecIdle(t *testing.T) {
+	eng := new(testEchoEngine)
+	var out bytes.Buffer
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ui := new(UI)
+	errCh := make(chan error, 1)
+	go func() { errCh <- ui.Run(nil, eng, WithIO(pr, &out)) }()
+
+	var status int
+	ok := false
+	for deadline := time.Now().Add(5 * time.Second); !ok && time.Now().Before(deadline); {
+		status, ok = ui.TryExec("hi")
+		time.Sleep(time.Millisecond)
+	}
+	if !ok || status != 0 {
+		t.Errorf("expected idle engine to execute the line but instead received: %d %v", status, ok)
+	}
+
+	pw.Close()
+	<-errCh
+	if out.String() != "hi\n\n" {
+		t.Errorf("expected %q but instead received: %q", "hi\n\n", out.String())
+	}
+}
+
+// testShutdownEngine counts how often it's shut down.
+type testShutdownEngine struct {
+	mu        sync.Mutex
+	shutdowns int
+	err       error
+}
+
+func (eng *testShutdownEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	return 0
+}
+
+func (eng *testShutdownEngine) Shutdown(ctx context.Context) error {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	eng.shutdowns++
+	return eng.err
+}
+
+func (eng *testShutdownEngine) count() int {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	return eng.shutdowns
+}
+
+func TestShutdowner(t *testing.T) {
+	skipUnlessSharing(t)
+
+	eng := new(testShutdownEngine)
+
+	// Two UIs share the engine, so only the last one to exit shuts it down
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	first := new(UI)
+	first.SetIO(pr, ioutil.Discard)
+	go func() { errCh <- first.Run(nil, eng, WithSharedEngine()) }()
+	for attached := false; !attached; time.Sleep(time.Millisecond) {
+		first.mu.Lock()
+		attached = first.reqCh != nil
+		first.mu.Unlock()
+	}
+
+	err := Run(nil, eng, WithIO(&testLineReader{lines: []string{"a\n"}}, ioutil.Discard), WithSharedEngine())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if n := eng.count(); n != 0 {
+		t.Errorf("expected engine to not be shut down while in use but instead received: %d shutdowns", n)
+	}
+
+	pw.Close()
+	if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if n := eng.count(); n != 1 {
+		t.Errorf("expected 1 shutdown but instead received: %d", n)
+	}
+
+	// A shutdown error is returned by the last Run
+	eng.err = errors.New("save failed")
+	err = Run(nil, eng, WithIO(&testLineReader{}, ioutil.Discard))
+	if errors.Cause(err) != eng.err {
+		t.Errorf("expected %v but instead received: %v", eng.err, err)
+	}
+	if n := eng.count(); n != 2 {
+		t.Errorf("expected 2 shutdowns but instead received: %d", n)
+	}
+}
+
+// testCloseEngine records its Exec, Shutdown and Close calls.
+type testCloseEngine struct {
+	mu    sync.Mutex
+	calls []string
+	err   error
+}
+
+func (eng *testCloseEngine) record(call string) {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	eng.calls = append(eng.calls, call)
+}
+
+func (eng *testCloseEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	time.Sleep(10 * time.Millisecond)
+	eng.record("exec")
+	return 0
+}
+
+func (eng *testCloseEngine) Shutdown(ctx context.Context) error {
+	eng.record("shutdown")
+	return nil
+}
+
+func (eng *testCloseEngine) Close() error {
+	eng.record("close")
+	return eng.err
+}
+
+func (eng *testCloseEngine) called() string {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	return strings.Join(eng.calls, ",")
+}
+
+func TestCloser(t *testing.T) {
+	skipUnlessSharing(t)
+
+	eng := new(testCloseEngine)
+
+	// Two UIs share the engine, so only the last one to exit closes it
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	first := new(UI)
+	first.SetIO(pr, ioutil.Discard)
+	go func() { errCh <- first.Run(nil, eng, WithSharedEngine()) }()
+	for attached := false; !attached; time.Sleep(time.Millisecond) {
+		first.mu.Lock()
+		attached = first.reqCh != nil
+		first.mu.Unlock()
+	}
+
+	err := Run(nil, eng, WithIO(&testLineReader{lines: []string{"a\n"}}, ioutil.Discard), WithSharedEngine())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	pw.Write([]byte("b\n"))
+	pw.Close()
+	if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if ex := "exec,exec,shutdown,close"; eng.called() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, eng.called())
+	}
+
+	// A close error is returned by the last Run
+	eng.err = errors.New("close failed")
+	err = Run(nil, eng, WithIO(&testLineReader{}, ioutil.Discard))
+	if errors.Cause(err) != eng.err {
+		t.Errorf("expected %v but instead received: %v", eng.err, err)
+	}
+	if _, ok := IsRecoverable(err); !ok {
+		t.Errorf("expected %v to be recoverable", err)
+	}
+}
+
+func TestCloser_Isolated(t *testing.T) {
+	eng := new(testCloseEngine)
+
+	// Isolated UIs running the same engine still only close it once
+	// the last one exits
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	first := new(UI)
+	first.SetIO(pr, ioutil.Discard)
+	go func() { errCh <- first.Run(nil, eng, WithIsolatedEngine()) }()
+	for attached := false; !attached; time.Sleep(time.Millisecond) {
+		first.mu.Lock()
+		attached = first.reqCh != nil
+		first.mu.Unlock()
+	}
+
+	err := Run(nil, eng, WithIO(&testLineReader{lines: []string{"a\n"}}, ioutil.Discard), WithIsolatedEngine())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if ex := "exec"; eng.called() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, eng.called())
+	}
+
+	pw.Write([]byte("b\n"))
+	pw.Close()
+	if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if ex := "exec,exec,shutdown,close"; eng.called() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, eng.called())
+	}
+}
+
+// testConcurrencyEngine records how many of its Exec calls ran at once.
+type testConcurrencyEngine struct {
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (eng *testConcurrencyEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.mu.Lock()
+	eng.running++
+	if eng.running > eng.max {
+		eng.max = eng.running
+	}
+	eng.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	eng.mu.Lock()
+	eng.running--
+	eng.mu.Unlock()
+	return 0
+}
+
+func TestRunWithSharedEngine_Serialized(t *testing.T) {
+	skipUnlessSharing(t)
+
+	eng := new(testConcurrencyEngine)
+	lines := []string{"a\n", "b\n", "c\n", "d\n", "e\n"}
+
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			in := &testLineReader{lines: append([]string(nil), lines...)}
+			errCh <- Run(nil, eng, WithIO(in, ioutil.Discard), WithSharedEngine())
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+			t.Error(err)
+		}
+	}
+	if eng.max != 1 {
+		t.Errorf("expected Exec calls to be serialized but instead %d ran at once", eng.max)
+	}
+}
+
+func TestRunWithSharedEngine_Unhashable(t *testing.T) {
+	var out bytes.Buffer
+	eng := EngineFunc(func(ctx context.Context, line string, ui io.ReadWriter) int {
+		io.WriteString(ui, line+"\n")
+		return 0
+	})
+
+	err := Run(nil, eng, WithIO(&testLineReader{lines: []string{"a\n"}}, &out), WithSharedEngine())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if !strings.Contains(out.String(), "a\n") {
+		t.Errorf("expected the engine to be run isolated but instead received: %q", out.String())
+	}
+}
+
+// testReservedEngine returns the reserved status named by the line
+// and echos anything else.
+type testReservedEngine struct {
+	testEchoEngine
+}
+
+func (eng *testReservedEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	switch strings.TrimSpace(line) {
+	case "skip":
+		return StatusNotHandled
+	case "clear":
+		return StatusClear
+	case "exit":
+		return StatusExit
+	}
+	return eng.testEchoEngine.Exec(ctx, line, ui)
+}
+
+func TestRun_ReservedStatuses(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		TTY   bool
+		Lines []string
+		Opts  []Option
+		Ex    string
+	}{
+		{Name: "NotHandled", Lines: []string{"skip\n", "a\n"}, Ex: ">>>a\n>\n"},
+		{Name: "Clear", Lines: []string{"clear\n", "a\n"}, Ex: ">>>a\n>\n"},
+		{Name: "ClearTerminal", TTY: true, Lines: []string{"clear\n", "a\n"}, Ex: ">" + clearScreen + ">>a\n>\n"},
+		{Name: "Exit", Lines: []string{"a\n", "exit\n", "b\n"}, Ex: ">>a\n>\n"},
+		{
+			Name:  "ExitSequence",
+			Lines: []string{"a; exit; b\n", "c\n"},
+			Opts:  []Option{WithCommandSeparator(CommandSeparator{Sep: ";", ContinueOnError: true})},
+			Ex:    ">>a\n\n",
+		},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.TTY }
+
+			var out bytes.Buffer
+			ui := new(UI)
+			opts := append([]Option{WithPrefix(">"), WithIO(&testLineReader{lines: tc.Lines}, &out), WithTheme(MonochromeTheme), WithRetry()}, tc.Opts...)
+			err := ui.Run(nil, new(testReservedEngine), opts...)
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+			if ui.lastFailed != "" {
+				subT.Errorf("expected no failed command but instead received: %q", ui.lastFailed)
+			}
+		})
+	}
+}
+
+func TestDisableEngineSharing(t *testing.T) {
+	defer DisableEngineSharing(atomic.LoadInt32(&noSharing) == 1)
+	DisableEngineSharing(true)
+
+	started := make(chan string, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	in := &testLineReader{lines: []string{"a\n"}}
+	eng := testValueEngine{started: started}
+	go func() { errCh <- Run(ctx, eng, WithIO(in, ioutil.Discard), WithSharedEngine()) }()
+	<-started
+
+	// The registry isn't consulted, so the engine is never in it
+	engines.Lock()
+	_, exists := engines.engs[eng]
+	engines.Unlock()
+	if exists {
+		t.Errorf("expected engine to not be shared")
+	}
+
+	cancel()
+	<-errCh
+}
+
+func TestNewFuncEngine(t *testing.T) {
+	echo := func(ctx context.Context, line string, ui io.ReadWriter) int {
+		ui.Write([]byte(line + "\n"))
+		return 0
+	}
+	if NewFuncEngine(echo) == NewFuncEngine(echo) {
+		t.Errorf("expected every call to return a distinct Engine")
+	}
+
+	in := &testLineReader{lines: []string{"hello\n"}}
+	var out bytes.Buffer
+	err := Run(nil, NewFuncEngine(echo), WithPrefix(">"), WithIO(in, &out), WithStrictEngineIdentity())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if ex := ">>hello\n>\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
@@ -0,0 +1,43 @@
+package sand
+
+import (
+	"os"
+	"strings"
+)
+
+// WithEnvSnapshot imports the environment variables of the process
+// whose names start with prefix, or all of them if prefix is empty,
+// as session variables when Run starts, see Set. Unlike falling back
+// to the environment, see VarExpansion, the snapshot isn't affected
+// by the environment changing afterwards, e.g. by os.Setenv, nor by
+// any other session, so expansions stay the same for the whole
+// session. Variables which are already set in the session are kept,
+// so a UI which is run again keeps its own values.
+//
+func WithEnvSnapshot(prefix string) Option {
+	return func(ui *UI) {
+		ui.envPrefix = &prefix
+	}
+}
+
+// snapshotEnv imports the environment as specified by WithEnvSnapshot.
+func (ui *UI) snapshotEnv() {
+	if ui.envPrefix == nil {
+		return
+	}
+
+	ui.vars.Lock()
+	defer ui.vars.Unlock()
+	if ui.vars.vars == nil {
+		ui.vars.vars = make(map[string]string)
+	}
+	for _, kv := range os.Environ() {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 || !strings.HasPrefix(kv[:i], *ui.envPrefix) {
+			continue
+		}
+		if _, ok := ui.vars.vars[kv[:i]]; !ok {
+			ui.vars.vars[kv[:i]] = kv[i+1:]
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestWithEnvSnapshot(t *testing.T) {
+	os.Setenv("SAND_SNAP_NAME", "bob")
+	defer os.Unsetenv("SAND_SNAP_NAME")
+	os.Setenv("SAND_OTHER_NAME", "alice")
+	defer os.Unsetenv("SAND_OTHER_NAME")
+
+	run := func(ui *UI) string {
+		in := &testLineReader{lines: []string{"[$SAND_SNAP_NAME][$SAND_OTHER_NAME]\n"}}
+		var out bytes.Buffer
+		err := ui.Run(nil, new(testEchoEngine), WithIO(in, &out), WithEnvSnapshot("SAND_SNAP_"), WithVarExpansion(VarExpansion{}))
+		if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	a := new(UI)
+	b := new(UI)
+
+	if out := run(a); out != "[bob][]\n\n" {
+		t.Errorf("expected %q but instead received: %q", "[bob][]\n\n", out)
+	}
+
+	// Neither the environment nor another session affect the snapshot
+	os.Setenv("SAND_SNAP_NAME", "carol")
+	b.Set("SAND_SNAP_NAME", "dave")
+	if out := run(a); out != "[bob][]\n\n" {
+		t.Errorf("expected %q but instead received: %q", "[bob][]\n\n", out)
+	}
+	if out := run(b); out != "[dave][]\n\n" {
+		t.Errorf("expected %q but instead received: %q", "[dave][]\n\n", out)
+	}
+
+	c := new(UI)
+	if out := run(c); out != "[carol][]\n\n" {
+		t.Errorf("expected %q but instead received: %q", "[carol][]\n\n", out)
+	}
+}
@@ -1,12 +1,10 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"github.com/Zaba505/sand"
+	"github.com/Zaba505/sand/sandtest"
 	"io"
-	"io/ioutil"
-	"strings"
 	"testing"
 )
 
@@ -27,10 +25,16 @@ func (eng *CmdTester) Exec(ctx context.Context, line string, ui io.ReadWriter) i
 // echoHandler wraps rootCmd in the testing framework.
 func echoHandler(t *testing.T) func(context.Context, string, io.ReadWriter) int {
 	return func(ctx context.Context, line string, ui io.ReadWriter) int {
-		rootCmd.SetArgs(strings.Split(line, " "))
+		args, err := sand.Fields(line)
+		if err != nil {
+			t.Errorf("malformed command line: %s", err)
+			return 1
+		}
+		rootCmd.SetArgs(args)
 		rootCmd.SetOutput(ui)
+		rootCmd.Run = echo(ui)
 
-		err := rootCmd.Execute()
+		err = rootCmd.Execute()
 		if err != nil {
 			t.Errorf("cobra command encountered an error: %s", err)
 			return 1
@@ -56,45 +60,29 @@ func TestRootCmd(t *testing.T) {
 			In:    "goodbye, world!",
 			ExOut: "[goodbye, world!]",
 		},
+		{
+			Name:  "TestQuoted",
+			In:    `say "hello,  world!"`,
+			ExOut: "[say hello,  world!]",
+		},
 	}
 
 	for _, testCase := range testCases {
 		inData := testCase.In
 		outData := testCase.ExOut
 		t.Run(testCase.Name, func(subT *testing.T) {
-			var in, out bytes.Buffer
-
 			eng := &CmdTester{
 				T: subT,
 				H: echoHandler,
 			}
 
-			ui := new(sand.UI)
-
-			ui.SetPrefix(">")
-			ui.SetIO(&in, &out)
-
-			rootCmd.Run = echo(ui)
-
-			_, err := in.Write([]byte(inData))
-			if err != nil {
-				subT.Errorf("failed writing to input buffer: %s", err)
-			}
-
-			err = ui.Run(nil, eng)
-			var ok bool
-			if err, ok = sand.IsRecoverable(err); !ok || err != io.EOF && err != nil {
-				subT.Errorf("unexpected error encountered during UI.Run(): %s", err)
-			}
-
-			b, err := ioutil.ReadAll(&out)
-			if err != nil {
-				subT.Errorf("failed reading everything from output buffer: %s", err)
+			res := sandtest.Run(nil, eng, inData, sand.WithPrefix(">"))
+			if res.Err != nil {
+				subT.Errorf("unexpected error encountered during UI.Run(): %s", res.Err)
 			}
 
-			b = bytes.Trim(b, "\n>")
-			if string(b) != outData {
-				subT.Fail()
+			if res.Output != ">"+outData+"\n" {
+				subT.Errorf("expected output %q but instead received: %q", ">"+outData+"\n", res.Output)
 			}
 		})
 
@@ -12,20 +12,9 @@ import (
 // EchoEngine simply echos the given line
 type EchoEngine struct{}
 
-// Exec simply writes the given line back to the ui
-func (eng *EchoEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) (status int) {
-	select {
-	case <-ctx.Done():
-		return
-	default:
-	}
-
-	_, err := ui.Write([]byte(line))
-	if err != nil {
-		log.Printf("error encountered: %s\n", err)
-		return 1
-	}
-	return
+// ExecOut simply returns the given line, for the ui to write
+func (eng *EchoEngine) ExecOut(ctx context.Context, line string, ui io.ReadWriter) (string, int) {
+	return line, 0
 }
 
 func main() {
@@ -34,7 +23,7 @@ func main() {
 	log.SetOutput(os.Stdout)
 	err := ui.Run(
 		nil,
-		new(EchoEngine),
+		sand.NewOutputEngine(new(EchoEngine)),
 		sand.WithPrefix(">"),
 		sand.WithIO(os.Stdin, os.Stdout),
 	)
@@ -123,19 +123,16 @@ func (eng *T3Engine) Exec(ctx context.Context, line string, ui io.ReadWriter) in
 
 func (eng *T3Engine) printBoard(w io.Writer) {
 	ui, _ := w.(*sand.UI)
-	ui.SetPrefix("")
-	defer func() {
-		ui.SetPrefix(">")
-	}()
-
-	fmt.Fprintf(w, ` %s | %s | %s
+	ui.SuppressPrefix(func() {
+		fmt.Fprintf(w, ` %s | %s | %s
 -----------
  %s | %s | %s
 -----------
  %s | %s | %s
 `, eng.board[0][0], eng.board[0][1], eng.board[0][2],
-		eng.board[1][0], eng.board[1][1], eng.board[1][2],
-		eng.board[2][0], eng.board[2][1], eng.board[2][2])
+			eng.board[1][0], eng.board[1][1], eng.board[1][2],
+			eng.board[2][0], eng.board[2][1], eng.board[2][2])
+	})
 }
 
 func (eng *T3Engine) isOver() bool {
@@ -0,0 +1,40 @@
+// Package main is a Go plugin exporting an Engine, see sand.LoadEngine.
+// It's built by:
+//
+//	go build -buildmode=plugin -o greet.so ./example/plugin
+//
+// It doesn't import sand, since the Engine interface is satisfied by
+// the method alone, so it doesn't depend on the version of sand the
+// host is built with.
+//
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// GreetEngine greets whoever is named by the line
+type GreetEngine struct {
+	Greeting string
+}
+
+// Exec writes the greeting for the given line
+func (eng *GreetEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	name := strings.TrimSpace(line)
+	if name == "" {
+		name = "world"
+	}
+	if _, err := io.WriteString(ui, eng.Greeting+", "+name+"!\n"); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// Engine is the symbol looked up by sand.LoadEngine
+var Engine = GreetEngine{Greeting: "hello"}
+
+// main is never called, since this is a plugin, but keeps
+// "go build ./..." from failing on it.
+func main() {}
@@ -8,7 +8,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"unicode/utf8"
 )
 
 // Player represents X or O
@@ -57,15 +56,9 @@ func (eng *T3Engine) Exec(ctx context.Context, line string, ui io.ReadWriter) in
 	for !eng.isOver() {
 		// Print game board
 		eng.printBoard(ui)
-		_, err := ui.Write(nil)
-		if err != nil {
-			log.Println(err)
-			return 1
-		}
 
-		// Next, get user position input
-		b := make([]byte, 2)
-		_, err = ui.Read(b)
+		// Next, get user position input, until it's a free position
+		pos, err := eng.readMove(ui.(*sand.UI))
 		if err == context.Canceled {
 			return 1
 		}
@@ -73,37 +66,10 @@ func (eng *T3Engine) Exec(ctx context.Context, line string, ui io.ReadWriter) in
 			log.Println(err)
 			return 1
 		}
-		r, _ := utf8.DecodeRune(b)
 
 		// Update board
-		switch r {
-		case '1':
-			eng.board[2][0] = curPlayer
-		case '2':
-			eng.board[2][1] = curPlayer
-		case '3':
-			eng.board[2][2] = curPlayer
-		case '4':
-			eng.board[1][0] = curPlayer
-		case '5':
-			eng.board[1][1] = curPlayer
-		case '6':
-			eng.board[1][2] = curPlayer
-		case '7':
-			eng.board[0][0] = curPlayer
-		case '8':
-			eng.board[0][1] = curPlayer
-		case '9':
-			eng.board[0][2] = curPlayer
-		default:
-			select {
-			case <-ctx.Done():
-				return 0
-			default:
-			}
-			fmt.Fprintln(ui, "Invalid position:", r)
-			// TODO: Add retry logic
-		}
+		i := int(pos[0] - '1')
+		eng.board[2-i/3][i%3] = curPlayer
 
 		// Switch players
 		if curPlayer == X {
@@ -125,21 +91,81 @@ func (eng *T3Engine) Exec(ctx context.Context, line string, ui io.ReadWriter) in
 	return 0
 }
 
+// readMove reads positions, a single keypress each, until it's a free
+// position. If the input is a terminal, it's put into raw mode, so the
+// player doesn't have to press enter, and the keypress is echoed.
+func (eng *T3Engine) readMove(ui *sand.UI) (string, error) {
+	restore, err := ui.EnterRawMode()
+	raw := err == nil
+	if raw {
+		defer restore()
+	}
+
+	ui.SuppressPrefix(func() { ui.Printf(">") })
+	for {
+		r, _, err := ui.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n', ' ':
+			continue
+		case 0x04: // Ctrl-D, which isn't turned into EOF in raw mode
+			return "", io.EOF
+		}
+
+		pos := string(r)
+		if raw {
+			ui.SuppressPrefix(func() { ui.Println(pos) })
+		} else if err = skipLine(ui); err != nil {
+			return "", err
+		}
+
+		verr := eng.validPosition(pos)
+		if verr == nil {
+			return pos, nil
+		}
+		ui.SuppressPrefix(func() { ui.Printf("%s\n>", verr) })
+	}
+}
+
+// skipLine discards the rest of a line, since input that isn't from a
+// terminal in raw mode still comes in lines.
+func skipLine(ui *sand.UI) error {
+	for {
+		r, _, err := ui.ReadRune()
+		if err != nil || r == '\n' {
+			return err
+		}
+	}
+}
+
+// validPosition checks that pos is one of the free positions, 1 through 9,
+// laid out like a numpad.
+func (eng *T3Engine) validPosition(pos string) error {
+	if len(pos) != 1 || pos[0] < '1' || pos[0] > '9' {
+		return fmt.Errorf("Invalid position: %q", pos)
+	}
+
+	i := int(pos[0] - '1')
+	if eng.board[2-i/3][i%3] != Nan {
+		return fmt.Errorf("Position %s is taken", pos)
+	}
+	return nil
+}
+
 func (eng *T3Engine) printBoard(w io.Writer) {
 	ui, _ := w.(*sand.UI)
-	ui.SetPrefix("")
-	defer func() {
-		ui.SetPrefix(">")
-	}()
-
-	fmt.Fprintf(w, ` %s | %s | %s
+	ui.SuppressPrefix(func() {
+		fmt.Fprintf(w, ` %s | %s | %s
 -----------
  %s | %s | %s
 -----------
  %s | %s | %s
 `, eng.board[0][0], eng.board[0][1], eng.board[0][2],
-		eng.board[1][0], eng.board[1][1], eng.board[1][2],
-		eng.board[2][0], eng.board[2][1], eng.board[2][2])
+			eng.board[1][0], eng.board[1][1], eng.board[1][2],
+			eng.board[2][0], eng.board[2][1], eng.board[2][2])
+	})
 }
 
 func (eng *T3Engine) isOver() bool {
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/Zaba505/sand"
+	"github.com/Zaba505/sand/sandtest"
+	"strings"
+	"testing"
+)
+
+func TestT3Engine(t *testing.T) {
+	it := sandtest.NewInteraction().Expect(">").Send("tictactoe\n")
+	for _, move := range []string{"1", "4", "2", "5"} {
+		it.Expect("-----------").Expect(">").Send(move + "\n")
+	}
+
+	// Invalid positions are asked for again
+	it.Expect(">").Send("5\n").Expect("Position 5 is taken\n>")
+	it.Send("x\n").Expect("Invalid position: \"x\"\n>")
+	it.Send("3\n").Expect("Player X won!\n")
+
+	res := it.Run(nil, new(T3Engine), sand.WithPrefix(">"))
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+
+	board := " O | O |  \n-----------\n X | X | X\n"
+	if !strings.Contains(res.Output, board) {
+		t.Errorf("expected final board %q in output but instead received: %q", board, res.Output)
+	}
+}
@@ -0,0 +1,117 @@
+package sand
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// cmdOutKey is the context key for the output state of a command
+// executed beside the current one, see cmdOutput.
+//
+type cmdOutKey struct{}
+
+// cmdOutput is the output state of a command executed beside the
+// current one, i.e. a background job or one for ExecReader, so
+// executing it leaves the state of the current command as is.
+//
+type cmdOutput struct {
+	w    io.Writer    // the pipe of ExecReader, or nil to write to the UI
+	n    int64        // bytes written, see WithMaxOutputPerCommand
+	wrap *wordWrapper // see WithWordWrap
+}
+
+// execOutput is the ReadWriter given to an Engine executing a line
+// beside the current command. It reads from the UI and writes to the
+// pipe of ExecReader, or else to the UI.
+//
+type execOutput struct {
+	*UI
+	out *cmdOutput
+}
+
+func (o execOutput) Write(b []byte) (int, error) {
+	if o.out.w != nil {
+		return o.out.w.Write(b)
+	}
+	return o.UI.writeOutput(&o.out.n, o.out.wrap, b)
+}
+
+// engineIO returns the ReadWriter to give to the Engine, which is the
+// UI itself unless the line is executed beside the current command.
+//
+func (ui *UI) engineIO(ctx context.Context) io.ReadWriter {
+	if out, ok := ctx.Value(cmdOutKey{}).(*cmdOutput); ok {
+		return execOutput{UI: ui, out: out}
+	}
+	return ui
+}
+
+// CommandOutput is the output of a command executed by ExecReader.
+//
+type CommandOutput struct {
+	pr     *io.PipeReader
+	done   chan struct{}
+	status int
+}
+
+// Read reads the output of the command, as it's written, until the
+// command returns, at which point io.EOF is returned.
+//
+func (o *CommandOutput) Read(b []byte) (int, error) {
+	return o.pr.Read(b)
+}
+
+// Close discards the rest of the output. Any further writes by the
+// Engine fail with io.ErrClosedPipe.
+//
+func (o *CommandOutput) Close() error {
+	return o.pr.Close()
+}
+
+// Status waits for the command to return and returns its status,
+// which, unlike a command read by Run, never ends the session.
+//
+func (o *CommandOutput) Status() int {
+	<-o.done
+	return o.status
+}
+
+// ExecReader executes the line, e.g. for an embedder to pipe the
+// output of one command into another, and returns the output as a
+// Reader instead of writing it to the UI. The Reader is a
+// *CommandOutput, whose Status method returns the status of the
+// command.
+//
+// The same as for background jobs, see WithJobs, the line is passed
+// to the Engine given to Run directly, so it may be called from
+// within Exec, but the Engine must be safe for concurrent use. Only
+// what the Engine writes to the ReadWriter given to Exec, or returns
+// as a Result or output, is part of the output and it's never
+// prefixed or filtered. The Engine blocks on writing until the
+// output is read, so it should be read until io.EOF, or else closed.
+//
+func (ui *UI) ExecReader(line string) (io.Reader, error) {
+	ui.mu.Lock()
+	running := ui.reqCh != nil
+	ui.mu.Unlock()
+	if !running {
+		return nil, errors.New("sand: executing a command needs a running session")
+	}
+
+	pr, pw := io.Pipe()
+	o := &CommandOutput{pr: pr, done: make(chan struct{})}
+	ui.mu.Lock()
+	eng := ui.wrapped
+	ui.mu.Unlock()
+	go func() {
+		defer close(o.done)
+		ctx := context.WithValue(ui.ctx, cmdOutKey{}, &cmdOutput{w: pw})
+		o.status = 1
+		if ui.authorize(ctx, line) {
+			o.status = ui.execEngine(ctx, eng, line)
+		}
+		pw.Close()
+	}()
+	return o, nil
+}
@@ -0,0 +1,107 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testPipeEngine echos lines, except for "fail", which fails with
+// status 3, and "pipe" lines, which execute the rest of the line
+// through ExecReader and write its output in upper case, along
+// with its status, or "drop" lines, which discard the output.
+//
+type testPipeEngine struct{}
+
+func (testPipeEngine) Exec(ctx context.Context, line string, rw io.ReadWriter) int {
+	line = strings.TrimSpace(line)
+	if line == "fail" {
+		rw.Write([]byte("oops\n"))
+		return 3
+	}
+	cmd := strings.SplitN(line, " ", 2)
+	if len(cmd) < 2 || cmd[0] != "pipe" && cmd[0] != "drop" {
+		rw.Write([]byte(line + "\n"))
+		return 0
+	}
+
+	r, err := rw.(*UI).ExecReader(cmd[1])
+	if err != nil {
+		return 1
+	}
+	if cmd[0] == "drop" {
+		r.(*CommandOutput).Close()
+		status := r.(*CommandOutput).Status()
+		rw.Write([]byte(strconv.Itoa(status) + "\n"))
+		return 0
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 1
+	}
+	status := r.(*CommandOutput).Status()
+	rw.Write([]byte(strings.ToUpper(string(b)) + strconv.Itoa(status) + "\n"))
+	return 0
+}
+
+func TestUI_ExecReader(t *testing.T) {
+	ui := new(UI)
+	if _, err := ui.ExecReader("a"); err == nil {
+		t.Errorf("expected ExecReader to fail when not running")
+	}
+
+	testCases := []struct {
+		Name string
+		In   string
+		Ex   string
+	}{
+		{Name: "Output", In: "pipe hello\n", Ex: "HELLO\n0\n\n"},
+		{Name: "Status", In: "pipe fail\n", Ex: "OOPS\n3\n\n"},
+		{Name: "Close", In: "drop fail\n", Ex: "3\n\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			err := Run(nil, testPipeEngine{}, WithIO(strings.NewReader(tc.In), &out))
+			if err != nil && err != io.EOF {
+				subT.Fatal(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestUI_ExecReader_OutputLimit(t *testing.T) {
+	eng := EngineFunc(func(ctx context.Context, line string, rw io.ReadWriter) int {
+		if line != "pipe" {
+			rw.Write([]byte("piped\n"))
+			return 0
+		}
+		rw.Write([]byte("abcdef"))
+		r, err := rw.(*UI).ExecReader("echo")
+		if err != nil {
+			return 1
+		}
+		ioutil.ReadAll(r)
+		rw.Write([]byte("ghij"))
+		return 0
+	})
+
+	// The piped command mustn't restart the count of the current one
+	var out bytes.Buffer
+	err := Run(nil, eng, WithIO(strings.NewReader("pipe\n"), &out), WithMaxOutputPerCommand(8))
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if ex := "abcdefgh" + truncatedNotice + "\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
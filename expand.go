@@ -0,0 +1,238 @@
+package sand
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// VarExpansion configures how WithVarExpansion expands variables.
+//
+type VarExpansion struct {
+	// Env makes variables which aren't set in the session
+	// fall back to the environment of the process.
+	Env bool
+
+	// KeepUnknown leaves references to unknown variables as is,
+	// instead of expanding them to nothing.
+	KeepUnknown bool
+
+	// Commands expands $(cmd) to the output of executing cmd, with
+	// trailing newlines removed, instead of writing it, e.g. for
+	// "set x $(status)". Substitutions are expanded before cmd is
+	// executed and may be nested up to MaxSubstitutionDepth deep.
+	Commands bool
+}
+
+// MaxSubstitutionDepth is how deep command substitutions may be nested.
+const MaxSubstitutionDepth = 8
+
+// ErrSubstitutionDepth is returned when command substitutions are
+// nested deeper than MaxSubstitutionDepth, e.g. by a command which
+// substitutes itself.
+//
+var ErrSubstitutionDepth = errors.New("sand: command substitutions are nested too deeply")
+
+// WithVarExpansion expands references to session variables, $VAR
+// and ${VAR}, in every line before it is executed, see Set. Like a
+// shell, variables aren't expanded within single quotes, but are
+// within double quotes, and "\$" results in a literal "$". The
+// quotes themselves are left in the line for the engine.
+//
+func WithVarExpansion(cfg VarExpansion) Option {
+	return func(ui *UI) {
+		ui.expansion = &cfg
+	}
+}
+
+// lookupVar returns the value of the variable, as configured by the expansion.
+func (ui *UI) lookupVar(name string) (string, bool) {
+	if v, ok := ui.Get(name); ok {
+		return v, true
+	}
+	if ui.expansion.Env {
+		return os.LookupEnv(name)
+	}
+	return "", false
+}
+
+// expandVars expands the variables referenced in line, along with
+// any command substitutions.
+//
+func (ui *UI) expandVars(line string) (string, error) {
+	return ui.expandDepth(line, 0)
+}
+
+// expandDepth expands line, which is within depth substitutions.
+func (ui *UI) expandDepth(line string, depth int) (string, error) {
+	lookup := func(name, ref string) string {
+		v, ok := ui.lookupVar(name)
+		if !ok && ui.expansion.KeepUnknown {
+			return ref
+		}
+		return v
+	}
+	var subst func(string) (string, error)
+	if ui.expansion.Commands {
+		subst = func(cmd string) (string, error) { return ui.substitute(cmd, depth+1) }
+	}
+	return expand(line, lookup, subst)
+}
+
+// ExpandVars expands references to variables, $VAR and ${VAR}, in
+// line the same as WithVarExpansion, except without command
+// substitution. Variables are looked up by lookup and unknown ones
+// expand to nothing.
+//
+func ExpandVars(line string, lookup func(name string) (string, bool)) string {
+	line, _ = expand(line, func(name, ref string) string {
+		v, _ := lookup(name)
+		return v
+	}, nil)
+	return line
+}
+
+// expand expands the variable references in line to what lookup
+// returns for them, given their name and the whole reference, and,
+// unless subst is nil, command substitutions to what subst returns
+// for the command.
+//
+func expand(line string, lookup func(name, ref string) string, subst func(cmd string) (string, error)) (string, error) {
+	if strings.IndexByte(line, '$') == -1 {
+		return line, nil
+	}
+
+	var buf bytes.Buffer
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\'' && quote != '"':
+			quote ^= '\''
+		case c == '"' && quote != '\'':
+			quote ^= '"'
+		case c == '\\' && quote != '\'' && i+1 < len(line):
+			i++
+			if line[i] != '$' {
+				buf.WriteByte(c)
+			}
+			c = line[i]
+		case c == '$' && quote != '\'' && subst != nil && strings.HasPrefix(line[i:], "$("):
+			end := substitutionEnd(line[i:])
+			if end == -1 {
+				break
+			}
+
+			out, err := subst(line[i+2 : i+end])
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(out)
+			i += end
+			continue
+		case c == '$' && quote != '\'':
+			name, ref := varRef(line[i:])
+			if ref == "" {
+				break
+			}
+			i += len(ref) - 1
+			buf.WriteString(lookup(name, ref))
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String(), nil
+}
+
+// substitutionEnd returns the index of the parenthesis closing the
+// substitution at the start of s, which begins with "$(", or -1 if
+// it isn't closed.
+//
+func substitutionEnd(s string) int {
+	open := 0
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			open++
+		case ')':
+			open--
+			if open == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// substitute executes cmd, after expanding it, and returns its
+// output instead of writing it.
+//
+func (ui *UI) substitute(cmd string, depth int) (string, error) {
+	if depth > MaxSubstitutionDepth {
+		return "", ErrSubstitutionDepth
+	}
+	cmd, err := ui.expandDepth(cmd, depth)
+	if err != nil {
+		return "", err
+	}
+
+	ui.mu.Lock()
+	reqCh := ui.reqCh
+	ui.mu.Unlock()
+	if reqCh == nil {
+		return "", errors.New("sand: command substitution needs a running session")
+	}
+
+	// Capture everything written, without the prefix
+	var buf bytes.Buffer
+	out := ui.out
+	ui.out = &buf
+	atomic.AddInt32(&ui.noPrefix, 1)
+	defer func() {
+		atomic.AddInt32(&ui.noPrefix, -1)
+		ui.out = out
+	}()
+
+	if _, ok := ui.execBuiltin(ui.ctx, cmd); !ok {
+		ui.exec(ui.ctx, cmd, reqCh)
+	}
+	return strings.TrimRight(buf.String(), "\r\n"), nil
+}
+
+// varRef parses the variable reference at the start of s, which
+// begins with a "$", and returns the name along with the whole
+// reference. The reference is empty if s doesn't start with one.
+//
+func varRef(s string) (name, ref string) {
+	if strings.HasPrefix(s, "${") {
+		end := strings.IndexByte(s, '}')
+		if end == -1 || !isVarName(s[2:end]) {
+			return "", ""
+		}
+		return s[2:end], s[:end+1]
+	}
+
+	n := 1
+	for n < len(s) && isVarByte(s[n], n == 1) {
+		n++
+	}
+	if n == 1 {
+		return "", ""
+	}
+	return s[1:n], s[:n]
+}
+
+func isVarName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isVarByte(s[i], i == 0) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isVarByte(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
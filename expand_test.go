@@ -0,0 +1,138 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUI_ExpandVars(t *testing.T) {
+	os.Setenv("SAND_TEST_HOME", "/home/sand")
+	defer os.Unsetenv("SAND_TEST_HOME")
+
+	testCases := []struct {
+		Name string
+		Cfg  VarExpansion
+		In   string
+		Ex   string
+	}{
+		{Name: "Plain", In: "echo hi\n", Ex: "echo hi\n"},
+		{Name: "Simple", In: "echo $name!\n", Ex: "echo bob!\n"},
+		{Name: "Braces", In: "echo ${name}by\n", Ex: "echo bobby\n"},
+		{Name: "Adjacent", In: "$name$n_2\n", Ex: "bob2\n"},
+		{Name: "DoubleQuotes", In: `say "hi $name"`, Ex: `say "hi bob"`},
+		{Name: "SingleQuotes", In: `say 'hi $name'`, Ex: `say 'hi $name'`},
+		{Name: "SingleInDouble", In: `say "'$name'"`, Ex: `say "'bob'"`},
+		{Name: "DoubleInSingle", In: `say '"$name"'`, Ex: `say '"$name"'`},
+		{Name: "Escaped", In: `cost \$name`, Ex: `cost $name`},
+		{Name: "EscapedInDouble", In: `"\${name}"`, Ex: `"${name}"`},
+		{Name: "EscapedQuote", In: `\'$name`, Ex: `\'bob`},
+		{Name: "OtherEscape", In: `a\tb`, Ex: `a\tb`},
+		{Name: "LoneDollar", In: "$ 5 $", Ex: "$ 5 $"},
+		{Name: "Unclosed", In: "${name", Ex: "${name"},
+		{Name: "BadBraces", In: "${a-b}", Ex: "${a-b}"},
+		{Name: "Unknown", In: "[$nope][${nope}]", Ex: "[][]"},
+		{Name: "KeepUnknown", Cfg: VarExpansion{KeepUnknown: true}, In: "[$nope][${nope}]", Ex: "[$nope][${nope}]"},
+		{Name: "NoEnv", In: "$SAND_TEST_HOME", Ex: ""},
+		{Name: "Env", Cfg: VarExpansion{Env: true}, In: "$SAND_TEST_HOME", Ex: "/home/sand"},
+		{Name: "SessionBeforeEnv", Cfg: VarExpansion{Env: true}, In: "$name", Ex: "bob"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := &UI{expansion: &tc.Cfg}
+			ui.Set("name", "bob")
+			ui.Set("n_2", "2")
+
+			if s, _ := ui.expandVars(tc.In); s != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, s)
+			}
+		})
+	}
+}
+
+func TestRunWithVarExpansion(t *testing.T) {
+	in := &testLineReader{lines: []string{"set x 1\n", "echo $x\n"}}
+	var out bytes.Buffer
+
+	err := Run(nil, new(testEchoEngine), WithIO(in, &out), WithVarBuiltins(), WithVarExpansion(VarExpansion{}))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if out.String() != "echo 1\n\n" {
+		t.Errorf("expected %q but instead received: %q", "echo 1\n\n", out.String())
+	}
+}
+
+func TestRunWithCommandSubstitution(t *testing.T) {
+	deep := strings.Repeat("$(", MaxSubstitutionDepth+1) + "x" + strings.Repeat(")", MaxSubstitutionDepth+1)
+
+	testCases := []struct {
+		Name  string
+		Lines []string
+		Ex    string
+	}{
+		{Name: "Capture", Lines: []string{"set x $(hello world)\n", "echo [$x]\n"}, Ex: "echo [hello world]\n\n"},
+		{Name: "Nested", Lines: []string{"echo $(a $(b))\n"}, Ex: "echo a b\n\n"},
+		{Name: "Quoted", Lines: []string{"echo '$(a)'\n"}, Ex: "echo '$(a)'\n\n"},
+		{Name: "Unclosed", Lines: []string{"echo $(a\n"}, Ex: "echo $(a\n\n"},
+		{Name: "TooDeep", Lines: []string{"echo " + deep + "\n"}, Ex: ErrSubstitutionDepth.Error() + "\n\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: tc.Lines}
+			var out bytes.Buffer
+
+			err := Run(nil, new(testEchoEngine), WithIO(in, &out), WithVarBuiltins(), WithVarExpansion(VarExpansion{Commands: true}))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"x": "1", "name": "sand"}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	line := `echo $x ${name} '$x' "$x" \$x $unknown $(cmd)`
+	ex := `echo 1 sand '$x' "1" $x  $(cmd)`
+	if s := ExpandVars(line, lookup); s != ex {
+		t.Errorf("expected %q but instead received: %q", ex, s)
+	}
+}
+
+func FuzzExpandVars(f *testing.F) {
+	for _, seed := range []string{
+		"", "$x", "${x}", "${x", "$", "$$", `\$x`, `'$x'`, `"$x"`, `"'$x'"`, `'"$x"'`,
+		"$(x)", "$(($x))", `a\`, "${}", "${1x}", "$x$x${x}", "世界$x",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		s := ExpandVars(line, func(string) (string, bool) { return "v", true })
+
+		// Lines without references are left as is
+		if strings.IndexByte(line, '$') == -1 && s != line {
+			t.Errorf("expected %q to be left as is but instead received: %q", line, s)
+		}
+
+		// Variables expanding to themselves are stable
+		again := ExpandVars(line, func(name string) (string, bool) { return "$" + name, true })
+		if strings.IndexByte(line, '\\') == -1 && strings.IndexByte(again, '$') == -1 && again != line {
+			t.Errorf("expected %q to be stable but instead received: %q", line, again)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// DefaultFanoutLimit is the number of units of a line executed
+// concurrently by default, see FanoutEngine.
+const DefaultFanoutLimit = 4
+
+// FanoutEngine is implemented by Engines with commands whose
+// arguments are independent units, e.g. "ping host1 host2 host3".
+// The UI executes the units of such a line concurrently, instead of
+// calling Exec, and writes the output of every unit in the order of
+// the units, each one only once it is done, so the output of units
+// is never interleaved.
+//
+type FanoutEngine interface {
+	Engine
+
+	// Fanout splits the line into its units, or returns nil if the
+	// line isn't fanned out, in which case Exec is called as usual.
+	Fanout(line string) []string
+
+	// ExecUnit executes a single unit, writing its output to w.
+	ExecUnit(ctx context.Context, unit string, w io.Writer) (status int)
+}
+
+// WithExecConcurrencyPerLine specifies the maximum number of units
+// of a line executed concurrently, see FanoutEngine. The default is
+// DefaultFanoutLimit.
+//
+func WithExecConcurrencyPerLine(n int) Option {
+	return func(ui *UI) {
+		ui.fanout = n
+	}
+}
+
+// fanoutUnit is the outcome of executing a single unit.
+type fanoutUnit struct {
+	out    bytes.Buffer
+	status int
+	done   chan struct{}
+}
+
+// execFanout executes the units with bounded concurrency, writing
+// their output to w, and returns the status of the first unit that
+// failed, in the order of the units, or 0 if none did.
+//
+func (ui *UI) execFanout(ctx context.Context, eng FanoutEngine, units []string, w io.Writer) int {
+	limit := ui.fanout
+	if limit <= 0 {
+		limit = DefaultFanoutLimit
+	}
+
+	results := make([]*fanoutUnit, len(units))
+	for i := range results {
+		results[i] = &fanoutUnit{done: make(chan struct{})}
+	}
+	go func() {
+		sem := make(chan struct{}, limit)
+		for i, unit := range units {
+			sem <- struct{}{}
+			go func(res *fanoutUnit, unit string) {
+				defer func() { <-sem }()
+				defer close(res.done)
+				res.status = eng.ExecUnit(ctx, unit, &res.out)
+			}(results[i], unit)
+		}
+	}()
+
+	status := 0
+	for _, res := range results {
+		<-res.done
+		if res.out.Len() > 0 {
+			if _, err := w.Write(res.out.Bytes()); err != nil && status == 0 {
+				status = 1
+			}
+		}
+		if res.status != 0 && status == 0 {
+			status = res.status
+		}
+	}
+	return status
+}
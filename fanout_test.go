@@ -0,0 +1,90 @@
+package sand
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPingEngine fans out "ping" to every host, the later hosts
+// finishing first, and fails for the host "down".
+type testPingEngine struct {
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (eng *testPingEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	_, err := ui.Write([]byte(line + "\n"))
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func (eng *testPingEngine) Fanout(line string) []string {
+	args := strings.Fields(line)
+	if len(args) < 2 || args[0] != "ping" {
+		return nil
+	}
+	return args[1:]
+}
+
+func (eng *testPingEngine) ExecUnit(ctx context.Context, host string, w io.Writer) int {
+	eng.mu.Lock()
+	eng.running++
+	if eng.running > eng.max {
+		eng.max = eng.running
+	}
+	delay := time.Duration(10-len(host)) * 5 * time.Millisecond
+	eng.mu.Unlock()
+	defer func() {
+		eng.mu.Lock()
+		eng.running--
+		eng.mu.Unlock()
+	}()
+
+	time.Sleep(delay)
+	if host == "down" {
+		io.WriteString(w, host+" unreachable\n")
+		return 2
+	}
+	io.WriteString(w, "pong "+host+"\n")
+	return 0
+}
+
+func TestFanoutEngine(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Line  string
+		Limit int
+		Ex    string
+	}{
+		{Name: "NotFannedOut", Line: "echo\n", Ex: ">>echo\n>\n"},
+		{Name: "InOrder", Line: "ping a bb ccc\n", Ex: ">>pong a\n>pong bb\n>pong ccc\n>\n"},
+		{Name: "Limit", Line: "ping a bb ccc dddd\n", Limit: 2, Ex: ">>pong a\n>pong bb\n>pong ccc\n>pong dddd\n>\n"},
+		{Name: "Failed", Line: "ping a down ccc\n", Ex: ">>pong a\n>down unreachable\n>pong ccc\n\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			eng := new(testPingEngine)
+			out := runBuiltinTest(subT, eng, tc.Line, WithExecConcurrencyPerLine(tc.Limit))
+			if out != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out)
+			}
+
+			limit := tc.Limit
+			if limit == 0 {
+				limit = DefaultFanoutLimit
+			}
+			if eng.max > limit {
+				subT.Errorf("expected at most %d units at once but instead received: %d", limit, eng.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package sand
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fields splits line into words separated by whitespace, like a
+// shell, e.g. for engines parsing their arguments. Within single
+// quotes, everything is literal. Within double quotes, a backslash
+// escapes a double quote or another backslash. Outside of quotes, a
+// backslash escapes any character. The quotes and escaping backslashes
+// themselves are removed, so "a 'b c'" results in "a" and "b c".
+//
+// A quote which isn't closed, or a backslash ending the line, results
+// in a *ParseError pointing at it, along with the fields as if the
+// quote extended to the end of the line, or the backslash were literal.
+//
+func Fields(line string) ([]string, error) {
+	fields, err := parseFields(line)
+	if err != nil {
+		return fields, err
+	}
+	return fields, nil
+}
+
+// parseFields splits line as documented by Fields.
+func parseFields(line string) ([]string, *ParseError) {
+	var fields []string
+	var cur strings.Builder
+	inField := false
+	var quote rune
+	var quotePos, escapePos int
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && r != '"' && r != '\\' {
+				cur.WriteByte('\\')
+			}
+		case r == '\\' && quote != '\'':
+			escaped, inField = true, true
+			escapePos = i
+			continue
+		case quote != 0 && r == quote:
+			quote = 0
+			continue
+		case quote == 0 && (r == '\'' || r == '"'):
+			quote, inField = r, true
+			quotePos = i
+			continue
+		case quote == 0 && unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+			continue
+		}
+		cur.WriteRune(r)
+		inField = true
+	}
+	if escaped {
+		cur.WriteByte('\\')
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+
+	switch {
+	case quote != 0:
+		return fields, &ParseError{Line: line, Pos: quotePos, Msg: "unterminated quote"}
+	case escaped:
+		return fields, &ParseError{Line: line, Pos: escapePos, Msg: "unterminated escape"}
+	}
+	return fields, nil
+}
+
+// ParseError is a syntax error in a line, e.g. a quote which isn't
+// closed, see Fields. Along with CaretLine, it allows pointing
+// at the offending character of the line.
+//
+type ParseError struct {
+	// Line is the line which failed to parse.
+	Line string
+
+	// Pos is the byte offset of the offending character in Line.
+	Pos int
+
+	// Msg describes the error.
+	Msg string
+}
+
+// Error returns the message along with the column, counted in runes
+// starting at 1, of the offending character.
+//
+func (e *ParseError) Error() string {
+	col := utf8.RuneCountInString(e.Line[:e.Pos]) + 1
+	return fmt.Sprintf("sand: %s at column %d", e.Msg, col)
+}
+
+// CaretLine returns a line with a caret, "^", under the character of
+// line at the byte offset pos, e.g. for writing below line to point
+// at a ParseError. Tabs in line are kept, so the caret lines up when
+// both are written to a terminal, and wide characters are accounted
+// for.
+//
+func CaretLine(line string, pos int) string {
+	if pos > len(line) {
+		pos = len(line)
+	}
+
+	var b strings.Builder
+	for _, r := range line[:pos] {
+		if r == '\t' {
+			b.WriteByte('\t')
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", runeWidth(r)))
+	}
+	b.WriteByte('^')
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package sand
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestFields(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Line string
+		Ex   []string
+	}{
+		{Name: "Empty", Line: "", Ex: nil},
+		{Name: "Blank", Line: "  \n", Ex: nil},
+		{Name: "Words", Line: " a  b\tc\n", Ex: []string{"a", "b", "c"}},
+		{Name: "SingleQuotes", Line: `a 'b  c' 'd\e'`, Ex: []string{"a", "b  c", `d\e`}},
+		{Name: "DoubleQuotes", Line: `a "b \"c\" \\ \d"`, Ex: []string{"a", `b "c" \ \d`}},
+		{Name: "Adjacent", Line: `a'b'"c"d`, Ex: []string{"abcd"}},
+		{Name: "NestedQuotes", Line: `deploy "my 'app'" 'the "best" one' --env=prod`, Ex: []string{"deploy", "my 'app'", `the "best" one`, "--env=prod"}},
+		{Name: "EmptyQuotes", Line: `a '' ""`, Ex: []string{"a", "", ""}},
+		{Name: "Escapes", Line: `a\ b \'c`, Ex: []string{"a b", "'c"}},
+		{Name: "Unterminated", Line: `a "b c`, Ex: []string{"a", "b c"}},
+		{Name: "TrailingBackslash", Line: `a\`, Ex: []string{`a\`}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			if fields, _ := Fields(tc.Line); !reflect.DeepEqual(fields, tc.Ex) {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, fields)
+			}
+		})
+	}
+}
+
+func FuzzFields(f *testing.F) {
+	for _, seed := range []string{
+		"", "a b c", `a 'b c' "d e"`, `"a \"b\" c"`, `'it'\''s'`, `a\ b`, `"unterminated`,
+		`'unterminated`, `\`, `"\\"`, "a\tb\nc", `"" ''`, `a"b"'c'd`, "世界 'こんにちは 世界'",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		fields, _ := Fields(line)
+
+		// Joining simple words and splitting them again is stable
+		simple := true
+		for _, field := range fields {
+			if field == "" || strings.ContainsAny(field, "'\"\\") || strings.IndexFunc(field, unicode.IsSpace) != -1 {
+				simple = false
+				break
+			}
+		}
+		if !simple {
+			return
+		}
+		if again, _ := Fields(strings.Join(fields, " ")); !reflect.DeepEqual(again, fields) {
+			t.Errorf("expected %q to be stable but instead received: %q", fields, again)
+		}
+	})
+}
+
+func TestFields_ParseError(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Line string
+		Pos  int
+		Err  string
+	}{
+		{Name: "Empty", Line: "", Pos: -1},
+		{Name: "Valid", Line: `a 'b c' "d" e\ f`, Pos: -1},
+		{Name: "UnterminatedDouble", Line: `a "b c`, Pos: 2, Err: "sand: unterminated quote at column 3"},
+		{Name: "UnterminatedSingle", Line: `ab 'c\`, Pos: 3, Err: "sand: unterminated quote at column 4"},
+		{Name: "LastQuoteUnterminated", Line: `'a' "b" 'c`, Pos: 8, Err: "sand: unterminated quote at column 9"},
+		{Name: "TrailingBackslash", Line: `a b\`, Pos: 3, Err: "sand: unterminated escape at column 4"},
+		{Name: "Multibyte", Line: `héllo "wörld`, Pos: 7, Err: "sand: unterminated quote at column 7"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			_, err := Fields(tc.Line)
+			if tc.Pos < 0 {
+				if err != nil {
+					subT.Errorf("expected no error but instead received: %v", err)
+				}
+				return
+			}
+
+			perr, ok := err.(*ParseError)
+			if !ok {
+				subT.Fatalf("expected a *ParseError but instead received: %v", err)
+			}
+			if perr.Pos != tc.Pos {
+				subT.Errorf("expected position %d but instead received: %d", tc.Pos, perr.Pos)
+			}
+			if perr.Error() != tc.Err {
+				subT.Errorf("expected %q but instead received: %q", tc.Err, perr.Error())
+			}
+		})
+	}
+}
+
+func TestCaretLine(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Line string
+		Pos  int
+		Ex   string
+	}{
+		{Name: "Start", Line: `"abc`, Pos: 0, Ex: "^"},
+		{Name: "Middle", Line: `a "b`, Pos: 2, Ex: "  ^"},
+		{Name: "Tab", Line: "a\t\"b", Pos: 2, Ex: " \t^"},
+		{Name: "Wide", Line: `世界 "b`, Pos: 7, Ex: "     ^"},
+		{Name: "PastEnd", Line: `ab`, Pos: 5, Ex: "  ^"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			if caret := CaretLine(tc.Line, tc.Pos); caret != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, caret)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package sand
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FileCompleter returns a CandidateLineCompleter completing the word
+// before the cursor as the path of a file, relative to dir, or the
+// working directory if dir is empty, see WithCandidateCompleter.
+// Directories are completed with a trailing slash and hidden files
+// are only candidates once the word starts with a dot. Directories,
+// symlinks and executables are described as such.
+//
+func FileCompleter(dir string) CandidateLineCompleter {
+	return func(line string, pos int) ([]Candidate, int) {
+		from := strings.LastIndexFunc(line[:pos], unicode.IsSpace) + 1
+		word := line[from:pos]
+		i := strings.LastIndexByte(word, '/') + 1
+		parent, base := word[:i], word[i:]
+
+		path := parent
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, from
+		}
+
+		var cands []Candidate
+		for _, fi := range infos {
+			name := fi.Name()
+			if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+				continue
+			}
+			c := Candidate{Text: parent + name, Description: describeFile(fi)}
+			if fi.IsDir() {
+				c.Text += "/"
+			}
+			cands = append(cands, c)
+		}
+		sort.Slice(cands, func(i, j int) bool { return cands[i].Text < cands[j].Text })
+		return cands, from
+	}
+}
+
+// describeFile describes the file, if it's a directory, symlink or
+// executable, for completing its path, see FileCompleter.
+//
+func describeFile(fi os.FileInfo) string {
+	switch mode := fi.Mode(); {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&0111 != 0:
+		return "executable"
+	}
+	return ""
+}
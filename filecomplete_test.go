@@ -0,0 +1,54 @@
+package sand
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileCompleter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, mode := range map[string]os.FileMode{"main.go": 0644, "make.sh": 0755, ".hidden": 0644, "sub/nested.txt": 0644} {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		Name   string
+		Line   string
+		Ex     []Candidate
+		ExFrom int
+	}{
+		{Name: "All", Line: "cat ", Ex: []Candidate{{"main.go", ""}, {"make.sh", "executable"}, {"sub/", "directory"}}, ExFrom: 4},
+		{Name: "Prefix", Line: "cat ma", Ex: []Candidate{{"main.go", ""}, {"make.sh", "executable"}}, ExFrom: 4},
+		{Name: "Hidden", Line: "cat .h", Ex: []Candidate{{".hidden", ""}}, ExFrom: 4},
+		{Name: "Nested", Line: "vi sub/n", Ex: []Candidate{{"sub/nested.txt", ""}}, ExFrom: 3},
+		{Name: "Missing", Line: "cat nope/", Ex: nil, ExFrom: 4},
+	}
+
+	complete := FileCompleter(dir)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			cands, from := complete(tc.Line, len(tc.Line))
+			if !reflect.DeepEqual(cands, tc.Ex) {
+				subT.Errorf("expected %v but instead received: %v", tc.Ex, cands)
+			}
+			if from != tc.ExFrom {
+				subT.Errorf("expected to replace from %d but instead received: %d", tc.ExFrom, from)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package sand
+
+import "io"
+
+// flusher is implemented by Writers which buffer, e.g. bufio.Writer.
+type flusher interface {
+	Flush() error
+}
+
+// WriteNow writes the provided bytes, just like Write, and then flushes
+// every output Writer that buffers, i.e. implements Flush() error. This
+// includes the prompt Writer, any tees and the transcript. Engines producing output over
+// time, e.g. progress or tailing a file, should use WriteNow to make sure
+// each piece of output reaches the user as it's written.
+//
+func (ui *UI) WriteNow(b []byte) (n int, err error) {
+	n, err = ui.Write(b)
+	if err != nil {
+		return
+	}
+
+	flushCh := make(chan error, 1)
+	go ui.flushAsync(flushCh)
+
+	select {
+	case <-ui.ctx.Done():
+		err = ui.ctx.Err()
+	case err = <-flushCh:
+	}
+	return
+}
+
+// flushAsync flushes every output Writer and sends the first error to the given channel
+func (ui *UI) flushAsync(flushCh chan error) {
+	if err := ui.flushBounded(); err != nil {
+		flushCh <- err
+		return
+	}
+
+	ws := append([]io.Writer{ui.output(), ui.promptW}, ui.tees...)
+	if ui.transcript != nil {
+		ws = append(ws, ui.transcript.w)
+	}
+
+	var ferr error
+	for _, w := range ws {
+		f, ok := w.(flusher)
+		if !ok {
+			continue
+		}
+		if err := f.Flush(); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	flushCh <- ferr
+}
@@ -0,0 +1,53 @@
+package sand
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// testStreamEngine writes its parts with WriteNow and records
+// what had reached the underlying buffer after each write.
+type testStreamEngine struct {
+	parts []string
+	buf   *bytes.Buffer
+	seen  []string
+}
+
+func (eng *testStreamEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	for _, part := range eng.parts {
+		if _, err := ui.(*UI).WriteNow([]byte(part)); err != nil {
+			return 1
+		}
+		eng.seen = append(eng.seen, eng.buf.String())
+	}
+	return 0
+}
+
+func TestUI_WriteNow(t *testing.T) {
+	var buf bytes.Buffer
+	out := bufio.NewWriterSize(&buf, 4096)
+
+	eng := &testStreamEngine{
+		parts: []string{"one\n", "two\n", "three\n"},
+		buf:   &buf,
+	}
+
+	err := Run(nil, eng, WithIO(bytes.NewReader([]byte("stream")), out))
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := []string{"one\n", "one\ntwo\n", "one\ntwo\nthree\n"}
+	if len(eng.seen) != len(ex) {
+		t.Fatalf("expected %d writes but instead received: %d", len(ex), len(eng.seen))
+	}
+	for i := range ex {
+		if eng.seen[i] != ex[i] {
+			t.Errorf("expected %q to be flushed after write %d but instead received: %q", ex[i], i, eng.seen[i])
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+	"sync/atomic"
+)
+
+// Framer decodes the messages of a byte stream, e.g. of a binary
+// protocol, see WithFramer.
+//
+type Framer interface {
+	// Frame returns the first message of buf, along with the number
+	// of bytes it takes up, including any header or delimiter, or
+	// n == 0 if buf doesn't hold a complete message yet. atEOF is
+	// set once no more input follows buf. An error ends the session.
+	Frame(buf []byte, atEOF bool) (n int, msg []byte, err error)
+}
+
+// WithFramer makes the UI split its input into messages decoded by
+// f, instead of taking whatever each read returns as a line, e.g. for
+// a length prefixed protocol, see LengthPrefixFramer. Each message is
+// executed as a line, as is, i.e. neither truncated at a NUL byte nor
+// with \r\n replaced, and without a line terminator being added.
+// Empty messages are skipped. Like any other read, waiting on a
+// message is interrupted once the session is done. Input from
+// AddInput isn't affected.
+//
+func WithFramer(f Framer) Option {
+	return func(ui *UI) {
+		ui.framer = f
+	}
+}
+
+// ErrFrameTooLarge is returned by LengthPrefixFramer when a message is
+// longer than allowed.
+//
+var ErrFrameTooLarge = errors.New("sand: framed message is too large")
+
+// LengthPrefixFramer is a Framer for messages prefixed by their length,
+// as an unsigned integer of Size bytes in the given byte order, e.g. the
+// zero value decodes messages prefixed by a 4 byte big endian length.
+//
+type LengthPrefixFramer struct {
+	// Size is the number of bytes of the length, either 1, 2, 4 or 8,
+	// and defaults to 4.
+	Size int
+
+	// Order is the byte order of the length, and defaults
+	// to binary.BigEndian.
+	Order binary.ByteOrder
+
+	// MaxLen is the maximum length of a message, beyond which Frame
+	// fails with ErrFrameTooLarge. It is unlimited if not positive.
+	MaxLen uint64
+}
+
+// Frame decodes the first length prefixed message of buf. Input ending
+// in the middle of a message fails with io.ErrUnexpectedEOF.
+//
+func (f LengthPrefixFramer) Frame(buf []byte, atEOF bool) (n int, msg []byte, err error) {
+	size, order := f.Size, f.Order
+	if size == 0 {
+		size = 4
+	}
+	if order == nil {
+		order = binary.BigEndian
+	}
+
+	if len(buf) < size {
+		return 0, nil, f.incomplete(buf, atEOF)
+	}
+	var l uint64
+	switch size {
+	case 1:
+		l = uint64(buf[0])
+	case 2:
+		l = uint64(order.Uint16(buf))
+	case 4:
+		l = uint64(order.Uint32(buf))
+	case 8:
+		l = order.Uint64(buf)
+	default:
+		return 0, nil, errors.Errorf("sand: invalid length prefix size %d", size)
+	}
+	if f.MaxLen > 0 && l > f.MaxLen {
+		return 0, nil, errors.Wrapf(ErrFrameTooLarge, "sand: message of %d bytes exceeds %d", l, f.MaxLen)
+	}
+
+	if uint64(len(buf)-size) < l {
+		return 0, nil, f.incomplete(buf, atEOF)
+	}
+	n = size + int(l)
+	return n, buf[size:n], nil
+}
+
+// incomplete returns the error for buf not holding a complete message.
+func (f LengthPrefixFramer) incomplete(buf []byte, atEOF bool) error {
+	if atEOF && len(buf) > 0 {
+		return errors.Wrap(io.ErrUnexpectedEOF, "sand: input ended within a framed message")
+	}
+	return nil
+}
+
+// readFrame reads the next message decoded by the framer, while
+// monitoring the given context. If the context is done, or the read
+// is interrupted by CancelRead, any partial message is kept buffered
+// for the next read.
+//
+func (ui *UI) readFrame(ctx context.Context) ([]byte, error) {
+	for {
+		atEOF := ui.rerr != nil && !isContextErr(ui.rerr) && ui.rerr != ErrReadInterrupted
+		n, msg, err := ui.framer.Frame(ui.rbuf[ui.rpos:], atEOF)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			ui.rpos += n
+			ui.canUnreadByte = false
+			ui.lastRuneSize = 0
+			if len(msg) == 0 {
+				continue
+			}
+			return append([]byte(nil), msg...), nil
+		}
+
+		if ui.rerr != nil {
+			if atEOF {
+				ui.rpos = len(ui.rbuf)
+			}
+			return nil, ui.readErr()
+		}
+
+		ui.fill(ctx)
+	}
+}
+
+// readChunk reads the next chunk of input for Run, which is a message
+// decoded by the framer, if any, or else the next line, however long
+// it is, without its terminator, see trimTerminator.
+//
+func (ui *UI) readChunk(b []byte) ([]byte, error) {
+	if ui.framer != nil {
+		return ui.readFrame(ui.ctx)
+	}
+	line, err := ui.readTerminated(ui.ctx)
+	line = ui.trimTerminator(line)
+	if len(line) > 0 && err == io.EOF {
+		atomic.StoreInt32(&ui.atEOF, 1)
+	}
+	if len(line) > 0 && err != nil {
+		// The same as after any other line, the error is left for the next read
+		ui.rerr, err = err, nil
+	}
+	return append(b[:0], line...), err
+}
+
+// trimTerminator strips the trailing newline from line, along with
+// the CR of a CRLF, unless it's kept, see WithStripCR.
+//
+func (ui *UI) trimTerminator(line []byte) []byte {
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		return line
+	}
+	line = line[:len(line)-1]
+	if !ui.keepCR && bytes.HasSuffix(line, []byte("\r")) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
@@ -0,0 +1,113 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// testFrame returns msg prefixed by its 4 byte big endian length.
+func testFrame(msg string) string {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(msg)))
+	return string(l[:]) + msg
+}
+
+func TestLengthPrefixFramer(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		F     LengthPrefixFramer
+		Buf   string
+		AtEOF bool
+		N     int
+		Msg   string
+		Err   error
+	}{
+		{Name: "Complete", Buf: testFrame("ab") + "x", N: 6, Msg: "ab"},
+		{Name: "PartialLength", Buf: "\x00\x00", N: 0},
+		{Name: "PartialMessage", Buf: testFrame("abc")[:5], N: 0},
+		{Name: "Empty", Buf: testFrame(""), N: 4, Msg: ""},
+		{Name: "OneByte", F: LengthPrefixFramer{Size: 1}, Buf: "\x02ab", N: 3, Msg: "ab"},
+		{Name: "LittleEndian", F: LengthPrefixFramer{Size: 2, Order: binary.LittleEndian}, Buf: "\x01\x00a", N: 3, Msg: "a"},
+		{Name: "TooLarge", F: LengthPrefixFramer{MaxLen: 2}, Buf: testFrame("abc"), Err: ErrFrameTooLarge},
+		{Name: "UnexpectedEOF", Buf: testFrame("abc")[:5], AtEOF: true, Err: io.ErrUnexpectedEOF},
+		{Name: "EOF", Buf: "", AtEOF: true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			n, msg, err := tc.F.Frame([]byte(tc.Buf), tc.AtEOF)
+			if errors.Cause(err) != tc.Err {
+				subT.Fatalf("expected %v but instead received: %v", tc.Err, err)
+			}
+			if n != tc.N || string(msg) != tc.Msg {
+				subT.Errorf("expected %d, %q but instead received: %d, %q", tc.N, tc.Msg, n, msg)
+			}
+		})
+	}
+}
+
+// testSplitReader returns its chunks one read at a time.
+type testSplitReader struct {
+	chunks []string
+}
+
+func (r *testSplitReader) Read(b []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestRunWithFramer(t *testing.T) {
+	// Messages split across reads, several in a read and binary ones
+	stream := testFrame("a b\r\n") + testFrame("") + testFrame("c\x00d") + testFrame("e")
+	in := &testSplitReader{chunks: []string{stream[:3], stream[3:12], stream[12:]}}
+	eng := new(testRecordEngine)
+
+	err := Run(nil, eng, WithIO(in, new(bytes.Buffer)), WithFramer(LengthPrefixFramer{}))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := []string{"a b\r\n", "c\x00d", "e"}
+	if len(eng.lines) != len(ex) {
+		t.Fatalf("expected %q but instead received: %q", ex, eng.lines)
+	}
+	for i := range ex {
+		if eng.lines[i] != ex[i] {
+			t.Errorf("expected %q but instead received: %q", ex[i], eng.lines[i])
+		}
+	}
+}
+
+func TestUI_readFrameCanceled(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ui := &UI{i: pr, framer: LengthPrefixFramer{}}
+
+	// Half a message, then the context is done while waiting on the rest
+	go pw.Write([]byte(testFrame("abc")[:5]))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ui.readFrame(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded but instead received: %v", err)
+	}
+
+	// The partial message is kept for the next read
+	go pw.Write([]byte("bc"))
+	msg, err := ui.readFrame(context.Background())
+	if err != nil || string(msg) != "abc" {
+		t.Errorf("expected %q but instead received: %q, %v", "abc", msg, err)
+	}
+}
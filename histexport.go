@@ -0,0 +1,180 @@
+package sand
+
+import (
+	"bufio"
+	"github.com/pkg/errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryFormat represents how ExportHistory and ImportHistory write
+// and read history, so it can be shared with a shell.
+//
+type HistoryFormat int
+
+const (
+	// HistoryPlain is one command per line. A command of several
+	// lines has every newline within it escaped by a backslash, i.e.
+	// a line ending with a backslash continues on the next line.
+	HistoryPlain HistoryFormat = iota
+
+	// HistoryBash is the format bash writes when HISTTIMEFORMAT is
+	// set. Every command is preceded by a line with a "#" and the
+	// time it was executed, in seconds since the Unix epoch. A
+	// command of several lines is written as is, all of its lines
+	// up to the next time belonging to it, which is how bash reads
+	// them back with the lithist option set.
+	HistoryBash
+
+	// HistoryZsh is the extended history format of zsh, which is
+	// one command per line as ": <time>:<duration>;<command>", the
+	// time being in seconds since the Unix epoch. The duration is
+	// always written as 0. Newlines within a command are escaped the
+	// same as in HistoryPlain.
+	HistoryZsh
+)
+
+// ErrNoHistory is returned by ImportHistory if history isn't recorded,
+// see WithHistoryExpansion.
+//
+var ErrNoHistory = errors.New("sand: history isn't recorded")
+
+// errHistoryFormat is returned for a HistoryFormat that doesn't exist.
+var errHistoryFormat = errors.New("sand: unknown history format")
+
+// ExportHistory writes the recorded commands, oldest first, to w in the
+// given format, e.g. to append them to the history file of a shell.
+// Commands loaded from a history file, see WithHistoryFile, are written
+// with the time they were loaded, since it's all that's known of them.
+//
+func (ui *UI) ExportHistory(w io.Writer, format HistoryFormat) error {
+	if format < HistoryPlain || format > HistoryZsh {
+		return errHistoryFormat
+	}
+	if ui.history == nil {
+		return nil
+	}
+
+	ui.history.RLock()
+	bw := bufio.NewWriter(w)
+	for i, cmd := range ui.history.entries {
+		var secs int64
+		if t := ui.history.timeOf(i); !t.IsZero() {
+			secs = t.Unix()
+		}
+
+		switch format {
+		case HistoryPlain:
+			bw.WriteString(escapeHistory(cmd))
+		case HistoryBash:
+			bw.WriteString("#" + strconv.FormatInt(secs, 10) + "\n" + cmd)
+		case HistoryZsh:
+			bw.WriteString(": " + strconv.FormatInt(secs, 10) + ":0;" + escapeHistory(cmd))
+		}
+		bw.WriteByte('\n')
+	}
+	ui.history.RUnlock()
+
+	return errors.Wrap(bw.Flush(), "sand: encountered error while exporting history")
+}
+
+// ImportHistory reads commands from r in the given format, e.g. from
+// the history file of a shell, and records them after the commands
+// recorded so far, oldest first. Blank commands are skipped. Plain
+// commands are recorded as of the time of the import.
+//
+func (ui *UI) ImportHistory(r io.Reader, format HistoryFormat) error {
+	if format < HistoryPlain || format > HistoryZsh {
+		return errHistoryFormat
+	}
+	if ui.history == nil {
+		return ErrNoHistory
+	}
+
+	entries, times, err := parseHistory(r, format, ui.clock().Now())
+	if err != nil {
+		return errors.Wrap(err, "sand: encountered error while importing history")
+	}
+
+	ui.history.Lock()
+	defer ui.history.Unlock()
+	for i, cmd := range entries {
+		if strings.TrimSpace(cmd) == "" {
+			continue
+		}
+		ui.history.entries = append(ui.history.entries, cmd)
+		ui.history.times = append(ui.history.times, times[i])
+	}
+	ui.history.trim()
+	return nil
+}
+
+// escapeHistory escapes the newlines of cmd with a backslash.
+func escapeHistory(cmd string) string {
+	return strings.Replace(cmd, "\n", "\\\n", -1)
+}
+
+// bashTime and zshEntry match the time lines of HistoryBash and the
+// lines of HistoryZsh.
+var (
+	bashTime = regexp.MustCompile(`^#([0-9]+)$`)
+	zshEntry = regexp.MustCompile(`^: ([0-9]+):[0-9]+;`)
+)
+
+// parseHistory reads the commands, along with their times, from r.
+// Commands without a time are given now.
+//
+func parseHistory(r io.Reader, format HistoryFormat, now time.Time) (entries []string, times []time.Time, err error) {
+	var cont bool         // the last line ended with an escaped newline
+	var timed, fresh bool // the last command has a time, and no lines yet
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+
+		if format == HistoryBash {
+			if m := bashTime.FindStringSubmatch(line); m != nil {
+				secs, _ := strconv.ParseInt(m[1], 10, 64)
+				entries = append(entries, "")
+				times = append(times, time.Unix(secs, 0))
+				timed, fresh = true, true
+				continue
+			}
+			if !timed {
+				// Commands are a line each until the first time
+				entries = append(entries, line)
+				times = append(times, now)
+				continue
+			}
+			i := len(entries) - 1
+			if !fresh {
+				entries[i] += "\n"
+			}
+			entries[i] += line
+			fresh = false
+			continue
+		}
+
+		if cont {
+			i := len(entries) - 1
+			cont = strings.HasSuffix(line, "\\")
+			entries[i] += "\n" + strings.TrimSuffix(line, "\\")
+			continue
+		}
+
+		t := now
+		if format == HistoryZsh {
+			if m := zshEntry.FindStringSubmatch(line); m != nil {
+				secs, _ := strconv.ParseInt(m[1], 10, 64)
+				t = time.Unix(secs, 0)
+				line = line[len(m[0]):]
+			}
+		}
+		cont = strings.HasSuffix(line, "\\")
+		entries = append(entries, strings.TrimSuffix(line, "\\"))
+		times = append(times, t)
+	}
+	return entries, times, s.Err()
+}
@@ -0,0 +1,120 @@
+package sand
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUI_ExportHistory(t *testing.T) {
+	newUI := func() *UI {
+		return &UI{history: &history{
+			entries: []string{"get foo", "define bar\n  baz\nend", "set foo 1"},
+			times:   []time.Time{time.Unix(100, 0), time.Unix(200, 0), time.Unix(300, 0)},
+		}}
+	}
+
+	testCases := []struct {
+		Name   string
+		Format HistoryFormat
+		Out    string
+	}{
+		{
+			Name:   "Plain",
+			Format: HistoryPlain,
+			Out:    "get foo\ndefine bar\\\n  baz\\\nend\nset foo 1\n",
+		},
+		{
+			Name:   "Bash",
+			Format: HistoryBash,
+			Out:    "#100\nget foo\n#200\ndefine bar\n  baz\nend\n#300\nset foo 1\n",
+		},
+		{
+			Name:   "Zsh",
+			Format: HistoryZsh,
+			Out:    ": 100:0;get foo\n: 200:0;define bar\\\n  baz\\\nend\n: 300:0;set foo 1\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := newUI()
+
+			var buf bytes.Buffer
+			if err := ui.ExportHistory(&buf, tc.Format); err != nil {
+				subT.Fatal(err)
+			}
+			if buf.String() != tc.Out {
+				subT.Fatalf("expected %q but instead received: %q", tc.Out, buf.String())
+			}
+
+			// Importing the export records the same commands again
+			if err := ui.ImportHistory(&buf, tc.Format); err != nil {
+				subT.Fatal(err)
+			}
+			ex := append(newUI().History(), newUI().History()...)
+			if got := ui.History(); !reflect.DeepEqual(got, ex) {
+				subT.Errorf("expected %q but instead received: %q", ex, got)
+			}
+			if tc.Format != HistoryPlain && !ui.history.times[5].Equal(time.Unix(300, 0)) {
+				subT.Errorf("expected %v but instead received: %v", time.Unix(300, 0), ui.history.times[5])
+			}
+		})
+	}
+}
+
+func TestUI_ImportHistory(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Format  HistoryFormat
+		In      string
+		Entries []string
+		Err     error
+	}{
+		{
+			Name:    "BashUntimed",
+			Format:  HistoryBash,
+			In:      "ls\ncd /tmp\n#100\nls -l\n",
+			Entries: []string{"ls", "cd /tmp", "ls -l"},
+		},
+		{
+			Name:    "ZshUntimed",
+			Format:  HistoryZsh,
+			In:      "ls\n: 100:5;ls -l\n",
+			Entries: []string{"ls", "ls -l"},
+		},
+		{
+			Name:    "SkipsBlank",
+			Format:  HistoryPlain,
+			In:      "ls\n\n  \nls -l\n",
+			Entries: []string{"ls", "ls -l"},
+		},
+		{
+			Name:   "UnknownFormat",
+			Format: HistoryZsh + 1,
+			Err:    errHistoryFormat,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := &UI{history: new(history)}
+			err := ui.ImportHistory(strings.NewReader(tc.In), tc.Format)
+			if err != tc.Err {
+				subT.Fatalf("expected %v but instead received: %v", tc.Err, err)
+			}
+			if got := ui.History(); !reflect.DeepEqual(got, tc.Entries) {
+				subT.Errorf("expected %q but instead received: %q", tc.Entries, got)
+			}
+		})
+	}
+
+	ui := new(UI)
+	if err := ui.ImportHistory(strings.NewReader("ls\n"), HistoryPlain); err != ErrNoHistory {
+		t.Errorf("expected %v but instead received: %v", ErrNoHistory, err)
+	}
+}
@@ -0,0 +1,117 @@
+package sand
+
+import (
+	"bufio"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WithHistoryFile persists the commands of every session in the file
+// at path, one per line, so History includes the commands of previous
+// sessions, e.g. for WithHistoryExpansion. The file is read when Run
+// starts and rewritten when it returns. A missing file is treated as
+// empty.
+//
+func WithHistoryFile(path string) Option {
+	return func(ui *UI) {
+		if ui.history == nil {
+			ui.history = new(history)
+		}
+		ui.histFile = path
+	}
+}
+
+// WithMaxHistoryFileSize limits the history file to the newest n
+// commands, like HISTFILESIZE. The oldest commands are dropped when
+// the file is rewritten. By default, the file isn't limited.
+//
+func WithMaxHistoryFileSize(n int) Option {
+	return func(ui *UI) {
+		ui.histMax = n
+	}
+}
+
+// loadHistory prepends the commands in the history file, if any, to
+// the recorded history. The file is only read by the first Run.
+//
+func (ui *UI) loadHistory() error {
+	if ui.histFile == "" || ui.histLoaded {
+		return nil
+	}
+	ui.histLoaded = true
+
+	f, err := os.Open(ui.histFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "sand: encountered error while reading history file")
+	}
+	defer f.Close()
+
+	var entries []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if strings.TrimSpace(s.Text()) != "" {
+			entries = append(entries, s.Text())
+		}
+	}
+	if err = s.Err(); err != nil {
+		return errors.Wrap(err, "sand: encountered error while reading history file")
+	}
+
+	times := make([]time.Time, len(entries))
+	now := ui.clock().Now()
+	for i := range times {
+		times[i] = now
+	}
+
+	ui.history.Lock()
+	ui.history.entries = append(entries, ui.history.entries...)
+	ui.history.times = append(times, ui.history.times...)
+	ui.history.trim()
+	ui.history.Unlock()
+	return nil
+}
+
+// saveHistory rewrites the history file with the recorded history,
+// trimmed to the newest commands allowed. The file is replaced
+// atomically, so it's never left half written.
+//
+func (ui *UI) saveHistory() error {
+	if ui.histFile == "" {
+		return nil
+	}
+
+	ui.history.RLock()
+	entries := ui.history.entries
+	if ui.histMax > 0 && len(entries) > ui.histMax {
+		entries = entries[len(entries)-ui.histMax:]
+	}
+	data := strings.Join(entries, "\n")
+	ui.history.RUnlock()
+	if data != "" {
+		data += "\n"
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(ui.histFile), filepath.Base(ui.histFile)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "sand: encountered error while writing history file")
+	}
+	_, err = tmp.WriteString(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), ui.histFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "sand: encountered error while writing history file")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sand
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history")
+
+	testCases := []struct {
+		Name  string
+		Lines []string
+		Max   int
+		Ex    string
+	}{
+		{Name: "Missing", Lines: []string{"a\n", "b\n"}, Ex: "a\nb\n"},
+		{Name: "Appended", Lines: []string{"c\n"}, Ex: "a\nb\nc\n"},
+		{Name: "Trimmed", Lines: []string{"d\n", "e\n"}, Max: 3, Ex: "c\nd\ne\n"},
+	}
+
+	for _, tc := range testCases {
+		in := &testLineReader{lines: tc.Lines}
+		err := Run(nil, new(testEchoEngine), WithIO(in, ioutil.Discard), WithHistoryFile(path), WithMaxHistoryFileSize(tc.Max))
+		if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.Ex {
+			t.Errorf("%s: expected %q but instead received: %q", tc.Name, tc.Ex, b)
+		}
+	}
+
+	// Only the history file itself is left behind
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the history file but instead received: %d files", len(files))
+	}
+}
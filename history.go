@@ -0,0 +1,237 @@
+package sand
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// history is the list of commands executed in a session.
+type history struct {
+	sync.RWMutex
+	entries []string
+	times   []time.Time // when each entry was recorded, see timeOf
+	size    int         // maximum number of entries, if positive, see WithHistory
+	cursor  int         // index of the recalled entry, see HistoryPrev
+}
+
+// add records the command, without its trailing newline, unless it
+// is blank, or a repeat of the last command, as of the given time.
+//
+func (h *history) add(cmd string, t time.Time) {
+	cmd = strings.TrimRight(cmd, "\r\n")
+	if strings.TrimSpace(cmd) == "" {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	if n := len(h.entries); n > 0 && h.entries[n-1] == cmd {
+		h.cursor = n
+		return
+	}
+	h.entries = append(h.entries, cmd)
+	h.times = append(h.times, t)
+	h.trim()
+}
+
+// trim drops the oldest entries beyond the size of the history, if
+// it's bounded, and resets the cursor. The caller must hold the lock.
+//
+func (h *history) trim() {
+	defer func() { h.cursor = len(h.entries) }()
+	n := len(h.entries) - h.size
+	if h.size <= 0 || n <= 0 {
+		return
+	}
+
+	h.entries = h.entries[:copy(h.entries, h.entries[n:])]
+	if n >= len(h.times) {
+		h.times = h.times[:0]
+		return
+	}
+	h.times = h.times[:copy(h.times, h.times[n:])]
+}
+
+// timeOf returns when the ith entry was recorded, which is the zero
+// time if it isn't known. The caller must hold the lock.
+//
+func (h *history) timeOf(i int) time.Time {
+	if i < len(h.times) {
+		return h.times[i]
+	}
+	return time.Time{}
+}
+
+// WithHistoryExpansion records the commands of the session and
+// expands history references in every new command, like a shell:
+// "!!" is the last command, "!n" is the nth command and "!prefix"
+// is the most recent command starting with prefix. The expanded
+// command is printed before it is executed. References aren't
+// expanded within single quotes and "\!" results in a literal "!".
+//
+func WithHistoryExpansion() Option {
+	return func(ui *UI) {
+		if ui.history == nil {
+			ui.history = new(history)
+		}
+		ui.expandHist = true
+	}
+}
+
+// WithHistory records the commands of the session, keeping only
+// the newest size of them, e.g. for an Engine to recall them with
+// HistoryPrev and HistoryNext. Blank commands and repeats of the last
+// command aren't recorded. A size of zero, or less, doesn't bound the
+// history, the same as for WithHistoryExpansion.
+//
+func WithHistory(size int) Option {
+	return func(ui *UI) {
+		if ui.history == nil {
+			ui.history = new(history)
+		}
+		ui.history.size = size
+	}
+}
+
+// History returns the commands recorded so far, oldest first.
+//
+func (ui *UI) History() []string {
+	if ui.history == nil {
+		return nil
+	}
+
+	ui.history.RLock()
+	defer ui.history.RUnlock()
+	return append([]string(nil), ui.history.entries...)
+}
+
+// HistoryPrev moves the history cursor back one command and returns
+// that command, e.g. for an Engine recalling commands on the up key.
+// Once the oldest command is reached, it's returned again. The cursor
+// is reset past the newest command whenever one is recorded. It
+// returns false if no command has been recorded.
+//
+func (ui *UI) HistoryPrev() (string, bool) {
+	if ui.history == nil {
+		return "", false
+	}
+
+	h := ui.history
+	h.Lock()
+	defer h.Unlock()
+	if len(h.entries) == 0 {
+		return "", false
+	}
+	if h.cursor > 0 {
+		h.cursor--
+	}
+	return h.entries[h.cursor], true
+}
+
+// HistoryNext moves the history cursor forward one command and
+// returns that command, e.g. for an Engine recalling commands on the
+// down key. Moving past the newest command returns false, along with
+// an empty command, i.e. the line being typed, see HistoryPrev.
+//
+func (ui *UI) HistoryNext() (string, bool) {
+	if ui.history == nil {
+		return "", false
+	}
+
+	h := ui.history
+	h.Lock()
+	defer h.Unlock()
+	if h.cursor >= len(h.entries)-1 {
+		h.cursor = len(h.entries)
+		return "", false
+	}
+	h.cursor++
+	return h.entries[h.cursor], true
+}
+
+// AskHistory returns the answers given to Ask so far, oldest first.
+// They are kept separate from History.
+//
+func (ui *UI) AskHistory() []string {
+	ui.askHistory.RLock()
+	defer ui.askHistory.RUnlock()
+	return append([]string(nil), ui.askHistory.entries...)
+}
+
+// expandHistory expands the history references in line and reports
+// whether there were any. The error names the first reference which
+// doesn't match any command.
+//
+func (ui *UI) expandHistory(line string) (string, bool, error) {
+	if strings.IndexByte(line, '!') == -1 {
+		return line, false, nil
+	}
+
+	ui.history.RLock()
+	defer ui.history.RUnlock()
+	entries := ui.history.entries
+
+	var buf bytes.Buffer
+	var quoted, expanded bool
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '\\' && !quoted && i+1 < len(line) && line[i+1] == '!':
+			i++
+			c = '!'
+		case c == '!' && !quoted:
+			ref := line[i+1:]
+			if end := strings.IndexAny(ref, " \t\r\n\"'"); end != -1 {
+				ref = ref[:end]
+			}
+			if strings.HasPrefix(ref, "!") {
+				ref = "!"
+			}
+			if ref == "" || ref[0] == '=' || ref[0] == '(' {
+				break
+			}
+
+			cmd, ok := lookupHistory(entries, ref)
+			if !ok {
+				return line, false, fmt.Errorf("sand: !%s: event not found", ref)
+			}
+			buf.WriteString(cmd)
+			i += len(ref)
+			expanded = true
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String(), expanded, nil
+}
+
+// lookupHistory returns the command referenced by ref, the
+// part of a history reference following the "!".
+//
+func lookupHistory(entries []string, ref string) (string, bool) {
+	if ref == "!" {
+		ref = "-1"
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 0 {
+			n += len(entries) + 1
+		}
+		if n < 1 || n > len(entries) {
+			return "", false
+		}
+		return entries[n-1], true
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if strings.HasPrefix(entries[i], ref) {
+			return entries[i], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,178 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUI_ExpandHistory(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		In    string
+		Ex    string
+		ExErr string
+	}{
+		{Name: "None", In: "ls -l\n", Ex: "ls -l\n"},
+		{Name: "Last", In: "!!\n", Ex: "echo b\n"},
+		{Name: "LastInline", In: "sudo !! now\n", Ex: "sudo echo b now\n"},
+		{Name: "Number", In: "!1\n", Ex: "ls -l\n"},
+		{Name: "Negative", In: "!-2\n", Ex: "echo a\n"},
+		{Name: "Prefix", In: "!ec\n", Ex: "echo b\n"},
+		{Name: "PrefixOlder", In: "!ls\n", Ex: "ls -l\n"},
+		{Name: "Quoted", In: "say '!!'\n", Ex: "say '!!'\n"},
+		{Name: "Escaped", In: `say \!!` + "\n", Ex: "say !!\n"},
+		{Name: "Bang", In: "wow! x != y\n", Ex: "wow! x != y\n"},
+		{Name: "NoNumber", In: "!9\n", ExErr: "sand: !9: event not found"},
+		{Name: "NoPrefix", In: "!zz\n", ExErr: "sand: !zz: event not found"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := &UI{history: &history{entries: []string{"ls -l", "echo a", "echo b"}}}
+
+			s, _, err := ui.expandHistory(tc.In)
+			if tc.ExErr != "" {
+				if err == nil || err.Error() != tc.ExErr {
+					subT.Errorf("expected error %q but instead received: %v", tc.ExErr, err)
+				}
+				return
+			}
+			if err != nil {
+				subT.Error(err)
+			}
+			if s != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, s)
+			}
+		})
+	}
+}
+
+func TestRunWithHistoryExpansion(t *testing.T) {
+	in := &testLineReader{lines: []string{"echo a\n", "\n", "!!\n", "!x\n"}}
+	var out, prompt bytes.Buffer
+
+	ui := new(UI)
+	err := ui.Run(nil, new(testEchoEngine), WithIO(in, &out), WithPromptWriter(&prompt), WithHistoryExpansion())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	if out.String() != "echo a\necho a\n" {
+		t.Errorf("expected output %q but instead received: %q", "echo a\necho a\n", out.String())
+	}
+	if prompt.String() != "echo a\nsand: !x: event not found\n\n" {
+		t.Errorf("expected prompt %q but instead received: %q", "echo a\nsand: !x: event not found\n\n", prompt.String())
+	}
+
+	ex := []string{"echo a"}
+	if h := ui.History(); !reflect.DeepEqual(h, ex) {
+		t.Errorf("expected history %q but instead received: %q", ex, h)
+	}
+}
+
+// testAskEngine asks for a name on every line.
+type testAskEngine struct {
+	execs int
+}
+
+func (eng *testAskEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.execs++
+	ui.(*UI).Ask("name? ")
+	return 0
+}
+
+func TestAskHistory(t *testing.T) {
+	in := &testLineReader{lines: []string{"greet\n", "bob\n", "greet\n", "alice\n"}}
+
+	ui := new(UI)
+	err := ui.Run(nil, new(testAskEngine), WithIO(in, ioutil.Discard), WithHistoryExpansion())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	if h, ex := ui.History(), []string{"greet"}; !reflect.DeepEqual(h, ex) {
+		t.Errorf("expected history %q but instead received: %q", ex, h)
+	}
+	if h, ex := ui.AskHistory(), []string{"bob", "alice"}; !reflect.DeepEqual(h, ex) {
+		t.Errorf("expected ask history %q but instead received: %q", ex, h)
+	}
+}
+
+func TestRunWithHistory(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Size  int
+		Lines []string
+		Ex    []string
+	}{
+		{Name: "Blank", Size: 3, Lines: []string{"a\n", "\n", "  \n", "b\n"}, Ex: []string{"a", "b"}},
+		{Name: "Repeats", Size: 3, Lines: []string{"a\n", "a\n", "b\n", "a\n"}, Ex: []string{"a", "b", "a"}},
+		{Name: "Bounded", Size: 2, Lines: []string{"a\n", "b\n", "c\n", "d\n"}, Ex: []string{"c", "d"}},
+		{Name: "Unbounded", Lines: []string{"a\n", "a\n", "b\n", "a\n"}, Ex: []string{"a", "b", "a"}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := new(UI)
+			in := &testLineReader{lines: tc.Lines}
+			err := ui.Run(nil, new(testEchoEngine), WithIO(in, ioutil.Discard), WithHistory(tc.Size))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Fatal(err)
+			}
+			if h := ui.History(); !reflect.DeepEqual(h, tc.Ex) {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, h)
+			}
+		})
+	}
+}
+
+func TestUI_HistoryPrevNext(t *testing.T) {
+	ui := new(UI)
+	if _, ok := ui.HistoryPrev(); ok {
+		t.Errorf("expected no history")
+	}
+
+	WithHistory(2)(ui)
+	for _, cmd := range []string{"a", "b", "c"} {
+		ui.history.add(cmd, time.Time{})
+	}
+
+	type step struct {
+		next bool
+		cmd  string
+		ok   bool
+	}
+	steps := []step{
+		{cmd: "c", ok: true},
+		{cmd: "b", ok: true},
+		{cmd: "b", ok: true},
+		{next: true, cmd: "c", ok: true},
+		{next: true},
+		{next: true},
+		{cmd: "c", ok: true},
+	}
+	for i, s := range steps {
+		recall := ui.HistoryPrev
+		if s.next {
+			recall = ui.HistoryNext
+		}
+		cmd, ok := recall()
+		if cmd != s.cmd || ok != s.ok {
+			t.Errorf("expected %q, %t on step %d but instead received: %q, %t", s.cmd, s.ok, i, cmd, ok)
+		}
+	}
+
+	// Recording a command resets the cursor
+	ui.HistoryPrev()
+	ui.history.add("d", time.Time{})
+	if cmd, _ := ui.HistoryPrev(); cmd != "d" {
+		t.Errorf("expected %q but instead received: %q", "d", cmd)
+	}
+}
@@ -0,0 +1,104 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+)
+
+// HistoryEntry is a command recorded in the history, see SearchHistory.
+type HistoryEntry struct {
+	// Index is the number of the command, starting at 1, the same
+	// as in a "!n" history reference.
+	Index int
+
+	// Command is the command, without its trailing newline.
+	Command string
+}
+
+// SearchHistory returns the most recent commands matching pattern,
+// newest first, e.g. for a reverse search through thousands of
+// commands. A pattern containing a "*" is a glob, which must match
+// the whole command and where "*" matches any text, and otherwise
+// any command containing the pattern matches. An empty pattern
+// matches every command. At most limit commands are returned, unless
+// limit isn't positive.
+//
+func (ui *UI) SearchHistory(pattern string, limit int) []HistoryEntry {
+	if ui.history == nil {
+		return nil
+	}
+
+	ui.history.RLock()
+	defer ui.history.RUnlock()
+
+	var found []HistoryEntry
+	for i := len(ui.history.entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(found) >= limit {
+			break
+		}
+		cmd := ui.history.entries[i]
+		if matchHistory(pattern, cmd) {
+			found = append(found, HistoryEntry{Index: i + 1, Command: cmd})
+		}
+	}
+	return found
+}
+
+// matchHistory reports whether cmd matches the pattern of SearchHistory.
+func matchHistory(pattern, cmd string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.Contains(cmd, pattern)
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(cmd, parts[0]) {
+		return false
+	}
+	cmd = cmd[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(cmd, part)
+		if i == -1 {
+			return false
+		}
+		cmd = cmd[i+len(part):]
+	}
+	return len(cmd) >= len(last) && strings.HasSuffix(cmd, last)
+}
+
+// WithHistoryBuiltin records the commands of the session and installs
+// a "history" builtin, which lists the most recent commands, oldest
+// first, along with their numbers. Given an argument, only commands
+// matching it are listed, see SearchHistory. At most limit commands
+// are listed, unless limit isn't positive.
+//
+func WithHistoryBuiltin(limit int) Option {
+	return func(ui *UI) {
+		if ui.history == nil {
+			ui.history = new(history)
+		}
+		ui.addBuiltin("history", "list recent commands, history [PATTERN]", historyBuiltin(limit))
+	}
+}
+
+// historyBuiltin returns the builtin for listing at most limit commands.
+func historyBuiltin(limit int) builtinFunc {
+	return func(ctx context.Context, args []string, ui *UI) int {
+		found := ui.SearchHistory(strings.Join(args, " "), limit)
+
+		rows := make([][]string, 0, len(found))
+		for i := len(found) - 1; i >= 0; i-- {
+			rows = append(rows, []string{strconv.Itoa(found[i].Index), found[i].Command})
+		}
+
+		var buf bytes.Buffer
+		writeTable(&buf, rows)
+		if _, err := ui.write(buf.Bytes()); err != nil {
+			return 1
+		}
+		return 0
+	}
+}
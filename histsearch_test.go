@@ -0,0 +1,92 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestUI_SearchHistory(t *testing.T) {
+	ui := &UI{history: &history{entries: []string{
+		"get foo",
+		"set foo 1",
+		"get bar",
+		"set bar 2",
+		"getfoo",
+	}}}
+
+	testCases := []struct {
+		Name    string
+		Pattern string
+		Limit   int
+		Entries []HistoryEntry
+	}{
+		{
+			Name:    "Substring",
+			Pattern: "foo",
+			Entries: []HistoryEntry{{5, "getfoo"}, {2, "set foo 1"}, {1, "get foo"}},
+		},
+		{
+			Name:    "SubstringLimit",
+			Pattern: "foo",
+			Limit:   2,
+			Entries: []HistoryEntry{{5, "getfoo"}, {2, "set foo 1"}},
+		},
+		{
+			Name:    "Glob",
+			Pattern: "get *",
+			Entries: []HistoryEntry{{3, "get bar"}, {1, "get foo"}},
+		},
+		{
+			Name:    "GlobLimit",
+			Pattern: "get *",
+			Limit:   1,
+			Entries: []HistoryEntry{{3, "get bar"}},
+		},
+		{
+			Name:    "GlobInner",
+			Pattern: "s*b*2",
+			Entries: []HistoryEntry{{4, "set bar 2"}},
+		},
+		{
+			Name:    "GlobAnchored",
+			Pattern: "*foo",
+			Entries: []HistoryEntry{{5, "getfoo"}, {1, "get foo"}},
+		},
+		{
+			Name:    "Empty",
+			Limit:   3,
+			Entries: []HistoryEntry{{5, "getfoo"}, {4, "set bar 2"}, {3, "get bar"}},
+		},
+		{
+			Name:    "NoMatch",
+			Pattern: "del",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			entries := ui.SearchHistory(tc.Pattern, tc.Limit)
+			if !reflect.DeepEqual(entries, tc.Entries) {
+				subT.Errorf("expected %v but instead received: %v", tc.Entries, entries)
+			}
+		})
+	}
+}
+
+func TestWithHistoryBuiltin(t *testing.T) {
+	in := &testLineReader{lines: []string{"get foo\n", "set foo\n", "get bar\n", "history get*\n"}}
+	var out bytes.Buffer
+
+	err := Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(in, &out), WithHistoryBuiltin(1))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	ex := ">>get foo\n>>set foo\n>>get bar\n>3  get bar\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
@@ -0,0 +1,57 @@
+package sand
+
+import (
+	"sync"
+	"time"
+)
+
+// WithIdleFunc calls fn whenever Run has been waiting on input for
+// the given duration, and then again every time that duration passes
+// without input, e.g. for refreshing a status line. The prompt and
+// anything typed so far are left alone, so fn should use Interject
+// to write anything. fn is called on a goroutine of its own, but
+// never while a command is executing, since Run waits for fn to
+// return before executing the line that arrived in the meantime.
+//
+func WithIdleFunc(d time.Duration, fn func(ui *UI)) Option {
+	return func(ui *UI) {
+		ui.idleAfter = d
+		ui.idleFn = fn
+	}
+}
+
+// startIdle starts calling the idle func, see WithIdleFunc, until the
+// returned func is called, which waits for any call in progress.
+//
+func (ui *UI) startIdle() (stop func()) {
+	if ui.idleFn == nil || ui.idleAfter <= 0 {
+		return func() {}
+	}
+
+	var mu sync.Mutex // held while calling the idle func
+	var stopped bool
+	var stopTimer func()
+
+	var arm func()
+	arm = func() {
+		stopTimer = ui.afterFunc(ui.idleAfter, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if stopped {
+				return
+			}
+			ui.idleFn(ui)
+			arm()
+		})
+	}
+	mu.Lock()
+	arm()
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		stopped = true
+		stopTimer()
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,66 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithIdleFunc(t *testing.T) {
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	isTerminal = func(interface{}) bool { return false }
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	var out bytes.Buffer
+
+	var calls int32
+	idle := func(ui *UI) {
+		atomic.AddInt32(&calls, 1)
+		ui.Interject("refreshed")
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(pr, &out), WithIdleFunc(20*time.Millisecond, idle))
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n < 2 {
+		t.Fatalf("expected idle func to be called repeatedly but instead was called %d times", n)
+	}
+
+	// Input isn't consumed by the idle func
+	pw.Write([]byte("hello\n"))
+	pw.Close()
+
+	err := <-errCh
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(out.String(), ">\nrefreshed\n>\nrefreshed\n>") {
+		t.Errorf("expected interjections after the prompt but instead received: %q", out.String())
+	}
+	if !strings.Contains(out.String(), ">hello\n") {
+		t.Errorf("expected input to be executed but instead received: %q", out.String())
+	}
+}
+
+func TestWithIdleFunc_NotBeforeIdle(t *testing.T) {
+	var calls int32
+	idle := func(ui *UI) { atomic.AddInt32(&calls, 1) }
+
+	out := runBuiltinTest(t, new(testEchoEngine), "hello\n", WithIdleFunc(time.Hour, idle))
+	if out != ">>hello\n>\n" {
+		t.Errorf("expected %q but instead received: %q", ">>hello\n>\n", out)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("expected no idle calls but instead received: %d", n)
+	}
+}
@@ -0,0 +1,17 @@
+package sand
+
+// WithInputInterceptor specifies a function called with every line
+// read, without its terminator, before it's executed, e.g. to expand
+// user defined macros taking arguments. If it reports the line as
+// handled, the line is replaced by the expanded lines, which are then
+// executed one after another, the same as if they had been read in
+// its place, except that no prompt is written in between. Expanded
+// lines aren't intercepted themselves, nor recorded in the history,
+// which has the original line instead. Lines continuing an incomplete
+// one, see StatusNeedMore, aren't intercepted either.
+//
+func WithInputInterceptor(intercept func(line string) (expanded []string, handled bool)) Option {
+	return func(ui *UI) {
+		ui.intercept = intercept
+	}
+}
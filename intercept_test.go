@@ -0,0 +1,40 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWithInputInterceptor(t *testing.T) {
+	// "swap a b" expands into three commands
+	macros := func(line string) ([]string, bool) {
+		args := strings.Fields(line)
+		if len(args) != 3 || args[0] != "swap" {
+			return nil, false
+		}
+		return []string{
+			"set tmp " + args[1],
+			"set " + args[1] + " " + args[2],
+			"set " + args[2] + " tmp",
+		}, true
+	}
+
+	in := &testLineReader{lines: []string{"swap a b\n", "get a\n"}}
+	var out bytes.Buffer
+
+	eng := new(testEchoEngine)
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithInputInterceptor(macros))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	ex := ">>set tmp a\n>set a b\n>set b tmp\n>>get a\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if eng.execs != 4 {
+		t.Errorf("expected %d execs but instead received: %d", 4, eng.execs)
+	}
+}
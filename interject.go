@@ -0,0 +1,82 @@
+package sand
+
+// Interject writes msg on a line of its own, where the UI writes its
+// prompts, e.g. for a notification that a background job is done. If
+// Run is waiting on input after the prompt, msg is written in place of
+// the prompt line, which is then redrawn below it, so the prompt isn't
+// clobbered. It is meant to be called while Run is running and is
+// safe to call from any goroutine.
+//
+// Input the user has partially typed is held by the terminal until
+// the line is complete, so it isn't redrawn along with the prompt,
+// although it is still part of the line once entered.
+//
+func (ui *UI) Interject(msg string) error {
+	ui.promptMu.Lock()
+	defer ui.promptMu.Unlock()
+
+	_, err := ui.interject([]byte(msg + "\n"))
+	return err
+}
+
+// interject writes b, which ends with a newline, above the prompt if
+// Run is waiting on input after it, see Interject. The prompt lock
+// must be held.
+//
+func (ui *UI) interject(b []byte) (int, error) {
+	if ui.atPrompt {
+		lineBreak := "\n"
+		if isTerminal(ui.promptDest()) {
+			lineBreak = eraseLine
+		}
+		b = append([]byte(lineBreak), b...)
+		b = append(b, ui.renderPrompt(ui.ctx)...)
+	}
+	return ui.writePrompt(b)
+}
+
+// SafeWrite writes b where the UI writes its prompts, followed by a
+// newline unless b ends with one, e.g. for an embedder writing log
+// lines from goroutines of its own. It is safe to call from any
+// goroutine, whether Run is running or not, and never corrupts the
+// prompt or the output of a command.
+//
+// While Run waits on input, b is written above the prompt right away,
+// the same as by Interject. Otherwise, e.g. while a command executes,
+// b is held, and written once the command is done, before the next
+// prompt, so it's never mixed into the output of the command. Held
+// writes are written in the order they were made, before any pending
+// notifications, see NotifyChan. The returned n is always len(b)
+// once b is held, since it isn't written until later.
+//
+func (ui *UI) SafeWrite(b []byte) (n int, err error) {
+	line := append([]byte(nil), b...)
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+
+	ui.promptMu.Lock()
+	defer ui.promptMu.Unlock()
+	if !ui.atPrompt {
+		ui.heldWrites = append(ui.heldWrites, line...)
+		return len(b), nil
+	}
+
+	if _, err = ui.interject(line); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// writeHeld writes what SafeWrite held while Run wasn't waiting on
+// input. The prompt lock must be held.
+//
+func (ui *UI) writeHeld() error {
+	if len(ui.heldWrites) == 0 {
+		return nil
+	}
+	b := ui.heldWrites
+	ui.heldWrites = nil
+	_, err := ui.writePrompt(b)
+	return err
+}
@@ -0,0 +1,125 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUI_Interject(t *testing.T) {
+	testCases := []struct {
+		Name string
+		TTY  bool
+		Ex   string
+	}{
+		{Name: "Terminal", TTY: true, Ex: ">" + eraseLine + "job 1 done\n>\n"},
+		{Name: "NotTerminal", Ex: ">\njob 1 done\n>\n"},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.TTY }
+
+			pr, pw := io.Pipe()
+			defer pr.Close()
+			var out bytes.Buffer
+
+			ui := new(UI)
+			ui.SetPrefix(">")
+			ui.SetIO(pr, &out)
+			WithTheme(MonochromeTheme)(ui)
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- ui.Run(nil, new(testEchoEngine)) }()
+			for waiting := false; !waiting; time.Sleep(time.Millisecond) {
+				ui.promptMu.Lock()
+				waiting = ui.atPrompt
+				ui.promptMu.Unlock()
+			}
+
+			if err := ui.Interject("job 1 done"); err != nil {
+				subT.Error(err)
+			}
+			pw.Close()
+
+			err := <-errCh
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+// testLinesEngine writes 20 lines for every line it executes.
+type testLinesEngine struct{}
+
+func (testLinesEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	for i := 0; i < 20; i++ {
+		if _, err := ui.Write([]byte("engine\n")); err != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+func TestUI_SafeWrite(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	var out bytes.Buffer
+
+	ui := new(UI)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ui.Run(nil, testLinesEngine{}, WithPrefix(">"), WithIO(pr, &out))
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			ui.SafeWrite([]byte(fmt.Sprintf("external %d", i)))
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		io.WriteString(pw, "run\n")
+	}
+	wg.Wait()
+
+	// Writes held during the last command are written before the next prompt
+	io.WriteString(pw, "run\n")
+	pw.Close()
+	if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	var engineLines, external int
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.TrimLeft(line, ">")
+		switch {
+		case line == "":
+		case line == "engine":
+			engineLines++
+		case strings.HasPrefix(line, "external "):
+			external++
+		default:
+			t.Errorf("expected whole lines but instead received: %q", line)
+		}
+	}
+	if engineLines != 11*20 {
+		t.Errorf("expected %d engine lines but instead received: %d", 11*20, engineLines)
+	}
+	if external != 50 {
+		t.Errorf("expected %d external lines but instead received: %d", 50, external)
+	}
+}
@@ -0,0 +1,215 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JobState represents the state of a background job.
+type JobState int
+
+const (
+	// JobRunning is the state of a job whose Exec call hasn't returned.
+	JobRunning JobState = iota
+
+	// JobDone is the state of a job whose Exec call has returned.
+	JobDone
+
+	// JobCanceled is the state of a job canceled with CancelJob.
+	JobCanceled
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobRunning:
+		return "running"
+	case JobDone:
+		return "done"
+	case JobCanceled:
+		return "canceled"
+	default:
+		return "JobState(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// Job represents a command executed in the background.
+//
+type Job struct {
+	ID     int
+	Line   string
+	State  JobState
+	Status int // Status returned by Exec, once the job is no longer running
+}
+
+// jobTable tracks the background jobs of a UI.
+type jobTable struct {
+	sync.Mutex
+	nextID  int
+	jobs    map[int]*Job
+	cancels map[int]context.CancelFunc
+}
+
+// WithJobs enables background jobs. A line ending with "&" is
+// executed in the background, with the UI printing its job ID and
+// returning to the prompt immediately. The status of a background
+// job never ends Run. Since background jobs call Exec directly,
+// the Engine must be safe for concurrent use. The output of a job is
+// limited and wrapped on its own, see WithMaxOutputPerCommand.
+//
+// Along with this, the "jobs" builtin, for listing jobs, and the
+// "kill" builtin, for canceling a job by ID, are installed.
+//
+func WithJobs() Option {
+	return func(ui *UI) {
+		if ui.jobs == nil {
+			ui.jobs = &jobTable{
+				jobs:    make(map[int]*Job),
+				cancels: make(map[int]context.CancelFunc),
+			}
+		}
+		ui.addBuiltin("jobs", "list background jobs", jobsBuiltin)
+		ui.addBuiltin("kill", "cancel the background job with the given ID", killBuiltin)
+	}
+}
+
+// Jobs returns the background jobs, ordered by ID. Jobs which
+// are no longer running are kept until listed by the jobs builtin.
+//
+func (ui *UI) Jobs() []Job {
+	if ui.jobs == nil {
+		return nil
+	}
+
+	ui.jobs.Lock()
+	defer ui.jobs.Unlock()
+
+	jobs := make([]Job, 0, len(ui.jobs.jobs))
+	for _, j := range ui.jobs.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// CancelJob cancels the context of the background job with the given ID.
+//
+func (ui *UI) CancelJob(id int) error {
+	if ui.jobs == nil {
+		return errors.Errorf("sand: no such job, %d", id)
+	}
+
+	ui.jobs.Lock()
+	defer ui.jobs.Unlock()
+
+	j, ok := ui.jobs.jobs[id]
+	if !ok {
+		return errors.Errorf("sand: no such job, %d", id)
+	}
+	if j.State == JobRunning {
+		j.State = JobCanceled
+		ui.jobs.cancels[id]()
+	}
+	return nil
+}
+
+// execBackground starts the line as a background job, if it is one.
+func (ui *UI) execBackground(line string) bool {
+	if ui.jobs == nil {
+		return false
+	}
+
+	cmd := strings.TrimSpace(line)
+	if !strings.HasSuffix(cmd, "&") {
+		return false
+	}
+	cmd = strings.TrimSpace(strings.TrimSuffix(cmd, "&"))
+	if cmd == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(ui.ctx)
+	ui.jobs.Lock()
+	ui.jobs.nextID++
+	j := &Job{ID: ui.jobs.nextID, Line: cmd}
+	ui.jobs.jobs[j.ID] = j
+	ui.jobs.cancels[j.ID] = cancel
+	ui.jobs.Unlock()
+
+	ui.tracef("job", "[%d] %q", j.ID, cmd)
+	ui.write([]byte(fmt.Sprintf("[%d] %s\n", j.ID, cmd)))
+
+	ui.mu.Lock()
+	eng := ui.wrapped
+	ui.mu.Unlock()
+	out := new(cmdOutput)
+	if ui.wrap != nil {
+		out.wrap = new(wordWrapper)
+	}
+	ctx = context.WithValue(ctx, cmdOutKey{}, out)
+	go func() {
+		defer cancel()
+		status := 1
+		if ui.authorize(ctx, cmd) {
+			status = ui.execEngine(ctx, eng, cmd)
+		}
+
+		ui.jobs.Lock()
+		defer ui.jobs.Unlock()
+		j.Status = status
+		if j.State == JobRunning {
+			j.State = JobDone
+		}
+		delete(ui.jobs.cancels, j.ID)
+	}()
+	return true
+}
+
+// jobsBuiltin lists the background jobs and forgets those which are done.
+func jobsBuiltin(ctx context.Context, args []string, ui *UI) int {
+	var rows [][]string
+	for _, j := range ui.Jobs() {
+		state := j.State.String()
+		if j.State != JobRunning {
+			state += " (" + strconv.Itoa(j.Status) + ")"
+		}
+		rows = append(rows, []string{"[" + strconv.Itoa(j.ID) + "]", state, j.Line})
+
+		if j.State != JobRunning {
+			ui.jobs.Lock()
+			delete(ui.jobs.jobs, j.ID)
+			ui.jobs.Unlock()
+		}
+	}
+
+	var buf bytes.Buffer
+	writeTable(&buf, rows)
+	if _, err := ui.write(buf.Bytes()); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// killBuiltin cancels the background jobs with the given IDs.
+func killBuiltin(ctx context.Context, args []string, ui *UI) int {
+	if len(args) == 0 {
+		ui.write([]byte("usage: kill ID...\n"))
+		return 0
+	}
+
+	for _, arg := range args {
+		id, err := strconv.Atoi(strings.TrimPrefix(arg, "%"))
+		if err == nil {
+			err = ui.CancelJob(id)
+		}
+		if err != nil {
+			ui.write([]byte(ui.Theme().Error.Paint(fmt.Sprintf("sand: kill %s: no such job", arg)) + "\n"))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testWaitEngine blocks every line until its context is done.
+type testWaitEngine struct {
+	started chan string
+}
+
+func (eng *testWaitEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.started <- line
+	<-ctx.Done()
+	return 3
+}
+
+func TestWithJobs(t *testing.T) {
+	eng := &testWaitEngine{started: make(chan string, 1)}
+	var out bytes.Buffer
+	ui := &UI{prefix: []byte(">")}
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ui.Run(context.Background(), eng, WithIO(pr, &out), WithJobs())
+	}()
+
+	pw.Write([]byte("sleep 10 &\n"))
+	if line := <-eng.started; line != "sleep 10" {
+		t.Errorf("expected background line %q but instead received: %q", "sleep 10", line)
+	}
+
+	jobs := ui.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != 1 || jobs[0].State != JobRunning {
+		t.Fatalf("expected a single running job but instead received: %v", jobs)
+	}
+
+	pw.Write([]byte("jobs\n"))
+	pw.Write([]byte("kill 1\n"))
+	pw.Write([]byte("kill 7\n")) // Only read once kill 1 is done
+	if st := ui.Jobs()[0].State; st != JobCanceled {
+		t.Errorf("expected job to be canceled but instead received: %s", st)
+	}
+	pw.Close()
+
+	if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := ">[1] sleep 10\n>[1]  running  sleep 10\n>>sand: kill 7: no such job\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
+
+// testOutputJobEngine writes "j1" and "j2" for background jobs, with
+// the current command executed in between, see TestWithJobs_OutputLimit.
+//
+type testOutputJobEngine struct {
+	started, ran, release chan struct{}
+}
+
+func (eng *testOutputJobEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	if line != "job" {
+		ui.Write([]byte("123456\n"))
+		eng.ran <- struct{}{}
+		return 0
+	}
+	ui.Write([]byte("j1"))
+	eng.started <- struct{}{}
+	<-eng.release
+	ui.Write([]byte("j2"))
+	eng.ran <- struct{}{}
+	return 0
+}
+
+func TestWithJobs_OutputLimit(t *testing.T) {
+	eng := &testOutputJobEngine{started: make(chan struct{}), ran: make(chan struct{}), release: make(chan struct{})}
+	var out bytes.Buffer
+	ui := new(UI)
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ui.Run(context.Background(), eng, WithIO(pr, &out), WithJobs(), WithMaxOutputPerCommand(8))
+	}()
+
+	// Neither command counts the output of the other
+	pw.Write([]byte("job &\n"))
+	<-eng.started
+	pw.Write([]byte("fg\n"))
+	<-eng.ran
+	close(eng.release)
+	<-eng.ran
+	pw.Close()
+
+	if err, ok := IsRecoverable(<-errCh); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if s := out.String(); strings.Contains(s, "truncated") || !strings.Contains(s, "j2") {
+		t.Errorf("expected no output to be truncated but instead received: %q", s)
+	}
+}
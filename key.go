@@ -0,0 +1,122 @@
+package sand
+
+import (
+	"os"
+	"unicode/utf8"
+)
+
+// Synthetic keys returned by ReadKey for escape sequences. They
+// are all above utf8.MaxRune, so they never collide with input.
+//
+const (
+	KeyUp rune = utf8.MaxRune + 1 + iota
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPageUp
+	KeyPageDown
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+)
+
+// KeyEscape is returned by ReadKey for a lone escape.
+const KeyEscape rune = 0x1b
+
+// csiFinal maps the final byte of "ESC [ x" and "ESC O x" sequences to keys.
+var csiFinal = map[byte]rune{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+// csiTilde maps the parameter of "ESC [ n ~" sequences to keys.
+var csiTilde = map[string]rune{
+	"1":  KeyHome,
+	"2":  KeyInsert,
+	"3":  KeyDelete,
+	"4":  KeyEnd,
+	"5":  KeyPageUp,
+	"6":  KeyPageDown,
+	"7":  KeyHome,
+	"8":  KeyEnd,
+	"11": KeyF1,
+	"12": KeyF2,
+	"13": KeyF3,
+	"14": KeyF4,
+}
+
+// ReadKey reads a single keypress. If the input is a terminal, it
+// is put into raw mode for the duration of the call, so the key is
+// returned without waiting for Enter. Otherwise, the next rune is
+// read from the input. Recognized escape sequences, e.g. the arrow
+// keys, are returned as one of the synthetic Key constants, while
+// unrecognized ones are returned one rune at a time. In raw mode,
+// Ctrl-C generates an Interrupt instead of being returned.
+//
+func (ui *UI) ReadKey() (rune, error) {
+	if f, ok := ui.input().(*os.File); ok && isTerminal(f) {
+		if entered, err := ui.enterRaw(f); err == nil && entered {
+			defer ui.RestoreTerminal()
+		}
+	}
+
+	r, _, err := ui.ReadRune()
+	for err == nil && r == interruptKey && ui.inRawMode() {
+		raiseInterrupt()
+		r, _, err = ui.ReadRune()
+	}
+	if err != nil || r != KeyEscape {
+		return r, err
+	}
+
+	// Terminals send escape sequences in a single write, so only
+	// look at what's already been read instead of blocking
+	if key, n := parseEscape(ui.rbuf[ui.rpos:]); n > 0 {
+		ui.rpos += n
+		ui.canUnreadByte = false
+		ui.lastRuneSize = 0
+		return key, nil
+	}
+	return r, nil
+}
+
+// parseEscape parses the remainder of an escape sequence, after the
+// escape itself, and returns its key along with the bytes consumed.
+//
+func parseEscape(b []byte) (key rune, n int) {
+	if len(b) < 2 || b[0] != '[' && b[0] != 'O' {
+		return 0, 0
+	}
+	if key, ok := csiFinal[b[1]]; ok {
+		return key, 2
+	}
+	if b[0] != '[' {
+		return 0, 0
+	}
+
+	for i := 1; i < len(b) && i < 4; i++ {
+		if b[i] == '~' {
+			if key, ok := csiTilde[string(b[1:i])]; ok {
+				return key, i + 1
+			}
+			return 0, 0
+		}
+		if b[i] < '0' || b[i] > '9' {
+			return 0, 0
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,61 @@
+package sand
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUI_ReadKey(t *testing.T) {
+	testCases := []struct {
+		Name string
+		In   string
+		Ex   []rune
+	}{
+		{Name: "Runes", In: "yé", Ex: []rune{'y', 'é'}},
+		{Name: "Arrows", In: "\x1b[A\x1b[B\x1b[C\x1b[D", Ex: []rune{KeyUp, KeyDown, KeyRight, KeyLeft}},
+		{Name: "SS3", In: "\x1bOP\x1bOH", Ex: []rune{KeyF1, KeyHome}},
+		{Name: "Tilde", In: "\x1b[3~\x1b[6~\x1b[12~", Ex: []rune{KeyDelete, KeyPageDown, KeyF2}},
+		{Name: "LoneEscape", In: "\x1b", Ex: []rune{KeyEscape}},
+		{Name: "Unknown", In: "\x1b[9~", Ex: []rune{KeyEscape, '[', '9', '~'}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+
+			var keys []rune
+			for {
+				key, err := ui.ReadKey()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					subT.Fatal(err)
+				}
+				keys = append(keys, key)
+			}
+			if !reflect.DeepEqual(keys, tc.Ex) {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, keys)
+			}
+		})
+	}
+
+	t.Run("Canceled", func(subT *testing.T) {
+		pr, _ := io.Pipe()
+		defer pr.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ui := &UI{i: pr, ctx: ctx}
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		_, err := ui.ReadKey()
+		if err != context.Canceled {
+			subT.Errorf("expected %s but instead received: %v", context.Canceled, err)
+		}
+	})
+}
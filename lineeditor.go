@@ -0,0 +1,205 @@
+package sand
+
+import (
+	"context"
+	"fmt"
+)
+
+// LineEditor reads lines for Run, e.g. by wrapping a readline library
+// such as liner or go-prompt, in place of the UI reading its input
+// itself, see WithLineEditor.
+//
+type LineEditor interface {
+	// ReadLine writes the prompt, reads a line and returns it
+	// without its terminator. Once the input ends, it should
+	// return io.EOF, along with any last line lacking one. Its
+	// context is done once the session is.
+	ReadLine(ctx context.Context, prompt string) (string, error)
+}
+
+// WithLineEditor specifies the LineEditor to read the lines of the
+// session with, instead of the UI reading its input Reader, which is
+// still read by any call to Read or Ask from within Exec. The prompt
+// is rendered the same as otherwise, along with the prefix, countdown
+// and segments, but passed to ReadLine instead of being written.
+// Everything after reading, e.g. history, continuation and dispatch,
+// is the same as otherwise.
+//
+func WithLineEditor(e LineEditor) Option {
+	return func(ui *UI) {
+		ui.editor = e
+	}
+}
+
+// editLine reads the next line with the LineEditor of the UI. It
+// waits while the UI is paused, see Pause.
+//
+func (ui *UI) editLine(prompt []byte) ([]byte, error) {
+	if err := ui.waitResume(ui.ctx, nil); err != nil {
+		return nil, err
+	}
+
+	line, err := ui.editor.ReadLine(ui.ctx, string(prompt))
+	return []byte(line), err
+}
+
+// Key is a keypress, as returned by ReadKey, i.e. either a rune or
+// one of the synthetic Key constants, e.g. KeyUp.
+//
+type Key = rune
+
+// Editor is the line being edited by Prompt, with the cursor being
+// the index of the rune it's at, for key bindings to act on, see
+// WithKeyBinding. Its methods update the terminal along with the
+// line.
+//
+type Editor struct {
+	ui     *UI
+	prompt string
+	line   []rune
+	pos    int
+}
+
+// WithKeyBinding binds the key to the action, for the line editor of
+// Prompt. Bindings take precedence over the keys the editor handles
+// itself, except for Enter, and over the built-in bindings: Left,
+// Right, Home and End move the cursor, Ctrl-K kills the line after
+// the cursor, see KillLine, Ctrl-Y yanks it back, see Yank, and
+// Ctrl-L clears the screen, see ClearScreen. A nil action unbinds
+// the key.
+//
+func WithKeyBinding(key Key, action func(ed *Editor)) Option {
+	return func(ui *UI) {
+		if ui.keys == nil {
+			ui.keys = make(map[Key]func(*Editor))
+		}
+		ui.keys[key] = action
+	}
+}
+
+// defaultKeyBindings are the built-in bindings of the line editor,
+// see WithKeyBinding.
+//
+var defaultKeyBindings = map[Key]func(*Editor){
+	KeyLeft:  func(ed *Editor) { ed.SetCursor(ed.pos - 1) },
+	KeyRight: func(ed *Editor) { ed.SetCursor(ed.pos + 1) },
+	KeyHome:  func(ed *Editor) { ed.SetCursor(0) },
+	KeyEnd:   func(ed *Editor) { ed.SetCursor(len(ed.line)) },
+	0x0b:     KillLine,    // Ctrl-K
+	0x19:     Yank,        // Ctrl-Y
+	0x0c:     ClearScreen, // Ctrl-L
+}
+
+// keyBinding returns the action bound to the key, if any.
+func (ui *UI) keyBinding(key Key) func(*Editor) {
+	if action, ok := ui.keys[key]; ok {
+		return action
+	}
+	return defaultKeyBindings[key]
+}
+
+// KillLine deletes the line after the cursor, keeping it for Yank.
+func KillLine(ed *Editor) {
+	ed.ui.killed = ed.Delete(ed.pos, len(ed.line))
+}
+
+// Yank inserts the text last deleted by KillLine at the cursor.
+func Yank(ed *Editor) {
+	ed.Insert(ed.ui.killed)
+}
+
+// ClearScreen clears the terminal and redraws the line at its top.
+func ClearScreen(ed *Editor) {
+	ed.ui.writePrompt([]byte(clearScreen))
+	ed.Redraw()
+}
+
+// UI returns the UI the line is edited in, e.g. for writing to it.
+func (ed *Editor) UI() *UI { return ed.ui }
+
+// Line returns the line being edited.
+func (ed *Editor) Line() string { return string(ed.line) }
+
+// Cursor returns the index of the rune the cursor is at.
+func (ed *Editor) Cursor() int { return ed.pos }
+
+// SetLine replaces the line, moving the cursor to its end.
+func (ed *Editor) SetLine(line string) {
+	ed.line, ed.pos = []rune(line), len([]rune(line))
+	ed.Redraw()
+}
+
+// SetCursor moves the cursor to the rune at pos, within the line.
+func (ed *Editor) SetCursor(pos int) {
+	if pos < 0 {
+		pos = 0
+	} else if pos > len(ed.line) {
+		pos = len(ed.line)
+	}
+
+	switch {
+	case pos < ed.pos:
+		ed.ui.writePrompt([]byte(fmt.Sprintf("\x1b[%dD", displayWidth(string(ed.line[pos:ed.pos])))))
+	case pos > ed.pos:
+		ed.ui.writePrompt([]byte(fmt.Sprintf("\x1b[%dC", displayWidth(string(ed.line[ed.pos:pos])))))
+	}
+	ed.pos = pos
+}
+
+// Insert inserts s at the cursor, moving the cursor past it.
+func (ed *Editor) Insert(s string) {
+	if s == "" {
+		return
+	}
+	rs := []rune(s)
+	atEnd := ed.pos == len(ed.line)
+	ed.line = append(ed.line[:ed.pos], append(rs, ed.line[ed.pos:]...)...)
+	ed.pos += len(rs)
+	if atEnd {
+		ed.ui.writePrompt([]byte(s))
+		return
+	}
+	ed.Redraw()
+}
+
+// Delete deletes the runes of the line from from to to, moving the
+// cursor to from if it's after it, and returns them.
+//
+func (ed *Editor) Delete(from, to int) string {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(ed.line) {
+		to = len(ed.line)
+	}
+	if from >= to {
+		return ""
+	}
+
+	deleted := string(ed.line[from:to])
+	atEnd := to == len(ed.line) && ed.pos == to
+	ed.line = append(ed.line[:from], ed.line[to:]...)
+	switch {
+	case ed.pos >= to:
+		ed.pos -= to - from
+	case ed.pos > from:
+		ed.pos = from
+	}
+	if atEnd && to-from == 1 {
+		ed.ui.writePrompt([]byte("\b \b"))
+		return deleted
+	}
+	ed.Redraw()
+	return deleted
+}
+
+// Redraw writes the prompt and line again, over the current one,
+// with the cursor where it was.
+//
+func (ed *Editor) Redraw() {
+	b := []byte(eraseLine + ed.prompt + string(ed.line))
+	if back := displayWidth(string(ed.line[ed.pos:])); back > 0 {
+		b = append(b, fmt.Sprintf("\x1b[%dD", back)...)
+	}
+	ed.ui.writePrompt(b)
+}
@@ -0,0 +1,88 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testLineEditor returns its lines, one per ReadLine call, and
+// records the prompts it was given.
+//
+type testLineEditor struct {
+	lines   []string
+	prompts []string
+}
+
+func (e *testLineEditor) ReadLine(ctx context.Context, prompt string) (string, error) {
+	e.prompts = append(e.prompts, prompt)
+	if len(e.lines) == 0 {
+		return "", io.EOF
+	}
+	line := e.lines[0]
+	e.lines = e.lines[1:]
+	return line, nil
+}
+
+func TestRunWithLineEditor(t *testing.T) {
+	editor := &testLineEditor{lines: []string{"a", "", "b"}}
+	var out bytes.Buffer
+	err := Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(strings.NewReader("unread\n"), &out), WithLineEditor(editor))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	ex := ">a\n>b\n\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if p := strings.Join(editor.prompts, ""); p != ">>>>" {
+		t.Errorf("expected %q but instead received: %q", ">>>>", p)
+	}
+}
+
+func TestWithKeyBinding(t *testing.T) {
+	upper := WithKeyBinding(0x14, func(ed *Editor) { ed.SetLine(strings.ToUpper(ed.Line())) }) // Ctrl-T
+
+	testCases := []struct {
+		Name  string
+		In    string
+		Opts  []Option
+		Ex    string
+		ExOut string
+	}{
+		{Name: "Custom", In: "ab\x14\r", Opts: []Option{upper}, Ex: "AB", ExOut: "ab\r\x1b[K> AB\n"},
+		{Name: "InsertMiddle", In: "ac\x1b[Db\r", Ex: "abc", ExOut: "ac\x1b[1D\r\x1b[K> abc\x1b[1D\n"},
+		{Name: "BackspaceMiddle", In: "abc\x1b[D\x7f\r", Ex: "ac", ExOut: "abc\x1b[1D\r\x1b[K> ac\x1b[1D\n"},
+		{Name: "KillYank", In: "abc\x1b[D\x1b[D\x0b\x19\r", Ex: "abc", ExOut: "abc\x1b[1D\x1b[1D\r\x1b[K> abc\n"},
+		{Name: "HomeEnd", In: "ab\x1b[Hc\x1b[Fd\r", Ex: "cabd", ExOut: "ab\x1b[2D\r\x1b[K> cab\x1b[2D\x1b[2Cd\n"},
+		{Name: "Clear", In: "a\x0c\r", Ex: "a", ExOut: "a" + clearScreen + "\r\x1b[K> a\n"},
+		{Name: "Override", In: "ab\x0b\r", Opts: []Option{WithKeyBinding(0x0b, func(ed *Editor) { ed.Insert("!") })}, Ex: "ab!", ExOut: "ab!\n"},
+		{Name: "Unbound", In: "ab\x1b[Dc\r", Opts: []Option{WithKeyBinding(KeyLeft, nil)}, Ex: "abc", ExOut: "abc\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+			ui.out, ui.promptOut = &out, &out
+			for _, opt := range tc.Opts {
+				opt(ui)
+			}
+
+			line, err := ui.readEdited(PromptOptions{Prompt: "> "})
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if line != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, line)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
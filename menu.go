@@ -0,0 +1,174 @@
+package sand
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MenuHandler is called with the UI once its choice is selected.
+type MenuHandler func(ui *UI) error
+
+// DefaultMenuPrompt is the prompt of a Menu without one of its own.
+const DefaultMenuPrompt = "choice: "
+
+// Menu is a list of choices for an Engine to present to the user, e.g.
+// the main menu of a game, which dispatches the one that's selected:
+//
+//	m := sand.NewMenu("Main menu")
+//	m.Add("1", "Play", play)
+//	m.Add("2", "Quit", quit)
+//	err := m.Run(ui)
+//
+type Menu struct {
+	// Title is written above the choices, unless it's empty.
+	Title string
+
+	// Prompt is written before reading a selection, DefaultMenuPrompt
+	// if empty.
+	Prompt string
+
+	// Keypress selects a choice with a single key, see ReadKey,
+	// instead of a line. Keys must then all be a single rune.
+	Keypress bool
+
+	choices []menuChoice
+}
+
+// menuChoice is a choice added to a Menu.
+type menuChoice struct {
+	key, label string
+	handler    MenuHandler
+}
+
+// NewMenu returns an empty Menu with the given title.
+func NewMenu(title string) *Menu {
+	return &Menu{Title: title}
+}
+
+// Add adds a choice, selected by key, which is listed along with its
+// label in the order choices were added. Add panics if key is empty,
+// contains whitespace, or is already added.
+//
+func (m *Menu) Add(key, label string, handler MenuHandler) {
+	if key == "" || strings.IndexFunc(key, unicode.IsSpace) != -1 {
+		panic(fmt.Errorf("sand: invalid menu key %q", key))
+	}
+	if m.lookup(key) != nil {
+		panic(fmt.Errorf("sand: multiple menu choices for key %q", key))
+	}
+	m.choices = append(m.choices, menuChoice{key: key, label: label, handler: handler})
+}
+
+// lookup returns the choice selected by key, if any.
+func (m *Menu) lookup(key string) *menuChoice {
+	for i := range m.choices {
+		if m.choices[i].key == key {
+			return &m.choices[i]
+		}
+	}
+	return nil
+}
+
+// Run writes the menu, reads selections until one matches a choice,
+// writing an error for every one that doesn't, and calls the handler
+// of that choice, whose error is returned. If reading fails, e.g.
+// because the session is done, the error is returned without calling
+// any handler.
+//
+func (m *Menu) Run(ui *UI) error {
+	if err := m.render(ui); err != nil {
+		return err
+	}
+
+	var c *menuChoice
+	var err error
+	if m.Keypress {
+		c, err = m.readKey(ui)
+	} else {
+		c, err = m.readLine(ui)
+	}
+	if err != nil {
+		return err
+	}
+	if c.handler == nil {
+		return nil
+	}
+	return c.handler(ui)
+}
+
+// render writes the title along with the choices.
+func (m *Menu) render(ui *UI) error {
+	theme := ui.Theme()
+
+	var buf bytes.Buffer
+	if m.Title != "" {
+		buf.WriteString(theme.Header.Paint(m.Title) + "\n")
+	}
+	rows := make([][]string, 0, len(m.choices))
+	for _, c := range m.choices {
+		rows = append(rows, []string{theme.Suggestion.Paint(c.key), c.label})
+	}
+	writeTable(&buf, rows)
+
+	_, err := ui.write(buf.Bytes())
+	return err
+}
+
+// prompt returns the prompt written before reading a selection.
+func (m *Menu) prompt() string {
+	if m.Prompt == "" {
+		return DefaultMenuPrompt
+	}
+	return m.Prompt
+}
+
+// invalidChoice returns the error written for a selection matching no choice.
+func invalidChoice(key string) error {
+	return fmt.Errorf("sand: invalid choice %q", key)
+}
+
+// readLine reads lines until one selects a choice.
+func (m *Menu) readLine(ui *UI) (*menuChoice, error) {
+	answer, err := ui.AskValidated(m.prompt(), func(s string) error {
+		if m.lookup(strings.TrimSpace(s)) == nil {
+			return invalidChoice(strings.TrimSpace(s))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.lookup(strings.TrimSpace(answer)), nil
+}
+
+// readKey reads keys until one selects a choice, echoing each one.
+func (m *Menu) readKey(ui *UI) (*menuChoice, error) {
+	if _, err := ui.writePrompt([]byte(m.prompt())); err != nil {
+		return nil, err
+	}
+	for {
+		r, err := ui.ReadKey()
+		if err != nil {
+			return nil, err
+		}
+		if r == '\r' || r == '\n' || r == ' ' || r > utf8.MaxRune {
+			continue
+		}
+
+		key := string(r)
+		if _, err = ui.writePrompt([]byte(key + "\n")); err != nil {
+			return nil, err
+		}
+		if c := m.lookup(key); c != nil {
+			return c, nil
+		}
+
+		msg := ui.Theme().Error.Paint(invalidChoice(key).Error()) + "\n" + m.prompt()
+		if _, err = ui.writePrompt([]byte(msg)); err != nil {
+			return nil, err
+		}
+	}
+}
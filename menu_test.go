@@ -0,0 +1,108 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMenu_Run(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Keypress bool
+		In       string
+		Ex       string
+		ExOut    string
+	}{
+		{
+			Name:  "Line",
+			In:    "2\n",
+			Ex:    "quit",
+			ExOut: "Main\n1  Play\n2  Quit\nchoice: ",
+		},
+		{
+			Name:  "InvalidLine",
+			In:    "9\n 1 \n",
+			Ex:    "play",
+			ExOut: "Main\n1  Play\n2  Quit\nchoice: sand: invalid choice \"9\"\nchoice: ",
+		},
+		{
+			Name:     "Keypress",
+			Keypress: true,
+			In:       "x2",
+			Ex:       "quit",
+			ExOut:    "Main\n1  Play\n2  Quit\nchoice: x\nsand: invalid choice \"x\"\nchoice: 2\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+			ui.out, ui.promptOut = &out, &out
+
+			var selected string
+			m := NewMenu("Main")
+			m.Keypress = tc.Keypress
+			m.Add("1", "Play", func(*UI) error { selected = "play"; return nil })
+			m.Add("2", "Quit", func(*UI) error { selected = "quit"; return nil })
+
+			if err := m.Run(ui); err != nil {
+				subT.Error(err)
+			}
+			if selected != tc.Ex {
+				subT.Errorf("expected %q to be selected but instead received: %q", tc.Ex, selected)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected output %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestMenu_RunHandlerError(t *testing.T) {
+	ui := &UI{i: strings.NewReader("q\n"), ctx: context.Background()}
+	ui.out, ui.promptOut = new(bytes.Buffer), new(bytes.Buffer)
+
+	exErr := errors.New("quit")
+	m := NewMenu("")
+	m.Add("q", "Quit", func(*UI) error { return exErr })
+	if err := m.Run(ui); err != exErr {
+		t.Errorf("expected %v but instead received: %v", exErr, err)
+	}
+}
+
+func TestMenu_RunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pr, _ := io.Pipe()
+	defer pr.Close()
+	ui := &UI{i: pr, ctx: ctx}
+	ui.out, ui.promptOut = new(bytes.Buffer), new(bytes.Buffer)
+
+	m := NewMenu("")
+	m.Add("1", "Play", func(*UI) error { t.Errorf("expected handler to not be called"); return nil })
+	if err := m.Run(ui); err != context.Canceled {
+		t.Errorf("expected %v but instead received: %v", context.Canceled, err)
+	}
+}
+
+func TestMenu_Add(t *testing.T) {
+	for _, key := range []string{"", "a b", "1"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for key %q", key)
+				}
+			}()
+			m := NewMenu("")
+			m.Add("1", "Play", nil)
+			m.Add(key, "Other", nil)
+		}()
+	}
+}
@@ -0,0 +1,58 @@
+package sand
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Middleware wraps an Engine, e.g. for logging or timing every command,
+// or rejecting some of them, without baking it into the Engine itself.
+// The returned Engine is given every line, along with the io.ReadWriter
+// of the UI, and usually calls Exec of next, unless it short-circuits
+// the command by returning a status itself.
+//
+type Middleware func(next Engine) Engine
+
+// WithMiddleware wraps the Engine of the UI with the middlewares,
+// outermost first, so the first one is given every line before any of
+// the others. They're applied to every Engine the UI executes commands
+// with, including background jobs, see SwapEngine and WithJobs.
+//
+func WithMiddleware(mws ...Middleware) Option {
+	return func(ui *UI) {
+		ui.middleware = append(ui.middleware, mws...)
+	}
+}
+
+// wrapEngine wraps eng with the middlewares of the UI, once it's
+// attached, so the chain isn't built again for every command.
+//
+func (ui *UI) wrapEngine(eng Engine) Engine {
+	var h Engine = EngineFunc(func(ctx context.Context, line string, rw io.ReadWriter) int {
+		return ui.callEngine(ctx, eng, line, rw)
+	})
+	for i := len(ui.middleware) - 1; i >= 0; i-- {
+		h = ui.middleware[i](h)
+	}
+	return h
+}
+
+// RecoverMiddleware returns a Middleware which recovers a panic of
+// the Exec call of a command, writing it to the UI, and returns a
+// status of 1 instead, so the command fails without ending the
+// session, see also WithoutPanicRecovery.
+//
+func RecoverMiddleware() Middleware {
+	return func(next Engine) Engine {
+		return EngineFunc(func(ctx context.Context, line string, ui io.ReadWriter) (status int) {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintln(ui, newPanicError(r).Error())
+					status = 1
+				}
+			}()
+			return next.Exec(ctx, line, ui)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testTagMiddleware writes its tag before every command and rejects
+// lines starting with reject.
+func testTagMiddleware(tag, reject string) Middleware {
+	return func(next Engine) Engine {
+		return EngineFunc(func(ctx context.Context, line string, ui io.ReadWriter) int {
+			ui.Write([]byte(tag))
+			if reject != "" && strings.HasPrefix(line, reject) {
+				return 3
+			}
+			return next.Exec(ctx, line, ui)
+		})
+	}
+}
+
+// testPanicEngine panics on "panic" and echos anything else.
+type testPanicEngine struct {
+	testEchoEngine
+}
+
+func (eng *testPanicEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	if strings.TrimSpace(line) == "panic" {
+		panic("boom")
+	}
+	return eng.testEchoEngine.Exec(ctx, line, ui)
+}
+
+func TestRunWithMiddleware(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Lines []string
+		Mws   []Middleware
+		Opts  []Option
+		Ex    string
+	}{
+		{
+			Name:  "Order",
+			Lines: []string{"a\n", "b\n"},
+			Mws:   []Middleware{testTagMiddleware("1", ""), testTagMiddleware("2", "")},
+			Ex:    ">>1>2>a\n>>1>2>b\n>\n",
+		},
+		{
+			Name:  "ShortCircuit",
+			Lines: []string{"a\n", "x\n", "b\n"},
+			Mws:   []Middleware{testTagMiddleware("1", "x"), testTagMiddleware("2", "")},
+			Ex:    ">>1>2>a\n>>1\n",
+		},
+		{
+			Name:  "Recover",
+			Lines: []string{"panic\n", "a\n"},
+			Mws:   []Middleware{RecoverMiddleware()},
+			Opts:  []Option{WithContinueOnError()},
+			Ex:    ">>sand: recovered from panic: boom\n>>a\n>\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			in := &testLineReader{lines: tc.Lines}
+			opts := append([]Option{WithPrefix(">"), WithIO(in, &out), WithMiddleware(tc.Mws...)}, tc.Opts...)
+			err := Run(nil, new(testPanicEngine), opts...)
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Fatal(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestRunWithMiddleware_WrapsOnce(t *testing.T) {
+	var wraps int
+	count := func(next Engine) Engine {
+		wraps++
+		return next
+	}
+
+	in := &testLineReader{lines: []string{"a\n", "b\n", "c\n"}}
+	err := Run(nil, new(testEchoEngine), WithIO(in, ioutil.Discard), WithMiddleware(count))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if wraps != 1 {
+		t.Errorf("expected the engine to be wrapped once but instead it was wrapped %d times", wraps)
+	}
+}
@@ -0,0 +1,130 @@
+package sand
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+)
+
+// inputSourceKey is the context key for the name of the input a line
+// was read from, see InputSource.
+type inputSourceKey struct{}
+
+// InputSource returns the name of the input, added by AddInput, the
+// line being executed was read from. It returns an empty string for
+// lines read from the input Reader of the UI itself.
+//
+func InputSource(ctx context.Context) string {
+	name, _ := ctx.Value(inputSourceKey{}).(string)
+	return name
+}
+
+// sourcedLine is a line read from an input added by AddInput.
+type sourcedLine struct {
+	source string
+	line   string
+}
+
+// inputMux queues the lines read from the inputs added by AddInput,
+// in the order they were read, until Run executes them.
+//
+type inputMux struct {
+	mu      sync.Mutex
+	lines   []sourcedLine
+	waiting bool // set while Run is waiting on the next line
+}
+
+// AddInput adds r as another source of lines, e.g. a control socket
+// alongside the terminal, which drives the same Engine. Lines from
+// every input are executed one at a time, in the order they were
+// read, and a line is never interleaved with another one. If Run is
+// waiting on the terminal, the wait is interrupted by a line from r,
+// which is written after the prompt as "[name] line".
+//
+// The name of the input is available to the Engine through the
+// context passed to Exec, see InputSource. The output of the Engine,
+// however, always goes to the output Writer of the UI, whichever
+// input the line came from.
+//
+// Reading from r starts immediately and stops on its first error,
+// e.g. io.EOF, which, unlike for the input Reader of the UI, doesn't
+// end the session. Run never closes r.
+//
+func (ui *UI) AddInput(name string, r io.Reader) {
+	ui.mu.Lock()
+	if ui.inputs == nil {
+		ui.inputs = new(inputMux)
+	}
+	m := ui.inputs
+	ui.mu.Unlock()
+
+	go ui.readInput(m, name, r)
+}
+
+// readInput queues the lines of r until it fails.
+func (ui *UI) readInput(m *inputMux, name string, r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			m.mu.Lock()
+			m.lines = append(m.lines, sourcedLine{source: name, line: line})
+			if m.waiting {
+				ui.CancelRead()
+			}
+			m.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readNext reads the next chunk of input for Run, which is a queued
+// line from an added input, if there is one and no incomplete line
+// is pending, or else the next chunk of its own input, see readChunk.
+// It waits while the UI is paused, see Pause.
+//
+func (ui *UI) readNext(b []byte, pending string) (_ []byte, source string, err error) {
+	if err = ui.waitResume(ui.ctx, nil); err != nil {
+		return b[:0], "", err
+	}
+
+	ui.mu.Lock()
+	m := ui.inputs
+	ui.mu.Unlock()
+	if m == nil || pending != "" {
+		b, err = ui.readChunk(b)
+		return b, "", err
+	}
+
+	m.mu.Lock()
+	if len(m.lines) > 0 {
+		l := m.lines[0]
+		m.lines = m.lines[1:]
+		m.mu.Unlock()
+		return []byte(l.line), l.source, nil
+	}
+	// The read is armed before waiting is set, so a line queued in
+	// between still interrupts it.
+	cancel := ui.startCancelableRead()
+	m.waiting = true
+	m.mu.Unlock()
+
+	b, err = ui.readChunk(b)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ui.endCancelableRead(cancel)
+	m.waiting = false
+
+	// A line interrupting the read is executed right away, without
+	// writing the prompt again.
+	if err == ErrReadInterrupted && len(b) == 0 && len(m.lines) > 0 {
+		l := m.lines[0]
+		m.lines = m.lines[1:]
+		return []byte(l.line), l.source, nil
+	}
+	return b, "", err
+}
@@ -0,0 +1,68 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSourceEngine records the source of every line it receives.
+type testSourceEngine struct {
+	mu    sync.Mutex
+	execs []string
+}
+
+func (eng *testSourceEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	eng.execs = append(eng.execs, InputSource(ctx)+":"+strings.TrimSpace(line))
+	return 0
+}
+
+func (eng *testSourceEngine) received() []string {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	return append([]string(nil), eng.execs...)
+}
+
+func TestUI_AddInput(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	var out bytes.Buffer
+
+	ui := new(UI)
+	ui.SetPrefix(">")
+	ui.SetIO(pr, &out)
+
+	eng := new(testSourceEngine)
+	errCh := make(chan error, 1)
+	go func() { errCh <- ui.Run(nil, eng) }()
+
+	// The terminal is waiting, so the line must interrupt it
+	ui.AddInput("ctl", strings.NewReader("status\n"))
+	for start := time.Now(); len(eng.received()) == 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("expected line from added input to be executed")
+		}
+	}
+
+	pw.Write([]byte("hello\n"))
+	pw.Close()
+
+	err := <-errCh
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := []string{"ctl:status", ":hello"}
+	if execs := eng.received(); strings.Join(execs, ",") != strings.Join(ex, ",") {
+		t.Errorf("expected %v but instead received: %v", ex, execs)
+	}
+	if exOut := ">[ctl] status\n>>\n"; out.String() != exOut {
+		t.Errorf("expected %q but instead received: %q", exOut, out.String())
+	}
+}
@@ -0,0 +1,410 @@
+package sand
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Mux is an Engine which routes lines to other Engines based on
+// the first whitespace delimited token of the line, the verb. The
+// routed Engine is given the remainder of the line. Lines with an
+// unknown verb result in StatusNotHandled, so a Mux can be used
+// within a Chain, unless set otherwise, see SetUnknownStatus.
+//
+type Mux struct {
+	mu          sync.RWMutex
+	routes      map[string]muxRoute
+	nextRoute   int               // id of the latest route, see muxRoute
+	categories  map[string]string // see SetCategory
+	descs       map[string]string // see SetDescription
+	prefixMatch bool
+	syntaxCheck bool
+	parser      VerbParser
+	unknown     *unknownVerb // see SetUnknownStatus
+}
+
+// muxRoute is the Engine registered for a verb. Aliases, i.e. verbs
+// registered for the same Engine, share the id of the route, so
+// routes are told apart without comparing their Engines, which may
+// not be comparable.
+//
+type muxRoute struct {
+	eng Engine
+	id  int
+}
+
+// unknownVerb is how a Mux handles lines with an unknown verb.
+type unknownVerb struct {
+	status int
+	report bool
+}
+
+// VerbParser splits a line into the verb used for routing and the
+// remainder, which is given to the routed Engine.
+//
+type VerbParser func(line string) (verb, rest string)
+
+// NewMux returns an empty Mux.
+func NewMux() *Mux {
+	return &Mux{routes: make(map[string]muxRoute)}
+}
+
+// Handle registers the Engine for the given verb. Registering the
+// same Engine for multiple verbs makes them aliases of one another.
+// Handle panics if the verb is empty, contains whitespace, or is
+// already registered.
+//
+func (m *Mux) Handle(verb string, eng Engine) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	eng = m.checkRoute(verb, eng)
+	m.routes[verb] = m.newRoute(eng)
+}
+
+// HandleFunc registers fn for the given verb, the same as Handle,
+// e.g. for a small command which doesn't need a type of its own.
+// Unlike Engines registered by Handle, funcs are never aliases of
+// one another.
+//
+func (m *Mux) HandleFunc(verb string, fn func(ctx context.Context, line string, ui io.ReadWriter) int) {
+	if fn == nil {
+		panic(errNoEngine)
+	}
+	m.Handle(verb, EngineFunc(fn))
+}
+
+// HandleAll registers every Engine for its verb, the same as Handle,
+// e.g. for a command table. It panics the same as Handle, in which
+// case none of the verbs are registered.
+//
+func (m *Mux) HandleAll(routes map[string]Engine) {
+	verbs := make([]string, 0, len(routes))
+	for verb := range routes {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	engs := make([]Engine, len(verbs))
+	for i, verb := range verbs {
+		engs[i] = m.checkRoute(verb, routes[verb])
+	}
+	for i, verb := range verbs {
+		m.routes[verb] = m.newRoute(engs[i])
+	}
+}
+
+// newRoute returns the route of eng, which shares the id of a route
+// already registered for the same Engine, if any. The lock must be
+// held.
+//
+func (m *Mux) newRoute(eng Engine) muxRoute {
+	for _, r := range m.routes {
+		if sameEngine(r.eng, eng) {
+			return muxRoute{eng: eng, id: r.id}
+		}
+	}
+	m.nextRoute++
+	return muxRoute{eng: eng, id: m.nextRoute}
+}
+
+// sameEngine reports whether a and b are equal, treating Engines
+// which can't be compared, e.g. a struct holding a func, as distinct
+// instead of panicking.
+//
+func sameEngine(a, b Engine) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// checkRoute panics unless the Engine can be registered for the verb
+// and returns the Engine to register, which wraps an EngineFunc. The
+// lock must be held.
+//
+func (m *Mux) checkRoute(verb string, eng Engine) Engine {
+	if verb == "" || strings.IndexFunc(verb, unicode.IsSpace) != -1 {
+		panic(fmt.Errorf("sand: invalid mux verb %q", verb))
+	}
+	if eng == nil {
+		panic(errNoEngine)
+	}
+	if fn, ok := eng.(EngineFunc); ok {
+		eng = NewFuncEngine(fn)
+	}
+
+	if m.routes == nil {
+		m.routes = make(map[string]muxRoute)
+	}
+	if _, exists := m.routes[verb]; exists {
+		panic(fmt.Errorf("sand: multiple registrations for verb %q", verb))
+	}
+	return eng
+}
+
+// HandleWithTimeout is the same as Handle, except that the context of
+// every command routed to eng has a deadline of d, e.g. for a slow
+// command among fast ones. The deadline applies on top of the context
+// of the command, so an earlier deadline, e.g. of the session, see
+// WithSessionTimeout, or of an enclosing Mux route, still takes
+// precedence. A non-positive d is the same as no timeout.
+//
+func (m *Mux) HandleWithTimeout(verb string, eng Engine, d time.Duration) {
+	if eng != nil && d > 0 {
+		eng = timeoutEngine{eng: eng, timeout: d}
+	}
+	m.Handle(verb, eng)
+}
+
+// timeoutEngine executes lines with a deadline, see HandleWithTimeout.
+type timeoutEngine struct {
+	eng     Engine
+	timeout time.Duration
+}
+
+func (e timeoutEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.eng.Exec(ctx, line, ui)
+}
+
+// EnablePrefixMatching allows verbs to be abbreviated, as long as
+// the abbreviation is unambiguous, e.g. "stat" for "status". An
+// exact match always wins. An abbreviation shared by multiple
+// verbs is reported to the UI along with the candidates, unless
+// they are all aliases of the same Engine.
+//
+func (m *Mux) EnablePrefixMatching() {
+	m.mu.Lock()
+	m.prefixMatch = true
+	m.mu.Unlock()
+}
+
+// EnableSyntaxCheck checks the quoting of every line, see Fields,
+// before routing it. A malformed line isn't routed, instead the syntax
+// error is reported to the UI below the line, with a caret pointing at
+// the offending character, see CaretLine.
+//
+func (m *Mux) EnableSyntaxCheck() {
+	m.mu.Lock()
+	m.syntaxCheck = true
+	m.mu.Unlock()
+}
+
+// SetParser replaces how lines are split into their verb and the
+// remainder, for grammars which aren't delimited by whitespace. A
+// nil parser restores the default of splitting on whitespace.
+//
+func (m *Mux) SetParser(p VerbParser) {
+	m.mu.Lock()
+	m.parser = p
+	m.mu.Unlock()
+}
+
+// SetUnknownStatus makes lines with an unknown verb result in status,
+// instead of StatusNotHandled, e.g. for a Mux which isn't used within
+// a Chain. If report is true, the unknown verb is reported to the UI
+// as well.
+//
+func (m *Mux) SetUnknownStatus(status int, report bool) {
+	m.mu.Lock()
+	m.unknown = &unknownVerb{status: status, report: report}
+	m.mu.Unlock()
+}
+
+// Commands returns the registered verbs, sorted.
+func (m *Mux) Commands() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	verbs := make([]string, 0, len(m.routes))
+	for verb := range m.routes {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+	return verbs
+}
+
+// SetCategory puts the verbs in the given category, which the help
+// builtin lists them under, see Categorizer. The verbs needn't be
+// registered yet. An empty category removes them from theirs.
+//
+func (m *Mux) SetCategory(category string, verbs ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.categories == nil {
+		m.categories = make(map[string]string)
+	}
+	for _, verb := range verbs {
+		if category == "" {
+			delete(m.categories, verb)
+			continue
+		}
+		m.categories[verb] = category
+	}
+}
+
+// Category returns the category of the verb, see SetCategory.
+func (m *Mux) Category(verb string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.categories[verb]
+}
+
+// SetDescription describes the verb, e.g. with a summary of what it
+// does, which is listed beside it when completing verbs, see
+// CompleteCandidates. The verb needn't be registered yet. An empty
+// description removes the verb's.
+//
+func (m *Mux) SetDescription(verb, description string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.descs == nil {
+		m.descs = make(map[string]string)
+	}
+	if description == "" {
+		delete(m.descs, verb)
+		return
+	}
+	m.descs[verb] = description
+}
+
+// Complete returns the candidates for completing line, see Completer.
+// While the verb is typed, they're the registered verbs it's a prefix
+// of. After it, they're those of the Engine routed to for the rest of
+// the line, if it's a Completer, prefixed with the verb. Verbs are
+// delimited by whitespace, regardless of the VerbParser.
+//
+func (m *Mux) Complete(line string) []string {
+	return candidateTexts(m.CompleteCandidates(line))
+}
+
+// CompleteCandidates is the same as Complete, except that verbs are
+// described as set by SetDescription, see CandidateCompleter.
+//
+func (m *Mux) CompleteCandidates(line string) []Candidate {
+	verb, rest := splitVerb(line)
+	if verb+rest != strings.TrimLeftFunc(line, unicode.IsSpace) {
+		eng, _ := m.lookup(verb)
+		c, ok := eng.(Completer)
+		if !ok {
+			return nil
+		}
+		cands := completeCandidates(c, rest)
+		prefix := line[:len(line)-len(rest)]
+		for i := range cands {
+			cands[i].Text = prefix + cands[i].Text
+		}
+		return cands
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	lead := line[:len(line)-len(verb)]
+	var cands []Candidate
+	for v := range m.routes {
+		if strings.HasPrefix(v, verb) {
+			cands = append(cands, Candidate{Text: lead + v, Description: m.descs[v]})
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool { return cands[i].Text < cands[j].Text })
+	return cands
+}
+
+// splitVerb splits the line into its first whitespace delimited token and the remainder.
+func splitVerb(line string) (verb, rest string) {
+	line = strings.TrimLeftFunc(line, unicode.IsSpace)
+	i := strings.IndexFunc(line, unicode.IsSpace)
+	if i == -1 {
+		return line, ""
+	}
+	return line[:i], strings.TrimLeftFunc(line[i:], unicode.IsSpace)
+}
+
+// lookup returns the Engine for the verb and,
+// if the verb is ambiguous, the candidates.
+//
+func (m *Mux) lookup(verb string) (eng Engine, candidates []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if r, exists := m.routes[verb]; exists || !m.prefixMatch || verb == "" {
+		return r.eng, nil
+	}
+
+	var match muxRoute
+	aliases := true
+	for v, r := range m.routes {
+		if !strings.HasPrefix(v, verb) {
+			continue
+		}
+		if len(candidates) == 0 {
+			match = r
+		} else if r.id != match.id {
+			aliases = false
+		}
+		candidates = append(candidates, v)
+	}
+	if len(candidates) > 0 && aliases {
+		return match.eng, nil
+	}
+	sort.Strings(candidates)
+	return nil, candidates
+}
+
+// Exec routes the line to the Engine registered for its verb.
+func (m *Mux) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	m.mu.RLock()
+	parse := m.parser
+	check := m.syntaxCheck
+	unknown := m.unknown
+	m.mu.RUnlock()
+	if parse == nil {
+		parse = splitVerb
+	}
+
+	if check {
+		if _, perr := parseFields(line); perr != nil {
+			tracef(ui, "route", "%s", perr.Msg)
+			fmt.Fprintf(ui, "%s\n%s\n%s\n",
+				strings.TrimRight(line, "\r\n"),
+				CaretLine(line, perr.Pos),
+				themeOf(ui).Error.Paint(perr.Error()))
+			return 0
+		}
+	}
+
+	verb, rest := parse(line)
+
+	eng, candidates := m.lookup(verb)
+	if len(candidates) > 1 {
+		tracef(ui, "route", "verb %q is ambiguous", verb)
+		theme := themeOf(ui)
+		fmt.Fprintf(ui, "%s %s\n",
+			theme.Error.Paint(fmt.Sprintf("sand: ambiguous command %q, could be:", verb)),
+			theme.Suggestion.Paint(strings.Join(candidates, ", ")))
+		return 0
+	}
+	if eng == nil {
+		tracef(ui, "route", "verb %q not handled", verb)
+		if unknown == nil {
+			return StatusNotHandled
+		}
+		if unknown.report && verb != "" {
+			fmt.Fprintln(ui, themeOf(ui).Error.Paint(fmt.Sprintf("sand: unknown command %q", verb)))
+		}
+		return unknown.status
+	}
+	tracef(ui, "route", "verb %q to %T %q", verb, eng, rest)
+	return eng.Exec(ctx, rest, ui)
+}
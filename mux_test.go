@@ -0,0 +1,422 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// testRecordEngine records the lines it executes
+type testRecordEngine struct {
+	lines []string
+}
+
+func (eng *testRecordEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.lines = append(eng.lines, line)
+	return 0
+}
+
+// testBufferUI is a minimal io.ReadWriter for calling Exec directly
+type testBufferUI struct {
+	bytes.Buffer
+}
+
+func TestMux(t *testing.T) {
+	status, stop := new(testRecordEngine), new(testRecordEngine)
+	m := NewMux()
+	m.Handle("status", status)
+	m.Handle("stop", stop)
+
+	var ui testBufferUI
+	ctx := context.Background()
+	if s := m.Exec(ctx, "status -v now\n", &ui); s != 0 {
+		t.Errorf("expected status 0 but instead received: %d", s)
+	}
+	if len(status.lines) != 1 || status.lines[0] != "-v now\n" {
+		t.Errorf("expected remainder of line to be routed but instead received: %q", status.lines)
+	}
+
+	if s := m.Exec(ctx, "stat", &ui); s != StatusNotHandled {
+		t.Errorf("expected StatusNotHandled without prefix matching but instead received: %d", s)
+	}
+	if s := m.Exec(ctx, "", &ui); s != StatusNotHandled {
+		t.Errorf("expected StatusNotHandled for empty line but instead received: %d", s)
+	}
+}
+
+func TestMuxSetUnknownStatus(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Line   string
+		Report bool
+		Ex     string
+	}{
+		{Name: "Silent", Line: "stop\n"},
+		{Name: "Report", Line: "stop now\n", Report: true, Ex: "sand: unknown command \"stop\"\n"},
+		{Name: "Empty", Line: "\n", Report: true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			m := NewMux()
+			m.HandleFunc("status", func(ctx context.Context, line string, ui io.ReadWriter) int { return 0 })
+			m.SetUnknownStatus(127, tc.Report)
+
+			var ui testBufferUI
+			if s := m.Exec(context.Background(), tc.Line, &ui); s != 127 {
+				subT.Errorf("expected status 127 but instead received: %d", s)
+			}
+			if ui.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, ui.String())
+			}
+		})
+	}
+}
+
+// testDeadlineEngine waits for its context to be done, for at most
+// 100ms, and fails if it was.
+type testDeadlineEngine struct{}
+
+func (testDeadlineEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	select {
+	case <-ctx.Done():
+		return 1
+	case <-time.After(100 * time.Millisecond):
+		return 0
+	}
+}
+
+func TestMux_HandleWithTimeout(t *testing.T) {
+	m := NewMux()
+	m.HandleWithTimeout("slow", testDeadlineEngine{}, 10*time.Millisecond)
+	m.HandleWithTimeout("fast", testDeadlineEngine{}, 5*time.Second)
+
+	testCases := []struct {
+		Name   string
+		Line   string
+		Status int
+	}{
+		{Name: "TimedOut", Line: "slow\n", Status: 1},
+		{Name: "WithinTimeout", Line: "fast\n", Status: 0},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var ui testBufferUI
+			if s := m.Exec(context.Background(), tc.Line, &ui); s != tc.Status {
+				subT.Errorf("expected status %d but instead received: %d", tc.Status, s)
+			}
+		})
+	}
+}
+
+func TestMuxPrefixMatching(t *testing.T) {
+	status, stop, start := new(testRecordEngine), new(testRecordEngine), new(testRecordEngine)
+	m := NewMux()
+	m.Handle("status", status)
+	m.Handle("stop", stop)
+	m.Handle("start", start)
+	m.Handle("begin", start)
+	m.EnablePrefixMatching()
+
+	ctx := context.Background()
+	testCases := []struct {
+		Name   string
+		Line   string
+		Eng    *testRecordEngine
+		Status int
+		ExOut  string
+	}{
+		{Name: "Unique", Line: "stat", Eng: status},
+		{Name: "Exact", Line: "stop", Eng: stop},
+		{Name: "AmbiguousShort", Line: "sta", Status: 0, ExOut: "sand: ambiguous command \"sta\", could be: start, status\n"},
+		{Name: "Ambiguous", Line: "st", Status: 0, ExOut: "sand: ambiguous command \"st\", could be: start, status, stop\n"},
+		{Name: "Alias", Line: "b", Eng: start},
+		{Name: "Unknown", Line: "x", Status: StatusNotHandled},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var before int
+			if tc.Eng != nil {
+				before = len(tc.Eng.lines)
+			}
+
+			var ui testBufferUI
+			s := m.Exec(ctx, tc.Line, &ui)
+			if s != tc.Status {
+				subT.Errorf("expected status %d but instead received: %d", tc.Status, s)
+			}
+			if ui.String() != tc.ExOut {
+				subT.Errorf("expected output %q but instead received: %q", tc.ExOut, ui.String())
+			}
+			if tc.Eng != nil && len(tc.Eng.lines) != before+1 {
+				subT.Errorf("expected line to be routed to engine")
+			}
+		})
+	}
+}
+
+// testSliceEngine is an Engine which can't be compared.
+type testSliceEngine []string
+
+func (eng testSliceEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	return 0
+}
+
+func TestMuxPrefixMatching_Uncomparable(t *testing.T) {
+	noop := func(ctx context.Context, line string, ui io.ReadWriter) int { return 0 }
+	testCases := []struct {
+		Name   string
+		Handle func(m *Mux, verb string)
+	}{
+		{Name: "Funcs", Handle: func(m *Mux, verb string) { m.HandleFunc(verb, noop) }},
+		{Name: "Slices", Handle: func(m *Mux, verb string) { m.Handle(verb, testSliceEngine{verb}) }},
+		{Name: "Timeouts", Handle: func(m *Mux, verb string) { m.HandleWithTimeout(verb, EngineFunc(noop), time.Second) }},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			m := NewMux()
+			tc.Handle(m, "start")
+			tc.Handle(m, "status")
+			m.EnablePrefixMatching()
+
+			var ui testBufferUI
+			if s := m.Exec(context.Background(), "sta", &ui); s != 0 {
+				subT.Errorf("expected status 0 but instead received: %d", s)
+			}
+			ex := "sand: ambiguous command \"sta\", could be: start, status\n"
+			if ui.String() != ex {
+				subT.Errorf("expected output %q but instead received: %q", ex, ui.String())
+			}
+		})
+	}
+}
+
+func TestMuxHandlePanics(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Verb string
+	}{
+		{Name: "Empty", Verb: ""},
+		{Name: "Whitespace", Verb: "a b"},
+		{Name: "Duplicate", Verb: "a"},
+	}
+
+	m := NewMux()
+	m.Handle("a", new(testRecordEngine))
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					subT.Errorf("expected Handle to panic")
+				}
+			}()
+			m.Handle(tc.Verb, new(testRecordEngine))
+		})
+	}
+}
+
+func TestMuxHandleAll(t *testing.T) {
+	status, stop := new(testRecordEngine), new(testRecordEngine)
+	m := NewMux()
+	m.HandleAll(map[string]Engine{"status": status, "stop": stop})
+	m.HandleFunc("echo", func(ctx context.Context, line string, ui io.ReadWriter) int {
+		ui.Write([]byte(line))
+		return 0
+	})
+
+	if verbs := strings.Join(m.Commands(), " "); verbs != "echo status stop" {
+		t.Errorf("expected %q but instead received: %q", "echo status stop", verbs)
+	}
+
+	var ui testBufferUI
+	ctx := context.Background()
+	m.Exec(ctx, "stop now\n", &ui)
+	if len(stop.lines) != 1 || len(status.lines) != 0 {
+		t.Errorf("expected line to be routed to stop but instead received: %q, %q", stop.lines, status.lines)
+	}
+	m.Exec(ctx, "echo hi\n", &ui)
+	if ui.String() != "hi\n" {
+		t.Errorf("expected %q but instead received: %q", "hi\n", ui.String())
+	}
+
+	// Funcs are never aliases, so they're told apart by prefix matching
+	m.HandleFunc("echo2", func(ctx context.Context, line string, ui io.ReadWriter) int { return 0 })
+	m.EnablePrefixMatching()
+	ui.Reset()
+	m.Exec(ctx, "ech hi\n", &ui)
+	if !strings.Contains(ui.String(), "ambiguous") {
+		t.Errorf("expected ambiguous command but instead received: %q", ui.String())
+	}
+}
+
+func TestMuxHandleAllPanics(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Routes map[string]Engine
+	}{
+		{Name: "Duplicate", Routes: map[string]Engine{"a": new(testRecordEngine), "b": new(testRecordEngine)}},
+		{Name: "Whitespace", Routes: map[string]Engine{"a b": new(testRecordEngine), "c": new(testRecordEngine)}},
+		{Name: "NilEngine", Routes: map[string]Engine{"c": nil}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			m := NewMux()
+			m.Handle("a", new(testRecordEngine))
+			defer func() {
+				if r := recover(); r == nil {
+					subT.Errorf("expected HandleAll to panic")
+				}
+				if verbs := m.Commands(); len(verbs) != 1 {
+					subT.Errorf("expected no verbs to be registered but instead received: %q", verbs)
+				}
+			}()
+			m.HandleAll(tc.Routes)
+		})
+	}
+}
+
+func TestMuxSetParser(t *testing.T) {
+	eng := new(testRecordEngine)
+	m := NewMux()
+	m.Handle("get", eng)
+	m.SetParser(func(line string) (string, string) {
+		return strings.TrimSuffix(line, "()\n"), ""
+	})
+
+	var ui testBufferUI
+	if s := m.Exec(context.Background(), "get()\n", &ui); s != 0 || len(eng.lines) != 1 {
+		t.Errorf("expected line to be routed by the custom parser but instead received: %d %q", s, eng.lines)
+	}
+
+	m.SetParser(nil)
+	if s := m.Exec(context.Background(), "get()\n", &ui); s != StatusNotHandled {
+		t.Errorf("expected StatusNotHandled after restoring the default parser but instead received: %d", s)
+	}
+}
+
+// exampleSQLEngine prints the statements it executes.
+type exampleSQLEngine struct {
+	kind string
+}
+
+func (eng *exampleSQLEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	fmt.Fprintf(ui, "%s: %s", eng.kind, line)
+	return 0
+}
+
+func ExampleMux_SetParser() {
+	m := NewMux()
+	m.Handle("SELECT", &exampleSQLEngine{kind: "query"})
+	m.Handle("INSERT", &exampleSQLEngine{kind: "write"})
+
+	// Route by the leading keyword, in any case, and give the
+	// engine the whole statement, since "SELECT*FROM t" is valid.
+	m.SetParser(func(line string) (verb, rest string) {
+		stmt := strings.TrimSpace(line)
+		i := strings.IndexFunc(stmt, func(r rune) bool { return !unicode.IsLetter(r) })
+		if i == -1 {
+			i = len(stmt)
+		}
+		return strings.ToUpper(stmt[:i]), line
+	})
+
+	var ui testBufferUI
+	m.Exec(context.Background(), "select*from t;\n", &ui)
+	m.Exec(context.Background(), "INSERT INTO t VALUES (1);\n", &ui)
+	fmt.Print(ui.String())
+	// Output:
+	// query: select*from t;
+	// write: INSERT INTO t VALUES (1);
+}
+
+func TestMuxEnableSyntaxCheck(t *testing.T) {
+	eng := new(testRecordEngine)
+	m := NewMux()
+	m.Handle("say", eng)
+
+	var ui testBufferUI
+	if s := m.Exec(context.Background(), "say 'hi\n", &ui); s != 0 || len(eng.lines) != 1 {
+		t.Errorf("expected malformed line to be routed without the check but instead received: %d %q", s, eng.lines)
+	}
+
+	m.EnableSyntaxCheck()
+	ui.Reset()
+	if s := m.Exec(context.Background(), "say \"hi\n", &ui); s != 0 || len(eng.lines) != 1 {
+		t.Errorf("expected malformed line to not be routed but instead received: %d %q", s, eng.lines)
+	}
+	ex := "say \"hi\n    ^\nsand: unterminated quote at column 5\n"
+	if ui.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, ui.String())
+	}
+
+	if s := m.Exec(context.Background(), "say \"hi\"\n", &ui); s != 0 || len(eng.lines) != 2 {
+		t.Errorf("expected valid line to be routed but instead received: %d %q", s, eng.lines)
+	}
+}
+
+// testCompleteEngine is an Engine completing its arguments from words.
+type testCompleteEngine struct {
+	testWordCompleter
+}
+
+func (eng *testCompleteEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	return 0
+}
+
+func TestMux_CompleteCandidates(t *testing.T) {
+	m := NewMux()
+	m.Handle("status", new(testRecordEngine))
+	m.Handle("start", new(testRecordEngine))
+	m.Handle("stop", new(testRecordEngine))
+	m.Handle("git", &testCompleteEngine{testWordCompleter{"commit", "checkout", "push"}})
+	m.SetDescription("status", "shows the status")
+	m.SetDescription("git", "runs git")
+
+	testCases := []struct {
+		Name string
+		Line string
+		Ex   []Candidate
+	}{
+		{Name: "Verbs", Line: "", Ex: []Candidate{{"git", "runs git"}, {"start", ""}, {"status", "shows the status"}, {"stop", ""}}},
+		{Name: "Prefix", Line: "sta", Ex: []Candidate{{"start", ""}, {"status", "shows the status"}}},
+		{Name: "Leading", Line: "  sto", Ex: []Candidate{{"  stop", ""}}},
+		{Name: "Unknown", Line: "x", Ex: nil},
+		{Name: "Routed", Line: "git c", Ex: []Candidate{{"git commit", ""}, {"git checkout", ""}}},
+		{Name: "RoutedAll", Line: "git  ", Ex: []Candidate{{"git  commit", ""}, {"git  checkout", ""}, {"git  push", ""}}},
+		{Name: "NotCompleter", Line: "stop n", Ex: nil},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			if cands := m.CompleteCandidates(tc.Line); !reflect.DeepEqual(cands, tc.Ex) {
+				subT.Errorf("expected %v but instead received: %v", tc.Ex, cands)
+			}
+			if texts := m.Complete(tc.Line); !reflect.DeepEqual(texts, candidateTexts(tc.Ex)) {
+				subT.Errorf("expected %q but instead received: %q", candidateTexts(tc.Ex), texts)
+			}
+		})
+	}
+
+	// Caching keeps the descriptions
+	cands := CachedCompleter(m, time.Minute).CompleteCandidates("stat")
+	if ex := []Candidate{{"status", "shows the status"}}; !reflect.DeepEqual(cands, ex) {
+		t.Errorf("expected %v but instead received: %v", ex, cands)
+	}
+}
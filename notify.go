@@ -0,0 +1,80 @@
+package sand
+
+// notifyBuffer is the number of notifications which can be pending
+// before a send on the channel returned by NotifyChan blocks.
+const notifyBuffer = 16
+
+// NotifyChan returns the channel for an Engine to send unsolicited
+// messages to the user on, e.g. from a background watcher reporting
+// changes. Every UI has one channel, which is never closed, and every
+// message is written on a line of its own, see Interject.
+//
+// While Run waits on input, a message is written above the prompt
+// immediately. Messages sent while a command is executing are held
+// until it's done, and are then written before the next prompt, so
+// they're never mixed into the output of a command. Up to 16 messages
+// can be pending, after which sending blocks, as it does while no
+// session is running, so a sender which mustn't block should send in
+// a select with a default.
+//
+func (ui *UI) NotifyChan() chan<- string {
+	return ui.notifications()
+}
+
+// notifications returns the channel of NotifyChan, creating it if needed.
+func (ui *UI) notifications() chan string {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if ui.notifyCh == nil {
+		ui.notifyCh = make(chan string, notifyBuffer)
+	}
+	return ui.notifyCh
+}
+
+// writePendingNotes writes the notifications which are pending, see
+// NotifyChan, each on a line of its own.
+//
+func (ui *UI) writePendingNotes() error {
+	ch := ui.notifications()
+	var msgs []byte
+	for {
+		select {
+		case msg := <-ch:
+			msgs = append(msgs, msg+"\n"...)
+			continue
+		default:
+		}
+		break
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	_, err := ui.writePrompt(msgs)
+	return err
+}
+
+// startNotes interjects the notifications sent on NotifyChan, while
+// Run waits on input, until the returned func is called, which waits
+// for any notification being written.
+//
+func (ui *UI) startNotes() (stop func()) {
+	ch := ui.notifications()
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-quit:
+				return
+			case msg := <-ch:
+				ui.Interject(msg)
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-done
+	}
+}
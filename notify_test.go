@@ -0,0 +1,104 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testNotifyEngine sends a notification on "watch" before writing
+// "watching" and echos anything else.
+type testNotifyEngine struct {
+	testEchoEngine
+}
+
+func (eng *testNotifyEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	if strings.TrimSpace(line) != "watch" {
+		return eng.testEchoEngine.Exec(ctx, line, ui)
+	}
+	ui.(*UI).NotifyChan() <- "changed"
+	ui.Write([]byte("watching\n"))
+	return 0
+}
+
+// testWatchWriter records what's written and reports it on writes.
+type testWatchWriter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes chan string
+}
+
+func (w *testWatchWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case w.writes <- string(b):
+	default:
+	}
+	return w.buf.Write(b)
+}
+
+func (w *testWatchWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestUI_NotifyChan(t *testing.T) {
+	t.Run("DuringCommand", func(subT *testing.T) {
+		in := &testLineReader{lines: []string{"watch\n", "a\n"}}
+		var out bytes.Buffer
+
+		err := Run(nil, new(testNotifyEngine), WithPrefix(">"), WithIO(in, &out))
+		if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			subT.Error(err)
+		}
+
+		ex := ">>watching\nchanged\n>>a\n>\n"
+		if out.String() != ex {
+			subT.Errorf("expected %q but instead received: %q", ex, out.String())
+		}
+	})
+
+	t.Run("AtPrompt", func(subT *testing.T) {
+		pr, pw := io.Pipe()
+		out := &testWatchWriter{writes: make(chan string, 16)}
+
+		ui := new(UI)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- ui.Run(nil, new(testNotifyEngine), WithPrefix(">"), WithIO(pr, out), WithTheme(MonochromeTheme))
+		}()
+		for waiting := false; !waiting; time.Sleep(time.Millisecond) {
+			ui.promptMu.Lock()
+			waiting = ui.atPrompt
+			ui.promptMu.Unlock()
+		}
+
+		ui.NotifyChan() <- "changed"
+		for w := ""; !strings.Contains(w, "changed"); {
+			select {
+			case w = <-out.writes:
+			case <-time.After(5 * time.Second):
+				subT.Fatal("expected notification to be written")
+			}
+		}
+
+		// Input isn't disrupted by the notification
+		pw.Write([]byte("a\n"))
+		pw.Close()
+		err := <-errCh
+		if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			subT.Error(err)
+		}
+
+		ex := ">\nchanged\n>>a\n>\n"
+		if out.String() != ex {
+			subT.Errorf("expected %q but instead received: %q", ex, out.String())
+		}
+	})
+}
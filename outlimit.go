@@ -0,0 +1,45 @@
+package sand
+
+import "sync/atomic"
+
+// truncatedNotice is written in place of the output of a command
+// beyond its limit, see WithMaxOutputPerCommand.
+//
+const truncatedNotice = "\n...output truncated\n"
+
+// WithMaxOutputPerCommand specifies the maximum number of bytes a
+// single command may write, which guards against an Engine dumping
+// megabytes of output onto the user. The output beyond it is
+// discarded and replaced by a "...output truncated" notice, as is,
+// without failing the Write. The count starts over at every Exec.
+//
+func WithMaxOutputPerCommand(n int64) Option {
+	return func(ui *UI) {
+		ui.maxOut = n
+	}
+}
+
+// limitOutput returns the part of b within the output limit of the
+// command, whose output so far is counted by count, followed by the
+// notice if b exceeds it. Once the notice is written, it returns nil
+// for every Write after.
+//
+func (ui *UI) limitOutput(count *int64, b []byte) (out []byte, truncated bool) {
+	if ui.maxOut <= 0 {
+		return b, false
+	}
+
+	n := atomic.AddInt64(count, int64(len(b)))
+	prev := n - int64(len(b))
+	switch {
+	case n <= ui.maxOut:
+		return b, false
+	case prev > ui.maxOut:
+		return nil, true
+	}
+
+	keep := ui.maxOut - prev
+	out = make([]byte, 0, keep+int64(len(truncatedNotice)))
+	out = append(out, b[:keep]...)
+	return append(out, truncatedNotice...), true
+}
@@ -0,0 +1,73 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// testRepeatEngine writes the line the given number of times.
+type testRepeatEngine struct {
+	times int
+}
+
+func (eng testRepeatEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	for i := 0; i < eng.times; i++ {
+		if _, err := ui.Write([]byte(line + "\n")); err != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+func TestRunWithMaxOutputPerCommand(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Max   int64
+		Lines []string
+		Out   string
+	}{
+		{
+			Name:  "WithinLimit",
+			Max:   15,
+			Lines: []string{"abcd\n"},
+			Out:   ">>abcd\n>abcd\n>abcd\n>\n",
+		},
+		{
+			Name:  "Truncated",
+			Max:   7,
+			Lines: []string{"abcd\n"},
+			Out:   ">>abcd\n>ab" + truncatedNotice + ">\n",
+		},
+		{
+			Name:  "TruncatedAtWrite",
+			Max:   5,
+			Lines: []string{"abcd\n"},
+			Out:   ">>abcd\n>" + truncatedNotice + ">\n",
+		},
+		{
+			Name:  "ResetPerCommand",
+			Max:   7,
+			Lines: []string{"abcd\n", "efgh\n"},
+			Out:   ">>abcd\n>ab" + truncatedNotice + ">>efgh\n>ef" + truncatedNotice + ">\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: testCase.Lines}
+			var out bytes.Buffer
+
+			err := Run(nil, testRepeatEngine{times: 3}, WithPrefix(">"), WithIO(in, &out), WithMaxOutputPerCommand(testCase.Max))
+			var ok bool
+			if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+
+			if out.String() != testCase.Out {
+				subT.Errorf("expected %q but instead received: %q", testCase.Out, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package sand
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// PanicError is returned by Run when it recovers from a panic. The
+// stack is captured while recovering, so it still includes the
+// frames of the panic site. It's only printed when formatting the
+// error with "%+v", similar to github.com/pkg/errors.
+//
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine, as formatted by runtime/debug.Stack.
+	Stack []byte
+
+	cause error
+}
+
+// newPanicError creates a PanicError for the recovered value r.
+func newPanicError(r interface{}) *PanicError {
+	cause, ok := r.(error)
+	if !ok {
+		cause = fmt.Errorf("%v", r)
+	}
+	return &PanicError{Value: r, Stack: debug.Stack(), cause: cause}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("sand: recovered from panic: %v", e.Value)
+}
+
+// Cause returns the panic value if it's an error, otherwise an
+// error with the panic value as its message.
+//
+func (e *PanicError) Cause() error { return e.cause }
+
+// Format implements fmt.Formatter, printing the stack for "%+v".
+//
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		io.WriteString(s, e.Error())
+		if s.Flag('+') {
+			io.WriteString(s, "\n")
+			s.Write(e.Stack)
+		}
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
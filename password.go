@@ -0,0 +1,118 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// WithMaskChar specifies the character echoed for every character
+// typed into ReadPassword. By default, or if mask is 0, nothing is
+// echoed.
+//
+func WithMaskChar(mask rune) Option {
+	return func(ui *UI) {
+		ui.mask = mask
+	}
+}
+
+// ReadPassword writes the prompt, without the prefix, and reads a
+// line without echoing it. If the input is a terminal, it is put
+// into raw mode for the duration of the call and the mask character
+// is echoed instead, see WithMaskChar. Otherwise, the line is read
+// as is.
+//
+func (ui *UI) ReadPassword(prompt string) (string, error) {
+	secret, err := ui.ReadSecret(prompt)
+	return string(secret), err
+}
+
+// ReadSecret is the same as ReadPassword, except for returning the
+// line as bytes, which are never copied into a string, e.g. for an
+// Engine to zero the secret once it's done with it. Like
+// ReadPassword, the terminal is restored even if the read is
+// interrupted, e.g. by the context of the UI being done.
+//
+func (ui *UI) ReadSecret(prompt string) ([]byte, error) {
+	if _, err := ui.writePrompt([]byte(prompt)); err != nil {
+		return nil, err
+	}
+
+	f, ok := ui.input().(*os.File)
+	if !ok || !isTerminal(f) {
+		b, err := ui.readTerminated(ui.ctx)
+		if bytes.HasSuffix(b, []byte("\n")) {
+			b = bytes.TrimSuffix(b[:len(b)-1], []byte("\r"))
+		}
+		return append([]byte(nil), b...), err
+	}
+
+	entered, err := ui.enterRaw(f)
+	if err != nil {
+		return nil, err
+	}
+	if entered {
+		defer ui.RestoreTerminal()
+	}
+
+	return ui.readMasked(ui.mask)
+}
+
+// readMasked reads a line rune by rune, as typed into a terminal
+// in raw mode, echoing mask for every rune if it's non-zero.
+//
+func (ui *UI) readMasked(mask rune) ([]byte, error) {
+	var echo []byte
+	if mask != 0 {
+		echo = make([]byte, utf8.RuneLen(mask))
+		utf8.EncodeRune(echo, mask)
+	}
+
+	var line []byte
+	erase := func(n int) {
+		if echo != nil && n > 0 {
+			ui.writePrompt([]byte(strings.Repeat("\b \b", n)))
+		}
+	}
+
+	for {
+		r, _, err := ui.ReadRune()
+		if err != nil {
+			return line, err
+		}
+
+		switch r {
+		case '\r', '\n':
+			_, err = ui.writePrompt([]byte("\n"))
+			return line, err
+		case 0x04: // Ctrl-D
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+		case 0x7f, '\b': // Backspace
+			if len(line) > 0 {
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+				erase(1)
+			}
+		case 0x15: // Ctrl-U
+			erase(utf8.RuneCount(line))
+			line = line[:0]
+		case interruptKey:
+			if ui.inRawMode() {
+				raiseInterrupt()
+			}
+		default:
+			if r < ' ' {
+				continue
+			}
+			var buf [utf8.UTFMax]byte
+			line = append(line, buf[:utf8.EncodeRune(buf[:], r)]...)
+			if echo != nil {
+				ui.writePrompt(echo)
+			}
+		}
+	}
+}
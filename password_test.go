@@ -0,0 +1,88 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestUI_ReadPassword(t *testing.T) {
+	var out bytes.Buffer
+	ui := &UI{i: strings.NewReader("hunter2\n"), ctx: context.Background()}
+	ui.out, ui.promptOut = &out, &out
+
+	pass, err := ui.ReadPassword("Password: ")
+	if err != nil {
+		t.Error(err)
+	}
+	if pass != "hunter2" {
+		t.Errorf("expected %q but instead received: %q", "hunter2", pass)
+	}
+	if out.String() != "Password: " {
+		t.Errorf("expected input to not be echoed but instead received: %q", out.String())
+	}
+}
+
+func TestUI_ReadMasked(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Mask  rune
+		In    string
+		Ex    string
+		ExOut string
+	}{
+		{Name: "NoEcho", In: "abc\r", Ex: "abc", ExOut: "\n"},
+		{Name: "Mask", Mask: '*', In: "abc\r", Ex: "abc", ExOut: "***\n"},
+		{Name: "Backspace", Mask: '*', In: "abd\x7fc\r", Ex: "abc", ExOut: "***\b \b*\n"},
+		{Name: "BackspaceEmpty", Mask: '*', In: "\x7fa\r", Ex: "a", ExOut: "*\n"},
+		{Name: "KillLine", Mask: '•', In: "ab\x15c\r", Ex: "c", ExOut: "••\b \b\b \b•\n"},
+		{Name: "Multibyte", Mask: '*', In: "pä\x7f\x7fé\r", Ex: "é", ExOut: "**\b \b\b \b*\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+			ui.out, ui.promptOut = &out, &out
+
+			pass, err := ui.readMasked(tc.Mask)
+			if err != nil {
+				subT.Error(err)
+			}
+			if string(pass) != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, pass)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected output %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestUI_ReadSecret(t *testing.T) {
+	var out bytes.Buffer
+	ui := &UI{i: strings.NewReader("hunter2\n"), ctx: context.Background()}
+	ui.out, ui.promptOut = &out, &out
+
+	secret, err := ui.ReadSecret("Secret: ")
+	if err != nil {
+		t.Error(err)
+	}
+	if string(secret) != "hunter2" {
+		t.Errorf("expected %q but instead received: %q", "hunter2", secret)
+	}
+	if out.String() != "Secret: " {
+		t.Errorf("expected input to not be echoed but instead received: %q", out.String())
+	}
+
+	// A done context interrupts the read
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ui = &UI{i: strings.NewReader("hunter2\n"), ctx: ctx}
+	ui.out, ui.promptOut = &out, &out
+	if _, err = ui.ReadSecret("Secret: "); err != context.Canceled {
+		t.Errorf("expected %v but instead received: %v", context.Canceled, err)
+	}
+}
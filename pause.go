@@ -0,0 +1,96 @@
+package sand
+
+import (
+	"context"
+	"io"
+)
+
+// WithDropInputWhilePaused discards input that arrives while the UI
+// is paused, see Pause, instead of keeping it for once it's resumed,
+// e.g. so keys pressed while a dialog had the focus aren't executed.
+//
+func WithDropInputWhilePaused() Option {
+	return func(ui *UI) {
+		ui.dropPaused = true
+	}
+}
+
+// Pause stops the UI from consuming input, without ending the session,
+// e.g. while a modal dialog of the embedding application is open, until
+// Resume is called. Run, as well as an Engine reading from the UI, then
+// waits on Resume, instead of on the input Reader. Input which arrives
+// in the meantime is kept for once the UI is resumed, unless
+// WithDropInputWhilePaused is set. It is safe to call from any goroutine.
+//
+func (ui *UI) Pause() {
+	ui.pauseMu.Lock()
+	defer ui.pauseMu.Unlock()
+	if ui.resumeCh != nil {
+		return
+	}
+	if ui.pauseCh == nil {
+		ui.pauseCh = make(chan struct{})
+	}
+	close(ui.pauseCh)
+	ui.resumeCh = make(chan struct{})
+}
+
+// Resume lets the UI consume input again after Pause. It is safe to
+// call from any goroutine.
+//
+func (ui *UI) Resume() {
+	ui.pauseMu.Lock()
+	defer ui.pauseMu.Unlock()
+	if ui.resumeCh == nil {
+		return
+	}
+	close(ui.resumeCh)
+	ui.resumeCh = nil
+	ui.pauseCh = nil
+}
+
+// pauseState returns the channel closed by the next Pause and, while
+// the UI is paused, the one closed by Resume.
+//
+func (ui *UI) pauseState() (paused, resumed chan struct{}) {
+	ui.pauseMu.Lock()
+	defer ui.pauseMu.Unlock()
+	if ui.pauseCh == nil {
+		ui.pauseCh = make(chan struct{})
+	}
+	return ui.pauseCh, ui.resumeCh
+}
+
+// waitResume waits while the UI is paused. If p is given, and input
+// is dropped while paused, whatever p reads in the meantime is
+// discarded, except for an error, which completes p.
+//
+func (ui *UI) waitResume(ctx context.Context, p *pendingRead) error {
+	for {
+		_, resumed := ui.pauseState()
+		if resumed == nil {
+			return nil
+		}
+
+		var readCh chan ioResp
+		if p != nil && !p.done && ui.dropPaused {
+			readCh = p.ch
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resumed:
+		case resp := <-readCh:
+			if resp.err != nil {
+				p.resp = ioResp{err: resp.err}
+				p.done = true
+				continue
+			}
+			p.buf = make([]byte, len(p.buf))
+			p.ch = make(chan ioResp, 1)
+			var in io.Reader
+			in, p.gen = ui.inputGen()
+			go ui.readAsync(in, p.buf, p.ch)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCountEngine counts the lines it executes, safe for concurrent use
+type testCountEngine struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (eng *testCountEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.mu.Lock()
+	eng.lines = append(eng.lines, line)
+	eng.mu.Unlock()
+	return 0
+}
+
+func (eng *testCountEngine) executed() []string {
+	eng.mu.Lock()
+	defer eng.mu.Unlock()
+	return append([]string(nil), eng.lines...)
+}
+
+func TestUI_PauseResume(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Opts []Option
+		Ex   []string
+	}{
+		{Name: "Buffered", Ex: []string{"a", "b"}},
+		{Name: "Dropped", Opts: []Option{WithDropInputWhilePaused()}, Ex: []string{"b"}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			pr, pw := io.Pipe()
+			defer pr.Close()
+			var out bytes.Buffer
+
+			eng := new(testCountEngine)
+			ui := new(UI)
+			opts := append([]Option{WithIO(pr, &out)}, tc.Opts...)
+			errCh := make(chan error, 1)
+			go func() { errCh <- ui.Run(nil, eng, opts...) }()
+			for waiting := false; !waiting; time.Sleep(time.Millisecond) {
+				ui.promptMu.Lock()
+				waiting = ui.atPrompt
+				ui.promptMu.Unlock()
+			}
+
+			ui.Pause()
+			wrote := make(chan struct{})
+			go func() {
+				pw.Write([]byte("a\n"))
+				close(wrote)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			if lines := eng.executed(); len(lines) != 0 {
+				subT.Errorf("expected no input to be consumed while paused but instead executed: %q", lines)
+			}
+
+			ui.Resume()
+			<-wrote
+			pw.Write([]byte("b\n"))
+			pw.Close()
+
+			err := <-errCh
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			lines := eng.executed()
+			if len(lines) != len(tc.Ex) || lines[0] != tc.Ex[0] {
+				subT.Errorf("expected %q but instead executed: %q", tc.Ex, lines)
+			}
+		})
+	}
+}
+
+func TestUI_PauseBeforeRead(t *testing.T) {
+	in := &testLineReader{lines: []string{"a\n"}}
+	ui := &UI{i: in, ctx: context.Background()}
+	ui.Pause()
+
+	done := make(chan struct{})
+	go func() {
+		ui.ReadByte()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("expected read to wait while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ui.Resume()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected read to continue once resumed")
+	}
+}
@@ -0,0 +1,51 @@
+package sand
+
+import (
+	"github.com/pkg/errors"
+	"plugin"
+)
+
+// EngineSymbol is the name of the symbol LoadEngine looks up.
+const EngineSymbol = "Engine"
+
+// ErrNotEngine is returned by LoadEngine when the symbol of a plugin
+// doesn't implement Engine.
+//
+var ErrNotEngine = errors.New("sand: plugin symbol doesn't implement Engine")
+
+// LoadEngine loads the Go plugin at path, built with
+// "go build -buildmode=plugin", and returns the Engine it exports as
+// EngineSymbol, e.g. for a Mux to add commands without recompiling the
+// host, see example/plugin. The symbol is either a variable of a type
+// implementing Engine, as a pointer to it does too, or a variable of
+// type Engine itself.
+//
+// The limitations of the plugin package apply: plugins are only
+// supported on Linux, FreeBSD and macOS, with cgo enabled, a plugin
+// must be built with the same Go version and flags, e.g. -race, as
+// the host, and every package they share, including this one, must be
+// of the exact same version. A plugin is never unloaded, so loading it
+// again returns the same Engine.
+//
+func LoadEngine(path string) (Engine, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sand: failed to open plugin %s", path)
+	}
+
+	sym, err := p.Lookup(EngineSymbol)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sand: plugin %s has no %s symbol", path, EngineSymbol)
+	}
+
+	switch v := sym.(type) {
+	case *Engine:
+		if *v == nil {
+			return nil, errors.Wrapf(ErrNotEngine, "sand: %s of plugin %s is nil", EngineSymbol, path)
+		}
+		return *v, nil
+	case Engine:
+		return v, nil
+	}
+	return nil, errors.Wrapf(ErrNotEngine, "sand: %s of plugin %s is a %T", EngineSymbol, path, sym)
+}
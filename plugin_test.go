@@ -0,0 +1,99 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// testPlugins holds the plugins built by buildPlugin, which are only
+// built once, since a plugin can't be loaded again from another path.
+var testPlugins struct {
+	sync.Once
+	dir   string
+	paths map[string]string // by the directory of their source
+	err   error
+}
+
+// buildPlugin returns the path of the plugin in dir, building every
+// plugin the tests use with the same flags as the tests, on the first
+// call. It skips the test if plugins can't be built here, e.g. without
+// cgo.
+//
+func buildPlugin(t *testing.T, dir string) string {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd":
+	default:
+		t.Skipf("plugins unsupported on %s", runtime.GOOS)
+	}
+
+	p := &testPlugins
+	p.Do(func() {
+		if p.dir, p.err = ioutil.TempDir("", "sand-plugins"); p.err != nil {
+			return
+		}
+		p.paths = make(map[string]string)
+		for _, src := range []string{"./example/plugin", "./testdata/notengine"} {
+			path := filepath.Join(p.dir, filepath.Base(src)+".so")
+			args := []string{"build", "-buildmode=plugin", "-o", path}
+			if raceEnabled {
+				args = append(args, "-race")
+			}
+			out, err := exec.Command("go", append(args, src)...).CombinedOutput()
+			if err != nil {
+				p.err = fmt.Errorf("%s: %s", err, out)
+				return
+			}
+			p.paths[src] = path
+		}
+	})
+	if p.err != nil {
+		t.Skipf("plugins unsupported: %s", p.err)
+	}
+	return p.paths[dir]
+}
+
+func TestLoadEngine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building plugins is slow")
+	}
+
+	t.Run("Engine", func(subT *testing.T) {
+		eng, err := LoadEngine(buildPlugin(subT, "./example/plugin"))
+		if err != nil {
+			subT.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		ui := &UI{ctx: context.Background(), o: &out}
+		ui.out = ui.o
+		if status := eng.Exec(context.Background(), "sand\n", ui); status != 0 {
+			subT.Errorf("expected status 0 but instead received: %d", status)
+		}
+		ex := "hello, sand!\n"
+		if out.String() != ex {
+			subT.Errorf("expected %q but instead received: %q", ex, out.String())
+		}
+	})
+
+	t.Run("NotEngine", func(subT *testing.T) {
+		_, err := LoadEngine(buildPlugin(subT, "./testdata/notengine"))
+		if errors.Cause(err) != ErrNotEngine {
+			subT.Errorf("expected %v but instead received: %v", ErrNotEngine, err)
+		}
+	})
+
+	t.Run("Missing", func(subT *testing.T) {
+		_, err := LoadEngine(filepath.Join(subT.TempDir(), "missing.so"))
+		if err == nil {
+			subT.Errorf("expected an error for a missing plugin")
+		}
+	})
+}
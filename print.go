@@ -0,0 +1,33 @@
+package sand
+
+import "fmt"
+
+// Printer is implemented by the UI passed to Engine.Exec, so engines
+// can print formatted output without going through fmt.Fprintf, e.g.
+// by asserting ui.(Printer). Output is written the same as by Write,
+// i.e. with the prefix, through any output filter and tees, and gives
+// up once the session is done.
+//
+type Printer interface {
+	// Printf formats according to a format specifier and writes
+	// the result, see fmt.Printf.
+	Printf(format string, args ...interface{}) (n int, err error)
+
+	// Println formats its arguments, separated by spaces, and
+	// writes them followed by a newline, see fmt.Println.
+	Println(args ...interface{}) (n int, err error)
+}
+
+// Printf formats according to a format specifier and writes the
+// result, along with the prefix, see Write.
+//
+func (ui *UI) Printf(format string, args ...interface{}) (n int, err error) {
+	return ui.Write([]byte(fmt.Sprintf(format, args...)))
+}
+
+// Println formats its arguments, separated by spaces, and writes
+// them followed by a newline, along with the prefix, see Write.
+//
+func (ui *UI) Println(args ...interface{}) (n int, err error) {
+	return ui.Write([]byte(fmt.Sprintln(args...)))
+}
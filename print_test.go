@@ -0,0 +1,46 @@
+package sand
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// testPrintEngine prints the line using the Printer of the UI
+type testPrintEngine struct{}
+
+func (eng *testPrintEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	p, ok := ui.(Printer)
+	if !ok {
+		return 1
+	}
+	if _, err := p.Printf("%d:", len(line)); err != nil {
+		return 1
+	}
+	if _, err := p.Println("got", line); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func TestUI_Printf(t *testing.T) {
+	out := runBuiltinTest(t, new(testPrintEngine), "abc\n")
+
+	ex := ">>3:>got abc\n>\n"
+	if out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+}
+
+func TestUI_PrintlnCanceled(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ui := &UI{ctx: ctx, out: pw}
+	_, err := ui.Println("late")
+	if err != context.Canceled {
+		t.Errorf("expected %v but instead received: %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,157 @@
+package sand
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// progressWidth is the number of cells of the bar itself.
+const progressWidth = 30
+
+// progressStep is the percentage between the lines written in place
+// of the bar when the output isn't a terminal.
+const progressStep = 10
+
+// ProgressBar shows the progress of long running work, e.g. a download
+// or a build, from within Exec. On a terminal, the bar is drawn on a
+// line of its own, which is redrawn in place as the work progresses and
+// erased before anything else is written, so other output never ends up
+// tangled with it. Otherwise, a line with the percentage is written, as
+// output of the Engine, every 10 percent instead. A ProgressBar is safe
+// for concurrent use.
+//
+type ProgressBar struct {
+	ui    *UI
+	total int64
+	tty   bool
+
+	// Guarded by ui.progMu
+	n       int64
+	drawn   string // the bar as last drawn, if it's shown
+	lastPct int    // the last percentage written, when not a terminal
+	done    bool
+}
+
+// NewProgressBar returns a ProgressBar for work amounting to total,
+// e.g. the number of bytes to download. The bar is first shown by
+// Add and is complete once total is reached. A total which isn't
+// positive is treated as already reached.
+//
+func NewProgressBar(ui *UI, total int64) *ProgressBar {
+	return &ProgressBar{
+		ui:      ui,
+		total:   total,
+		tty:     isTerminal(ui.promptDest()),
+		lastPct: -1,
+	}
+}
+
+// Add adds n to the work done and shows the updated progress. The work
+// done never exceeds the total.
+//
+func (pb *ProgressBar) Add(n int64) {
+	ui := pb.ui
+	ui.progMu.Lock()
+	if pb.done {
+		ui.progMu.Unlock()
+		return
+	}
+	pb.n += n
+	if pb.n > pb.total {
+		pb.n = pb.total
+	}
+
+	if pb.tty {
+		if ui.progress != nil && ui.progress != pb {
+			ui.progress.erase()
+		}
+		ui.progress = pb
+		pb.draw()
+		ui.progMu.Unlock()
+		return
+	}
+
+	pct := pb.percent()
+	if pct == pb.lastPct || pct < 100 && pct/progressStep <= pb.lastPct/progressStep {
+		ui.progMu.Unlock()
+		return
+	}
+	pb.lastPct = pct
+	line := fmt.Sprintf("%d%% (%d/%d)\n", pct, pb.n, pb.total)
+	ui.progMu.Unlock()
+
+	ui.Write([]byte(line))
+}
+
+// Done completes the bar, erasing it from the terminal, or writing
+// the final percentage, if it isn't written yet, otherwise. Add has
+// no effect afterwards.
+//
+func (pb *ProgressBar) Done() {
+	ui := pb.ui
+	ui.progMu.Lock()
+	if pb.done {
+		ui.progMu.Unlock()
+		return
+	}
+	pb.done = true
+
+	if pb.tty {
+		pb.erase()
+		if ui.progress == pb {
+			ui.progress = nil
+		}
+		ui.progMu.Unlock()
+		return
+	}
+
+	pct := pb.percent()
+	written := pct == pb.lastPct
+	ui.progMu.Unlock()
+	if !written {
+		ui.Write([]byte(fmt.Sprintf("%d%% (%d/%d)\n", pct, pb.n, pb.total)))
+	}
+}
+
+// percent returns the percentage of work done.
+func (pb *ProgressBar) percent() int {
+	if pb.total <= 0 {
+		return 100
+	}
+	return int(pb.n * 100 / pb.total)
+}
+
+// draw draws the bar, unless it's already drawn the same.
+// The caller must hold ui.progMu.
+//
+func (pb *ProgressBar) draw() {
+	pct := pb.percent()
+	filled := pct * progressWidth / 100
+	bar := fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled), pct)
+	if bar == pb.drawn {
+		return
+	}
+	io.WriteString(pb.ui.promptDest(), "\r"+bar)
+	pb.drawn = bar
+}
+
+// erase erases the bar, if it's shown. The caller must hold ui.progMu.
+func (pb *ProgressBar) erase() {
+	if pb.drawn == "" {
+		return
+	}
+	io.WriteString(pb.ui.promptDest(), eraseLine)
+	pb.drawn = ""
+}
+
+// hideProgress erases the progress bar, if it's shown, before anything
+// else is written. The next Add draws it again, after that output.
+//
+func (ui *UI) hideProgress() {
+	ui.progMu.Lock()
+	if ui.progress != nil {
+		ui.progress.erase()
+	}
+	ui.progMu.Unlock()
+}
@@ -0,0 +1,89 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testProgressEngine reports progress in the given steps, writing the
+// line back halfway through.
+type testProgressEngine struct {
+	total int64
+	steps []int64
+}
+
+func (eng *testProgressEngine) Exec(ctx context.Context, line string, rw io.ReadWriter) int {
+	pb := NewProgressBar(rw.(*UI), eng.total)
+	for i, n := range eng.steps {
+		if i == len(eng.steps)/2 {
+			rw.Write([]byte(line + "\n"))
+		}
+		pb.Add(n)
+	}
+	pb.Done()
+	return 0
+}
+
+func TestProgressBar(t *testing.T) {
+	bar := func(pct int) string {
+		filled := pct * progressWidth / 100
+		return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled) + "]"
+	}
+
+	testCases := []struct {
+		Name  string
+		TTY   bool
+		Total int64
+		Steps []int64
+		Ex    string
+	}{
+		{
+			Name:  "NotTerminal",
+			Total: 100,
+			Steps: []int64{5, 5, 15, 25, 50},
+			Ex:    ">>10% (10/100)\n>hi\n>25% (25/100)\n>50% (50/100)\n>100% (100/100)\n>\n",
+		},
+		{
+			Name:  "NotTerminalUnfinished",
+			Total: 100,
+			Steps: []int64{5, 3},
+			Ex:    ">>hi\n>8% (8/100)\n>\n",
+		},
+		{
+			Name:  "NotTerminalOverflow",
+			Total: 10,
+			Steps: []int64{20},
+			Ex:    ">>hi\n>100% (10/10)\n>\n",
+		},
+		{
+			Name:  "Terminal",
+			TTY:   true,
+			Total: 100,
+			Steps: []int64{50, 50},
+			Ex:    ">\r" + bar(50) + "  50%\r\x1b[K>hi\n\r" + bar(100) + " 100%\r\x1b[K>\n",
+		},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.TTY }
+
+			in := &testLineReader{lines: []string{"hi\n"}}
+			var out bytes.Buffer
+			eng := &testProgressEngine{total: tc.Total, steps: tc.Steps}
+
+			err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithTheme(MonochromeTheme))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
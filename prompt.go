@@ -0,0 +1,249 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// isContextErr reports whether err is from a context being done.
+func isContextErr(err error) bool {
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
+
+// readLine reads the next line of input, while monitoring the
+// given context. The line terminator, either \n or \r\n, is not
+// included in the returned line. If the context is done, or the
+// read is interrupted by CancelRead, any partially read line is
+// kept buffered for the next read.
+//
+func (ui *UI) readLine(ctx context.Context) (string, error) {
+	line, _, err := ui.readLineRaw(ctx)
+	return line, err
+}
+
+// readLineRaw is the same as readLine, except for also reporting
+// whether the line was terminated.
+//
+func (ui *UI) readLineRaw(ctx context.Context) (string, bool, error) {
+	b, err := ui.readTerminated(ctx)
+	if !bytes.HasSuffix(b, []byte("\n")) {
+		return string(b), false, err
+	}
+	return strings.TrimSuffix(string(b[:len(b)-1]), "\r"), true, err
+}
+
+// readTerminated reads the next line of input, however long it is,
+// along with its terminator, unless the input ended before one. The
+// returned slice is only valid until the next read. If the context is
+// done, or the read is interrupted by CancelRead, nothing is returned
+// and any partially read line is kept buffered for the next read.
+//
+func (ui *UI) readTerminated(ctx context.Context) ([]byte, error) {
+	for {
+		buf := ui.rbuf[ui.rpos:]
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			ui.rpos += i + 1
+			ui.canUnreadByte = true
+			ui.lastRuneSize = 0
+			return buf[:i+1], nil
+		}
+
+		if ui.rerr != nil {
+			if isContextErr(ui.rerr) || ui.rerr == ErrReadInterrupted {
+				return nil, ui.readErr()
+			}
+			ui.rpos = len(ui.rbuf)
+			return buf, ui.readErr()
+		}
+
+		ui.fill(ctx)
+	}
+}
+
+// ReadLineRaw reads a line, without writing any prompt, the same as
+// Ask, and reports whether it was terminated by a newline, e.g. for a
+// protocol where a final line without one is incomplete. The
+// terminator, either \n or \r\n, is never part of the line.
+//
+// If the input ends with a line lacking the newline, that line is
+// returned along with hadNewline being false and io.EOF. Once the
+// input is exhausted, an empty line is returned along with io.EOF,
+// so a final line lacking the newline is told apart from a final
+// empty line by the line, or by the previous call having returned
+// hadNewline as true.
+//
+func (ui *UI) ReadLineRaw() (line string, hadNewline bool, err error) {
+	return ui.readLineRaw(ui.ctx)
+}
+
+// AtEOF reports whether the command being executed is from the last
+// line of the input, which ended without a newline, e.g. for an Engine
+// to commit a transaction along with the last command of a script. A
+// last line ending in a newline can't be told apart from any other
+// line, since the end of the input is only read after it's executed.
+// It's the same for every kind of input Reader, e.g. a bytes.Buffer or
+// a terminal.
+//
+func (ui *UI) AtEOF() bool {
+	return atomic.LoadInt32(&ui.atEOF) == 1
+}
+
+// ErrReadTimeout is returned by ReadLineTimeout when no line arrives
+// in time. It is recoverable and the input isn't lost, the next read
+// picks up any partially typed line.
+//
+var ErrReadTimeout = errors.New("sand: read timed out")
+
+// ReadLineTimeout reads a line, without writing any prompt, the same
+// as Ask, except that ErrReadTimeout is returned if no line arrives
+// within the given duration, e.g. for an Engine driving a menu to
+// redraw a live dashboard and prompt again. The read is abandoned,
+// not left running, so the next read gets whatever it would've read.
+//
+func (ui *UI) ReadLineTimeout(d time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ui.ctx, d)
+	defer cancel()
+
+	line, err := ui.readLine(ctx)
+	if err == context.DeadlineExceeded && ui.ctx.Err() == nil {
+		return "", ErrReadTimeout
+	}
+	return line, err
+}
+
+// Ask writes the prompt, without the prefix, and reads a line as
+// the answer. If history is enabled, e.g. by WithHistoryExpansion,
+// answers are recorded separately from the commands of the session,
+// so they are never recalled in place of a command, see AskHistory.
+//
+func (ui *UI) Ask(prompt string) (string, error) {
+	if _, err := ui.writePrompt([]byte(prompt)); err != nil {
+		return "", err
+	}
+
+	answer, err := ui.readLine(ui.ctx)
+	if err == nil && ui.history != nil {
+		ui.askHistory.add(answer, ui.clock().Now())
+	}
+	return answer, err
+}
+
+// CollectUntil reads lines, without writing any prompt, until a line
+// consisting of only the sentinel, e.g. for an Engine to read the
+// body of a "define foo" ... "end" block within Exec. The lines are
+// returned without their terminators and without the sentinel line.
+// Nested blocks are up to the Engine, which can call CollectUntil
+// again or count the nested openings itself. If the input ends
+// before the sentinel, the lines read so far are returned along with
+// an error whose cause is io.ErrUnexpectedEOF.
+//
+func (ui *UI) CollectUntil(sentinel string) ([]string, error) {
+	var lines []string
+	for {
+		line, err := ui.readLine(ui.ctx)
+		if strings.TrimSpace(line) == sentinel && (err == nil || err == io.EOF) {
+			return lines, nil
+		}
+		if err == io.EOF {
+			if line != "" {
+				lines = append(lines, line)
+			}
+			return lines, errors.Wrapf(io.ErrUnexpectedEOF, "sand: input ended before %q", sentinel)
+		}
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+// ErrTooManyTries is returned by AskValidated when every try was invalid.
+var ErrTooManyTries = errors.New("sand: too many invalid answers")
+
+// AskValidated is the same as Ask, except that it asks again, after
+// printing the validation error, until validate accepts the answer.
+// If maxTries is given and positive, at most that many answers are
+// read before giving up with ErrTooManyTries.
+//
+func (ui *UI) AskValidated(prompt string, validate func(string) error, maxTries ...int) (string, error) {
+	max := 0
+	if len(maxTries) > 0 {
+		max = maxTries[0]
+	}
+
+	for try := 1; ; try++ {
+		answer, err := ui.Ask(prompt)
+		if err != nil {
+			return answer, err
+		}
+
+		verr := validate(answer)
+		if verr == nil {
+			return answer, nil
+		}
+		if _, err = ui.writePrompt([]byte(ui.Theme().Error.Paint(verr.Error()) + "\n")); err != nil {
+			return answer, err
+		}
+		if max > 0 && try >= max {
+			return answer, ErrTooManyTries
+		}
+	}
+}
+
+// Confirm writes the prompt, without the prefix, and reads a yes
+// or no answer. An empty answer results in the default. Any other
+// answer results in the user being asked again.
+//
+func (ui *UI) Confirm(prompt string, def bool) (bool, error) {
+	return ui.confirm(ui.ctx, prompt, def)
+}
+
+// ConfirmTimeout is the same as Confirm, except that the default
+// is returned if the user doesn't answer within the given duration.
+// Any partially typed answer is kept for the next read.
+//
+func (ui *UI) ConfirmTimeout(prompt string, def bool, d time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ui.ctx, d)
+	defer cancel()
+
+	ok, err := ui.confirm(ctx, prompt, def)
+	if err == context.DeadlineExceeded && ui.ctx.Err() == nil {
+		return def, nil
+	}
+	return ok, err
+}
+
+func (ui *UI) confirm(ctx context.Context, prompt string, def bool) (bool, error) {
+	hint := " [y/N] "
+	if def {
+		hint = " [Y/n] "
+	}
+
+	for {
+		_, err := ui.writePrompt([]byte(prompt + hint))
+		if err != nil {
+			return def, err
+		}
+
+		line, err := ui.readLine(ctx)
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "":
+			if err != nil {
+				return def, err
+			}
+			return def, nil
+		}
+		if err != nil {
+			return def, err
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUI_Confirm(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		In    string
+		Def   bool
+		Ex    bool
+		ExOut string
+	}{
+		{Name: "Yes", In: "y\n", Ex: true, ExOut: "ok? [y/N] "},
+		{Name: "No", In: "No\r\n", Def: true, Ex: false, ExOut: "ok? [Y/n] "},
+		{Name: "Default", In: "\n", Def: true, Ex: true, ExOut: "ok? [Y/n] "},
+		{Name: "Retry", In: "maybe\nyes\n", Ex: true, ExOut: "ok? [y/N] ok? [y/N] "},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+			ui.out, ui.promptOut = &out, &out
+
+			ok, err := ui.Confirm("ok?", tc.Def)
+			if err != nil {
+				subT.Error(err)
+			}
+			if ok != tc.Ex {
+				subT.Errorf("expected %v but instead received: %v", tc.Ex, ok)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected output %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestUI_ConfirmTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	var out bytes.Buffer
+	ui := &UI{i: pr, ctx: context.Background()}
+	ui.out, ui.promptOut = &out, &out
+
+	start := time.Now()
+	ok, err := ui.ConfirmTimeout("ok?", true, 50*time.Millisecond)
+	if err != nil {
+		t.Error(err)
+	}
+	if !ok {
+		t.Errorf("expected default on timeout")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected timeout after 50ms but instead took: %s", d)
+	}
+
+	// The abandoned read must not lose the answer to the next question
+	go pw.Write([]byte("n\n"))
+	ok, err = ui.ConfirmTimeout("ok?", true, 5*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	if ok {
+		t.Errorf("expected answer from input after a previous timeout")
+	}
+}
+
+func TestUI_ReadLineTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	ui := &UI{i: pr, ctx: context.Background()}
+
+	start := time.Now()
+	line, err := ui.ReadLineTimeout(50 * time.Millisecond)
+	if err != ErrReadTimeout {
+		t.Errorf("expected ErrReadTimeout but instead received: %v", err)
+	}
+	if _, ok := IsRecoverable(err); !ok {
+		t.Errorf("expected ErrReadTimeout to be recoverable")
+	}
+	if line != "" {
+		t.Errorf("expected empty line but instead received: %q", line)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected timeout after 50ms but instead took: %s", d)
+	}
+
+	// The abandoned read must pick up the line written by the slow reader
+	go pw.Write([]byte("refresh\n"))
+	line, err = ui.ReadLineTimeout(5 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	if line != "refresh" {
+		t.Errorf("expected %q but instead received: %q", "refresh", line)
+	}
+}
+
+func TestUI_ReadLineRaw(t *testing.T) {
+	type rawLine struct {
+		Line       string
+		HadNewline bool
+		Err        error
+	}
+
+	testCases := []struct {
+		Name  string
+		Input string
+		Ex    []rawLine
+	}{
+		{
+			Name:  "Terminated",
+			Input: "a\nb\r\n",
+			Ex:    []rawLine{{"a", true, nil}, {"b", true, nil}, {"", false, io.EOF}},
+		},
+		{
+			Name:  "Unterminated",
+			Input: "a\nb",
+			Ex:    []rawLine{{"a", true, nil}, {"b", false, io.EOF}, {"", false, io.EOF}},
+		},
+		{
+			Name:  "EmptyLast",
+			Input: "a\n\n",
+			Ex:    []rawLine{{"a", true, nil}, {"", true, nil}, {"", false, io.EOF}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := &UI{i: strings.NewReader(tc.Input), ctx: context.Background()}
+			for _, ex := range tc.Ex {
+				var l rawLine
+				l.Line, l.HadNewline, l.Err = ui.ReadLineRaw()
+				if l != ex {
+					subT.Errorf("expected %+v but instead received: %+v", ex, l)
+				}
+			}
+		})
+	}
+}
+
+func TestUI_AskValidated(t *testing.T) {
+	isNumber := func(s string) error {
+		if _, err := strconv.Atoi(s); err != nil {
+			return errors.New("not a number: " + s)
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		Name  string
+		In    string
+		Max   []int
+		Ex    string
+		ExErr error
+		ExOut string
+	}{
+		{Name: "Valid", In: "42\n", Ex: "42", ExOut: "n? "},
+		{Name: "Retry", In: "x\n42\n", Ex: "42", ExOut: "n? not a number: x\nn? "},
+		{Name: "TooMany", In: "x\ny\n42\n", Max: []int{2}, Ex: "y", ExErr: ErrTooManyTries, ExOut: "n? not a number: x\nn? not a number: y\n"},
+		{Name: "EOF", In: "x\n", Ex: "", ExErr: io.EOF, ExOut: "n? not a number: x\nn? "},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+			ui.out, ui.promptOut = &out, &out
+
+			answer, err := ui.AskValidated("n? ", isNumber, tc.Max...)
+			if err != tc.ExErr {
+				subT.Errorf("expected error %v but instead received: %v", tc.ExErr, err)
+			}
+			if answer != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, answer)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected output %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestUI_CancelRead(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	ui := &UI{i: pr, ctx: context.Background()}
+
+	// Nothing to cancel yet, so this must not affect the next read
+	ui.CancelRead()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := ui.readLine(ui.ctx)
+		errCh <- err
+	}()
+
+	for {
+		ui.readMu.Lock()
+		waiting := ui.readCancel != nil
+		ui.readMu.Unlock()
+		if waiting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ui.CancelRead()
+
+	select {
+	case err := <-errCh:
+		if err != ErrReadInterrupted {
+			t.Errorf("expected %v but instead received: %v", ErrReadInterrupted, err)
+		}
+		if _, ok := IsRecoverable(err); !ok {
+			t.Errorf("expected %v to be recoverable", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected read to be interrupted")
+	}
+
+	go pw.Write([]byte("hello\n"))
+	line, err := ui.readLine(ui.ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if line != "hello" {
+		t.Errorf("expected %q but instead received: %q", "hello", line)
+	}
+}
+
+func TestUI_CollectUntil(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		In      string
+		Ex      []string
+		ExErr   error
+		ExAfter string
+	}{
+		{Name: "Block", In: "a\n  b\nend\nnext\n", Ex: []string{"a", "  b"}, ExAfter: "next"},
+		{Name: "Empty", In: " end \r\n", ExAfter: ""},
+		{Name: "SentinelAtEOF", In: "a\nend", Ex: []string{"a"}},
+		{Name: "UnexpectedEOF", In: "a\nb", Ex: []string{"a", "b"}, ExErr: io.ErrUnexpectedEOF},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+
+			lines, err := ui.CollectUntil("end")
+			if errors.Cause(err) != tc.ExErr {
+				subT.Errorf("expected error %v but instead received: %v", tc.ExErr, err)
+			}
+			if strings.Join(lines, "|") != strings.Join(tc.Ex, "|") {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, lines)
+			}
+			if tc.ExAfter != "" {
+				if after, _ := ui.readLine(ui.ctx); after != tc.ExAfter {
+					subT.Errorf("expected %q after the block but instead received: %q", tc.ExAfter, after)
+				}
+			}
+		})
+	}
+}
+
+// testEOFEngine records AtEOF for every line it executes.
+type testEOFEngine struct {
+	eofs []bool
+}
+
+func (eng *testEOFEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.eofs = append(eng.eofs, ui.(*UI).AtEOF())
+	return 0
+}
+
+func TestUI_AtEOF(t *testing.T) {
+	testCases := []struct {
+		Name string
+		In   func() io.Reader
+		Ex   []bool
+	}{
+		{Name: "Buffer", In: func() io.Reader { return bytes.NewBufferString("a\nb") }, Ex: []bool{false, true}},
+		{Name: "BufferNewline", In: func() io.Reader { return bytes.NewBufferString("a\nb\n") }, Ex: []bool{false, false}},
+		{Name: "Stream", In: func() io.Reader { return &testLineReader{lines: []string{"a\n", "b"}} }, Ex: []bool{false, true}},
+		{Name: "StreamNewline", In: func() io.Reader { return &testLineReader{lines: []string{"a\n", "b\n"}} }, Ex: []bool{false, false}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			eng := new(testEOFEngine)
+			err := Run(nil, eng, WithIO(tc.In(), ioutil.Discard))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Fatal(err)
+			}
+			if !reflect.DeepEqual(eng.eofs, tc.Ex) {
+				subT.Errorf("expected %v but instead received: %v", tc.Ex, eng.eofs)
+			}
+		})
+	}
+}
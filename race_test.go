@@ -0,0 +1,7 @@
+// +build race
+
+package sand
+
+// raceEnabled reports whether the tests are built with -race, which
+// plugins they load must be built with as well.
+const raceEnabled = true
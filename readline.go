@@ -0,0 +1,175 @@
+package sand
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PromptOptions configures how Prompt reads a line.
+//
+type PromptOptions struct {
+	// Prompt is written, without the prefix, before reading.
+	Prompt string
+
+	// Default is returned for an empty line.
+	Default string
+
+	// History holds entries, oldest first, e.g. AskHistory, which
+	// are recalled with the Up key, newest first, and the Down key.
+	// It's ignored if the input isn't a terminal.
+	History []string
+
+	// Completer completes the line when Tab is pressed. A single
+	// candidate replaces the line, while several are completed to
+	// their common prefix or, if there's none beyond the line,
+	// listed below it, one per row beside their descriptions if
+	// it's a CandidateCompleter. It's ignored if the input isn't a
+	// terminal.
+	Completer Completer
+
+	// Validate, if set, is called with every answer and the user is
+	// asked again, after the error is written, until it accepts one.
+	Validate func(answer string) error
+}
+
+// Prompt reads a line, as configured by opts, e.g. for an Engine to
+// read a rich input line in a single call. If the input is a terminal,
+// it is put into raw mode for the duration of the call, so the line can
+// be edited with Backspace, Ctrl-U and the key bindings of the UI, see
+// WithKeyBinding, history can be recalled and the line can be
+// completed. Otherwise, the line is read as is, the same as Ask. Like
+// Ask, answers are recorded if history is enabled, see AskHistory.
+//
+func (ui *UI) Prompt(opts PromptOptions) (string, error) {
+	for {
+		answer, err := ui.promptOnce(opts)
+		if err != nil {
+			return answer, err
+		}
+		if answer == "" {
+			answer = opts.Default
+		}
+		if ui.history != nil {
+			ui.askHistory.add(answer, ui.clock().Now())
+		}
+		if opts.Validate == nil {
+			return answer, nil
+		}
+
+		verr := opts.Validate(answer)
+		if verr == nil {
+			return answer, nil
+		}
+		if _, err = ui.writePrompt([]byte(ui.Theme().Error.Paint(verr.Error()) + "\n")); err != nil {
+			return answer, err
+		}
+	}
+}
+
+// promptOnce writes the prompt and reads a single answer.
+func (ui *UI) promptOnce(opts PromptOptions) (string, error) {
+	if _, err := ui.writePrompt([]byte(opts.Prompt)); err != nil {
+		return "", err
+	}
+
+	f, ok := ui.input().(*os.File)
+	if !ok || !isTerminal(f) {
+		return ui.readLine(ui.ctx)
+	}
+
+	entered, err := ui.enterRaw(f)
+	if err != nil {
+		return "", err
+	}
+	if entered {
+		defer ui.RestoreTerminal()
+	}
+	return ui.readEdited(opts)
+}
+
+// readEdited reads a line key by key, as typed into a terminal in raw
+// mode, echoing it and handling the editing keys of Prompt, along
+// with the key bindings of the UI, see WithKeyBinding.
+//
+func (ui *UI) readEdited(opts PromptOptions) (string, error) {
+	ed := &Editor{ui: ui, prompt: opts.Prompt}
+	hist := len(opts.History) // the entry recalled, or len if none
+
+	for {
+		r, err := ui.ReadKey()
+		if err != nil {
+			return ed.Line(), err
+		}
+
+		if action := ui.keyBinding(r); action != nil && r != '\r' && r != '\n' {
+			action(ed)
+			continue
+		}
+		switch r {
+		case '\r', '\n':
+			_, err = ui.writePrompt([]byte("\n"))
+			return ed.Line(), err
+		case 0x04: // Ctrl-D
+			if len(ed.line) == 0 {
+				return "", io.EOF
+			}
+		case 0x7f, '\b': // Backspace
+			ed.Delete(ed.pos-1, ed.pos)
+		case 0x15: // Ctrl-U
+			ed.line, ed.pos = ed.line[:0], 0
+			ed.Redraw()
+		case KeyUp, KeyDown:
+			if r == KeyUp && hist > 0 {
+				hist--
+			} else if r == KeyDown && hist < len(opts.History) {
+				hist++
+			} else {
+				continue
+			}
+			line := ""
+			if hist < len(opts.History) {
+				line = opts.History[hist]
+			}
+			ed.SetLine(line)
+		case '\t':
+			if opts.Completer == nil {
+				continue
+			}
+			cands := completeCandidates(opts.Completer, ed.Line())
+			if len(cands) == 0 {
+				continue
+			}
+			texts := candidateTexts(cands)
+			line := ed.Line()
+			if prefix := commonPrefix(texts); len(prefix) > len(line) {
+				line = prefix
+			} else if len(cands) > 1 && described(cands) {
+				ui.writePrompt(append([]byte("\n"), renderCandidates(cands, 0)...))
+			} else if len(cands) > 1 {
+				ui.writePrompt([]byte("\n" + strings.Join(texts, "  ") + "\n"))
+			}
+			ed.SetLine(line)
+		default:
+			if r < ' ' || r > utf8.MaxRune {
+				continue
+			}
+			ed.Insert(string(r))
+		}
+	}
+}
+
+// commonPrefix returns the longest prefix shared by every string.
+func commonPrefix(ss []string) string {
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
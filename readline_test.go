@@ -0,0 +1,157 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testWordCompleter completes the words it was given.
+type testWordCompleter []string
+
+func (c testWordCompleter) Complete(line string) []string {
+	var cands []string
+	for _, w := range c {
+		if strings.HasPrefix(w, line) {
+			cands = append(cands, w)
+		}
+	}
+	return cands
+}
+
+func TestUI_Prompt(t *testing.T) {
+	validate := func(s string) error {
+		if s == "no" {
+			return errors.New("not no")
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		Name  string
+		Opts  PromptOptions
+		In    string
+		Ex    string
+		ExOut string
+	}{
+		{
+			Name:  "Plain",
+			Opts:  PromptOptions{Prompt: "name? ", Default: "bob"},
+			In:    "alice\n",
+			Ex:    "alice",
+			ExOut: "name? ",
+		},
+		{
+			Name:  "Default",
+			Opts:  PromptOptions{Prompt: "name? ", Default: "bob"},
+			In:    "\n",
+			Ex:    "bob",
+			ExOut: "name? ",
+		},
+		{
+			Name:  "Validate",
+			Opts:  PromptOptions{Prompt: "? ", Validate: validate},
+			In:    "no\nyes\n",
+			Ex:    "yes",
+			ExOut: "? not no\n? ",
+		},
+		{
+			Name:  "IgnoresEditing",
+			Opts:  PromptOptions{Prompt: "? ", History: []string{"old"}, Completer: testWordCompleter{"abc"}},
+			In:    "a\t\n",
+			Ex:    "a\t",
+			ExOut: "? ",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background(), theme: &MonochromeTheme}
+			ui.out, ui.promptOut = &out, &out
+
+			answer, err := ui.Prompt(tc.Opts)
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if answer != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, answer)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestUI_ReadEdited(t *testing.T) {
+	opts := PromptOptions{
+		Prompt:    "> ",
+		History:   []string{"first", "second"},
+		Completer: testWordCompleter{"status", "stop", "start"},
+	}
+
+	testCases := []struct {
+		Name  string
+		In    string
+		Ex    string
+		ExOut string
+	}{
+		{Name: "Typed", In: "ab\r", Ex: "ab", ExOut: "ab\n"},
+		{Name: "Backspace", In: "abd\x7fc\r", Ex: "abc", ExOut: "abd\b \bc\n"},
+		{Name: "KillLine", In: "ab\x15c\r", Ex: "c", ExOut: "ab\r\x1b[K> c\n"},
+		{Name: "HistoryUp", In: "\x1b[A\x1b[A\r", Ex: "first", ExOut: "\r\x1b[K> second\r\x1b[K> first\n"},
+		{Name: "HistoryDown", In: "\x1b[A\x1b[B\r", Ex: "", ExOut: "\r\x1b[K> second\r\x1b[K> \n"},
+		{Name: "HistoryPastOldest", In: "\x1b[A\x1b[A\x1b[A\r", Ex: "first", ExOut: "\r\x1b[K> second\r\x1b[K> first\n"},
+		{Name: "CompleteSingle", In: "sto\t\r", Ex: "stop", ExOut: "sto\r\x1b[K> stop\n"},
+		{Name: "CompletePrefix", In: "s\t\r", Ex: "st", ExOut: "s\r\x1b[K> st\n"},
+		{Name: "CompleteList", In: "st\t\r", Ex: "st", ExOut: "st\nstatus  stop  start\n\r\x1b[K> st\n"},
+		{Name: "CompleteNone", In: "x\t\r", Ex: "x", ExOut: "x\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{i: strings.NewReader(tc.In), ctx: context.Background()}
+			ui.out, ui.promptOut = &out, &out
+
+			line, err := ui.readEdited(opts)
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if line != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, line)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestUI_ReadEdited_Described(t *testing.T) {
+	m := NewMux()
+	for _, verb := range []string{"status", "stop", "start"} {
+		m.Handle(verb, new(testRecordEngine))
+	}
+	m.SetDescription("stop", "stops it")
+
+	var out bytes.Buffer
+	ui := &UI{i: strings.NewReader("st\t\r"), ctx: context.Background()}
+	ui.out, ui.promptOut = &out, &out
+
+	line, err := ui.readEdited(PromptOptions{Prompt: "> ", Completer: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "st" {
+		t.Errorf("expected %q but instead received: %q", "st", line)
+	}
+	if ex := "st\nstart\nstatus\nstop    stops it\n\r\x1b[K> st\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
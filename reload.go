@@ -0,0 +1,25 @@
+package sand
+
+import "fmt"
+
+// WithReloadFunc specifies a function for reloading configuration,
+// which the UI calls whenever it receives a SIGHUP. The outcome of
+// the reload is reported to the user and the session continues.
+// User provided signal handlers are applied before this, so SIGHUP
+// can still be transformed into another signal. Platforms without
+// SIGHUP never call the function.
+//
+func WithReloadFunc(fn func() error) Option {
+	return func(ui *UI) {
+		ui.reload = fn
+	}
+}
+
+// doReload calls the reload func and reports the outcome.
+func (ui *UI) doReload() {
+	msg := "sand: reloaded configuration\n"
+	if err := ui.reload(); err != nil {
+		msg = ui.Theme().Error.Paint(fmt.Sprintf("sand: failed to reload configuration, %s", err)) + "\n"
+	}
+	ui.writePrompt([]byte(msg))
+}
@@ -0,0 +1,8 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package sand
+
+import "os"
+
+// reloadSignal is never delivered, since there is no SIGHUP.
+var reloadSignal os.Signal
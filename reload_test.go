@@ -0,0 +1,71 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package sand
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunWithReloadFunc(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Err   error
+		ExOut string
+	}{
+		{
+			Name:  "Success",
+			ExOut: "sand: reloaded configuration\n",
+		},
+		{
+			Name:  "Failure",
+			Err:   errors.New("bad config"),
+			ExOut: "sand: failed to reload configuration, bad config\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			pr, pw := io.Pipe()
+			var buf bytes.Buffer
+			out := &lockedWriter{w: &buf}
+
+			reloaded := make(chan struct{})
+			reload := func() error {
+				close(reloaded)
+				return tc.Err
+			}
+
+			go func() {
+				<-time.After(500 * time.Millisecond) // Give the UI a little time to start up
+				syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+
+				select {
+				case <-reloaded:
+				case <-time.After(5 * time.Second):
+					subT.Error("expected reload func to be called")
+				}
+				<-time.After(100 * time.Millisecond) // Give the UI time to report the reload
+				pw.Close()
+			}()
+
+			err := Run(nil, new(testEchoEngine), WithIO(pr, out), WithReloadFunc(reload))
+			var ok bool
+			if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+
+			out.mu.Lock()
+			defer out.mu.Unlock()
+			if !strings.Contains(buf.String(), tc.ExOut) {
+				subT.Errorf("expected output to contain %q but instead received: %q", tc.ExOut, buf.String())
+			}
+		})
+	}
+}
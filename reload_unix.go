@@ -0,0 +1,8 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package sand
+
+import "syscall"
+
+// reloadSignal is the signal which triggers a configuration reload.
+var reloadSignal = syscall.SIGHUP
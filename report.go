@@ -0,0 +1,44 @@
+package sand
+
+import "fmt"
+
+// WithStderrOnError makes Run print the error it returns to the Err
+// Writer of the UI, i.e. stderr unless set otherwise, see WithStderr,
+// if the error isn't recoverable, see IsRecoverable. The returned
+// error can be checked with IsReported, so callers which also log
+// errors don't print it twice.
+//
+func WithStderrOnError() Option {
+	return func(ui *UI) {
+		ui.reportErrs = true
+	}
+}
+
+// reportedErr marks an error which was printed by the UI.
+type reportedErr struct {
+	err error
+}
+
+func (e reportedErr) Error() string { return e.err.Error() }
+
+func (e reportedErr) Cause() error { return e.err }
+
+// IsReported reports whether err was already printed by Run, see
+// WithStderrOnError.
+//
+func IsReported(err error) bool {
+	_, ok := err.(reportedErr)
+	return ok
+}
+
+// reportErr prints err to the Err Writer, unless it's recoverable.
+// Unlike WriteErr, it still writes once the session is over.
+//
+func (ui *UI) reportErr(err error) error {
+	if _, ok := IsRecoverable(err); ok {
+		return err
+	}
+
+	fmt.Fprintln(ui.errOutput(), ui.Theme().Error.Paint(err.Error()))
+	return reportedErr{err: err}
+}
@@ -0,0 +1,75 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestUI_ReportErr(t *testing.T) {
+	var netErr error = &testNetErr{}
+
+	testCases := []struct {
+		Name  string
+		Err   error
+		ExOut string
+	}{
+		{Name: "Nil", Err: nil},
+		{Name: "EOF", Err: io.EOF},
+		{Name: "Canceled", Err: errors.Wrap(context.Canceled, "sand: wrapped")},
+		{Name: "NetError", Err: errors.Wrap(netErr, "sand: read failed"), ExOut: "sand: read failed: test net error\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{Err: &out}
+
+			err := ui.reportErr(tc.Err)
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected output %q but instead received: %q", tc.ExOut, out.String())
+			}
+			if IsReported(err) != (tc.ExOut != "") {
+				subT.Errorf("expected error to be reported only if printed")
+			}
+			if errors.Cause(err) != errors.Cause(tc.Err) {
+				subT.Errorf("expected cause %v but instead received: %v", errors.Cause(tc.Err), errors.Cause(err))
+			}
+		})
+	}
+}
+
+// testNetErr is a net.Error, which IsRecoverable considers fatal.
+type testNetErr struct{}
+
+func (*testNetErr) Error() string   { return "test net error" }
+func (*testNetErr) Timeout() bool   { return false }
+func (*testNetErr) Temporary() bool { return false }
+
+func TestRunWithStderrOnError(t *testing.T) {
+	var stderr bytes.Buffer
+	filter := func(b []byte) []byte {
+		var m map[string]int
+		m["x"]++
+		return b
+	}
+
+	ui := new(UI)
+	err := ui.Run(nil, new(testEchoEngine),
+		WithIO(strings.NewReader("a\n"), ioutil.Discard),
+		WithInputFilter(filter),
+		WithStderrOnError(),
+		WithStderr(&stderr),
+	)
+	if !IsReported(err) {
+		t.Errorf("expected reported error but instead received: %v", err)
+	}
+	if !strings.HasPrefix(stderr.String(), "sand: recovered from panic: assignment to entry in nil map") {
+		t.Errorf("expected panic to be printed but instead received: %q", stderr.String())
+	}
+}
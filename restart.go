@@ -0,0 +1,63 @@
+package sand
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+// WithRestartCommand installs a builtin of the given name, e.g.
+// "reload", which tears down the current Engine and replaces it with
+// a new one created by calling factory, e.g. for picking up changes
+// to its configuration or recovering a wedged Engine, without ending
+// the session. Everything kept by the UI, e.g. its variables and
+// history, is kept across the restart.
+//
+// The restart takes effect once the builtin returns, so no command
+// of the session is executing. The old Engine is detached the same as
+// by PopEngine, i.e. shut down if no other UI uses it, see
+// Shutdowner, and an error shutting it down is written, instead of
+// ending the session. Commands executing the old Engine directly,
+// e.g. background jobs, see WithJobs, finish on the old Engine.
+//
+func WithRestartCommand(name string, factory func() Engine) Option {
+	return func(ui *UI) {
+		ui.restartFn = factory
+		ui.addBuiltin(name, "restart the engine", restartBuiltin)
+	}
+}
+
+// restartBuiltin requests the Engine to be restarted, see WithRestartCommand.
+func restartBuiltin(ctx context.Context, args []string, ui *UI) int {
+	ui.mu.Lock()
+	ui.restartReq = true
+	ui.mu.Unlock()
+	return 0
+}
+
+// restartEngine replaces the current Engine with a new one, if it was
+// requested by the restart builtin.
+//
+func (ui *UI) restartEngine(s *engineStack) {
+	ui.mu.Lock()
+	req := ui.restartReq
+	ui.restartReq = false
+	ui.mu.Unlock()
+	if !req {
+		return
+	}
+
+	eng := ui.restartFn()
+	if eng == nil {
+		ui.writePrompt([]byte(ui.Theme().Error.Paint(errNoEngine.Error()) + "\n"))
+		return
+	}
+	ui.tracef("restart", "%T", eng)
+	err := ui.popEngine(s)
+	ui.pushEngine(s, eng)
+	if err != nil {
+		err = errors.Wrap(err, "sand: encountered error while shutting down engine")
+		ui.writePrompt([]byte(ui.Theme().Error.Paint(err.Error()) + "\n"))
+		return
+	}
+	ui.writePrompt([]byte("sand: restarted engine\n"))
+}
@@ -0,0 +1,71 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testGenEngine writes its generation, and sets it as the variable
+// "gen" for a "set" line. It counts its shutdowns.
+//
+type testGenEngine struct {
+	gen       int
+	shutdowns int32
+}
+
+func (eng *testGenEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	gen := strconv.Itoa(eng.gen)
+	if strings.TrimSpace(line) == "set" {
+		ui.(*UI).Set("gen", gen)
+	}
+	ui.Write([]byte("gen " + gen + "\n"))
+	return 0
+}
+
+func (eng *testGenEngine) Shutdown(ctx context.Context) error {
+	atomic.AddInt32(&eng.shutdowns, 1)
+	return nil
+}
+
+func TestWithRestartCommand(t *testing.T) {
+	var engs []*testGenEngine
+	factory := func() Engine {
+		eng := &testGenEngine{gen: len(engs) + 1}
+		engs = append(engs, eng)
+		return eng
+	}
+
+	ui := new(UI)
+	in := &testLineReader{lines: []string{"set\n", "reload\n", "id\n"}}
+	var out bytes.Buffer
+	err := ui.Run(nil, factory(), WithPrefix(">"), WithIO(in, &out), WithHistoryExpansion(), WithRestartCommand("reload", factory))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	ex := ">>gen 1\n>sand: restarted engine\n>>gen 2\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if len(engs) != 2 {
+		t.Fatalf("expected 2 engines but instead received: %d", len(engs))
+	}
+	for i, eng := range engs {
+		if n := atomic.LoadInt32(&eng.shutdowns); n != 1 {
+			t.Errorf("expected engine %d to be shut down once but instead received: %d", i+1, n)
+		}
+	}
+
+	// The session is kept across the restart
+	if gen, _ := ui.Get("gen"); gen != "1" {
+		t.Errorf("expected %q but instead received: %q", "1", gen)
+	}
+	if h := strings.Join(ui.History(), ","); h != "set,reload,id" {
+		t.Errorf("expected %q but instead received: %q", "set,reload,id", h)
+	}
+}
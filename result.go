@@ -0,0 +1,214 @@
+package sand
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// Result represents the structured outcome of a command, see ResultEngine.
+//
+type Result struct {
+	// Status is treated the same as the status returned by Exec.
+	Status int
+
+	// Payload is the output of the command, which the
+	// UI renders according to its output format.
+	Payload interface{}
+
+	// Meta is any metadata about the command, e.g. how many
+	// rows a query affected, and is never rendered.
+	Meta map[string]string
+}
+
+// ResultEngine is implemented by Engines which return a Result,
+// instead of writing their output themselves. The UI calls
+// ExecResult in place of Exec and renders the Payload.
+//
+type ResultEngine interface {
+	Engine
+
+	// ExecResult is the same as Exec, except for returning a Result.
+	ExecResult(ctx context.Context, line string, ui io.ReadWriter) Result
+}
+
+// OutputEngine is implemented by types which return their output,
+// instead of writing it themselves, e.g. simple engines which don't
+// need to stream output or read input, see NewOutputEngine. The UI
+// calls ExecOut in place of Exec and writes the returned output,
+// unless it's empty, after the call, rendered the same as a string
+// payload of a Result, see WithOutputFormat. Anything written to ui
+// during the call is thus written before the returned output. An
+// Engine implementing both ResultEngine and OutputEngine is treated
+// as a ResultEngine.
+//
+type OutputEngine interface {
+	// ExecOut is the same as Exec, except for returning the output.
+	ExecOut(ctx context.Context, line string, ui io.ReadWriter) (out string, status int)
+}
+
+// OutputFunc is a func used as an OutputEngine.
+type OutputFunc func(ctx context.Context, line string, ui io.ReadWriter) (out string, status int)
+
+// ExecOut calls f.
+func (f OutputFunc) ExecOut(ctx context.Context, line string, ui io.ReadWriter) (string, int) {
+	return f(ctx, line, ui)
+}
+
+// NewOutputEngine returns an Engine executing every line by calling
+// ExecOut of oe, so it doesn't need an Exec method of its own, e.g.
+// for an Engine echoing every line:
+//
+//	sand.Run(ctx, sand.NewOutputEngine(sand.OutputFunc(func(ctx context.Context, line string, ui io.ReadWriter) (string, int) {
+//		return line, 0
+//	})))
+//
+// Like NewFuncEngine, every call returns a distinct Engine.
+//
+func NewOutputEngine(oe OutputEngine) Engine {
+	if oe == nil {
+		panic(errNoEngine)
+	}
+	return &outputEngine{OutputEngine: oe}
+}
+
+// outputEngine is an Engine calling an OutputEngine, see
+// NewOutputEngine. The UI calls ExecOut itself, so Exec is only
+// called by Engines routing lines to it, e.g. a Mux, and writes the
+// output as is, along with a newline.
+//
+type outputEngine struct {
+	OutputEngine
+}
+
+func (e *outputEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	out, status := e.ExecOut(ctx, line, ui)
+	if out == "" {
+		return status
+	}
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	if _, err := io.WriteString(ui, out); err != nil && status == 0 {
+		return 1
+	}
+	return status
+}
+
+// OutputFormat represents how the UI renders Result payloads.
+type OutputFormat int
+
+const (
+	// FormatText renders strings and byte slices as is and
+	// anything else as formatted by the fmt package's "%v".
+	FormatText OutputFormat = iota
+
+	// FormatJSON renders payloads as JSON, one per line.
+	FormatJSON
+)
+
+// WithOutputFormat specifies how to render Result payloads, the
+// default being FormatText.
+//
+func WithOutputFormat(f OutputFormat) Option {
+	return func(ui *UI) {
+		ui.format = f
+	}
+}
+
+// LastResult returns the Result of the most recent command
+// executed by a ResultEngine.
+//
+func (ui *UI) LastResult() Result {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	return ui.lastResult
+}
+
+// execEngine calls the Engine with the line, through the middlewares
+// of the UI it's wrapped by, see wrapEngine, and returns its status.
+//
+func (ui *UI) execEngine(ctx context.Context, wrapped Engine, line string) int {
+	return wrapped.Exec(ctx, line, ui.engineIO(ctx))
+}
+
+// callEngine calls the Engine with the line and returns its status,
+// rendering the Result of a ResultEngine, or output of an OutputEngine,
+// and executing the units of a FanoutEngine.
+//
+func (ui *UI) callEngine(ctx context.Context, eng Engine, line string, rw io.ReadWriter) (status int) {
+	if ui.trace != nil {
+		ui.tracef("enter", "%T %q", eng, line)
+		defer func() { ui.tracef("exit", "%T status %d", eng, status) }()
+	}
+	if _, beside := ctx.Value(cmdOutKey{}).(*cmdOutput); !beside {
+		atomic.StoreInt64(&ui.cmdOut, 0)
+		ui.resetWrap()
+	}
+
+	if fe, ok := eng.(FanoutEngine); ok {
+		if units := fe.Fanout(line); units != nil {
+			return ui.execFanout(ctx, fe, units, rw)
+		}
+	}
+
+	if re, ok := eng.(ResultEngine); ok {
+		res := re.ExecResult(ctx, line, rw)
+		ui.mu.Lock()
+		ui.lastResult = res
+		ui.mu.Unlock()
+
+		if res.Payload == nil {
+			return res.Status
+		}
+		return ui.writePayload(rw, res.Payload, res.Status)
+	}
+
+	if oe, ok := eng.(OutputEngine); ok {
+		out, status := oe.ExecOut(ctx, line, rw)
+		if out == "" {
+			return status
+		}
+		return ui.writePayload(rw, out, status)
+	}
+	return eng.Exec(ctx, line, rw)
+}
+
+// writePayload renders and writes the payload to w, returning the
+// status of the command, which is 1 instead of 0 if the write fails.
+//
+func (ui *UI) writePayload(w io.Writer, payload interface{}, status int) int {
+	b, err := ui.renderPayload(payload)
+	if err == nil {
+		_, err = w.Write(b)
+	}
+	if err != nil && status == 0 {
+		return 1
+	}
+	return status
+}
+
+// renderPayload renders the payload according to the output format.
+func (ui *UI) renderPayload(payload interface{}) ([]byte, error) {
+	if ui.format == FormatJSON {
+		b, err := json.Marshal(payload)
+		return append(b, '\n'), err
+	}
+
+	var s string
+	switch v := payload.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprintf("%v", v)
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,122 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// testResultEngine returns a Result with a fixed payload for every line.
+type testResultEngine struct {
+	payload interface{}
+}
+
+func (eng *testResultEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	panic("Exec must not be called for a ResultEngine")
+}
+
+func (eng *testResultEngine) ExecResult(ctx context.Context, line string, ui io.ReadWriter) Result {
+	return Result{Payload: eng.payload, Meta: map[string]string{"line": line}}
+}
+
+func TestResultEngine(t *testing.T) {
+	type row struct {
+		Name string `json:"name"`
+		N    int    `json:"n"`
+	}
+
+	testCases := []struct {
+		Name    string
+		Payload interface{}
+		Format  OutputFormat
+		Ex      string
+	}{
+		{Name: "String", Payload: "hello", Ex: "hello\n"},
+		{Name: "Bytes", Payload: []byte("hello\n"), Ex: "hello\n"},
+		{Name: "Struct", Payload: row{Name: "a", N: 1}, Ex: "{a 1}\n"},
+		{Name: "None", Payload: nil, Ex: ""},
+		{Name: "JSON", Payload: row{Name: "a", N: 1}, Format: FormatJSON, Ex: "{\"name\":\"a\",\"n\":1}\n"},
+		{Name: "JSONString", Payload: "hello", Format: FormatJSON, Ex: "\"hello\"\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: []string{"q\n"}}
+			var out bytes.Buffer
+
+			ui := new(UI)
+			err := ui.Run(nil, &testResultEngine{payload: tc.Payload}, WithIO(in, &out), WithOutputFormat(tc.Format))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+
+			if ex := tc.Ex + "\n"; out.String() != ex {
+				subT.Errorf("expected %q but instead received: %q", ex, out.String())
+			}
+			if line := ui.LastResult().Meta["line"]; line != "q" {
+				subT.Errorf("expected metadata to be recorded but instead received: %q", line)
+			}
+		})
+	}
+}
+
+// testOutputEngine writes direct, if any, and returns the line as output.
+type testOutputEngine struct {
+	direct string
+	status int
+}
+
+func (eng *testOutputEngine) ExecOut(ctx context.Context, line string, ui io.ReadWriter) (string, int) {
+	if eng.direct != "" {
+		ui.Write([]byte(eng.direct))
+	}
+	return line, eng.status
+}
+
+func TestOutputEngine(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Line   string
+		Eng    *testOutputEngine
+		Format OutputFormat
+		Ex     string
+	}{
+		{Name: "Output", Line: "hello\n", Eng: &testOutputEngine{}, Ex: "hello\n\n"},
+		{Name: "Empty", Line: "\n", Eng: &testOutputEngine{}, Ex: "\n"},
+		{Name: "DirectFirst", Line: "hello\n", Eng: &testOutputEngine{direct: "direct "}, Ex: "direct hello\n\n"},
+		{Name: "JSON", Line: "hello\n", Eng: &testOutputEngine{}, Format: FormatJSON, Ex: "\"hello\"\n\n"},
+		{Name: "Status", Line: "hello\n", Eng: &testOutputEngine{status: 3}, Ex: "hello\n\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: []string{tc.Line}}
+			var out bytes.Buffer
+
+			err := Run(nil, NewOutputEngine(tc.Eng), WithIO(in, &out), WithOutputFormat(tc.Format))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestOutputEngine_Routed(t *testing.T) {
+	echo := OutputFunc(func(ctx context.Context, line string, ui io.ReadWriter) (string, int) {
+		return line, 0
+	})
+	m := NewMux()
+	m.Handle("echo", NewOutputEngine(echo))
+
+	out := runBuiltinTest(t, m, "echo hello\n")
+	if ex := ">>hello\n>\n"; out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+}
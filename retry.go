@@ -0,0 +1,57 @@
+package sand
+
+import (
+	"context"
+	"strings"
+)
+
+// WithRetry installs a "retry" builtin, which executes the most recent
+// command that failed, i.e. returned a non-zero status, again, e.g.
+// after fixing whatever made it fail. Since a failing command ends
+// Run, this is meant for a UI which is run again afterwards, which
+// keeps the failed command. The command is written before it's
+// executed and forgotten once it succeeds.
+//
+func WithRetry() Option {
+	return func(ui *UI) {
+		ui.addBuiltin("retry", "execute the most recent failed command again", retryBuiltin)
+	}
+}
+
+// recordStatus remembers the line if its status is a failure, see WithRetry.
+func (ui *UI) recordStatus(line string, status int) {
+	switch status {
+	case 0, StatusNeedMore, StatusNotHandled, StatusClear, StatusExit:
+		return
+	}
+	ui.mu.Lock()
+	ui.lastFailed = line
+	ui.mu.Unlock()
+}
+
+func retryBuiltin(ctx context.Context, args []string, ui *UI) int {
+	ui.mu.Lock()
+	line, reqCh := ui.lastFailed, ui.reqCh
+	ui.mu.Unlock()
+	if line == "" || reqCh == nil {
+		ui.writePrompt([]byte("no failed command to retry\n"))
+		return 0
+	}
+
+	ui.writePrompt([]byte(strings.TrimRight(line, "\r\n") + "\n"))
+	status := ui.exec(ctx, line, reqCh)
+	if status != 0 {
+		return status
+	}
+
+	ui.mu.Lock()
+	if ui.lastFailed == line {
+		ui.lastFailed = ""
+	}
+	ui.mu.Unlock()
+	if err := ui.logCommand(line); err != nil {
+		ui.writePrompt([]byte(ui.Theme().Error.Paint(err.Error()) + "\n"))
+		return 1
+	}
+	return 0
+}
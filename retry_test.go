@@ -0,0 +1,57 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testFlakyEngine fails the given number of times before echoing lines.
+type testFlakyEngine struct {
+	fails int
+	execs int
+}
+
+func (eng *testFlakyEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.execs++
+	if eng.fails > 0 {
+		eng.fails--
+		return 1
+	}
+	ui.Write([]byte(line + "\n"))
+	return 0
+}
+
+func TestWithRetry(t *testing.T) {
+	ui := new(UI)
+	eng := &testFlakyEngine{fails: 1}
+	run := func(lines ...string) string {
+		var out bytes.Buffer
+		in := &testLineReader{lines: lines}
+		ui.Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithRetry())
+		return out.String()
+	}
+
+	if out := run("retry\n"); !strings.Contains(out, "no failed command to retry\n") {
+		t.Errorf("expected message about no failed command but instead received: %q", out)
+	}
+
+	run("a\n")
+	if eng.execs != 1 {
+		t.Fatalf("expected failing command to be executed once but instead received: %d", eng.execs)
+	}
+
+	if out, ex := run("retry\n"), ">a\n>a\n>\n"; out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+	if eng.execs != 2 {
+		t.Errorf("expected failed command to be executed again but instead received: %d", eng.execs)
+	}
+
+	// The command is forgotten once it succeeded
+	if out := run("retry\n"); !strings.Contains(out, "no failed command to retry\n") {
+		t.Errorf("expected message about no failed command but instead received: %q", out)
+	}
+}
@@ -0,0 +1,57 @@
+package sand
+
+import (
+	"golang.org/x/term"
+	"os"
+	"strings"
+)
+
+// WithRightPrompt renders the result of fn right aligned on the
+// prompt line, like zsh's RPROMPT, e.g. for showing the time or the
+// status of the last command. It is only rendered if the prompt is
+// written to a terminal and hidden if it doesn't fit on the line.
+//
+func WithRightPrompt(fn func() string) Option {
+	return func(ui *UI) {
+		ui.rprompt = fn
+	}
+}
+
+// termWidth returns the number of columns of v, or 0 if v isn't a
+// terminal. It is a variable so tests can use a fixed width.
+//
+var termWidth = func(v interface{}) int {
+	f, ok := v.(*os.File)
+	if !ok || !isTerminal(f) {
+		return 0
+	}
+	w, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return w
+}
+
+// rightPrompt returns the right prompt padded to end at the last
+// column, given the left prompt, wrapped in cursor save and restore
+// sequences so input is still read after the left prompt. It returns
+// an empty string if there's no right prompt or it doesn't fit.
+//
+func (ui *UI) rightPrompt(left string) string {
+	if ui.rprompt == nil {
+		return ""
+	}
+	cols := termWidth(ui.promptDest())
+	if cols <= 0 {
+		return ""
+	}
+
+	right := ui.rprompt()
+	// Keep a column free between the prompts and at the end, where
+	// the cursor would otherwise wrap to the next line
+	pad := cols - displayWidth(left) - displayWidth(right) - 1
+	if right == "" || pad < 1 {
+		return ""
+	}
+	return "\x1b7" + strings.Repeat(" ", pad) + right + "\x1b8"
+}
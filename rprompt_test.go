@@ -0,0 +1,35 @@
+package sand
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithRightPrompt(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Width int
+		Right string
+		Ex    string
+	}{
+		{Name: "NotTerminal", Width: 0, Right: "12:00", Ex: ">"},
+		{Name: "Aligned", Width: 20, Right: "12:00", Ex: ">\x1b7" + strings.Repeat(" ", 13) + "12:00\x1b8"},
+		{Name: "ANSI", Width: 10, Right: "\x1b[32mok\x1b[0m", Ex: ">\x1b7" + strings.Repeat(" ", 6) + "\x1b[32mok\x1b[0m\x1b8"},
+		{Name: "Wide", Width: 10, Right: "世界", Ex: ">\x1b7" + strings.Repeat(" ", 4) + "世界\x1b8"},
+		{Name: "Overflow", Width: 6, Right: "12:00", Ex: ">"},
+		{Name: "Empty", Width: 20, Right: "", Ex: ">"},
+	}
+
+	defer func(f func(interface{}) int) { termWidth = f }(termWidth)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			termWidth = func(interface{}) int { return tc.Width }
+
+			out := runBuiltinTest(subT, new(testEchoEngine), "", WithRightPrompt(func() string { return tc.Right }))
+			if ex := tc.Ex + "\n"; out != ex {
+				subT.Errorf("expected %q but instead received: %q", ex, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package sandtest
+
+import (
+	"bytes"
+	"flag"
+	"github.com/Zaba505/sand"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"testing"
+)
+
+// update is the -update flag of the test binary, which makes Golden
+// rewrite the golden files instead of comparing against them. A test
+// binary importing sandtest thus can't define a flag of its own by
+// that name.
+//
+var update = flag.Bool("update", false, "rewrite the golden files of sandtest.Golden")
+
+// Scrubber normalizes the nondeterministic parts of the output of a
+// session, e.g. timestamps, so it can be compared against a golden file.
+//
+type Scrubber func(output string) string
+
+// timestamp matches dates with a time, e.g. "2006-01-02T15:04:05Z07:00",
+// and times on their own, e.g. "15:04:05.000".
+var timestamp = regexp.MustCompile(`(\d{4}-\d{2}-\d{2}[T ]|\b)\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+
+// ScrubTimestamps replaces every timestamp in the output with
+// "TIMESTAMP", i.e. a time.RFC3339 timestamp, optionally with a space
+// instead of the "T", fractional seconds or without the zone, e.g.
+// "2006-01-02 15:04:05", or a time of the day on its own, e.g. "15:04:05".
+//
+func ScrubTimestamps(output string) string {
+	return timestamp.ReplaceAllString(output, "TIMESTAMP")
+}
+
+// Golden runs the Engine, the same as Run, with the contents of the
+// file at inputPath as its input and compares everything written,
+// by the Engine and the UI alike, after ScrubTimestamps, against the
+// contents of the golden file at goldenPath. With the -update flag
+// given to "go test", the golden file is written instead.
+//
+//	func TestSession(t *testing.T) {
+//		sandtest.Golden(t, new(Engine), "testdata/session.input", "testdata/session.golden")
+//	}
+//
+func Golden(t testing.TB, eng sand.Engine, inputPath, goldenPath string, opts ...sand.Option) {
+	t.Helper()
+	GoldenScrubbed(t, eng, inputPath, goldenPath, ScrubTimestamps, opts...)
+}
+
+// GoldenScrubbed is the same as Golden, except for normalizing the
+// output with scrub instead of ScrubTimestamps, which is done without
+// if scrub is nil.
+//
+func GoldenScrubbed(t testing.TB, eng sand.Engine, inputPath, goldenPath string, scrub Scrubber, opts ...sand.Option) {
+	t.Helper()
+
+	input, err := ioutil.ReadFile(inputPath)
+	if err != nil {
+		t.Fatalf("sandtest: encountered error while reading input: %s", err)
+	}
+
+	var out bytes.Buffer
+	opts = append([]sand.Option{sand.WithIO(bytes.NewReader(input), &out)}, opts...)
+	err = sand.Run(nil, eng, opts...)
+	if root, ok := sand.IsRecoverable(err); !ok || root != nil && root != io.EOF {
+		t.Fatalf("sandtest: encountered error while running engine: %s", err)
+	}
+
+	output := out.String()
+	if scrub != nil {
+		output = scrub(output)
+	}
+
+	if *update {
+		if err = ioutil.WriteFile(goldenPath, []byte(output), 0644); err != nil {
+			t.Fatalf("sandtest: encountered error while updating golden file: %s", err)
+		}
+		return
+	}
+
+	golden, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("sandtest: encountered error while reading golden file, run with -update to create it: %s", err)
+	}
+	if output != string(golden) {
+		t.Errorf("expected output of %s to match %s: %q but instead received: %q", inputPath, goldenPath, golden, output)
+	}
+}
@@ -0,0 +1,51 @@
+package sandtest
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// clockEngine writes every line it receives back to the ui, along
+// with the current time.
+type clockEngine struct{}
+
+func (clockEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	ui.Write([]byte(time.Now().Format(time.RFC3339) + " " + line + "\n"))
+	return 0
+}
+
+func TestGolden(t *testing.T) {
+	Golden(t, new(echoEngine), "testdata/echo.input", "testdata/echo.golden")
+}
+
+func TestGoldenScrubbed(t *testing.T) {
+	Golden(t, clockEngine{}, "testdata/echo.input", "testdata/clock.golden")
+
+	eof := func(s string) string { return s + "EOF\n" }
+	GoldenScrubbed(t, new(echoEngine), "testdata/echo.input", "testdata/echo.scrubbed.golden", eof)
+}
+
+func TestScrubTimestamps(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Output string
+		Ex     string
+	}{
+		{Name: "RFC3339", Output: "at 2006-01-02T15:04:05+07:00.\n", Ex: "at TIMESTAMP.\n"},
+		{Name: "RFC3339Nano", Output: "2006-01-02T15:04:05.999999999Z\n", Ex: "TIMESTAMP\n"},
+		{Name: "DateTime", Output: "[2006-01-02 15:04:05] ok\n", Ex: "[TIMESTAMP] ok\n"},
+		{Name: "Time", Output: "took until 15:04:05.000\n", Ex: "took until TIMESTAMP\n"},
+		{Name: "None", Output: "version 1.2.3\n", Ex: "version 1.2.3\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			if out := ScrubTimestamps(tc.Output); out != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package sandtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/Zaba505/sand"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long an Interaction waits for each expected
+// output, unless its Timeout is set.
+const DefaultTimeout = 5 * time.Second
+
+// step represents either expecting output or sending input.
+type step struct {
+	expect string
+	send   string
+	isSend bool
+}
+
+// Interaction scripts a session with an Engine which reads input
+// mid Exec, e.g. a game asking for the next move. Every step waits
+// for the previous one, so input is only sent once the expected
+// output, from either the Engine or the UI, has been written.
+//
+//	res := sandtest.NewInteraction().
+//		Send("tictactoe\n").
+//		Expect(">").Send("5\n").
+//		Run(nil, new(T3Engine), sand.WithPrefix(">"))
+//
+type Interaction struct {
+	// Timeout is how long to wait for each expected output,
+	// DefaultTimeout is used if it is zero.
+	Timeout time.Duration
+
+	steps []step
+}
+
+// NewInteraction returns an empty Interaction.
+func NewInteraction() *Interaction {
+	return new(Interaction)
+}
+
+// Expect adds a step which waits for s to be written, after the
+// output matched by the previous Expect step.
+//
+func (it *Interaction) Expect(s string) *Interaction {
+	it.steps = append(it.steps, step{expect: s})
+	return it
+}
+
+// Send adds a step which sends input to the UI.
+func (it *Interaction) Send(input string) *Interaction {
+	it.steps = append(it.steps, step{send: input, isSend: true})
+	return it
+}
+
+// Run runs the Engine in a new UI, performing the steps of the
+// Interaction, and then ends the session by closing the input. If
+// an expected output isn't written in time, the session is canceled
+// and the Err of the Result describes the unmet expectation. The
+// options are treated the same as by the Run function.
+//
+func (it *Interaction) Run(ctx context.Context, eng sand.Engine, opts ...sand.Option) *Result {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	timeout := it.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	t := &transcript{changed: make(chan struct{})}
+	out, prompt := &transcriptWriter{t: t}, &transcriptWriter{t: t}
+	pr, pw := io.Pipe()
+
+	stepErr := make(chan error, 1)
+	go func() {
+		defer pw.Close()
+		for _, s := range it.steps {
+			var err error
+			if s.isSend {
+				_, err = pw.Write([]byte(s.send))
+			} else {
+				err = t.await(ctx, s.expect, timeout)
+			}
+			if err != nil {
+				stepErr <- err
+				cancel()
+				return
+			}
+		}
+		stepErr <- nil
+	}()
+
+	opts = append([]sand.Option{
+		sand.WithIO(pr, out),
+		sand.WithPromptWriter(prompt),
+	}, opts...)
+
+	err := sand.Run(ctx, eng, opts...)
+	pr.Close()
+	if serr := <-stepErr; serr != nil {
+		err = serr
+	} else if root, ok := sand.IsRecoverable(err); ok && (root == nil || root == io.EOF) {
+		err = nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	return &Result{
+		Output: out.buf.String(),
+		Prompt: prompt.buf.String(),
+		Err:    err,
+	}
+}
+
+// transcript is the combined output of the Engine and the UI.
+type transcript struct {
+	sync.Mutex
+	buf     bytes.Buffer
+	pos     int           // end of the last expected output
+	changed chan struct{} // closed, and replaced, on every write
+}
+
+// await waits for s to be written after the last expected output.
+func (t *transcript) await(ctx context.Context, s string, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		t.Lock()
+		rest := t.buf.String()[t.pos:]
+		if i := strings.Index(rest, s); i >= 0 {
+			t.pos += i + len(s)
+			t.Unlock()
+			return nil
+		}
+		changed := t.changed
+		t.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return fmt.Errorf("sandtest: expected %q within %s but received: %q", s, timeout, rest)
+		}
+	}
+}
+
+// transcriptWriter writes to its own buffer as well as the transcript.
+type transcriptWriter struct {
+	t   *transcript
+	buf bytes.Buffer
+}
+
+func (w *transcriptWriter) Write(b []byte) (int, error) {
+	w.t.Lock()
+	defer w.t.Unlock()
+
+	w.buf.Write(b)
+	w.t.buf.Write(b)
+	close(w.t.changed)
+	w.t.changed = make(chan struct{})
+	return len(b), nil
+}
@@ -0,0 +1,52 @@
+// Package sandtest provides utilities for testing sand Engines.
+//
+package sandtest
+
+import (
+	"bytes"
+	"context"
+	"github.com/Zaba505/sand"
+	"io"
+	"strings"
+)
+
+// Result represents the outcome of running an Engine with Run.
+//
+type Result struct {
+	// Output is everything written by the Engine.
+	Output string
+
+	// Prompt is everything written by the UI itself, i.e. prompts,
+	// EOF hints and the newline written when the session ends.
+	Prompt string
+
+	// Err is the error returned by sand.Run, with any
+	// recoverable errors, e.g. io.EOF, already filtered out.
+	Err error
+}
+
+// Run runs the Engine in a new UI which reads the given input and keeps
+// the output of the Engine separate from the output of the UI. This
+// allows for asserting on exactly what the Engine wrote, without having
+// to strip prompts from it. The options are applied after the IO options
+// set by Run, so they shouldn't override the IO.
+//
+func Run(ctx context.Context, eng sand.Engine, input string, opts ...sand.Option) *Result {
+	var out, prompt bytes.Buffer
+
+	opts = append([]sand.Option{
+		sand.WithIO(strings.NewReader(input), &out),
+		sand.WithPromptWriter(&prompt),
+	}, opts...)
+
+	err := sand.Run(ctx, eng, opts...)
+	if root, ok := sand.IsRecoverable(err); ok && (root == nil || root == io.EOF) {
+		err = nil
+	}
+
+	return &Result{
+		Output: out.String(),
+		Prompt: prompt.String(),
+		Err:    err,
+	}
+}
@@ -0,0 +1,94 @@
+package sandtest
+
+import (
+	"context"
+	"fmt"
+	"github.com/Zaba505/sand"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoEngine writes every line it receives back to the ui.
+type echoEngine struct {
+	execs int
+}
+
+func (eng *echoEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.execs++
+	ui.Write([]byte(line + "\n"))
+	return 0
+}
+
+func TestRun(t *testing.T) {
+	res := Run(nil, new(echoEngine), "hello\n")
+	if res.Err != nil {
+		t.Error(res.Err)
+	}
+	if res.Output != "hello\n" {
+		t.Errorf("expected output %q but instead received: %q", "hello\n", res.Output)
+	}
+	if res.Prompt != "\n" {
+		t.Errorf("expected prompt %q but instead received: %q", "\n", res.Prompt)
+	}
+}
+
+func TestRunWithPrefix(t *testing.T) {
+	res := Run(nil, new(echoEngine), "hello", sand.WithPrefix(">"))
+	if res.Err != nil {
+		t.Error(res.Err)
+	}
+
+	// Engine writes still carry the prefix, but prompts don't end up in the output
+	if res.Output != ">hello\n" {
+		t.Errorf("expected output %q but instead received: %q", ">hello\n", res.Output)
+	}
+	if res.Prompt != ">>\n" {
+		t.Errorf("expected prompt %q but instead received: %q", ">>\n", res.Prompt)
+	}
+}
+
+// greetEngine asks for a name, mid Exec, and greets it.
+type greetEngine struct {
+	execs int
+}
+
+func (eng *greetEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.execs++
+	ui.Write([]byte("name? "))
+
+	b := make([]byte, 64)
+	n, err := ui.Read(b)
+	if err != nil {
+		return 1
+	}
+	fmt.Fprintf(ui, "hello, %s", b[:n])
+	return 0
+}
+
+func TestInteraction(t *testing.T) {
+	res := NewInteraction().
+		Expect(">").Send("greet\n").
+		Expect("name? ").Send("bob\n").
+		Expect("hello, bob\n").
+		Run(nil, new(greetEngine), sand.WithPrefix(">"))
+	if res.Err != nil {
+		t.Error(res.Err)
+	}
+
+	ex := ">name? >hello, bob\n" // Engine writes carry the prefix
+	if res.Output != ex {
+		t.Errorf("expected output %q but instead received: %q", ex, res.Output)
+	}
+}
+
+func TestInteractionTimeout(t *testing.T) {
+	it := NewInteraction().Send("greet\n").Expect("password? ")
+	it.Timeout = 50 * time.Millisecond
+
+	res := it.Run(nil, new(greetEngine))
+	if res.Err == nil || !strings.Contains(res.Err.Error(), `expected "password? "`) {
+		t.Errorf("expected unmet expectation error but instead received: %v", res.Err)
+	}
+}
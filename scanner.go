@@ -0,0 +1,88 @@
+package sand
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// Scanner reads the input line by line, like a bufio.Scanner, except
+// that Scan returns once its context is done, see NewScanner.
+//
+type Scanner struct {
+	ctx  context.Context
+	ui   *UI
+	sc   *bufio.Scanner // used for Readers other than a UI
+	line string
+	err  error
+	done bool
+}
+
+// NewScanner returns a Scanner reading lines from r, e.g. for an
+// Engine reading the answers to a menu, or form, within Exec. Pass
+// the context and io.ReadWriter given to Exec. Unlike a bufio.Scanner
+// reading the UI, which blocks until a line arrives, Scan returns
+// false once ctx is done, e.g. on Ctrl-C, and Err returns the error of
+// the context then. If r isn't a *UI, e.g. when driven by Drive, ctx
+// is only checked before every line.
+//
+func NewScanner(ctx context.Context, r io.Reader) *Scanner {
+	s := &Scanner{ctx: ctx}
+	if ui, ok := r.(*UI); ok {
+		s.ui = ui
+	} else {
+		s.sc = bufio.NewScanner(r)
+	}
+	return s
+}
+
+// Scan advances the Scanner to the next line, which is then available
+// through Text. It returns false once the input ends, reading fails
+// or the context is done.
+//
+func (s *Scanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	if s.err = s.ctx.Err(); s.err != nil {
+		s.done = true
+		return false
+	}
+
+	if s.sc != nil {
+		if !s.sc.Scan() {
+			s.err, s.done = s.sc.Err(), true
+			return false
+		}
+		s.line = s.sc.Text()
+		return true
+	}
+
+	line, err := s.ui.readLine(s.ctx)
+	if err != nil {
+		s.done = true
+		if err != io.EOF {
+			s.err = err
+			return false
+		}
+		if line == "" {
+			return false
+		}
+	}
+	s.line = line
+	return true
+}
+
+// Text returns the line read by the last call to Scan, without its
+// line terminator.
+//
+func (s *Scanner) Text() string {
+	return s.line
+}
+
+// Err returns the error which stopped the Scanner, which is nil if
+// the input ended, or the error of the context if it's done.
+//
+func (s *Scanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,64 @@
+package sand
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanner(t *testing.T) {
+	testCases := []struct {
+		Name string
+		R    func(in string) io.Reader
+	}{
+		{
+			Name: "UI",
+			R: func(in string) io.Reader {
+				return &UI{i: strings.NewReader(in), ctx: context.Background()}
+			},
+		},
+		{
+			Name: "Reader",
+			R:    func(in string) io.Reader { return strings.NewReader(in) },
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(subT *testing.T) {
+			s := NewScanner(context.Background(), testCase.R("a\nb\r\nc"))
+
+			var lines []string
+			for s.Scan() {
+				lines = append(lines, s.Text())
+			}
+			if s.Err() != nil {
+				subT.Error(s.Err())
+			}
+			if got := strings.Join(lines, ","); got != "a,b,c" {
+				subT.Errorf("expected %q but instead received: %q", "a,b,c", got)
+			}
+		})
+	}
+}
+
+func TestScanner_Canceled(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ui := &UI{i: pr, ctx: context.Background()}
+	s := NewScanner(ctx, ui)
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if s.Scan() {
+		t.Errorf("expected Scan to return false but instead read: %q", s.Text())
+	}
+	if s.Err() != context.Canceled {
+		t.Errorf("expected context.Canceled but instead received: %v", s.Err())
+	}
+	if s.Scan() {
+		t.Errorf("expected Scan to keep returning false once done")
+	}
+}
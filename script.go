@@ -0,0 +1,40 @@
+package sand
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// WithScriptMode runs the session as a script, e.g. a file of
+// commands, instead of interactively. No prompt is written, nor the
+// prefix of Write, and the end of the input ends the session without
+// an error. A command returning a non-zero status ends it with an
+// error, unless given WithContinueOnError.
+//
+func WithScriptMode() Option {
+	return func(ui *UI) {
+		ui.script = true
+	}
+}
+
+// WithContinueOnError keeps the session going after a command returns
+// a non-zero status, which ends it otherwise. StatusExit still ends
+// the session.
+//
+func WithContinueOnError() Option {
+	return func(ui *UI) {
+		ui.contOnErr = true
+	}
+}
+
+// RunScript executes the commands read from src with the Engine, in
+// order, in script mode, see WithScriptMode, e.g. for using the same
+// Engine both interactively and in batch. The output goes to stdout,
+// unless given by WithIO, whose input Reader is ignored.
+//
+func RunScript(ctx context.Context, eng Engine, src io.Reader, opts ...Option) error {
+	opts = append([]Option{WithIO(nil, os.Stdout)}, opts...)
+	opts = append(opts, WithScriptMode(), func(ui *UI) { ui.i = src })
+	return Run(ctx, eng, opts...)
+}
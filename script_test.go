@@ -0,0 +1,66 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testScriptEngine is a testFailEngine which also exits on "exit".
+type testScriptEngine struct {
+	testFailEngine
+}
+
+func (eng *testScriptEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	if strings.TrimSpace(line) == "exit" {
+		return StatusExit
+	}
+	return eng.testFailEngine.Exec(ctx, line, ui)
+}
+
+func TestRunScript(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Src   string
+		Opts  []Option
+		Ex    string
+		ExErr string
+	}{
+		{Name: "Lines", Src: "a\nb\n", Ex: "a\nb\n"},
+		{Name: "Unterminated", Src: "a\nb", Ex: "a\nb\n"},
+		{Name: "Empty", Src: ""},
+		{Name: "Fail", Src: "a\nfail\nb\n", Ex: "a\nfail\n", ExErr: `sand: "fail" failed with status 2`},
+		{Name: "ContinueOnError", Src: "a\nfail\nb\n", Opts: []Option{WithContinueOnError()}, Ex: "a\nfail\nb\n"},
+		{Name: "Exit", Src: "a\nexit\nb\n", Opts: []Option{WithContinueOnError()}, Ex: "a\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			eng := &testScriptEngine{}
+			opts := append([]Option{WithPrefix(">"), WithIO(strings.NewReader("ignored\n"), &out)}, tc.Opts...)
+			err := RunScript(nil, eng, strings.NewReader(tc.Src), opts...)
+			if tc.ExErr == "" && err != nil || tc.ExErr != "" && (err == nil || err.Error() != tc.ExErr) {
+				subT.Errorf("expected error %q but instead received: %v", tc.ExErr, err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestRunWithContinueOnError(t *testing.T) {
+	in := &testLineReader{lines: []string{"fail\n", "a\n"}}
+	var out bytes.Buffer
+	err := Run(nil, new(testFailEngine), WithPrefix(">"), WithIO(in, &out), WithContinueOnError())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if ex := ">>fail\n>>a\n>\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
@@ -0,0 +1,136 @@
+package sand
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Segment is a part of the prompt, see WithPromptSegments. It returns
+// the text of the segment, which is left out if empty, and the color
+// to paint it in, e.g. one of the theme of ui, so that a monochrome
+// theme leaves it unstyled.
+//
+type Segment func(ui *UI) (text string, color Color)
+
+// SegmentSeparator separates the segments of the prompt.
+const SegmentSeparator = " "
+
+// WithPromptSegments renders the segments left to right in front of
+// the prompt, each painted in its own color and separated, from each
+// other and the prompt, by SegmentSeparator, e.g. the working
+// directory followed by the status of the last command:
+//
+//	~/src/sand [1] >
+//
+// The segments are rendered before every prompt, so they're always up
+// to date. If the prompt is written to a terminal, the segments take
+// up at most half of its columns, the first ones being left out until
+// the rest fit, and the last one being shortened from its start, which
+// is then marked by "…", if it doesn't fit by itself.
+//
+func WithPromptSegments(segs ...Segment) Option {
+	return func(ui *UI) {
+		ui.segments = segs
+	}
+}
+
+// CwdSegment renders the working directory of the session, see Cwd,
+// with the home directory shortened to "~", in the Header color.
+//
+func CwdSegment(ui *UI) (string, Color) {
+	dir := ui.Cwd()
+	home, err := os.UserHomeDir()
+	if err == nil && home != "" && (dir == home || strings.HasPrefix(dir, home+string(filepath.Separator))) {
+		dir = "~" + dir[len(home):]
+	}
+	return dir, ui.Theme().Header
+}
+
+// StatusSegment renders the status of the last command, see StatusVar,
+// as "[status]" in the Error color, unless it succeeded.
+//
+func StatusSegment(ui *UI) (string, Color) {
+	status, _ := ui.Get(StatusVar)
+	if status == "" || status == "0" {
+		return "", ""
+	}
+	if _, err := strconv.Atoi(status); err != nil {
+		return "", ""
+	}
+	return "[" + status + "]", ui.Theme().Error
+}
+
+// renderSegments renders the prompt segments to go in front of the
+// prompt, shortened to fit the terminal, if any.
+//
+func (ui *UI) renderSegments() string {
+	if len(ui.segments) == 0 {
+		return ""
+	}
+
+	type segment struct {
+		text  string
+		color Color
+	}
+	var segs []segment
+	for _, seg := range ui.segments {
+		if text, color := seg(ui); text != "" {
+			segs = append(segs, segment{text, color})
+		}
+	}
+
+	// The width of the segments, each followed by the separator
+	width := func() (w int) {
+		for _, s := range segs {
+			w += displayWidth(s.text) + len(SegmentSeparator)
+		}
+		return
+	}
+	if cols := termWidth(ui.promptDest()); cols > 0 {
+		max := cols / 2
+		for len(segs) > 1 && width() > max {
+			segs = segs[1:]
+		}
+		if len(segs) == 1 && width() > max {
+			segs[0].text = truncateLeft(segs[0].text, max-len(SegmentSeparator))
+		}
+	}
+
+	var b strings.Builder
+	for _, s := range segs {
+		if s.text == "" {
+			continue
+		}
+		b.WriteString(s.color.Paint(s.text))
+		b.WriteString(SegmentSeparator)
+	}
+	return b.String()
+}
+
+// truncateLeft shortens s from its start, marking it by "…", until
+// it takes up at most w columns. It returns an empty string if w
+// leaves no room for anything but the mark.
+//
+func truncateLeft(s string, w int) string {
+	if displayWidth(s) <= w {
+		return s
+	}
+	if w < 2 {
+		return ""
+	}
+
+	n := 1 // the mark
+	i := len(s)
+	for i > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if n+runeWidth(r) > w {
+			break
+		}
+		n += runeWidth(r)
+		i -= size
+	}
+	return "…" + s[i:]
+}
@@ -0,0 +1,85 @@
+package sand
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithPromptSegments(t *testing.T) {
+	text := func(s string, c Color) Segment {
+		return func(*UI) (string, Color) { return s, c }
+	}
+
+	testCases := []struct {
+		Name  string
+		Width int
+		Segs  []Segment
+		Ex    string
+	}{
+		{Name: "NotTerminal", Segs: []Segment{text("a", ""), text("bb", "")}, Ex: "a bb >"},
+		{Name: "Painted", Segs: []Segment{text("a", "1"), text("b", "")}, Ex: "\x1b[1ma\x1b[0m b >"},
+		{Name: "Empty", Segs: []Segment{text("", "1"), text("b", "")}, Ex: "b >"},
+		{Name: "Fits", Width: 20, Segs: []Segment{text("dir", ""), text("[1]", "")}, Ex: "dir [1] >"},
+		{Name: "DropsFirst", Width: 12, Segs: []Segment{text("dir", ""), text("[1]", "")}, Ex: "[1] >"},
+		{Name: "Truncated", Width: 12, Segs: []Segment{text("/usr/local", "")}, Ex: "…ocal >"},
+		{Name: "Wide", Width: 12, Segs: []Segment{text("世界世界", "")}, Ex: "…世界 >"},
+	}
+
+	defer func(f func(interface{}) int) { termWidth = f }(termWidth)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			termWidth = func(interface{}) int { return tc.Width }
+
+			out := runBuiltinTest(subT, new(testEchoEngine), "", WithPromptSegments(tc.Segs...))
+			if ex := tc.Ex + "\n"; out != ex {
+				subT.Errorf("expected %q but instead received: %q", ex, out)
+			}
+		})
+	}
+}
+
+func TestStatusSegment(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Status string
+		Ex     string
+	}{
+		{Name: "Unset"},
+		{Name: "Success", Status: "0"},
+		{Name: "Failure", Status: "2", Ex: "[2]"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := &UI{theme: &MonochromeTheme}
+			if tc.Status != "" {
+				ui.Set(StatusVar, tc.Status)
+			}
+			if s, _ := StatusSegment(ui); s != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, s)
+			}
+		})
+	}
+}
+
+func TestCwdSegment(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	ui := new(UI)
+	WithWorkingDir(filepath.Join(home, "src"))(ui)
+	ex := "~" + string(filepath.Separator) + "src"
+	if s, _ := CwdSegment(ui); s != ex {
+		t.Errorf("expected %q but instead received: %q", ex, s)
+	}
+
+	WithWorkingDir(filepath.Dir(home))(ui)
+	if s, _ := CwdSegment(ui); s != filepath.Dir(home) {
+		t.Errorf("expected %q but instead received: %q", filepath.Dir(home), s)
+	}
+}
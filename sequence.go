@@ -0,0 +1,155 @@
+package sand
+
+import (
+	"context"
+	"strings"
+)
+
+// CommandSeparator configures how WithCommandSeparator splits lines.
+//
+type CommandSeparator struct {
+	// Sep separates the commands of a line, ";" if empty.
+	Sep string
+
+	// ContinueOnError keeps executing the commands of a line after
+	// one of them failed, instead of stopping at the failed one.
+	ContinueOnError bool
+}
+
+// WithCommandSeparator executes lines consisting of multiple commands,
+// e.g. "cmd1; cmd2", one command after another, like a shell. The
+// separator isn't split on within single or double quotes and "\;"
+// results in a literal ";". Each command is executed as if it were a
+// line of its own, so builtins and background jobs work as usual.
+//
+// Commands may also be joined by "&&", which executes the command
+// after it only if the one before succeeded, and "||", which only
+// does so if it failed, e.g. "build && deploy || rollback". Like a
+// shell, these bind tighter than the separator and are evaluated
+// left to right, so the status of "cmd1 || cmd2" is that of cmd2 if
+// cmd1 failed and 0 otherwise. "\&" and "\|" result in a literal "&"
+// and "|".
+//
+// The status of a line is that of the last command executed. Unless
+// ContinueOnError is set, a failure which isn't handled by "||" ends
+// the line, skipping the commands after it. Lines continuing an
+// incomplete one, see StatusNeedMore, aren't split and if a command
+// turns out to be incomplete, it's continued along with the commands
+// after it.
+//
+func WithCommandSeparator(cfg CommandSeparator) Option {
+	return func(ui *UI) {
+		if cfg.Sep == "" {
+			cfg.Sep = ";"
+		}
+		ui.cmdSep = &cfg
+	}
+}
+
+// Operators joining the commands of a line, see WithCommandSeparator.
+const (
+	opAnd = "&&"
+	opOr  = "||"
+)
+
+// Command is a command of a line split by SplitCommands.
+type Command struct {
+	// Line is the command, ending with the same line terminator as
+	// the line it was split from.
+	Line string
+
+	// Op is the operator joining it to the previous command, "&&"
+	// or "||", or empty if it follows a separator.
+	Op string
+
+	offset int // where the command starts within the line
+}
+
+// SplitCommands splits line on sep and the operators, outside of
+// quotes, the same as WithCommandSeparator, and returns the commands,
+// each trimmed of spaces and ending with the same line terminator as
+// line. Escaped separators and operators are unescaped and empty
+// commands are dropped.
+//
+func SplitCommands(line, sep string) (cmds []Command) {
+	body := strings.TrimRight(line, "\r\n")
+	eol := line[len(body):]
+
+	var cur strings.Builder
+	var op string
+	start := 0
+	flush := func(next int, nextOp string) {
+		if cmd := strings.TrimSpace(cur.String()); cmd != "" {
+			cmds = append(cmds, Command{Line: cmd + eol, Op: op, offset: start})
+		}
+		cur.Reset()
+		op, start = nextOp, next
+	}
+
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\'' && quote != '"':
+			quote ^= '\''
+		case c == '"' && quote != '\'':
+			quote ^= '"'
+		case c == '\\' && quote == 0 && strings.HasPrefix(body[i+1:], sep):
+			cur.WriteString(sep)
+			i += len(sep)
+			continue
+		case c == '\\' && quote == 0 && i+1 < len(body) && (body[i+1] == '&' || body[i+1] == '|'):
+			i++
+			c = body[i]
+		case quote == 0 && strings.HasPrefix(body[i:], sep):
+			flush(i+len(sep), "")
+			i += len(sep) - 1
+			continue
+		case quote == 0 && (strings.HasPrefix(body[i:], opAnd) || strings.HasPrefix(body[i:], opOr)):
+			flush(i+2, body[i:i+2])
+			i++
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	flush(len(body), "")
+	return
+}
+
+// execSequence executes the commands of line one after another, see
+// WithCommandSeparator, and returns the status of the last command
+// executed. If a command needs more input, the rest of line, starting
+// at that command, is returned along with StatusNeedMore.
+//
+func (ui *UI) execSequence(ctx context.Context, line string, engs *engineStack) (status int, rest string, err error) {
+	cmds := SplitCommands(line, ui.cmdSep.Sep)
+	if len(cmds) == 0 {
+		status, err = ui.dispatch(ctx, line, false, engs.top().reqCh)
+		ui.applyEngineSwaps(engs)
+		return status, line, err
+	}
+
+	for i, cmd := range cmds {
+		if cmd.Op == "" && status != 0 && !ui.cmdSep.ContinueOnError {
+			return
+		}
+		if cmd.Op == opAnd && status != 0 || cmd.Op == opOr && status == 0 {
+			ui.tracef("skip", "%s %q", cmd.Op, cmd.Line)
+			continue
+		}
+
+		status, err = ui.dispatch(ctx, cmd.Line, false, engs.top().reqCh)
+		ui.applyEngineSwaps(engs)
+		if err != nil || ctx.Err() != nil {
+			return
+		}
+		if status == StatusNeedMore {
+			return status, strings.TrimSpace(line[cmds[i].offset:]), nil
+		}
+		status = ui.settleStatus(status)
+		if status == StatusExit {
+			return
+		}
+	}
+	return
+}
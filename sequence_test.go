@@ -0,0 +1,137 @@
+package sand
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testFailEngine echos lines and fails those starting with "fail"
+type testFailEngine struct {
+	testEchoEngine
+}
+
+func (eng *testFailEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.testEchoEngine.Exec(ctx, line, ui)
+	if strings.HasPrefix(line, "fail") {
+		return 2
+	}
+	return 0
+}
+
+func TestSplitCommands(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Line string
+		Sep  string
+		Ex   []string
+	}{
+		{Name: "Single", Line: "a b\n", Sep: ";", Ex: []string{"a b\n"}},
+		{Name: "Multiple", Line: "a; b ;c\n", Sep: ";", Ex: []string{"a\n", "b\n", "c\n"}},
+		{Name: "Empty", Line: ";a;;\n", Sep: ";", Ex: []string{"a\n"}},
+		{Name: "Quoted", Line: "a 'x;y' \"z;w\"; b\n", Sep: ";", Ex: []string{"a 'x;y' \"z;w\"\n", "b\n"}},
+		{Name: "Escaped", Line: "a\\;b; c\n", Sep: ";", Ex: []string{"a;b\n", "c\n"}},
+		{Name: "Custom", Line: "a ; b :: c", Sep: "::", Ex: []string{"a ; b", "c"}},
+		{Name: "Operators", Line: "a && b || c; d\n", Sep: ";", Ex: []string{"a\n", "&&b\n", "||c\n", "d\n"}},
+		{Name: "EscapedOperators", Line: "a \\&\\& b '||' c & \n", Sep: ";", Ex: []string{"a && b '||' c &\n"}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var cmds []string
+			for _, cmd := range SplitCommands(tc.Line, tc.Sep) {
+				cmds = append(cmds, cmd.Op+cmd.Line)
+			}
+			if !reflect.DeepEqual(cmds, tc.Ex) {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, cmds)
+			}
+		})
+	}
+}
+
+func TestWithCommandSeparator(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Cfg     CommandSeparator
+		In      string
+		Ex      string
+		ExExecs int
+	}{
+		{Name: "Sequential", In: "a; b; c\n", Ex: ">>a\n>b\n>c\n>\n", ExExecs: 3},
+		{Name: "StopOnError", In: "a; fail; c\n", Ex: ">>a\n>fail\n\n", ExExecs: 2},
+		{Name: "ContinueOnError", Cfg: CommandSeparator{ContinueOnError: true}, In: "a; fail; c\n", Ex: ">>a\n>fail\n>c\n>\n", ExExecs: 3},
+		{Name: "AndSuccess", In: "a && b\n", Ex: ">>a\n>b\n>\n", ExExecs: 2},
+		{Name: "AndFailure", In: "fail && b; c\n", Ex: ">>fail\n\n", ExExecs: 1},
+		{Name: "OrSuccess", In: "a || b; c\n", Ex: ">>a\n>c\n>\n", ExExecs: 2},
+		{Name: "OrFailure", In: "fail || b; c\n", Ex: ">>fail\n>b\n>c\n>\n", ExExecs: 3},
+		{Name: "LeftToRight", In: "fail && b || c\n", Ex: ">>fail\n>c\n>\n", ExExecs: 2},
+		{Name: "Builtin", In: "version; a\n", Ex: ">v1 (" + runtime.Version() + ")\n>a\n>\n", ExExecs: 1},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			eng := new(testFailEngine)
+			in := &testLineReader{lines: []string{tc.In}}
+			var out strings.Builder
+
+			err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithCommandSeparator(tc.Cfg), WithVersion("v1"))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+			if eng.execs != tc.ExExecs {
+				subT.Errorf("expected %d execs but instead received: %d", tc.ExExecs, eng.execs)
+			}
+		})
+	}
+}
+
+func FuzzSplitCommands(f *testing.F) {
+	for _, seed := range []string{
+		"", "a", "a; b", "a && b || c; d", `a ';' b`, `a "&&" b`, `a \; b`, `a \&\& b`, "'unterminated; a",
+		`"unterminated; a`, ";;", "&&", "||", "a &", "a & b", "a ||| b", "a;\n", "a\r\n",
+	} {
+		f.Add(seed, ";")
+	}
+	f.Add("a :: b", "::")
+
+	f.Fuzz(func(t *testing.T, line, sep string) {
+		if sep == "" {
+			return
+		}
+		cmds := SplitCommands(line, sep)
+
+		simple := !strings.ContainsAny(line, "'\"\\\r\n") && !strings.ContainsAny(sep, "&| \t\r\n\v\f")
+		for _, cmd := range cmds {
+			if strings.TrimSpace(cmd.Line) == "" {
+				t.Errorf("expected no empty commands but instead received: %+v", cmds)
+			}
+			if cmd.Op != "" || cmd.Line != strings.TrimSpace(cmd.Line) {
+				simple = false
+			}
+		}
+		if !simple {
+			return
+		}
+
+		// Joining the commands and splitting them again is stable
+		lines := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			lines[i] = cmd.Line
+		}
+		var again []string
+		for _, cmd := range SplitCommands(strings.Join(lines, " "+sep+" "), sep) {
+			again = append(again, cmd.Line)
+		}
+		if !reflect.DeepEqual(again, lines) && len(lines) > 0 {
+			t.Errorf("expected %q to be stable but instead received: %q", lines, again)
+		}
+	})
+}
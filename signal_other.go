@@ -0,0 +1,8 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package sand
+
+import "os"
+
+// terminateSignal is never delivered, since there is no SIGTERM.
+var terminateSignal os.Signal
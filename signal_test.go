@@ -0,0 +1,12 @@
+package sand
+
+import "os"
+
+// injectSignals returns an Option replacing signal.Notify for the UI,
+// so the returned channel receives the channel monitorSys listens on.
+//
+func injectSignals() (chan chan<- os.Signal, Option) {
+	chs := make(chan chan<- os.Signal, 1)
+	notify := func(c chan<- os.Signal, sigs ...os.Signal) { chs <- c }
+	return chs, withSignalSource(notify, func(chan<- os.Signal) {})
+}
@@ -0,0 +1,12 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package sand
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminateSignal is the signal which, along with Interrupt and
+// Kill, causes the terminal to be restored.
+var terminateSignal os.Signal = syscall.SIGTERM
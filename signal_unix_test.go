@@ -0,0 +1,141 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package sand
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMonitorSys(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Sig      os.Signal
+		Handlers map[os.Signal]SignalHandler
+		Canceled bool
+	}{
+		{Name: "Interrupt", Sig: os.Interrupt, Canceled: true},
+		{Name: "Other", Sig: syscall.SIGUSR1},
+		{
+			Name: "HandlerInterrupts",
+			Sig:  syscall.SIGUSR1,
+			Handlers: map[os.Signal]SignalHandler{
+				syscall.SIGUSR1: func(os.Signal) os.Signal { return os.Interrupt },
+			},
+			Canceled: true,
+		},
+		{
+			Name: "HandlerIgnoresInterrupt",
+			Sig:  os.Interrupt,
+			Handlers: map[os.Signal]SignalHandler{
+				os.Interrupt: func(os.Signal) os.Signal { return syscall.SIGUSR1 },
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(subT *testing.T) {
+			chs, inject := injectSignals()
+
+			pr, pw := io.Pipe()
+			defer pr.Close()
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- Run(nil, new(testEchoEngine), WithIO(pr, ioutil.Discard), WithSignalHandlers(testCase.Handlers), inject)
+			}()
+
+			sigCh := <-chs
+			sigCh <- testCase.Sig
+
+			// The session keeps going unless the signal canceled it
+			if !testCase.Canceled {
+				select {
+				case err := <-errCh:
+					subT.Fatalf("expected session to keep going but instead received: %v", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+				pw.Close()
+			}
+
+			var err error
+			select {
+			case err = <-errCh:
+			case <-time.After(5 * time.Second):
+				subT.Fatal("expected session to end")
+			}
+			root, _ := IsRecoverable(err)
+			if testCase.Canceled && root != context.Canceled {
+				subT.Errorf("expected context.Canceled but instead received: %v", err)
+			}
+			if !testCase.Canceled && root != nil && root != io.EOF {
+				subT.Errorf("expected session to end with its input but instead received: %v", err)
+			}
+		})
+	}
+}
+
+func TestUI_SignalHandlers(t *testing.T) {
+	ignore := func(os.Signal) os.Signal { return nil }
+
+	testCases := []struct {
+		Name string
+		Opts []Option
+		Ex   map[os.Signal]string
+	}{
+		{
+			Name: "Defaults",
+			Ex: map[os.Signal]string{
+				os.Interrupt:    "ends the session",
+				os.Kill:         "ends the session",
+				syscall.SIGTERM: "restores the terminal",
+			},
+		},
+		{
+			Name: "Custom",
+			Opts: []Option{
+				WithSignalHandlers(map[os.Signal]SignalHandler{syscall.SIGUSR1: ignore}),
+				WithSignalHandler(os.Interrupt, "asks before ending the session", ignore),
+				WithReloadFunc(func() error { return nil }),
+			},
+			Ex: map[os.Signal]string{
+				os.Interrupt:    "custom: asks before ending the session",
+				os.Kill:         "ends the session",
+				syscall.SIGTERM: "restores the terminal",
+				syscall.SIGHUP:  "reloads the configuration, see WithReloadFunc",
+				syscall.SIGUSR1: "custom",
+			},
+		},
+		{
+			Name: "Restricted",
+			Opts: []Option{
+				WithSignalHandlers(map[os.Signal]SignalHandler{syscall.SIGUSR1: ignore}),
+				WithHandledSignals(os.Interrupt),
+				WithInterruptCancelsCommand(),
+			},
+			Ex: map[os.Signal]string{
+				os.Interrupt: "cancels the running command, or else ends the session",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			ui := new(UI)
+			for _, opt := range tc.Opts {
+				opt(ui)
+			}
+
+			descs := ui.SignalHandlers()
+			if !reflect.DeepEqual(descs, tc.Ex) {
+				subT.Errorf("expected %v but instead received: %v", tc.Ex, descs)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package sand
+
+import "os"
+
+// WithSignalHandler registers a handler for a single signal, along
+// with a description of what it does, which SignalHandlers reports.
+// It is otherwise the same as passing the handler to WithSignalHandlers,
+// which a later call to it replaces.
+//
+func WithSignalHandler(sig os.Signal, desc string, h SignalHandler) Option {
+	return func(ui *UI) {
+		handlers := make(map[os.Signal]SignalHandler, len(ui.sigHandlers)+1)
+		for s, h := range ui.sigHandlers {
+			handlers[s] = h
+		}
+		handlers[sig] = h
+		ui.sigHandlers = handlers
+
+		if ui.sigDescs == nil {
+			ui.sigDescs = make(map[os.Signal]string)
+		}
+		ui.sigDescs[sig] = desc
+	}
+}
+
+// SignalHandlers describes how the UI handles each signal it acts on,
+// e.g. for reporting why a signal didn't do what was expected. Signals
+// with a custom handler, see WithSignalHandlers, are described as
+// "custom", followed by the description given to WithSignalHandler,
+// if any. The others are described by their default behaviour, e.g.
+// Interrupt by "ends the session". Signals the UI doesn't listen for,
+// see WithHandledSignals, are left out.
+//
+// The returned map is a copy, and it is safe to call concurrently.
+//
+func (ui *UI) SignalHandlers() map[os.Signal]string {
+	handled := func(sig os.Signal) bool {
+		if sig == nil {
+			return false
+		}
+		if len(ui.signals) == 0 {
+			return true
+		}
+		for _, s := range ui.signals {
+			if s == sig {
+				return true
+			}
+		}
+		return false
+	}
+
+	descs := make(map[os.Signal]string)
+	interrupt := "ends the session"
+	if ui.intCancels {
+		interrupt = "cancels the running command, or else ends the session"
+	}
+	defaults := map[os.Signal]string{
+		os.Interrupt:    interrupt,
+		os.Kill:         "ends the session",
+		terminateSignal: "restores the terminal",
+	}
+	if ui.reload != nil {
+		defaults[reloadSignal] = "reloads the configuration, see WithReloadFunc"
+	}
+	for sig, desc := range defaults {
+		if handled(sig) {
+			descs[sig] = desc
+		}
+	}
+
+	for sig := range ui.sigHandlers {
+		if !handled(sig) {
+			continue
+		}
+		desc := "custom"
+		if d := ui.sigDescs[sig]; d != "" {
+			desc += ": " + d
+		}
+		descs[sig] = desc
+	}
+	return descs
+}
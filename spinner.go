@@ -0,0 +1,125 @@
+package sand
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultSpinnerFrames are the frames of the spinner, if WithSpinner
+// isn't given any.
+var DefaultSpinnerFrames = []string{"|", "/", "-", "\\"}
+
+// DefaultSpinnerInterval is how long each frame of the spinner is
+// shown, if WithSpinner isn't given an interval.
+const DefaultSpinnerInterval = 100 * time.Millisecond
+
+// eraseLine moves the cursor to the start of the line and clears it.
+const eraseLine = "\r\x1b[K"
+
+// WithSpinner shows a spinner, cycling through the frames every
+// interval, while a command runs and hasn't written any output yet.
+// The spinner is erased before the output is written, so it never
+// ends up in between, and is only shown if the prompt is written to
+// a terminal. The first frame is shown after one interval, so quick
+// commands never show it.
+//
+func WithSpinner(frames []string, interval time.Duration) Option {
+	if len(frames) == 0 {
+		frames = DefaultSpinnerFrames
+	}
+	if interval <= 0 {
+		interval = DefaultSpinnerInterval
+	}
+	return func(ui *UI) {
+		ui.spinner = &spinner{frames: frames, interval: interval}
+	}
+}
+
+// spinner draws its frames on a terminal until hidden.
+type spinner struct {
+	frames   []string
+	interval time.Duration
+
+	mu    sync.Mutex
+	stop  chan struct{} // set while spinning
+	shown bool
+}
+
+// start starts spinning on w until hide is called.
+func (s *spinner) start(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop = make(chan struct{})
+	s.shown = false
+	go s.spin(w, s.stop)
+}
+
+// spin draws the next frame on w every interval until stop is closed.
+func (s *spinner) spin(w io.Writer, stop chan struct{}) {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		s.mu.Lock()
+		select {
+		case <-stop:
+			s.mu.Unlock()
+			return
+		default:
+		}
+		io.WriteString(w, "\r"+s.frames[i%len(s.frames)])
+		s.shown = true
+		s.mu.Unlock()
+	}
+}
+
+// hide stops the spinner, if it is spinning, and erases it from w.
+func (s *spinner) hide(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.stop = nil
+	if s.shown {
+		io.WriteString(w, eraseLine)
+		s.shown = false
+	}
+}
+
+// startSpinner starts the spinner, if enabled and the prompt is
+// written to a terminal, and returns a func for hiding it again.
+//
+func (ui *UI) startSpinner() (hide func()) {
+	w := ui.promptDest()
+	if ui.spinner == nil || !isTerminal(w) {
+		return func() {}
+	}
+	ui.spinner.start(w)
+	return ui.hideSpinner
+}
+
+// hideSpinner hides the spinner before anything else is written.
+func (ui *UI) hideSpinner() {
+	if ui.spinner != nil {
+		ui.spinner.hide(ui.promptDest())
+	}
+}
+
+// promptDest returns the Writer the UI writes its prompts to,
+// without any tees or transcript, i.e. possibly a terminal.
+//
+func (ui *UI) promptDest() io.Writer {
+	if ui.promptW != nil {
+		return ui.promptW
+	}
+	return ui.output()
+}
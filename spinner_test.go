@@ -0,0 +1,69 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSpinEngine sleeps before writing every line back, so the
+// spinner is shown.
+type testSpinEngine struct {
+	delay time.Duration
+}
+
+func (eng *testSpinEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	time.Sleep(eng.delay)
+	if strings.TrimSpace(line) == "quiet" {
+		return 0
+	}
+	_, err := ui.Write([]byte(line + "\n"))
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func TestWithSpinner(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		TTY   bool
+		Delay time.Duration
+		Line  string
+		Ex    string
+	}{
+		{Name: "NotTerminal", Delay: 50 * time.Millisecond, Line: "hi\n", Ex: ">>hi\n>\n"},
+		{Name: "Quick", TTY: true, Line: "hi\n", Ex: ">>hi\n>\n"},
+		{Name: "Output", TTY: true, Delay: 50 * time.Millisecond, Line: "hi\n", Ex: ">\r.\r\x1b[K>hi\n>\n"},
+		{Name: "NoOutput", TTY: true, Delay: 50 * time.Millisecond, Line: "quiet\n", Ex: ">\r.\r\x1b[K>\n"},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.TTY }
+
+			in := &testLineReader{lines: []string{tc.Line}}
+			var out bytes.Buffer
+			eng := &testSpinEngine{delay: tc.Delay}
+
+			// The interval is long enough for a single frame to be shown
+			err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithTheme(MonochromeTheme), WithSpinner([]string{"."}, 30*time.Millisecond))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			// A slow scheduler may get to show more than one frame
+			s := out.String()
+			for strings.Contains(s, "\r.\r.") {
+				s = strings.Replace(s, "\r.\r.", "\r.", -1)
+			}
+			if s != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, s)
+			}
+		})
+	}
+}
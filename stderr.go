@@ -0,0 +1,34 @@
+package sand
+
+import (
+	"io"
+	"os"
+)
+
+// WithStderr sets the Err Writer of the UI, where WriteErr writes
+// diagnostics of engines, which is stderr by default, e.g. to keep
+// them out of the output of commands, or to collect them separately.
+//
+func WithStderr(w io.Writer) Option {
+	return func(ui *UI) {
+		ui.Err = w
+	}
+}
+
+// errOutput returns where WriteErr writes, see WithStderr.
+func (ui *UI) errOutput() io.Writer {
+	if ui.Err == nil {
+		return os.Stderr
+	}
+	return ui.Err
+}
+
+// WriteErr writes the provided bytes, as is, to the error Writer of
+// the UI, see WithStderr, e.g. for an Engine reporting a diagnostic,
+// which shouldn't carry the prefix or be interleaved with the output
+// of the command. Like Write, it returns early if the context of the
+// UI is done.
+//
+func (ui *UI) WriteErr(b []byte) (n int, err error) {
+	return ui.writeTo(ui.errOutput(), b)
+}
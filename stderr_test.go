@@ -0,0 +1,49 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testErrEngine writes the line to the error Writer of the UI and
+// echos it.
+type testErrEngine struct{}
+
+func (eng *testErrEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	ui.(*UI).WriteErr([]byte("warning: " + line + "\n"))
+	ui.Write([]byte(line + "\n"))
+	return 0
+}
+
+func TestUI_WriteErr(t *testing.T) {
+	var out, errOut bytes.Buffer
+	in := &testLineReader{lines: []string{"a\n", "b\n"}}
+	err := Run(nil, new(testErrEngine), WithPrefix(">"), WithIO(in, &out), WithStderr(&errOut))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	if ex := ">>a\n>>b\n>\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if ex := "warning: a\nwarning: b\n"; errOut.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, errOut.String())
+	}
+}
+
+func TestUI_WriteErr_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var errOut strings.Builder
+	ui := &UI{ctx: ctx, Err: &errOut}
+	if _, err := ui.WriteErr([]byte("a\n")); err != context.Canceled {
+		t.Errorf("expected %v but instead received: %v", context.Canceled, err)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("expected nothing to be written but instead received: %q", errOut.String())
+	}
+}
@@ -0,0 +1,109 @@
+package sand
+
+import "github.com/pkg/errors"
+
+// SwapEngine routes the lines of the session to eng, instead of the
+// current Engine, until PopEngine is called, e.g. for an Engine
+// entering a sub-shell or a REPL within the REPL. Swaps nest like a
+// stack: every SwapEngine pushes an Engine and every PopEngine pops
+// the current one, restoring the Engine which was current before it.
+//
+// The swap takes effect once the current command is done, so it may
+// be called from within Exec. The Engine is run the same as the one
+// given to Run, e.g. shared with other UIs running it, if the UI
+// shares its Engines, see WithSharedEngine, and it's detached once
+// it's popped, or when Run returns, which shuts it down if no other UI
+// uses it, see Shutdowner.
+//
+func (ui *UI) SwapEngine(eng Engine) {
+	ui.mu.Lock()
+	ui.engSwaps = append(ui.engSwaps, eng)
+	ui.mu.Unlock()
+}
+
+// PopEngine restores the Engine which was current before the latest
+// SwapEngine, once the current command is done. The Engine given to
+// Run is never popped, so it does nothing without a matching swap.
+// An error shutting down the popped Engine is written, instead of
+// ending the session.
+//
+func (ui *UI) PopEngine() {
+	ui.mu.Lock()
+	ui.engSwaps = append(ui.engSwaps, nil)
+	ui.mu.Unlock()
+}
+
+// engineFrame is an Engine on the stack of a session, see SwapEngine.
+type engineFrame struct {
+	eng      Engine
+	wrapped  Engine // eng wrapped by the middlewares, see wrapEngine
+	reqCh    chan execReq
+	detached chan error
+}
+
+// engineStack holds the Engines of a session, the current one last.
+type engineStack []engineFrame
+
+// top returns the current Engine.
+func (s engineStack) top() engineFrame { return s[len(s)-1] }
+
+// pushEngine starts eng and makes it current.
+func (ui *UI) pushEngine(s *engineStack, eng Engine) {
+	f := engineFrame{eng: eng, wrapped: ui.wrapEngine(eng), reqCh: make(chan execReq)}
+	f.detached = ui.startEngine(eng, f.wrapped, f.reqCh)
+	*s = append(*s, f)
+
+	ui.mu.Lock()
+	ui.reqCh = f.reqCh
+	ui.eng, ui.wrapped = eng, f.wrapped
+	ui.mu.Unlock()
+}
+
+// popEngine detaches the current Engine, making the previous one
+// current, and returns the error of shutting it down.
+//
+func (ui *UI) popEngine(s *engineStack) error {
+	f := s.top()
+	*s = (*s)[:len(*s)-1]
+
+	// TryExec sends on reqCh under the lock, so it mustn't be
+	// closed before it's replaced.
+	ui.mu.Lock()
+	ui.reqCh = nil
+	if len(*s) > 0 {
+		ui.reqCh = s.top().reqCh
+		ui.eng, ui.wrapped = s.top().eng, s.top().wrapped
+	}
+	ui.mu.Unlock()
+	close(f.reqCh)
+	return <-f.detached
+}
+
+// applyEngineSwaps applies the swaps requested by SwapEngine and
+// PopEngine since it was last called, after replacing the attachment
+// of an abandoned Engine, see WithExecGracePeriod, and restarting the
+// Engine, see WithRestartCommand.
+//
+func (ui *UI) applyEngineSwaps(s *engineStack) {
+	ui.reattachAbandoned(s)
+	ui.restartEngine(s)
+
+	ui.mu.Lock()
+	swaps := ui.engSwaps
+	ui.engSwaps = nil
+	ui.mu.Unlock()
+
+	for _, eng := range swaps {
+		if eng != nil {
+			ui.pushEngine(s, eng)
+			continue
+		}
+		if len(*s) < 2 {
+			continue
+		}
+		if err := ui.popEngine(s); err != nil {
+			err = errors.Wrap(err, "sand: encountered error while shutting down engine")
+			ui.writePrompt([]byte(ui.Theme().Error.Paint(err.Error()) + "\n"))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testModeEngine writes its name along with every line, swapping in
+// its sub Engine on "sub" and popping itself on "exit".
+type testModeEngine struct {
+	name      string
+	sub       Engine
+	shutdowns int
+}
+
+func (eng *testModeEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	switch strings.TrimSpace(line) {
+	case "sub":
+		ui.(*UI).SwapEngine(eng.sub)
+	case "exit":
+		ui.(*UI).PopEngine()
+	default:
+		ui.Write([]byte(eng.name + ":" + line + "\n"))
+	}
+	return 0
+}
+
+func (eng *testModeEngine) Shutdown(ctx context.Context) error {
+	eng.shutdowns++
+	return nil
+}
+
+func TestUI_SwapEngine(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Lines     []string
+		Out       string
+		Shutdowns int
+	}{
+		{
+			Name:      "Swap",
+			Lines:     []string{"x\n", "sub\n", "y\n", "exit\n", "z\n"},
+			Out:       "a:x\nb:y\na:z\n\n",
+			Shutdowns: 1,
+		},
+		{
+			Name:      "Nested",
+			Lines:     []string{"sub\n", "sub\n", "y\n", "exit\n", "y\n", "exit\n", "z\n"},
+			Out:       "c:y\nb:y\na:z\n\n",
+			Shutdowns: 1,
+		},
+		{
+			Name:      "PopWithoutSwap",
+			Lines:     []string{"exit\n", "x\n"},
+			Out:       "a:x\n\n",
+			Shutdowns: 0,
+		},
+		{
+			Name:      "NotPopped",
+			Lines:     []string{"sub\n", "y\n"},
+			Out:       "b:y\n\n",
+			Shutdowns: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(subT *testing.T) {
+			c := &testModeEngine{name: "c"}
+			b := &testModeEngine{name: "b", sub: c}
+			a := &testModeEngine{name: "a", sub: b}
+
+			in := &testLineReader{lines: testCase.Lines}
+			var out bytes.Buffer
+			err := Run(nil, a, WithIO(in, &out))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+
+			if out.String() != testCase.Out {
+				subT.Errorf("expected %q but instead received: %q", testCase.Out, out.String())
+			}
+			if b.shutdowns != testCase.Shutdowns {
+				subT.Errorf("expected sub engine to be shut down %d times but instead received: %d", testCase.Shutdowns, b.shutdowns)
+			}
+			if a.shutdowns != 1 {
+				subT.Errorf("expected engine to be shut down once but instead received: %d", a.shutdowns)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// withSyncIO makes the UI read from and write to its IO directly,
+// instead of on goroutines it can give up on, whatever the Reader
+// and Writer are, e.g. for tests to be deterministic. Reads and
+// writes then can't be cancelled while they block, see isMemIO.
+//
+func withSyncIO() Option {
+	return func(ui *UI) {
+		ui.syncIO = true
+	}
+}
+
+// isMemIO reports whether v is backed by memory, e.g. a bytes.Buffer,
+// so a Read or Write never blocks and there's nothing to cancel.
+//
+func isMemIO(v interface{}) bool {
+	switch v.(type) {
+	case *bytes.Buffer, *bytes.Reader, *strings.Reader, *strings.Builder:
+		return true
+	}
+	return v == ioutil.Discard
+}
+
+// syncReader reports whether to read from r directly.
+func (ui *UI) syncReader(r io.Reader) bool {
+	return ui.syncIO || isMemIO(r)
+}
+
+// syncWriter reports whether to write to w directly, which is either
+// the output of the UI or where it writes its prompts.
+//
+func (ui *UI) syncWriter(w io.Writer) bool {
+	if ui.syncIO || isMemIO(w) {
+		return true
+	}
+	if len(ui.tees) > 0 || ui.transcript != nil || ui.bounded != nil {
+		return false
+	}
+	if w == ui.promptOut && ui.promptW != nil {
+		return isMemIO(ui.promptW)
+	}
+	return w == ui.out && isMemIO(ui.output())
+}
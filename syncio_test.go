@@ -0,0 +1,83 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testGoroutineEngine writes the line back a few times and records
+// the most goroutines running after any of the writes.
+type testGoroutineEngine struct {
+	base, max int
+}
+
+func (eng *testGoroutineEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.base = runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		if _, err := ui.Write([]byte(line + "\n")); err != nil {
+			return 1
+		}
+		if n := runtime.NumGoroutine(); n > eng.max {
+			eng.max = n
+		}
+	}
+	return 0
+}
+
+func TestRunSyncIO(t *testing.T) {
+	// The first Run starts the os/signal goroutine, which never exits
+	Run(nil, new(testEchoEngine), WithIO(strings.NewReader(""), new(bytes.Buffer)))
+
+	testCases := []struct {
+		Name string
+		In   func() io.Reader
+		Opts []Option
+	}{
+		{
+			Name: "Buffer",
+			In:   func() io.Reader { return bytes.NewBufferString("a\n") },
+		},
+		{
+			Name: "Option",
+			In:   func() io.Reader { return &testLineReader{lines: []string{"a\n"}} },
+			Opts: []Option{withSyncIO()},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			base := runtime.NumGoroutine()
+			for i := 0; i < 10; i++ {
+				eng := new(testGoroutineEngine)
+				var out bytes.Buffer
+				opts := append([]Option{WithIO(tc.In(), &out), WithPrefix(">")}, tc.Opts...)
+				err := Run(nil, eng, opts...)
+				if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+					subT.Fatal(err)
+				}
+
+				want := ">" + strings.Repeat(">a\n", 10) + ">\n"
+				if out.String() != want {
+					subT.Fatalf("expected %q but instead received: %q", want, out.String())
+				}
+				if eng.max > eng.base {
+					subT.Errorf("expected %d goroutines while writing but instead received: %d", eng.base, eng.max)
+				}
+			}
+
+			n := runtime.NumGoroutine()
+			for deadline := time.Now().Add(time.Second); n > base && time.Now().Before(deadline); n = runtime.NumGoroutine() {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if n > base {
+				subT.Errorf("expected %d goroutines but instead received: %d", base, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package sand
+
+import "io"
+
+// WithTee specifies extra Writers which all output, prefix
+// included, is mirrored to. This is useful for keeping a log
+// of interactive sessions. By default, an error writing to any
+// of the extra Writers fails the Write, just like io.MultiWriter.
+// See WithIgnoreTeeErrors for changing that behaviour.
+//
+func WithTee(extra ...io.Writer) Option {
+	return func(ui *UI) {
+		ui.tees = append(ui.tees, extra...)
+	}
+}
+
+// WithIgnoreTeeErrors specifies that errors encountered while
+// writing to the Writers given to WithTee should be ignored.
+// Errors from the main output Writer are always reported.
+//
+func WithIgnoreTeeErrors() Option {
+	return func(ui *UI) {
+		ui.ignoreTeeErrs = true
+	}
+}
+
+// ignoreErrWriter wraps a Writer and drops any error it returns.
+type ignoreErrWriter struct {
+	w io.Writer
+}
+
+func (w ignoreErrWriter) Write(b []byte) (int, error) {
+	w.w.Write(b)
+	return len(b), nil
+}
+
+// teeOutput returns the Writer which mirrors out to all of the tees.
+func (ui *UI) teeOutput(out io.Writer) io.Writer {
+	if len(ui.tees) == 0 {
+		return out
+	}
+
+	ws := make([]io.Writer, 0, len(ui.tees)+1)
+	ws = append(ws, out)
+	for _, w := range ui.tees {
+		if ui.ignoreTeeErrs {
+			w = ignoreErrWriter{w: w}
+		}
+		ws = append(ws, w)
+	}
+	return io.MultiWriter(ws...)
+}
@@ -0,0 +1,67 @@
+package sand
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"io"
+	"testing"
+)
+
+// testErrWriter fails every Write
+type testErrWriter struct{}
+
+var errTestWrite = errors.New("test write error")
+
+func (testErrWriter) Write(b []byte) (int, error) { return 0, errTestWrite }
+
+func TestWithTee(t *testing.T) {
+	in := bytes.NewReader([]byte("hello, world!"))
+	var out, log1, log2 bytes.Buffer
+
+	err := Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(in, &out), WithTee(&log1, &log2))
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := ">>hello, world!\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected output %q but instead received: %q", ex, out.String())
+	}
+	if log1.String() != ex || log2.String() != ex {
+		t.Errorf("expected tees to mirror output but instead received: %q and %q", log1.String(), log2.String())
+	}
+}
+
+func TestWithTeeErrors(t *testing.T) {
+	t.Run("Fail", func(subT *testing.T) {
+		in := bytes.NewReader([]byte("hello, world!"))
+		var out bytes.Buffer
+
+		err := Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(in, &out), WithTee(testErrWriter{}))
+		if errors.Cause(err) == errTestWrite {
+			return
+		}
+		subT.Errorf("expected tee write error but instead received: %v", err)
+	})
+
+	t.Run("Ignore", func(subT *testing.T) {
+		in := bytes.NewReader([]byte("hello, world!"))
+		var out, log bytes.Buffer
+
+		opts := []Option{
+			WithPrefix(">"),
+			WithIO(in, &out),
+			WithTee(testErrWriter{}, &log),
+			WithIgnoreTeeErrors(),
+		}
+		err := Run(nil, new(testEchoEngine), opts...)
+		var ok bool
+		if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+			subT.Error(err)
+		}
+		if log.String() != out.String() {
+			subT.Errorf("expected %q but instead received: %q", out.String(), log.String())
+		}
+	})
+}
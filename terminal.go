@@ -0,0 +1,135 @@
+package sand
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+	"os"
+)
+
+// ErrNotTerminal is returned by EnterRawMode if the input isn't a terminal.
+var ErrNotTerminal = errors.New("sand: input is not a terminal")
+
+// enterRaw puts f, a terminal, into raw mode until RestoreTerminal
+// is called. It reports whether the terminal was entered into raw
+// mode, as opposed to already being in it, i.e. whether the caller
+// is the one that should restore it.
+//
+func (ui *UI) enterRaw(f *os.File) (entered bool, err error) {
+	ui.termMu.Lock()
+	defer ui.termMu.Unlock()
+	if ui.termRestore != nil {
+		return false, nil
+	}
+
+	restore, err := makeRaw(f)
+	if err != nil {
+		return false, err
+	}
+	ui.termRestore = restore
+	return true, nil
+}
+
+// makeRaw puts the terminal into raw mode and returns a func for
+// restoring its previous state.
+//
+func makeRaw(f *os.File) (restore func() error, err error) {
+	fd := int(f.Fd())
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return term.Restore(fd, old) }, nil
+}
+
+// inRawMode reports whether the terminal was put into raw mode by
+// the UI, see enterRaw.
+//
+func (ui *UI) inRawMode() bool {
+	ui.termMu.Lock()
+	defer ui.termMu.Unlock()
+	return ui.termRestore != nil
+}
+
+// interruptKey is Ctrl-C, which raw mode reads as a key instead of
+// generating an Interrupt, see raiseInterrupt.
+const interruptKey = 0x03
+
+// raiseInterrupt sends an Interrupt to the process, for Ctrl-C to
+// still generate one in raw mode, see EnterRawMode. It does nothing
+// on platforms which can't, e.g. Windows.
+//
+func raiseInterrupt() {
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(os.Interrupt)
+	}
+}
+
+// rawNewlines returns b with every "\n" not preceded by "\r" turned
+// into "\r\n", since output isn't processed in raw mode, along with
+// the number of "\r" added.
+//
+func rawNewlines(b []byte) ([]byte, int) {
+	n := bytes.Count(b, []byte("\n")) - bytes.Count(b, []byte("\r\n"))
+	if n == 0 {
+		return b, 0
+	}
+
+	out := make([]byte, 0, len(b)+n)
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+	return out, n
+}
+
+// EnterRawMode puts the input terminal into raw mode, so input is
+// read as it is typed, a key at a time and without being echoed,
+// e.g. for games that react to single keypresses. Reading Ctrl-C
+// through the UI, e.g. with ReadKey, still generates an Interrupt,
+// and "\n" written through the UI still starts a new line, which it
+// doesn't when written to the terminal directly.
+//
+// The returned func restores the terminal and must be called once
+// done, although Run also restores it before returning, see
+// RestoreTerminal. If the terminal is already in raw mode, it is left
+// as is and restore does nothing, so calls nest. ErrNotTerminal is
+// returned if the input isn't a terminal, in which case input should
+// be read as lines instead.
+//
+func (ui *UI) EnterRawMode() (restore func(), err error) {
+	f, ok := ui.input().(*os.File)
+	if !ok || !isTerminal(f) {
+		return nil, ErrNotTerminal
+	}
+
+	entered, err := ui.enterRaw(f)
+	if err != nil {
+		return nil, err
+	}
+	if !entered {
+		return func() {}, nil
+	}
+	return func() { ui.RestoreTerminal() }, nil
+}
+
+// RestoreTerminal restores the terminal to the state it was in
+// before being put into raw mode, e.g. by ReadKey or ReadPassword.
+// Run calls it before returning, including when recovering from
+// a panic, and on receiving an Interrupt, Kill or SIGTERM, so the
+// terminal isn't left broken. It is a no-op if the terminal isn't
+// in raw mode, and always is on platforms without raw mode.
+//
+func (ui *UI) RestoreTerminal() error {
+	ui.termMu.Lock()
+	restore := ui.termRestore
+	ui.termRestore = nil
+	ui.termMu.Unlock()
+
+	if restore == nil {
+		return nil
+	}
+	return restore()
+}
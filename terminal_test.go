@@ -0,0 +1,85 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUI_EnterRawMode(t *testing.T) {
+	t.Run("NotTerminal", func(subT *testing.T) {
+		ui := &UI{i: strings.NewReader("")}
+		if _, err := ui.EnterRawMode(); err != ErrNotTerminal {
+			subT.Errorf("expected %v but instead received: %v", ErrNotTerminal, err)
+		}
+	})
+
+	t.Run("Nested", func(subT *testing.T) {
+		defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+		isTerminal = func(interface{}) bool { return true }
+
+		r, w, err := os.Pipe()
+		if err != nil {
+			subT.Fatal(err)
+		}
+		defer r.Close()
+		defer w.Close()
+
+		// The terminal is already in raw mode, so it must be left as is
+		var restored bool
+		ui := &UI{i: r}
+		ui.termRestore = func() error { restored = true; return nil }
+
+		restore, err := ui.EnterRawMode()
+		if err != nil {
+			subT.Fatal(err)
+		}
+		restore()
+		if restored {
+			subT.Error("expected nested restore to leave the terminal in raw mode")
+		}
+
+		ui.RestoreTerminal()
+		if !restored {
+			subT.Error("expected terminal to be restored")
+		}
+	})
+}
+
+func TestUI_WriteRawMode(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Raw  bool
+		In   string
+		Ex   string
+	}{
+		{Name: "Cooked", In: "a\nb\n", Ex: "a\nb\n"},
+		{Name: "Raw", Raw: true, In: "a\nb\r\n\nc", Ex: "a\r\nb\r\n\r\nc"},
+		{Name: "RawNoNewlines", Raw: true, In: "abc", Ex: "abc"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			var out bytes.Buffer
+			ui := &UI{ctx: context.Background()}
+			ui.out, ui.promptOut = &out, &out
+			if tc.Raw {
+				ui.termRestore = func() error { return nil }
+			}
+
+			n, err := ui.writePrompt([]byte(tc.In))
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if n != len(tc.In) {
+				subT.Errorf("expected %d bytes written but instead received: %d", len(tc.In), n)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
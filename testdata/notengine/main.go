@@ -0,0 +1,7 @@
+// Package main is a Go plugin whose Engine symbol isn't an Engine.
+package main
+
+// Engine is looked up by LoadEngine
+var Engine = 42
+
+func main() {}
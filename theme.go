@@ -0,0 +1,115 @@
+package sand
+
+import (
+	"golang.org/x/term"
+	"io"
+	"os"
+)
+
+// Color is an ANSI SGR parameter list, e.g. "1;34" for bold blue.
+// The empty Color leaves text unstyled.
+//
+type Color string
+
+// Paint wraps s in the escape sequences for c.
+//
+func (c Color) Paint(s string) string {
+	if c == "" || s == "" {
+		return s
+	}
+	return "\x1b[" + string(c) + "m" + s + "\x1b[0m"
+}
+
+// Theme bundles the colors used by the UI, its builtins and any
+// engines which choose to respect it.
+//
+type Theme struct {
+	Prompt     Color
+	Error      Color
+	Success    Color
+	Suggestion Color
+	Header     Color
+}
+
+var (
+	// DefaultTheme uses the basic ANSI colors.
+	DefaultTheme = Theme{
+		Prompt:     "1;32",
+		Error:      "31",
+		Success:    "32",
+		Suggestion: "36",
+		Header:     "1",
+	}
+
+	// MonochromeTheme doesn't style anything.
+	MonochromeTheme = Theme{}
+
+	// SolarizedTheme uses the 256 color approximations of the solarized palette.
+	SolarizedTheme = Theme{
+		Prompt:     "38;5;33",
+		Error:      "38;5;160",
+		Success:    "38;5;64",
+		Suggestion: "38;5;37",
+		Header:     "1;38;5;136",
+	}
+)
+
+// WithTheme sets the theme. Without it, the UI uses DefaultTheme
+// if its output is a terminal and MonochromeTheme otherwise.
+//
+func WithTheme(theme Theme) Option {
+	return func(ui *UI) {
+		ui.theme = &theme
+	}
+}
+
+// Theme returns the theme in use. Engines can access it by
+// asserting their io.ReadWriter to interface{ Theme() Theme }.
+//
+func (ui *UI) Theme() Theme {
+	if ui.theme != nil {
+		return *ui.theme
+	}
+	if isTerminal(ui.output()) {
+		return DefaultTheme
+	}
+	return MonochromeTheme
+}
+
+// WithStatusColoring paints the prompt in the Success color of the
+// theme after a command succeeded and in its Error color after one
+// failed, according to StatusVar, instead of the Prompt color. This
+// only happens if the prompt is written to a terminal.
+//
+func WithStatusColoring() Option {
+	return func(ui *UI) {
+		ui.statusColor = true
+	}
+}
+
+// promptColor returns the color of the prompt, see WithStatusColoring.
+func (ui *UI) promptColor() Color {
+	theme := ui.Theme()
+	if !ui.statusColor || !isTerminal(ui.promptDest()) {
+		return theme.Prompt
+	}
+	if status, _ := ui.Get(StatusVar); status != "" && status != "0" {
+		return theme.Error
+	}
+	return theme.Success
+}
+
+// themeOf returns the theme of rw, if it has one.
+func themeOf(rw io.ReadWriter) Theme {
+	if t, ok := rw.(interface{ Theme() Theme }); ok {
+		return t.Theme()
+	}
+	return MonochromeTheme
+}
+
+// isTerminal reports whether v is a terminal, e.g. a TTY.
+// It is a variable so tests can pretend to be a terminal.
+var isTerminal = func(v interface{}) bool {
+	f, ok := v.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,88 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestColor_Paint(t *testing.T) {
+	testCases := []struct {
+		Name string
+		C    Color
+		S    string
+		Ex   string
+	}{
+		{Name: "Plain", C: "", S: "hi", Ex: "hi"},
+		{Name: "Empty", C: "31", S: "", Ex: ""},
+		{Name: "Red", C: "31", S: "hi", Ex: "\x1b[31mhi\x1b[0m"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			if s := tc.C.Paint(tc.S); s != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, s)
+			}
+		})
+	}
+}
+
+func TestUI_Theme(t *testing.T) {
+	t.Run("NotTerminal", func(subT *testing.T) {
+		ui := &UI{o: new(bytes.Buffer)}
+		if ui.Theme() != MonochromeTheme {
+			subT.Errorf("expected monochrome theme but instead received: %v", ui.Theme())
+		}
+	})
+
+	t.Run("WithTheme", func(subT *testing.T) {
+		var out bytes.Buffer
+		ui := &UI{prefix: []byte(">")}
+		err := ui.Run(context.Background(), &testEchoEngine{},
+			WithIO(strings.NewReader("hi\n"), &out),
+			WithTheme(DefaultTheme),
+		)
+		if _, ok := IsRecoverable(err); !ok {
+			subT.Error(err)
+		}
+
+		ex := DefaultTheme.Prompt.Paint(">")
+		if !strings.HasPrefix(out.String(), ex) {
+			subT.Errorf("expected output to start with %q but instead received: %q", ex, out.String())
+		}
+	})
+}
+
+func TestWithStatusColoring(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		TTY    bool
+		Status string
+		Ex     string
+	}{
+		{Name: "NoStatus", TTY: true, Ex: DefaultTheme.Success.Paint(">")},
+		{Name: "Success", TTY: true, Status: "0", Ex: DefaultTheme.Success.Paint(">")},
+		{Name: "Failure", TTY: true, Status: "2", Ex: DefaultTheme.Error.Paint(">")},
+		{Name: "NotTerminal", Status: "2", Ex: ">"},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.TTY }
+
+			ui := &UI{prefix: []byte(">")}
+			WithStatusColoring()(ui)
+			if tc.Status != "" {
+				ui.Set(StatusVar, tc.Status)
+			}
+
+			if prompt := string(ui.renderPrompt(context.Background())); prompt != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, prompt)
+			}
+		})
+	}
+}
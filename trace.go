@@ -0,0 +1,51 @@
+package sand
+
+import (
+	"fmt"
+	"io"
+)
+
+// WithDispatchTrace writes a line to w for every step of handling a
+// command, e.g. to find out why a command didn't reach the Engine it
+// was meant for in a setup of Muxes and Chains. Each line is the
+// stage, a colon and the details of the step:
+//
+//	expand    the line after history or variable expansion changed it
+//	dispatch  the line about to be executed
+//	skip      a command of a sequence skipped by && or ||
+//	builtin   the builtin executing the line
+//	job       the line started as a background job
+//	enter     the Engine called with the line
+//	route     the Engine a Mux routes the verb to, or why it doesn't
+//	chain     an Engine of a Chain which didn't handle the line
+//	exit      the Engine returning, along with its status
+//	status    the status of the command
+//
+// The trace is meant for debugging, so its format may change. It's off
+// by default, which costs no more than a nil check per step.
+//
+func WithDispatchTrace(w io.Writer) Option {
+	return func(ui *UI) {
+		ui.trace = nil
+		if w != nil {
+			ui.trace = &lockedWriter{w: w}
+		}
+	}
+}
+
+// tracef writes a step to the dispatch trace, if enabled.
+func (ui *UI) tracef(stage, format string, args ...interface{}) {
+	if ui.trace == nil {
+		return
+	}
+	fmt.Fprintf(ui.trace, "%s: %s\n", stage, fmt.Sprintf(format, args...))
+}
+
+// tracef writes a step to the dispatch trace of rw, if it's a UI,
+// for Engines which are only given the UI as an io.ReadWriter.
+//
+func tracef(rw io.ReadWriter, stage, format string, args ...interface{}) {
+	if ui, ok := rw.(*UI); ok {
+		ui.tracef(stage, format, args...)
+	}
+}
@@ -0,0 +1,54 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestWithDispatchTrace(t *testing.T) {
+	mux := NewMux()
+	mux.Handle("greet", new(testEchoEngine))
+	eng := Chain(mux, new(testEchoEngine))
+
+	in := &testLineReader{lines: []string{"greet sand\n", "other\n"}}
+	var trace bytes.Buffer
+	err := Run(nil, eng, WithIO(in, ioutil.Discard), WithDispatchTrace(&trace))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := []string{
+		`dispatch: "greet sand"`,
+		`enter: *sand.EngineChain "greet sand"`,
+		`route: verb "greet" to *sand.testEchoEngine "sand"`,
+		`exit: *sand.EngineChain status 0`,
+		`status: 0`,
+		`dispatch: "other"`,
+		`enter: *sand.EngineChain "other"`,
+		`route: verb "other" not handled`,
+		`chain: *sand.Mux didn't handle the line`,
+		`exit: *sand.EngineChain status 0`,
+		`status: 0`,
+	}
+	lines := strings.Split(strings.TrimSuffix(trace.String(), "\n"), "\n")
+	if strings.Join(lines, "\n") != strings.Join(ex, "\n") {
+		t.Errorf("expected %q but instead received: %q", ex, lines)
+	}
+}
+
+func TestWithDispatchTrace_Builtin(t *testing.T) {
+	in := &testLineReader{lines: []string{"clear\n"}}
+	var trace bytes.Buffer
+	err := Run(nil, new(testEchoEngine), WithIO(in, ioutil.Discard), WithClear(), WithDispatchTrace(&trace))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := "dispatch: \"clear\"\nbuiltin: clear\nstatus: 0\n"
+	if trace.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, trace.String())
+	}
+}
@@ -0,0 +1,50 @@
+package sand
+
+import (
+	"io"
+	"sync"
+)
+
+// WithTranscript specifies a Writer to record a transcript of the
+// session to. Unlike WithTee, the transcript also records the input
+// that was consumed, so it reconstructs what a user sitting at a
+// terminal would have seen, even when the input is a file or pipe.
+//
+// The transcript format is simply the output of the UI, prefix
+// included, with any input written in the order it was read. Thus,
+// an input line is always found directly after the prompt it was
+// typed at. Errors writing to the transcript are ignored.
+//
+func WithTranscript(w io.Writer) Option {
+	return func(ui *UI) {
+		ui.transcript = &lockedWriter{w: w}
+	}
+}
+
+// lockedWriter serializes Write calls to the underlying Writer.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(b)
+}
+
+// transcribe returns out with the transcript, if any, mirroring it.
+func (ui *UI) transcribe(out io.Writer) io.Writer {
+	if ui.transcript == nil {
+		return out
+	}
+	return io.MultiWriter(out, ignoreErrWriter{w: ui.transcript})
+}
+
+// transcribeInput records the given input to the transcript, if any.
+func (ui *UI) transcribeInput(b []byte) {
+	if ui.transcript == nil || len(b) == 0 {
+		return
+	}
+	ui.transcript.Write(b)
+}
@@ -0,0 +1,47 @@
+package sand
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// testLineReader returns a single line per Read call, like a terminal would.
+type testLineReader struct {
+	lines []string
+}
+
+func (r *testLineReader) Read(b []byte) (int, error) {
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, r.lines[0])
+	r.lines[0] = r.lines[0][n:]
+	if len(r.lines[0]) == 0 {
+		r.lines = r.lines[1:]
+	}
+	return n, nil
+}
+
+func TestWithTranscript(t *testing.T) {
+	script := []string{"hello\n", "sand\n", "bye\n"}
+	in := &testLineReader{lines: append([]string(nil), script...)}
+	var out, transcript bytes.Buffer
+
+	err := Run(nil, new(testEchoEngine), WithPrefix("> "), WithIO(in, &out), WithTranscript(&transcript))
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := "> > hello\n> > sand\n> > bye\n> \n"
+	if out.String() != ex {
+		t.Errorf("expected output %q but instead received: %q", ex, out.String())
+	}
+
+	// Each input line should directly follow the prompt it was read at
+	ex = "> hello\n> hello\n> sand\n> sand\n> bye\n> bye\n> \n"
+	if transcript.String() != ex {
+		t.Errorf("expected transcript %q but instead received: %q", ex, transcript.String())
+	}
+}
@@ -8,6 +8,7 @@
 package sand
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -17,12 +18,23 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 // errNoEngine represents an interpreter trying to be run without a backing engine.
 var errNoEngine = errors.New("sand: engine must be non-null")
 
+// ErrMissingIO is returned by Run if the input Reader or output Writer
+// is nil, e.g. if only one of them was given to WithIO, instead of
+// failing on the first read or write.
+//
+var ErrMissingIO = errors.New("sand: input and output must both be set")
+
 // IsRecoverable guesses if the provided error is considered
 // recoverable from. In the sense that the main function can keep
 // running and not log.Fatal or retry or something of that nature.
@@ -38,6 +50,7 @@ var errNoEngine = errors.New("sand: engine must be non-null")
 //		- err == nil
 //		- context.Cancelled
 // 		- context.DeadlineExceeded
+//		- ErrReadInterrupted and ErrReadTimeout
 //		- newLineErr (an internal error, which isn't really important)
 //
 func IsRecoverable(err error) (root error, ok bool) {
@@ -84,8 +97,43 @@ func WithPrefix(prefix string) Option {
 	}
 }
 
+// WithReadPrefix specifies the prompt written before reading each
+// line, instead of the prefix, which is then only written before
+// the output of engines, e.g. "$ " as the prompt while output is
+// prefixed by "| ", or nothing at all. The read prefix is written
+// before lines continuing an incomplete one as well, see
+// StatusNeedMore, and the countdown and right prompt, see
+// WithCountdown and WithRightPrompt, are rendered around it.
+//
+func WithReadPrefix(prefix string) Option {
+	return func(ui *UI) {
+		ui.readPrefix = append([]byte{}, prefix...)
+	}
+}
+
+// WithPrefixFunc specifies a func rendering the prompt, which is
+// called every time the prompt is about to be written, e.g. to show
+// the current directory or state of a connection, such as
+// "db (readonly)> ". It takes precedence over the prefix and read
+// prefix, but only for the prompt, the prefix is still written before
+// the output of engines, see WithReadPrefix.
+//
+func WithPrefixFunc(fn func() string) Option {
+	return func(ui *UI) {
+		ui.prefixFn = fn
+	}
+}
+
 // WithIO specifies the Reader and Writer to use for IO.
 //
+// Reads and writes are done on goroutines of their own, so they can
+// be given up on, e.g. by CancelRead or the session ending, without
+// waiting for the Reader or Writer. A Reader or Writer backed by
+// memory, i.e. a bytes.Buffer, bytes.Reader, strings.Reader or
+// strings.Builder, never blocks and is thus used directly instead,
+// meaning a read from one is never interrupted, it just returns
+// whatever is left, or io.EOF.
+//
 func WithIO(in io.Reader, out io.Writer) Option {
 	return func(ui *UI) {
 		ui.i = in
@@ -98,6 +146,186 @@ func WithIO(in io.Reader, out io.Writer) Option {
 func WithSignalHandlers(handlers map[os.Signal]SignalHandler) Option {
 	return func(ui *UI) {
 		ui.sigHandlers = handlers
+		ui.sigDescs = nil
+	}
+}
+
+// WithHandledSignals restricts the signals the UI listens for to the
+// given ones, instead of all signals. Only these are passed to the
+// handlers from WithSignalHandlers, and the default shutdown only
+// happens if Interrupt is among them. Every other signal keeps its
+// default behaviour, as if the UI wasn't running, e.g. an unlisted
+// SIGINT terminates the program.
+//
+func WithHandledSignals(sigs ...os.Signal) Option {
+	return func(ui *UI) {
+		ui.signals = sigs
+	}
+}
+
+// WithIgnoreEOF specifies the number of consecutive EOFs on empty
+// input required for the UI to exit, like IGNOREEOF in bash. A hint
+// is printed for every EOF that is ignored. Values less than 2 keep
+// the default behaviour of exiting on the first EOF.
+//
+func WithIgnoreEOF(n int) Option {
+	return func(ui *UI) {
+		ui.ignoreEOF = n
+	}
+}
+
+// WithInputFilter specifies a filter which every chunk of raw input
+// is passed through, as it is read from the underlying Reader. Since
+// this happens before the input is split into lines, the filter may
+// change where lines end, e.g. by stripping the CR from CRLF. The
+// filter may modify the chunk in place and return a subslice of it.
+//
+func WithInputFilter(filter func([]byte) []byte) Option {
+	return func(ui *UI) {
+		ui.inFilter = filter
+	}
+}
+
+// WithStripCR specifies whether the CR of CRLF line endings, e.g.
+// from Windows clients or files, is stripped from lines along with
+// the newline, before they are passed to the Engine. This is the
+// default. Otherwise the CR is left at the end of the line.
+//
+func WithStripCR(strip bool) Option {
+	return func(ui *UI) {
+		ui.keepCR = !strip
+	}
+}
+
+// WithOutputFilter specifies a filter which everything engines write
+// through the UI is passed through, e.g. for redacting secrets. The
+// filter sees every Write call separately, without the prefix, and
+// its result is what tees and transcripts receive. The filter must
+// not modify the given slice, since it belongs to the engine.
+//
+func WithOutputFilter(filter func([]byte) []byte) Option {
+	return func(ui *UI) {
+		ui.outFilter = filter
+	}
+}
+
+// WithPromptWriter specifies a separate Writer for the output of
+// the UI itself, i.e. prompts, EOF hints and the newline written
+// when Run returns. Engine output still goes to the output Writer.
+//
+func WithPromptWriter(w io.Writer) Option {
+	return func(ui *UI) {
+		ui.promptW = w
+	}
+}
+
+// WithPromptToStderr writes the output of the UI itself to stderr,
+// see WithPromptWriter. This keeps prompts out of piped output, so
+// it only contains what engines wrote.
+//
+func WithPromptToStderr() Option {
+	return func(ui *UI) {
+		ui.promptW = os.Stderr
+	}
+}
+
+// WithSharedEngine shares the goroutine of the Engine with every
+// other UI sharing an equal Engine value, instead of running it on a
+// goroutine of its own, which is the default. Exec calls for the UIs
+// sharing it are then serialized, e.g. for an Engine which isn't safe
+// for concurrent use but is run by several UIs at once, so a command
+// waits for the one of another UI to return, even one abandoned by
+// WithExecGracePeriod. The Engine is only shut down once the last of
+// them exits, see Shutdowner. Since the Engine is then a map key, its underlying
+// type must be hashable, see Engine and WithStrictEngineIdentity.
+// DisableEngineSharing overrides this for every UI.
+//
+func WithSharedEngine() Option {
+	return func(ui *UI) {
+		ui.shared = true
+	}
+}
+
+// WithIsolatedEngine runs the Engine on a goroutine of its own,
+// which is the default, undoing a WithSharedEngine given before it.
+// An isolated Engine value may still be used by other UIs, so Exec
+// must then be safe for concurrent use, see RunFactory for avoiding
+// that.
+//
+func WithIsolatedEngine() Option {
+	return func(ui *UI) {
+		ui.shared = false
+	}
+}
+
+// WithStrictEngineIdentity guards against unrelated UIs sharing an
+// Engine by accident, see WithSharedEngine. UIs share the goroutine,
+// and state, of Engines which are equal, as map keys, so two distinct
+// values of a struct type with equal fields, or pointers to an empty
+// struct, which may all be the same pointer, are the same Engine to
+// them. In strict mode, only pointers to a non-empty type are shared.
+// When any other Engine equals one already running, a warning is
+// written and it's run isolated instead, see WithIsolatedEngine.
+//
+func WithStrictEngineIdentity() Option {
+	return func(ui *UI) {
+		ui.strictEng = true
+	}
+}
+
+// WithoutPanicRecovery stops Run from recovering panics, so they
+// crash the program along with their full stack trace. This is
+// meant for development, by default Run recovers panics and returns
+// them as errors. Panics in Engine.Exec happen on the goroutine of
+// the engine, so Run can never recover them either way.
+//
+func WithoutPanicRecovery() Option {
+	return func(ui *UI) {
+		ui.noRecover = true
+	}
+}
+
+// WithEchoInput writes every line read after the prompt, like a
+// terminal echoes what is typed, so the output of a session driven
+// by a file or pipe shows what was executed. It has no effect if
+// the input is a terminal, since the terminal already echoes.
+//
+func WithEchoInput() Option {
+	return func(ui *UI) {
+		ui.echoInput = true
+	}
+}
+
+// WithInteractivePromptOnly only writes the prompt if the input is a
+// terminal, like most shells, so the output of a session driven by a
+// file or pipe holds nothing but what the Engine wrote, without the
+// newline ending the last prompt either. The prefix of Write isn't
+// affected.
+//
+func WithInteractivePromptOnly() Option {
+	return func(ui *UI) {
+		ui.ttyPrompt = true
+	}
+}
+
+// WithAutoNewline makes the UI write a newline after any command
+// whose output doesn't end with one, so the next prompt always
+// starts on its own line.
+//
+func WithAutoNewline() Option {
+	return func(ui *UI) {
+		ui.autoNewline = true
+	}
+}
+
+// WithFinalNewline specifies whether a newline is written once the
+// session ends, ending the last prompt, which is the default. Leaving
+// it out is meant for comparing the output of a session exactly, e.g.
+// in tests.
+//
+func WithFinalNewline(enabled bool) Option {
+	return func(ui *UI) {
+		ui.noFinalNL = !enabled
 	}
 }
 
@@ -108,26 +336,241 @@ func WithSignalHandlers(handlers map[os.Signal]SignalHandler) Option {
 // By default, UI will shutdown on Interrupt and Kill signals.
 //
 type UI struct {
+	// Byte counters, kept first for 64-bit alignment
+	nRead      int64
+	nWritten   int64
+	maxBytes   int64
+	nCmds      int64
+	maxCmds    int64
+	cmdOut     int64 // bytes written by the current command
+	maxOut     int64
+	nAbandoned int64 // see WithExecGracePeriod
+	lastByte   int32 // last byte written, for WithAutoNewline
+	noPrefix   int32 // number of active SuppressPrefix calls
+	writeStuck int32 // set once a write timed out, see WithWriteTimeout
+	atEOF      int32 // set while executing the last line of the input, see AtEOF
+
+	// Err is where WriteErr writes the diagnostics of engines, and
+	// where Run reports its error, see WithStderrOnError. It's
+	// stderr if nil, see WithStderr.
+	Err io.Writer
+
 	// I/O shit
+	ioMu        sync.RWMutex // guards i, o, prefix and inGen, see SetIO
+	inGen       int          // incremented by SetIO
 	i           io.Reader
 	o           io.Writer
 	prefix      []byte
+	readPrefix  []byte        // nil unless set, see WithReadPrefix
+	prefixFn    func() string // guarded by ioMu, see WithPrefixFunc
 	sigHandlers map[os.Signal]SignalHandler
+	notifySig   func(chan<- os.Signal, ...os.Signal) // see withSignalSource
+	stopSig     func(chan<- os.Signal)
+	sigDescs    map[os.Signal]string // see WithSignalHandler
+	signals     []os.Signal          // all signals if empty
+	reload      func() error
+	ignoreEOF   int
+	noRecover   bool
+	shared      bool // see WithSharedEngine
+	strictEng   bool
+	format      OutputFormat
+	countdown   bool
+	spinner     *spinner
+	progMu      sync.Mutex   // guards progress and the state of every bar
+	progress    *ProgressBar // shown on a terminal, see NewProgressBar
+	fanout      int
+	rprompt     func() string
+	segments    []Segment // see WithPromptSegments
+	autoNewline bool
+	noFinalNL   bool // see WithFinalNewline
+	intCancels  bool // see WithInterruptCancelsCommand
+	echoInput   bool
+	reportErrs  bool // see WithStderrOnError
+	mask        rune
+	termMu      sync.Mutex
+	termRestore func() error // set while the terminal is in raw mode
+	builtins    map[string]builtin
+	theme       *Theme
+	jobs        *jobTable
+	vars        varStore
+	expansion   *VarExpansion
+	envPrefix   *string // see WithEnvSnapshot
+	history     *history
+	askHistory  history
+	expandHist  bool
+	histFile    string
+	histMax     int
+	histLoaded  bool
+	redactCfg   bool
+	statusColor bool
+	ttyPrompt   bool
+	emptyLine   EmptyLineAction
+	cmdSep      *CommandSeparator
+	framer      Framer                        // see WithFramer
+	incomplete  func(string) bool             // see WithContinuation
+	intercept   func(string) ([]string, bool) // see WithInputInterceptor
+	editor      LineEditor                    // see WithLineEditor
+	keys        map[Key]func(*Editor)         // see WithKeyBinding
+	killed      string                        // see KillLine
+	middleware  []Middleware                  // see WithMiddleware
+	completeFn  CandidateLineCompleter        // see WithCompleter
+	completion  string                        // line held by completeTabs
+	script      bool                          // see WithScriptMode
+	contOnErr   bool                          // see WithContinueOnError
+	auth        Authorizer
+	cmdLog      io.Writer
+	eng         Engine
+	wrapped     Engine // eng wrapped by the middlewares, see wrapEngine
+
+	// Shutdown
+	mu           sync.Mutex
+	running      chan struct{} // closed once the current command is done
+	current      string        // see Current
+	cancelCur    context.CancelFunc
+	curCanceled  bool
+	abandoned    bool // see reattachAbandoned
+	execGrace    time.Duration
+	lastResult   Result
+	lastFailed   string       // see WithRetry
+	cwd          string       // see Chdir
+	reqCh        chan execReq // set while running, see TryExec
+	drainTimeout time.Duration
+	sessTimeout  time.Duration
+	clk          Clock       // see WithClock
+	cmdTimeout   *cmdTimeout // see WithCommandTimeout
+	writeTimeout time.Duration
+	firstTimeout time.Duration
+	idleAfter    time.Duration // see WithIdleFunc
+	idleFn       func(*UI)
+	inputs       *inputMux     // set by AddInput
+	engSwaps     []Engine      // nil pops, see SwapEngine
+	restartFn    func() Engine // see WithRestartCommand
+	restartReq   bool
+	notifyCh     chan string // see NotifyChan
+
+	// Output
+	out           io.Writer // o along with any tees, set by Run
+	outFilter     func([]byte) []byte
+	outBound      int
+	wrap          *wordWrapper   // see WithWordWrap
+	bounded       *boundedWriter // set by Run, see WithBoundedOutput
+	promptW       io.Writer
+	promptOut     io.Writer // promptW, or out, along with any tees, set by Run
+	promptMu      sync.Mutex
+	atPrompt      bool   // set while Run waits on input after the prompt, see Interject
+	heldWrites    []byte // see SafeWrite
+	tees          []io.Writer
+	ignoreTeeErrs bool
+	transcript    *lockedWriter
+	trace         *lockedWriter // see WithDispatchTrace
+
+	// Buffered input, see Read, ReadByte and ReadRune
+	inFilter      func([]byte) []byte
+	keepCR        bool
+	rbuf          []byte
+	rpos          int
+	rerr          error
+	canUnreadByte bool
+	lastRuneSize  int
+	pendingRead   *pendingRead
+	readMu        sync.Mutex
+	readCancel    chan struct{} // set while waiting on a read, see CancelRead
+	pauseMu       sync.Mutex
+	pauseCh       chan struct{} // closed by Pause
+	resumeCh      chan struct{} // set while paused, closed by Resume
+	dropPaused    bool
+	syncIO        bool // see withSyncIO
 
 	ctx context.Context // This is reset for every Run call
 }
 
-// SetPrefix sets the interpreters line prefix
+// SetPrefix sets the interpreters line prefix. It's safe to call
+// while Run is running, e.g. from an Engine, and takes effect from
+// the next Write or prompt on.
 //
 func (ui *UI) SetPrefix(prefix string) {
+	ui.ioMu.Lock()
 	ui.prefix = []byte(prefix)
+	ui.ioMu.Unlock()
+}
+
+// linePrefix returns the current line prefix, see SetPrefix.
+func (ui *UI) linePrefix() []byte {
+	ui.ioMu.RLock()
+	defer ui.ioMu.RUnlock()
+	return ui.prefix
 }
 
-// SetIO sets the interpreters I/O.
+// SetPrefixFunc sets the func rendering the prompt, see WithPrefixFunc,
+// or removes it if fn is nil. It's safe to call while Run is running,
+// e.g. from an Engine, and takes effect from the next prompt on.
+//
+func (ui *UI) SetPrefixFunc(fn func() string) {
+	ui.ioMu.Lock()
+	ui.prefixFn = fn
+	ui.ioMu.Unlock()
+}
+
+// SuppressPrefix calls fn with the prefix left out of every Write,
+// e.g. for rendering a table or ascii art, and restores it once fn
+// returns, even if it panics. Unlike setting an empty prefix, this
+// never changes the prefix itself, so the prompt is left as is.
+//
+func (ui *UI) SuppressPrefix(fn func()) {
+	atomic.AddInt32(&ui.noPrefix, 1)
+	defer atomic.AddInt32(&ui.noPrefix, -1)
+	fn()
+}
+
+// SetIO sets the interpreters I/O. It's safe to call while Run is
+// running, e.g. to swap the output to a log mid-session. Anything
+// written afterwards, including the prompt, goes to out, along with
+// any tees and the transcript. A read already waiting on the previous
+// input Reader is abandoned, and the next read is from in instead, so
+// whatever the previous Reader returns afterwards is lost. Run never
+// closes either Reader.
 //
 func (ui *UI) SetIO(in io.Reader, out io.Writer) {
+	ui.ioMu.Lock()
 	ui.i = in
 	ui.o = out
+	ui.inGen++
+	ui.ioMu.Unlock()
+
+	ui.CancelRead()
+}
+
+// input returns the current input Reader, see SetIO.
+func (ui *UI) input() io.Reader {
+	in, _ := ui.inputGen()
+	return in
+}
+
+// inputGen returns the current input Reader along with how many
+// times it was set by SetIO, which tells reads on a previous one apart.
+//
+func (ui *UI) inputGen() (io.Reader, int) {
+	ui.ioMu.RLock()
+	defer ui.ioMu.RUnlock()
+	return ui.i, ui.inGen
+}
+
+// output returns the current output Writer, see SetIO.
+func (ui *UI) output() io.Writer {
+	ui.ioMu.RLock()
+	defer ui.ioMu.RUnlock()
+	return ui.o
+}
+
+// currentOutput forwards every Write to the output Writer of the UI
+// at the time of the Write, so that SetIO takes effect mid-session.
+//
+type currentOutput struct {
+	ui *UI
+}
+
+func (w currentOutput) Write(b []byte) (int, error) {
+	return w.ui.output().Write(b)
 }
 
 // Run creates a UI and associates the provided Engine to it.
@@ -138,7 +581,20 @@ func Run(ctx context.Context, eng Engine, opts ...Option) error {
 	return ui.Run(ctx, eng, opts...)
 }
 
-// minRead
+// RunFactory is the same as Run, except the Engine is created by
+// calling factory, once per call. UIs running the same Engine share
+// its state, even though each runs it on a goroutine of its own,
+// unless given WithSharedEngine, which is fine for stateless engines.
+// Stateful engines, e.g. a game keeping its board in the Engine,
+// should instead be run with a factory so each UI gets its own
+// instance. The factory should return a new pointer on every call,
+// since equal Engine values are still shared by WithSharedEngine.
+//
+func RunFactory(ctx context.Context, factory func() Engine, opts ...Option) error {
+	return Run(ctx, factory(), opts...)
+}
+
+// minRead is the size of the reads from the input Reader.
 const minRead = 512
 
 // newLineErr is used for internal use when checking recoverable errors
@@ -150,25 +606,44 @@ func (e newLineErr) Error() string {
 	return fmt.Sprintf("sand: encountered error when writing newline, %s", e.werr)
 }
 
+// eofHint returns the message printed when an EOF is ignored.
+func eofHint(left int) []byte {
+	if left == 1 {
+		return []byte("\nUse Ctrl-D again to exit.\n")
+	}
+	return []byte(fmt.Sprintf("\nUse Ctrl-D %d more times to exit.\n", left))
+}
+
 // Run starts the user interface with the provided sources
 // for input and output of the interpreter and engine.
 // The prefix will be printed before every line.
 //
+// The input is read a line at a time, however long it is, and every
+// line is passed to Exec on its own, without its newline, or CRLF.
+//
 func (ui *UI) Run(ctx context.Context, eng Engine, opts ...Option) (err error) {
 	// Make sure engine is set
 	if eng == nil {
 		panic(errNoEngine)
 	}
 
+	// Report the error, once everything else is done
+	defer func() {
+		if ui.reportErrs {
+			err = ui.reportErr(err)
+		}
+	}()
+
+	// Never leave the terminal in raw mode
+	defer ui.RestoreTerminal()
+
 	// Catch any panics
 	defer func() {
+		if ui.noRecover {
+			return
+		}
 		if r := recover(); r != nil {
-			rerr, ok := r.(error)
-			if !ok {
-				return
-			}
-
-			err = errors.Wrap(rerr, "sand: recovered from panic")
+			err = newPanicError(r)
 		}
 	}()
 
@@ -176,32 +651,123 @@ func (ui *UI) Run(ctx context.Context, eng Engine, opts ...Option) (err error) {
 	for _, opt := range opts {
 		opt(ui)
 	}
+	if err = ui.checkIO(); err != nil {
+		return
+	}
+	ui.out = ui.transcribe(ui.teeOutput(currentOutput{ui}))
+	ui.bounded = nil
+	if ui.outBound > 0 {
+		ui.bounded = newBoundedWriter(ui.out, ui.outBound)
+		ui.out = ui.bounded
+		defer ui.bounded.close()
+	}
+	ui.promptOut = ui.out
+	if ui.promptW != nil {
+		ui.promptOut = ui.transcribe(ui.teeOutput(ui.promptW))
+	}
+	ui.eng = eng
+	ui.snapshotEnv()
+	atomic.StoreInt32(&ui.writeStuck, 0)
+
+	// Load persisted history
+	if err = ui.loadHistory(); err != nil {
+		return
+	}
+	defer func() {
+		if herr := ui.saveHistory(); herr != nil && (err == nil || err == io.EOF) {
+			err = herr
+		}
+	}()
 
 	// Check if context is nil
-	var cancel context.CancelFunc
 	if ctx == nil {
-		ctx, cancel = context.WithCancel(context.Background())
+		ctx = context.Background()
 	}
 
-	ui.ctx = ctx
-	if cancel == nil {
+	var cancel context.CancelFunc
+	if ui.sessTimeout > 0 {
+		ui.ctx, cancel = context.WithTimeout(ctx, ui.sessTimeout)
+	} else {
 		ui.ctx, cancel = context.WithCancel(ctx)
 	}
 	defer cancel()
 
+	// End the session if it's never used, see WithFirstInputTimeout
+	var noInput int32
+	gotInput := func() {}
+	if ui.firstTimeout > 0 {
+		stop := ui.afterFunc(ui.firstTimeout, func() {
+			atomic.StoreInt32(&noInput, 1)
+			cancel()
+		})
+		gotInput = stop
+		defer func() {
+			stop()
+			if atomic.LoadInt32(&noInput) == 1 && isContextErr(errors.Cause(err)) {
+				err = ErrNoInput
+			}
+		}()
+	}
+
+	// The session can outlive its context while draining
+	sess := ui.ctx
+	if ui.drainTimeout > 0 {
+		var cancelIO context.CancelFunc
+		ui.ctx, cancelIO = context.WithCancel(detachedContext{Context: sess})
+		defer cancelIO()
+		go ui.drain(sess, cancelIO)
+	}
+
 	// Set up channels
-	reqCh := make(chan execReq)
+	var engs engineStack
 	sigs := make(chan os.Signal, 1)
-	defer close(reqCh)
+	monitored := make(chan struct{})
+	defer func() {
+		// Signals are monitored until the session is over
+		cancel()
+		<-monitored
+	}()
+	defer func() {
+		// Engines swapped in are detached first, the one given
+		// to Run last, whose Shutdown error takes precedence.
+		ui.mu.Lock()
+		ui.engSwaps = nil
+		ui.mu.Unlock()
+		var serr error
+		for len(engs) > 0 {
+			if perr := ui.popEngine(&engs); perr != nil {
+				serr = perr
+			}
+		}
+		if serr != nil && (err == nil || err == io.EOF) {
+			err = errors.Wrap(serr, "sand: encountered error while shutting down engine")
+		}
+	}()
 
 	// Start engine and signal monitoring
-	go ui.monitorSys(ui.ctx, cancel, sigs)
-	ui.startEngine(ctx, eng, reqCh)
+	go func() {
+		ui.monitorSys(sess, cancel, sigs)
+		close(monitored)
+	}()
+	ui.pushEngine(&engs, eng)
 
 	// Now, begin reading lines from input.
 	defer func() {
-		if err == nil || err == io.EOF {
-			_, err = ui.o.Write([]byte("\n"))
+		if ferr := ui.flushBounded(); ferr != nil && (err == nil || err == io.EOF) {
+			err = ferr
+		}
+	}()
+	defer func() {
+		if ui.script && err == io.EOF {
+			err = nil
+		}
+	}()
+	showPrompt := !ui.script && (!ui.ttyPrompt || isTerminal(ui.input()))
+	defer func() {
+		if showPrompt && !ui.noFinalNL && (err == nil || err == io.EOF) {
+			var n int
+			n, err = ui.promptOut.Write([]byte("\n"))
+			atomic.AddInt64(&ui.nWritten, int64(n))
 			if err != nil {
 				err = newLineErr{werr: err}
 			}
@@ -209,31 +775,232 @@ func (ui *UI) Run(ctx context.Context, eng Engine, opts ...Option) (err error) {
 		}
 	}()
 
-	var n int
+	var n, eofs int
+	var pending string
+	var queued []string // lines expanded by the input interceptor
+
+	// writeErr returns the error ending the session for failing to
+	// write what, which is that of the session itself once it's done.
+	writeErr := func(werr error, what string) error {
+		if sess.Err() != nil {
+			return sess.Err()
+		}
+		return errors.Wrap(werr, "sand: encountered error while writing "+what)
+	}
 	for {
-		// Write prefix
-		_, err = ui.Write(nil)
-		if err != nil {
-			err = errors.Wrap(err, "sand: encountered error while writing prefix")
-			return
+		// Write prefix, unless executing the lines of an intercepted one
+		intercepted := len(queued) > 0
+		var prompt []byte
+		if !intercepted {
+			ui.promptMu.Lock()
+			err = ui.writeHeld()
+			if err == nil {
+				err = ui.writePendingNotes()
+			}
+			if showPrompt {
+				prompt = ui.renderPrompt(sess)
+			}
+			if err == nil && len(prompt) > 0 && ui.editor == nil {
+				_, err = ui.writePrompt(prompt)
+			}
+			if err == nil && ui.completion != "" {
+				_, err = ui.writePrompt([]byte(ui.completion))
+			}
+			ui.atPrompt = err == nil
+			ui.promptMu.Unlock()
+			if err != nil {
+				err = errors.Wrap(err, "sand: encountered error while writing prefix")
+				return
+			}
 		}
 
 		// Read line
+		var src string
 		b := make([]byte, minRead)
-		n, err = ui.Read(b)
-		if err != nil && err != io.EOF || n == 0 {
+		if intercepted {
+			b, queued, err = []byte(queued[0]), queued[1:], nil
+		} else {
+			atomic.StoreInt32(&ui.atEOF, 0)
+			stopIdle := ui.startIdle()
+			stopNotes := ui.startNotes()
+			if ui.editor != nil {
+				b, err = ui.editLine(prompt)
+			} else {
+				b, src, err = ui.readNext(b, pending)
+			}
+			stopNotes()
+			stopIdle()
+		}
+		n = len(b)
+		if n > 0 || err == nil {
+			gotInput()
+		}
+		ui.promptMu.Lock()
+		ui.atPrompt = false
+		ui.promptMu.Unlock()
+		if n == 0 && err == io.EOF {
+			eofs++
+			if eofs < ui.ignoreEOF {
+				_, err = ui.writePrompt(eofHint(ui.ignoreEOF - eofs))
+				if err != nil {
+					err = errors.Wrap(err, "sand: encountered error while writing EOF hint")
+					return
+				}
+				continue
+			}
+		}
+		if err == ErrReadInterrupted {
+			err = nil
+			continue
+		}
+		if err != nil && (err != io.EOF || n == 0) {
+			if sess.Err() != nil {
+				err = sess.Err()
+			}
 			return
 		}
+		eofs = 0
 
-		// Truncate nil bytes
+		// Truncate nil bytes, unless framed
 		idx := bytes.IndexByte(b, 0)
-		if idx != -1 {
+		if idx != -1 && ui.framer == nil {
 			b = b[:idx]
 		}
 
-		// Execute line
-		status := ui.exec(ui.ctx, string(b), reqCh)
-		if status != 0 {
+		// Execute line, along with any previous incomplete lines
+		chunk := string(b)
+		if !ui.keepCR && ui.framer == nil {
+			chunk = strings.Replace(chunk, "\r\n", "\n", -1)
+		}
+		if ui.completeFn != nil && ui.editor == nil && src == "" && !intercepted {
+			var ok bool
+			if chunk, ok = ui.completeTabs(chunk); !ok {
+				continue
+			}
+		}
+		ctx := ui.ctx
+		if src != "" {
+			ctx = context.WithValue(ctx, inputSourceKey{}, src)
+			if _, werr := ui.writePrompt([]byte("[" + src + "] " + strings.TrimRight(chunk, "\r\n") + "\n")); werr != nil {
+				err = writeErr(werr, "input")
+				return
+			}
+		} else if ui.echoInput && !isTerminal(ui.input()) {
+			if _, werr := ui.writePrompt([]byte(strings.TrimRight(chunk, "\r\n") + "\n")); werr != nil {
+				err = writeErr(werr, "input")
+				return
+			}
+		}
+		var repeated bool
+		if pending == "" {
+			var ok bool
+			if chunk, repeated, ok = ui.handleEmptyLine(chunk); !ok {
+				continue
+			}
+		}
+		if ui.expandHist && pending == "" && !repeated && !intercepted {
+			var expanded bool
+			var herr error
+			chunk, expanded, herr = ui.expandHistory(chunk)
+			if herr != nil {
+				if _, werr := ui.writePrompt([]byte(ui.Theme().Error.Paint(herr.Error()) + "\n")); werr != nil {
+					err = writeErr(werr, "history error")
+					return
+				}
+				continue
+			}
+			if expanded {
+				ui.tracef("expand", "%q", chunk)
+				if _, werr := ui.writePrompt([]byte(strings.TrimRight(chunk, "\r\n") + "\n")); werr != nil {
+					err = writeErr(werr, "expanded input")
+					return
+				}
+			}
+		}
+		if ui.history != nil && !repeated && !intercepted {
+			ui.history.add(chunk, ui.clock().Now())
+		}
+		if ui.intercept != nil && pending == "" && !intercepted {
+			if lines, ok := ui.intercept(strings.TrimRight(chunk, "\r\n")); ok {
+				ui.tracef("expand", "%q", lines)
+				queued = append(queued, lines...)
+				continue
+			}
+		}
+		if ui.expansion != nil {
+			expanded, verr := ui.expandVars(chunk)
+			if verr != nil {
+				if _, werr := ui.writePrompt([]byte(ui.Theme().Error.Paint(verr.Error()) + "\n")); werr != nil {
+					err = writeErr(werr, "expansion error")
+					return
+				}
+				continue
+			}
+			if expanded != chunk {
+				ui.tracef("expand", "%q", expanded)
+			}
+			chunk = expanded
+		}
+		line := pending + chunk
+		written := atomic.LoadInt64(&ui.nWritten)
+		var status int
+		var derr error
+		if ui.incomplete != nil && ui.incomplete(line) {
+			status = StatusNeedMore
+		} else if pending == "" && ui.cmdSep != nil {
+			status, line, derr = ui.execSequence(ctx, line, &engs)
+		} else {
+			status, derr = ui.dispatch(ctx, line, pending != "", engs.top().reqCh)
+			ui.applyEngineSwaps(&engs)
+		}
+		if derr != nil {
+			err = derr
+			return
+		}
+		if ferr := ui.flushBounded(); ferr != nil && !isContextErr(ferr) {
+			err = ferr
+			return
+		}
+		if ui.autoNewline && atomic.LoadInt64(&ui.nWritten) != written && atomic.LoadInt32(&ui.lastByte) != '\n' {
+			if _, werr := ui.write([]byte("\n")); werr != nil {
+				err = writeErr(werr, "newline")
+				return
+			}
+		}
+		pending = ""
+		if status == StatusNeedMore {
+			pending = line
+			if !strings.HasSuffix(pending, "\n") {
+				pending += "\n"
+			}
+			status = 0
+		}
+		status = ui.settleStatus(status)
+		if pending == "" {
+			ui.Set(StatusVar, strconv.Itoa(status))
+		}
+		if sess.Err() != nil {
+			err = sess.Err()
+			return
+		}
+		if ui.overQuota() {
+			err = ErrMaxBytes
+			return
+		}
+		if atomic.LoadInt32(&ui.writeStuck) == 1 {
+			err = ErrWriteTimeout
+			return
+		}
+		if ui.overCommandQuota() {
+			// The quota ends the session, whether the message is written or not
+			ui.writePrompt([]byte(ui.Theme().Error.Paint(ErrMaxCommands.Error()) + "\n"))
+			err = ErrMaxCommands
+			return
+		}
+		if status != 0 && (status == StatusExit || !ui.contOnErr) {
+			if ui.script && status != StatusExit {
+				err = errors.Errorf("sand: %q failed with status %d", strings.TrimRight(line, "\r\n"), status)
+			}
 			return
 		}
 
@@ -244,47 +1011,226 @@ func (ui *UI) Run(ctx context.Context, eng Engine, opts ...Option) (err error) {
 	}
 }
 
+// dispatch executes a single command, by a builtin, as a background
+// job or by the Engine, unless it continues an incomplete line, in
+// which case it always goes to the Engine. The error is that of
+// logging the command, see WithCommandLog.
+//
+func (ui *UI) dispatch(ctx context.Context, line string, cont bool, reqCh chan execReq) (int, error) {
+	ui.tracef("dispatch", "%q", line)
+	if !cont {
+		if status, ok := ui.execBuiltin(ctx, line); ok {
+			ui.tracef("status", "%d", status)
+			return status, nil
+		}
+		if ui.execBackground(line) {
+			return 0, nil
+		}
+	}
+
+	hideSpinner := ui.startSpinner()
+	status := ui.exec(ctx, line, reqCh)
+	hideSpinner()
+	ui.tracef("status", "%d", status)
+	ui.recordStatus(line, status)
+	if status == 0 {
+		if err := ui.logCommand(line); err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// checkIO returns ErrMissingIO, describing which is missing, unless
+// both the input and output are set.
+//
+func (ui *UI) checkIO() error {
+	switch {
+	case ui.input() == nil && ui.output() == nil:
+		return errors.Wrap(ErrMissingIO, "sand: no input Reader or output Writer, see WithIO")
+	case ui.input() == nil:
+		return errors.Wrap(ErrMissingIO, "sand: output Writer is set, but input Reader is nil")
+	case ui.output() == nil:
+		return errors.Wrap(ErrMissingIO, "sand: input Reader is set, but output Writer is nil")
+	}
+	return nil
+}
+
+// renderPrompt returns the prompt written before reading each line
+// of the session ctx. It's the prefix func, if any, or else the read
+// prefix, or else the prefix. The countdown, segments and right
+// prompt are added if enabled, and it's painted by the theme.
+//
+func (ui *UI) renderPrompt(ctx context.Context) []byte {
+	prompt := string(ui.linePrefix())
+	if ui.readPrefix != nil {
+		prompt = string(ui.readPrefix)
+	}
+	ui.ioMu.RLock()
+	fn := ui.prefixFn
+	ui.ioMu.RUnlock()
+	if fn != nil {
+		prompt = fn()
+	}
+	if ui.countdown {
+		prompt = Countdown(ctx) + prompt
+	}
+	if prompt != "" {
+		prompt = ui.promptColor().Paint(prompt)
+	}
+	prompt = ui.renderSegments() + prompt
+	prompt += ui.rightPrompt(prompt)
+	return []byte(prompt)
+}
+
 var engines = struct {
 	sync.Mutex
-	engs map[Engine]chan chan execReq
+	engs map[Engine]*engineRunner
 }{
-	engs: make(map[Engine]chan chan execReq),
+	engs: make(map[Engine]*engineRunner),
+}
+
+// noSharing is set by DisableEngineSharing.
+var noSharing int32
+
+// DisableEngineSharing makes every UI run its Engine isolated, as if
+// it was given WithIsolatedEngine, when disable is true, so Engines
+// are never shared between UIs running them concurrently, regardless
+// of how the UIs are configured, e.g. by WithSharedEngine. No UI
+// started afterwards consults the registry of running Engines, whose
+// goroutine, and state, it would otherwise share with other UIs
+// running an equal Engine. An Engine is still only shut down once
+// the last UI using it exits, see Shutdowner.
+//
+// This is meant to be called once, e.g. in main or TestMain, by
+// programs whose UIs must never affect one another, e.g. servers. The
+// cost is a goroutine per UI, instead of per Engine, and that an
+// Engine used by several UIs at once must be safe for concurrent use,
+// since Exec is then called concurrently. UIs already running aren't
+// affected.
+//
+func DisableEngineSharing(disable bool) {
+	var v int32
+	if disable {
+		v = 1
+	}
+	atomic.StoreInt32(&noSharing, v)
+}
+
+// engineRunner represents a running engine.
+type engineRunner struct {
+	attach chan attachment
+	detach chan attachment
+	done   chan struct{} // closed once the runner has stopped accepting UIs
+	shared bool          // set if registered in engines, see WithSharedEngine
+	execMu sync.Mutex    // serializes Exec calls, if shared
+}
+
+// attachment represents a UI attached to an engineRunner.
+type attachment struct {
+	reqCh    chan execReq
+	detached chan error // receives the Shutdown error once detached
+	wrapped  Engine     // the Engine wrapped by the middlewares of the UI
+}
+
+// newEngineRunner returns a runner which isn't running yet.
+func newEngineRunner() *engineRunner {
+	return &engineRunner{
+		attach: make(chan attachment),
+		detach: make(chan attachment),
+		done:   make(chan struct{}),
+	}
 }
 
-// startEngine starts the provided engine and uses it
-// to execute commands.
+// startEngine starts the provided engine, or attaches to it if it's
+// already running, and uses it to execute commands, by calling
+// wrapped, until uiReqCh is closed. The returned channel then
+// receives the error of shutting down the engine, if this was the
+// last UI using it, see Shutdowner.
 //
-func (ui *UI) startEngine(ctx context.Context, eng Engine, uiReqCh chan execReq) {
-	engines.Lock()
-	reqCh, exists := engines.engs[eng]
-	if !exists {
-		reqCh = make(chan chan execReq)
-		engines.engs[eng] = reqCh
-		go runEngine(ctx, eng, reqCh)
+func (ui *UI) startEngine(eng, wrapped Engine, uiReqCh chan execReq) (detached chan error) {
+	a := attachment{reqCh: uiReqCh, detached: make(chan error, 1), wrapped: wrapped}
+	isolate := func() chan error {
+		r := newEngineRunner()
+		go runEngine(eng, r)
+		r.attach <- a
+		return a.detached
 	}
-	engines.Unlock()
+	if !ui.shared || !isHashable(eng) || atomic.LoadInt32(&noSharing) == 1 {
+		return isolate()
+	}
+
+	for {
+		engines.Lock()
+		r, exists := engines.engs[eng]
+		if exists && ui.strictEng && !hasIdentity(eng) {
+			engines.Unlock()
+			msg := fmt.Sprintf("sand: engine %T equals one already running, so it's run isolated; use a pointer to share it", eng)
+			ui.writePrompt([]byte(ui.Theme().Error.Paint(msg) + "\n"))
+			return isolate()
+		}
+		if !exists {
+			r = newEngineRunner()
+			r.shared = true
+			engines.engs[eng] = r
+			go runEngine(eng, r)
+		}
+		engines.Unlock()
 
-	reqCh <- uiReqCh
+		// The runner may be shutting down, in which
+		// case a new one must be started.
+		select {
+		case r.attach <- a:
+			return a.detached
+		case <-r.done:
+		}
+	}
+}
+
+// withSignalSource replaces signal.Notify and signal.Stop for the
+// UI, e.g. for tests to inject signals into monitorSys.
+//
+func withSignalSource(notify func(chan<- os.Signal, ...os.Signal), stop func(chan<- os.Signal)) Option {
+	return func(ui *UI) {
+		ui.notifySig, ui.stopSig = notify, stop
+	}
 }
 
 // monitorSys monitors syscalls from the OS
 //
 func (ui *UI) monitorSys(ctx context.Context, cancel context.CancelFunc, sigCh chan os.Signal) {
-	signal.Notify(sigCh)
+	notify, stop := signal.Notify, signal.Stop
+	if ui.notifySig != nil {
+		notify, stop = ui.notifySig, ui.stopSig
+	}
+	notify(sigCh, ui.signals...)
 	defer close(sigCh)
-	defer signal.Stop(sigCh)
+	defer stop(sigCh)
 
 	for {
 		select {
 		case <-ctx.Done():
+			return
 		case sig := <-sigCh:
 			handler, exists := ui.sigHandlers[sig]
 			if exists {
 				sig = handler(sig)
 			}
+			if sig == os.Interrupt && ui.intCancels {
+				if _, running := ui.Current(); running {
+					ui.CancelCurrent()
+					continue
+				}
+			}
+			if sig == os.Kill || sig == os.Interrupt || sig == terminateSignal {
+				ui.RestoreTerminal()
+			}
 			if sig == os.Kill || sig == os.Interrupt {
 				cancel()
 			}
+			if sig == reloadSignal && ui.reload != nil {
+				ui.doReload()
+			}
 		}
 	}
 }
@@ -295,45 +1241,356 @@ type ioResp struct {
 	err error
 }
 
+// pendingRead represents a Read call on the underlying input Reader,
+// whose result hasn't been fully consumed yet. A read that's given up
+// on, e.g. due to a timeout, is kept pending so that the next read
+// picks up its result instead of losing it or starting another Read.
+//
+type pendingRead struct {
+	buf  []byte
+	ch   chan ioResp
+	resp ioResp
+	done bool
+	gen  int // see inputGen
+}
+
 // readAsync wraps a Read call and sends the result to the given channel
 //
-func (ui *UI) readAsync(b []byte, readCh chan ioResp) {
+func (ui *UI) readAsync(r io.Reader, b []byte, readCh chan ioResp) {
 	var resp ioResp
-	resp.n, resp.err = ui.i.Read(b)
+	resp.n, resp.err = r.Read(b)
+	readCh <- resp
+	close(readCh)
+}
+
+// read performs a single Read call on the underlying input Reader,
+// while monitoring the given context.
+//
+func (ui *UI) read(ctx context.Context, b []byte) (n int, err error) {
+	if ui.overQuota() {
+		return 0, ErrMaxBytes
+	}
+
+	p := ui.pendingRead
+	if p != nil && !p.done {
+		// A read on an input Reader replaced by SetIO is abandoned
+		if _, gen := ui.inputGen(); gen != p.gen {
+			p = nil
+		}
+	}
+	if p == nil {
+		if err = ui.waitResume(ctx, nil); err != nil {
+			return
+		}
+		in, gen := ui.inputGen()
+		if ui.syncReader(in) {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			n, err = in.Read(b)
+			ui.countRead(b[:n])
+			return
+		}
+		p = &pendingRead{
+			buf: make([]byte, len(b)),
+			ch:  make(chan ioResp, 1),
+			gen: gen,
+		}
+		ui.pendingRead = p
+		go ui.readAsync(in, p.buf, p.ch)
+	}
+
+	if !p.done {
+		cancel := ui.startCancelableRead()
+		defer ui.endCancelableRead(cancel)
+
+		for !p.done {
+			paused, _ := ui.pauseState()
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			case <-cancel:
+				err = ErrReadInterrupted
+				return
+			case p.resp = <-p.ch:
+				p.done = true
+			case <-paused:
+				if err = ui.waitResume(ctx, p); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	n = copy(b, p.buf[:p.resp.n])
+	p.buf = p.buf[n:]
+	p.resp.n -= n
+	if p.resp.n == 0 {
+		ui.pendingRead = nil
+		err = p.resp.err
+	}
+	ui.countRead(b[:n])
+	return
+}
+
+// countRead counts and transcribes the bytes just read.
+func (ui *UI) countRead(b []byte) {
+	atomic.AddInt64(&ui.nRead, int64(len(b)))
+	ui.transcribeInput(b)
+}
+
+// ErrReadInterrupted is returned by a read that was aborted by
+// CancelRead. It is recoverable and the input isn't lost, the next
+// read picks up where the interrupted one left off.
+//
+var ErrReadInterrupted = errors.New("sand: read interrupted")
+
+// CancelRead aborts the read currently blocked on the underlying
+// input Reader, if any, without cancelling the session, e.g. to
+// redraw the prompt after a notification was written. The read
+// returns ErrReadInterrupted and, if it was Run waiting on the next
+// line, the prompt is written again. It is safe to call from any
+// goroutine.
+//
+func (ui *UI) CancelRead() {
+	ui.readMu.Lock()
+	defer ui.readMu.Unlock()
+	if ui.readCancel == nil {
+		return
+	}
 	select {
-	case <-ui.ctx.Done():
-	case readCh <- resp:
+	case <-ui.readCancel:
+	default:
+		close(ui.readCancel)
 	}
-	close(readCh)
 }
 
+// startCancelableRead returns the channel closed by CancelRead
+// before the read, that is about to block, is done. A channel that
+// is already set, i.e. the read was armed beforehand, is reused.
+//
+func (ui *UI) startCancelableRead() chan struct{} {
+	ui.readMu.Lock()
+	defer ui.readMu.Unlock()
+	if ui.readCancel == nil {
+		ui.readCancel = make(chan struct{})
+	}
+	return ui.readCancel
+}
+
+// endCancelableRead forgets the channel of a finished read, so
+// a later CancelRead doesn't abort the next read instead.
+//
+func (ui *UI) endCancelableRead(cancel chan struct{}) {
+	ui.readMu.Lock()
+	defer ui.readMu.Unlock()
+	if ui.readCancel == cancel {
+		ui.readCancel = nil
+	}
+}
+
+// maxEmptyReads is the number of consecutive empty reads
+// tolerated before fill gives up with io.ErrNoProgress.
+const maxEmptyReads = 100
+
+// fill reads a new chunk of input into the internal buffer.
+// Any unconsumed bytes are kept, along with enough of the
+// consumed bytes to still allow UnreadByte and UnreadRune.
+//
+func (ui *UI) fill(ctx context.Context) {
+	keep := ui.rpos
+	if keep > utf8.UTFMax {
+		keep = utf8.UTFMax
+	}
+	rest := ui.rbuf[ui.rpos-keep:]
+
+	chunk := make([]byte, len(rest)+minRead)
+	copy(chunk, rest)
+
+	for empty := 0; empty < maxEmptyReads; {
+		n, err := ui.read(ctx, chunk[len(rest):])
+		data := chunk[len(rest) : len(rest)+n]
+		if n > 0 && ui.inFilter != nil {
+			data = ui.inFilter(data)
+		}
+		if len(data) > 0 || err != nil {
+			ui.rbuf = append(chunk[:len(rest)], data...)
+			ui.rpos = keep
+			ui.rerr = err
+			return
+		}
+		if n == 0 {
+			empty++
+		}
+	}
+	ui.rerr = io.ErrNoProgress
+}
+
+// readErr returns and clears the pending read error.
+func (ui *UI) readErr() error {
+	err := ui.rerr
+	ui.rerr = nil
+	return err
+}
+
+// buffered returns the number of bytes that can be read
+// without reading from the underlying input Reader.
+func (ui *UI) buffered() int { return len(ui.rbuf) - ui.rpos }
+
 // Read reads from the underlying input Reader.
 // This is a blocking call and handles monitoring
 // the current context. Thus, callers should handle
 // context errors appropriately. See examples for
 // such handling.
 //
+// Small reads are served from an internal buffer, so
+// wrapping the UI in a bufio.Reader or bufio.Scanner or
+// reading byte by byte does not cost a goroutine per call.
+//
 func (ui *UI) Read(b []byte) (n int, err error) {
-	readCh := make(chan ioResp, 1)
+	ui.lastRuneSize = 0
+	if len(b) == 0 {
+		if ui.buffered() > 0 {
+			return 0, nil
+		}
+		return 0, ui.readErr()
+	}
 
-	go ui.readAsync(b, readCh)
+	if ui.buffered() == 0 {
+		if ui.rerr != nil {
+			return 0, ui.readErr()
+		}
 
-	select {
-	case <-ui.ctx.Done():
-		err = ui.ctx.Err()
-		return
-	case resp := <-readCh:
-		n = resp.n
-		err = resp.err
+		// Large reads go straight to the underlying Reader
+		if len(b) >= minRead && ui.inFilter == nil {
+			ui.rbuf, ui.rpos = nil, 0
+			ui.canUnreadByte = false
+			return ui.read(ui.ctx, b)
+		}
+
+		ui.fill(ui.ctx)
+		if ui.buffered() == 0 {
+			return 0, ui.readErr()
+		}
 	}
+
+	n = copy(b, ui.rbuf[ui.rpos:])
+	ui.rpos += n
+	ui.canUnreadByte = true
 	return
 }
 
-// writeAsync wraps a Write call and send the result to the given channel
+// ReadByte reads and returns a single byte from the input.
+// It honors the current context the same way Read does.
 //
-func (ui *UI) writeAsync(b []byte, writeCh chan ioResp) {
-	var resp ioResp
-	resp.n, resp.err = ui.o.Write(b)
+func (ui *UI) ReadByte() (byte, error) {
+	ui.lastRuneSize = 0
+	for ui.buffered() == 0 {
+		if ui.rerr != nil {
+			return 0, ui.readErr()
+		}
+		ui.fill(ui.ctx)
+	}
+
+	c := ui.rbuf[ui.rpos]
+	ui.rpos++
+	ui.canUnreadByte = true
+	return c, nil
+}
+
+// UnreadByte unreads the last byte. Only the most
+// recently read byte can be unread.
+//
+func (ui *UI) UnreadByte() error {
+	if !ui.canUnreadByte || ui.rpos == 0 {
+		return bufio.ErrInvalidUnreadByte
+	}
+	ui.rpos--
+	ui.canUnreadByte = false
+	ui.lastRuneSize = 0
+	return nil
+}
+
+// ReadRune reads a single UTF-8 encoded rune from the input
+// and returns the rune and its size in bytes. If the encoded
+// rune is invalid, it consumes one byte and returns
+// unicode.ReplacementChar (U+FFFD) with a size of 1.
+// The same goes for a rune cut off by the end of the
+// input, so every byte of it is returned as U+FFFD,
+// followed by the error, instead of waiting for bytes
+// that will never come.
+//
+func (ui *UI) ReadRune() (r rune, size int, err error) {
+	for !utf8.FullRune(ui.rbuf[ui.rpos:]) && ui.rerr == nil {
+		ui.fill(ui.ctx)
+	}
+
+	ui.lastRuneSize = 0
+	if ui.buffered() == 0 {
+		return 0, 0, ui.readErr()
+	}
+
+	r, size = rune(ui.rbuf[ui.rpos]), 1
+	if r >= utf8.RuneSelf {
+		r, size = utf8.DecodeRune(ui.rbuf[ui.rpos:])
+	}
+	ui.rpos += size
+	ui.canUnreadByte = true
+	ui.lastRuneSize = size
+	return
+}
+
+// UnreadRune unreads the last rune. Only a rune
+// read by ReadRune can be unread.
+//
+func (ui *UI) UnreadRune() error {
+	if ui.lastRuneSize <= 0 || ui.rpos < ui.lastRuneSize {
+		return bufio.ErrInvalidUnreadRune
+	}
+	ui.rpos -= ui.lastRuneSize
+	ui.canUnreadByte = false
+	ui.lastRuneSize = 0
+	return nil
+}
+
+// ReadN reads exactly n bytes from the input, e.g. for engines
+// framing fixed-length records. It honors the current context the
+// same way Read does. If the input ends mid-record, the bytes read so
+// far are returned along with io.EOF. If the context is done, or the
+// read is interrupted by CancelRead, any partially read record is
+// kept buffered for the next read.
+//
+func (ui *UI) ReadN(n int) ([]byte, error) {
+	ui.lastRuneSize = 0
+	if n <= 0 {
+		return []byte{}, nil
+	}
+
+	for ui.buffered() < n && ui.rerr == nil {
+		ui.fill(ui.ctx)
+	}
+	if ui.buffered() < n && (isContextErr(ui.rerr) || ui.rerr == ErrReadInterrupted) {
+		return nil, ui.readErr()
+	}
+
+	m := n
+	if m > ui.buffered() {
+		m = ui.buffered()
+	}
+	b := append([]byte(nil), ui.rbuf[ui.rpos:ui.rpos+m]...)
+	ui.rpos += m
+	ui.canUnreadByte = m > 0
+	if m < n {
+		return b, ui.readErr()
+	}
+	return b, nil
+}
+
+// writeAsync wraps a Write call and send the result to the given channel.
+//
+func (ui *UI) writeAsync(w io.Writer, b []byte, writeCh chan ioResp) {
+	resp := ui.writeAll(w, b)
 	select {
 	case <-ui.ctx.Done():
 	case writeCh <- resp:
@@ -341,6 +1598,33 @@ func (ui *UI) writeAsync(b []byte, writeCh chan ioResp) {
 	close(writeCh)
 }
 
+// writeAll writes all of b to w. Short writes are retried until all
+// of b is written, since a Writer, e.g. a network connection, may
+// return one without an error.
+//
+func (ui *UI) writeAll(w io.Writer, b []byte) (resp ioResp) {
+	for {
+		var m int
+		m, resp.err = w.Write(b[resp.n:])
+		resp.n += m
+		atomic.AddInt64(&ui.nWritten, int64(m))
+		if resp.err != nil || resp.n >= len(b) {
+			break
+		}
+		if m == 0 {
+			resp.err = io.ErrShortWrite
+			break
+		}
+		if resp.err = ui.ctx.Err(); resp.err != nil {
+			break
+		}
+	}
+	if resp.n > 0 {
+		atomic.StoreInt32(&ui.lastByte, int32(b[resp.n-1]))
+	}
+	return
+}
+
 // Write writes the provided bytes to the UIs underlying
 // output along with the prefix characters.
 //
@@ -350,19 +1634,128 @@ func (ui *UI) writeAsync(b []byte, writeCh chan ioResp) {
 // cannot call SetPrefix + Write, simultaneously. See example
 // "tictactoe" for a demonstration of changing the prefix.
 //
+// The UI never buffers output itself, so Write returns once
+// the underlying Writer has returned. However, the underlying
+// Writer may buffer, see WriteNow for also flushing it.
+//
 func (ui *UI) Write(b []byte) (n int, err error) {
-	prefix := ui.prefix
-	if prefix == nil && b == nil { // skips writing empty prefix call in Run call
+	return ui.writeOutput(&ui.cmdOut, ui.wrap, b)
+}
+
+// writeOutput writes b as the output of a command, whose output so
+// far is counted by count and ends at the column of wrap, see
+// WithMaxOutputPerCommand and WithWordWrap.
+//
+func (ui *UI) writeOutput(count *int64, wrap *wordWrapper, b []byte) (n int, err error) {
+	limited, truncated := ui.limitOutput(count, b)
+	if truncated && limited == nil {
+		return len(b), nil
+	}
+	out, ok := ui.engineOutput(limited)
+	if !ok {
 		return
 	}
+	n, err = ui.write(ui.wrapOutput(wrap, out))
+	if truncated && err == nil {
+		n = len(b) // the output beyond the limit is discarded, not failed
+	}
+	return
+}
+
+// engineOutput returns what to write for an engine's Write call,
+// i.e. the prefix along with the filtered bytes.
+//
+func (ui *UI) engineOutput(b []byte) ([]byte, bool) {
+	prefix := ui.linePrefix()
+	if atomic.LoadInt32(&ui.noPrefix) > 0 || ui.script {
+		prefix = nil
+	}
+	if prefix == nil && b == nil { // skips writing empty prefix call in Run call
+		return nil, false
+	}
+	if ui.outFilter != nil && len(b) > 0 {
+		b = ui.outFilter(b)
+	}
+
+	// Never append to the prefix, which may have spare capacity shared
+	// by every Write
+	out := make([]byte, 0, len(prefix)+len(b))
+	out = append(out, prefix...)
+	return append(out, b...), true
+}
+
+// write writes the provided bytes, as is, to the UIs underlying
+// output, while monitoring the current context.
+//
+func (ui *UI) write(b []byte) (n int, err error) {
+	return ui.writeTo(ui.out, b)
+}
+
+// writePrompt writes the provided bytes, as is, to where the UI
+// writes its prompts, while monitoring the current context.
+//
+func (ui *UI) writePrompt(b []byte) (n int, err error) {
+	return ui.writeTo(ui.promptOut, b)
+}
+
+// writeTo writes the provided bytes to w, while monitoring the current
+// context. In raw mode, "\n" is written as "\r\n", see EnterRawMode.
+//
+func (ui *UI) writeTo(w io.Writer, b []byte) (n int, err error) {
+	if !ui.inRawMode() {
+		return ui.writeBytes(w, b)
+	}
+
+	out, added := rawNewlines(b)
+	n, err = ui.writeBytes(w, out)
+	if n == len(out) {
+		return len(b), err
+	}
+	// Which of the added "\r" were written is unknown, so undercount
+	if n -= added; n < 0 {
+		n = 0
+	}
+	return n, err
+}
+
+// writeBytes writes the provided bytes to w, as is, while monitoring the current context.
+func (ui *UI) writeBytes(w io.Writer, b []byte) (n int, err error) {
+	if ui.overQuota() {
+		return 0, ErrMaxBytes
+	}
+	if atomic.LoadInt32(&ui.writeStuck) == 1 {
+		return 0, ErrWriteTimeout
+	}
+	ui.hideSpinner()
+	ui.hideProgress()
+	ui.hideCountdown()
+
+	if ui.syncWriter(w) {
+		if err = ui.ctx.Err(); err != nil {
+			return
+		}
+		resp := ui.writeAll(w, b)
+		return resp.n, resp.err
+	}
+
+	var timeout <-chan time.Time
+	if ui.writeTimeout > 0 {
+		t := ui.clock().NewTimer(ui.writeTimeout)
+		defer t.Stop()
+		timeout = t.C()
+	}
 
 	writeCh := make(chan ioResp, 1)
-	go ui.writeAsync(append(prefix, b...), writeCh)
+	go ui.writeAsync(w, b, writeCh)
 
 	select {
 	case <-ui.ctx.Done():
 		err = ui.ctx.Err()
 		return
+	case <-timeout:
+		ui.abandonWrites()
+		err = ErrWriteTimeout
+		return
 	case resp := <-writeCh:
 		n = resp.n
 		err = resp.err
@@ -3,16 +3,23 @@
 package sand
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"reflect"
+	"regexp"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 // Code generated by MockGen. DO NOT EDIT.
@@ -207,6 +214,125 @@ func TestRunWithNoEngine(t *testing.T) {
 	ui.Run(nil, nil)
 }
 
+func TestRunWithReadPrefix(t *testing.T) {
+	in := &testLineReader{lines: []string{"hello\n", "sand\n"}}
+	var out bytes.Buffer
+
+	err := Run(nil, new(testEchoEngine), WithPrefix("| "), WithReadPrefix("$ "), WithIO(in, &out))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := "$ | hello\n$ | sand\n$ \n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
+
+func TestRunWithPrefixFunc(t *testing.T) {
+	in := &testLineReader{lines: []string{"hello\n", "sand\n"}}
+	var out bytes.Buffer
+
+	var n int
+	prompt := func() string {
+		n++
+		return fmt.Sprintf("db (%d)> ", n)
+	}
+	ui := new(UI)
+	err := ui.Run(nil, new(testEchoEngine), WithPrefix("| "), WithPrefixFunc(prompt), WithIO(in, &out))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := "db (1)> | hello\ndb (2)> | sand\ndb (3)> \n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+
+	ui.SetPrefixFunc(nil)
+	if p := string(ui.renderPrompt(context.Background())); p != "| " {
+		t.Errorf("expected %q but instead received: %q", "| ", p)
+	}
+}
+
+// testHandoffEngine echos lines, like testEchoEngine, and hands
+// the UI it's called with over to the test after its first Exec.
+type testHandoffEngine struct {
+	uis chan *UI
+}
+
+func (eng *testHandoffEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	_, err := ui.Write([]byte(line + "\n"))
+	select {
+	case eng.uis <- ui.(*UI):
+	default:
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// TestUI_SetIO swaps the I/O from another goroutine, while Run is
+// waiting on the first input, so it's meant to be run with -race.
+func TestUI_SetIO(t *testing.T) {
+	r1, w1 := io.Pipe()
+	defer w1.Close()
+	var out1, out2 bytes.Buffer
+
+	eng := &testHandoffEngine{uis: make(chan *UI, 1)}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(nil, eng, WithPrefix(">"), WithIO(r1, &out1))
+	}()
+
+	_, err := w1.Write([]byte("a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ui := <-eng.uis
+	ui.SetPrefix("$ ")
+	ui.SetIO(strings.NewReader("b\n"), &out2)
+
+	err = <-errCh
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	if !strings.HasPrefix(out1.String(), ">>a\n") || strings.Contains(out1.String(), "b") {
+		t.Errorf("expected only the output of a in %q", out1.String())
+	}
+	if !strings.Contains(out2.String(), "$ b\n") || strings.Contains(out2.String(), "a") {
+		t.Errorf("expected only the output of b in %q", out2.String())
+	}
+}
+
+func TestRunWithMissingIO(t *testing.T) {
+	testCases := []struct {
+		Name string
+		In   io.Reader
+		Out  io.Writer
+	}{
+		{Name: "OnlyInput", In: strings.NewReader("hello\n")},
+		{Name: "OnlyOutput", Out: new(bytes.Buffer)},
+		{Name: "Neither"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			eng := new(testEchoEngine)
+			err := Run(nil, eng, WithIO(tc.In, tc.Out))
+			if errors.Cause(err) != ErrMissingIO {
+				subT.Errorf("expected %v but instead received: %v", ErrMissingIO, err)
+			}
+			if eng.execs != 0 {
+				subT.Errorf("expected engine to not be called")
+			}
+		})
+	}
+}
+
 func TestRunWithSignalInterrupt(t *testing.T) {
 	go func() {
 		<-time.After(time.Second) // Give the UI a little time to start up
@@ -248,3 +374,814 @@ func TestRunWithSignalInterrupt(t *testing.T) {
 		t.Errorf("expected context.Canceled but instead received: %s", err)
 	}
 }
+
+func TestUI_ReadRune(t *testing.T) {
+	ui := &UI{
+		i:   bytes.NewReader([]byte("aé世\n")),
+		ctx: context.Background(),
+	}
+
+	for _, ex := range []rune{'a', 'é', '世', '\n'} {
+		r, size, err := ui.ReadRune()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != ex || size != utf8.RuneLen(ex) {
+			t.Errorf("expected %q but instead received: %q", ex, r)
+		}
+	}
+
+	if err := ui.UnreadRune(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ui.UnreadRune(); err != bufio.ErrInvalidUnreadRune {
+		t.Errorf("expected bufio.ErrInvalidUnreadRune but instead received: %v", err)
+	}
+
+	c, err := ui.ReadByte()
+	if err != nil || c != '\n' {
+		t.Errorf("expected newline after UnreadRune but instead received: %q, %v", c, err)
+	}
+
+	_, _, err = ui.ReadRune()
+	if err != io.EOF {
+		t.Errorf("expected io.EOF but instead received: %v", err)
+	}
+}
+
+func TestUI_ReadRuneTruncatedAtEOF(t *testing.T) {
+	// The last rune, 世, is cut off across two reads before EOF
+	ui := &UI{
+		i:   &testChunkReader{chunks: []testChunk{{s: "a\xe4"}, {s: "\xb8"}}},
+		ctx: context.Background(),
+	}
+
+	for _, ex := range []rune{'a', utf8.RuneError, utf8.RuneError} {
+		r, size, err := ui.ReadRune()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != ex || size != 1 {
+			t.Errorf("expected %q with size 1 but instead received: %q with size %d", ex, r, size)
+		}
+	}
+
+	_, _, err := ui.ReadRune()
+	if err != io.EOF {
+		t.Errorf("expected io.EOF but instead received: %v", err)
+	}
+}
+
+func TestUI_ReadN(t *testing.T) {
+	in := &testLineReader{lines: []string{"ab", "cdef", "gh"}}
+	ui := &UI{i: in, ctx: context.Background()}
+
+	for _, ex := range []string{"abc", "def"} {
+		b, err := ui.ReadN(3)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(b) != ex {
+			t.Errorf("expected %q but instead received: %q", ex, b)
+		}
+	}
+
+	b, err := ui.ReadN(3)
+	if err != io.EOF {
+		t.Errorf("expected io.EOF but instead received: %v", err)
+	}
+	if string(b) != "gh" {
+		t.Errorf("expected partial record %q but instead received: %q", "gh", b)
+	}
+}
+
+func TestUI_ReadNCanceled(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ui := &UI{i: pr, ctx: ctx}
+	go func() {
+		pw.Write([]byte("ab"))
+		cancel()
+	}()
+
+	if _, err := ui.ReadN(4); err != context.Canceled {
+		t.Errorf("expected %v but instead received: %v", context.Canceled, err)
+	}
+
+	// The partial record must not be lost
+	ui.ctx = context.Background()
+	go pw.Write([]byte("cd"))
+	b, err := ui.ReadN(4)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != "abcd" {
+		t.Errorf("expected %q but instead received: %q", "abcd", b)
+	}
+}
+
+func TestUI_ReadWithBufio(t *testing.T) {
+	lines := []string{"hello", "sand", strings.Repeat("x", 2*minRead)}
+
+	ui := &UI{
+		i:   strings.NewReader(strings.Join(lines, "\n")),
+		ctx: context.Background(),
+	}
+
+	r := bufio.NewReaderSize(ui, 16)
+	for _, ex := range lines {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if strings.TrimSuffix(line, "\n") != ex {
+			t.Errorf("expected %q but instead received: %q", ex, line)
+		}
+	}
+}
+
+// benchInput is a large input for scanning benchmarks
+var benchInput = bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 1<<12)
+
+func BenchmarkUI_ReadByte(b *testing.B) {
+	b.SetBytes(int64(len(benchInput)))
+	for i := 0; i < b.N; i++ {
+		ui := &UI{
+			i:   bytes.NewReader(benchInput),
+			ctx: context.Background(),
+		}
+
+		var err error
+		for err == nil {
+			_, err = ui.ReadByte()
+		}
+		if err != io.EOF {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUI_Scanner(b *testing.B) {
+	b.SetBytes(int64(len(benchInput)))
+	for i := 0; i < b.N; i++ {
+		ui := &UI{
+			i:   bytes.NewReader(benchInput),
+			ctx: context.Background(),
+		}
+
+		s := bufio.NewScanner(ui)
+		s.Split(bufio.ScanRunes)
+		for s.Scan() {
+		}
+		if err := s.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// testChunk is a single Read result for testChunkReader
+type testChunk struct {
+	s   string
+	err error
+}
+
+// testChunkReader returns the given chunks, one per Read call,
+// and then io.EOF forever. This mimics a terminal where EOF
+// doesn't necessarily mean the end of the input.
+type testChunkReader struct {
+	chunks []testChunk
+}
+
+func (r *testChunkReader) Read(b []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	c := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	return copy(b, c.s), c.err
+}
+
+func TestRunWithIgnoreEOF(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		N      int
+		Chunks []testChunk
+		ExOut  string
+	}{
+		{
+			Name:  "Disabled",
+			N:     0,
+			ExOut: ">\n",
+		},
+		{
+			Name:  "Three",
+			N:     3,
+			ExOut: ">\nUse Ctrl-D 2 more times to exit.\n>\nUse Ctrl-D again to exit.\n>\n",
+		},
+		{
+			Name: "ResetByInput",
+			N:    2,
+			Chunks: []testChunk{
+				{err: io.EOF},
+				{s: "hi"},
+			},
+			ExOut: ">\nUse Ctrl-D again to exit.\n>>hi\n>\nUse Ctrl-D again to exit.\n>\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testChunkReader{chunks: tc.Chunks}
+			var out bytes.Buffer
+
+			err := Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(in, &out), WithIgnoreEOF(tc.N))
+			var ok bool
+			if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.ExOut {
+				subT.Errorf("expected %q but instead received: %q", tc.ExOut, out.String())
+			}
+		})
+	}
+}
+
+func TestRunWithInputFilter(t *testing.T) {
+	in := &testLineReader{lines: []string{"hello\r\n", "\r", "world\r\n"}}
+	var out bytes.Buffer
+
+	stripCR := func(b []byte) []byte {
+		return bytes.Replace(b, []byte("\r"), nil, -1)
+	}
+
+	eng := new(testBlockEngine)
+	err := Run(nil, eng, WithIO(in, &out), WithInputFilter(stripCR))
+	var ok bool
+	if err, ok = IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	// A chunk filtered down to nothing must not end the session
+	ex := []string{"hello", "hello\nworld"}
+	if !reflect.DeepEqual(eng.lines, ex) {
+		t.Errorf("expected Exec calls %q but instead received: %q", ex, eng.lines)
+	}
+}
+
+func TestRunWithStripCR(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Strip bool
+		Ex    []string
+	}{
+		{Name: "Strip", Strip: true, Ex: []string{"a", "a\nb;"}},
+		{Name: "Keep", Strip: false, Ex: []string{"a\r", "a\r\nb;\r"}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: []string{"a\r\n", "b;\r\n"}}
+			eng := new(testBlockEngine)
+
+			err := Run(nil, eng, WithIO(in, ioutil.Discard), WithStripCR(tc.Strip))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if !reflect.DeepEqual(eng.lines, tc.Ex) {
+				subT.Errorf("expected Exec calls %q but instead received: %q", tc.Ex, eng.lines)
+			}
+		})
+	}
+}
+
+func TestRunWithoutPanicRecovery(t *testing.T) {
+	errPanic := errors.New("filter panic")
+	filter := func(b []byte) []byte { panic(errPanic) }
+
+	t.Run("Recovered", func(subT *testing.T) {
+		err := Run(nil, new(testEchoEngine), WithIO(strings.NewReader("a\n"), ioutil.Discard), WithInputFilter(filter))
+		if errors.Cause(err) != errPanic {
+			subT.Errorf("expected recovered panic but instead received: %v", err)
+		}
+	})
+
+	t.Run("Propagated", func(subT *testing.T) {
+		defer func() {
+			if r := recover(); r != errPanic {
+				subT.Errorf("expected panic to propagate but instead received: %v", r)
+			}
+		}()
+
+		Run(nil, new(testEchoEngine), WithIO(strings.NewReader("a\n"), ioutil.Discard), WithInputFilter(filter), WithoutPanicRecovery())
+		subT.Errorf("expected Run to panic")
+	})
+}
+
+func TestRunPanicError(t *testing.T) {
+	filter := func(b []byte) []byte { panic("boom") }
+	err := Run(nil, new(testEchoEngine), WithIO(strings.NewReader("a\n"), ioutil.Discard), WithInputFilter(filter))
+
+	perr, ok := err.(*PanicError)
+	if !ok {
+		t.Fatalf("expected a PanicError but instead received: %v", err)
+	}
+	if perr.Value != "boom" {
+		t.Errorf("expected panic value %q but instead received: %v", "boom", perr.Value)
+	}
+	if !bytes.Contains(perr.Stack, []byte("TestRunPanicError")) {
+		t.Errorf("expected stack to include the panic site but instead received: %s", perr.Stack)
+	}
+
+	s := fmt.Sprintf("%+v", err)
+	if !strings.HasPrefix(s, "sand: recovered from panic: boom\n") || len(s) <= len(err.Error())+1 {
+		t.Errorf("expected %%+v to include the stack but instead received: %s", s)
+	}
+	if s = fmt.Sprint(err); s != "sand: recovered from panic: boom" {
+		t.Errorf("expected message without stack but instead received: %s", s)
+	}
+}
+
+func TestRunRestoresTerminal(t *testing.T) {
+	restored := make(chan struct{}, 2)
+	inRaw := func(ui *UI) {
+		ui.termMu.Lock()
+		defer ui.termMu.Unlock()
+		ui.termRestore = func() error {
+			restored <- struct{}{}
+			return nil
+		}
+	}
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	// Re-route SIGHUP to SIGTERM, which must restore without ending the session
+	ui := new(UI)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ui.Run(nil, new(testEchoEngine), WithIO(pr, ioutil.Discard), inRaw,
+			WithSignalHandlers(map[os.Signal]SignalHandler{
+				syscall.SIGHUP: func(os.Signal) os.Signal { return syscall.SIGTERM },
+			}),
+		)
+	}()
+
+	time.Sleep(100 * time.Millisecond) // Give the UI a little time to start up
+	syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+	select {
+	case <-restored:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected terminal to be restored on SIGTERM")
+	}
+
+	// Back in raw mode, Run must restore before returning
+	inRaw(ui)
+	pw.Close()
+	<-errCh
+	select {
+	case <-restored:
+	default:
+		t.Error("expected terminal to be restored by Run")
+	}
+}
+
+func TestRun_Lines(t *testing.T) {
+	long := strings.Repeat("x", 3*minRead)
+	testCases := []struct {
+		Name string
+		In   string
+		Ex   []string
+	}{
+		{Name: "Long", In: long + "\n", Ex: []string{long}},
+		{Name: "Multiple", In: "a\nb\r\nc", Ex: []string{"a", "b", "c"}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			eng := new(testRecordEngine)
+			err := Run(nil, eng, WithIO(strings.NewReader(tc.In), ioutil.Discard))
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if !reflect.DeepEqual(eng.lines, tc.Ex) {
+				subT.Errorf("expected Exec calls %q but instead received: %q", tc.Ex, eng.lines)
+			}
+		})
+	}
+}
+
+func TestRunWithAutoNewline(t *testing.T) {
+	// Unlike testEchoEngine, the line is written without a newline
+	echo := EngineFunc(func(ctx context.Context, line string, ui io.ReadWriter) int {
+		ui.Write([]byte(line))
+		return 0
+	})
+
+	testCases := []struct {
+		Name string
+		Opts []Option
+		Ex   string
+	}{
+		{Name: "Without", Ex: ">>a>>b>\n"},
+		{Name: "With", Opts: []Option{WithAutoNewline()}, Ex: ">>a\n>>b\n>\n"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: []string{"a\n", "b\n"}}
+			var out bytes.Buffer
+
+			opts := append([]Option{WithPrefix(">"), WithIO(in, &out)}, tc.Opts...)
+			err := Run(nil, echo, opts...)
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestRunWithFinalNewline(t *testing.T) {
+	testCases := []struct {
+		Name string
+		Opts []Option
+		Ex   string
+	}{
+		{Name: "Default", Ex: ">>a\n>\n"},
+		{Name: "Enabled", Opts: []Option{WithFinalNewline(true)}, Ex: ">>a\n>\n"},
+		{Name: "Disabled", Opts: []Option{WithFinalNewline(false)}, Ex: ">>a\n>"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: []string{"a\n"}}
+			var out bytes.Buffer
+
+			opts := append([]Option{WithPrefix(">"), WithIO(in, &out)}, tc.Opts...)
+			err := Run(nil, new(testEchoEngine), opts...)
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestRunWithPromptToStderr(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	in := &testLineReader{lines: []string{"a\n", "b\n"}}
+	var stdout bytes.Buffer
+	err = Run(nil, new(testEchoEngine), WithIO(in, &stdout), WithPrefix(">"), WithPromptToStderr())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	w.Close()
+
+	prompts, _ := ioutil.ReadAll(r)
+	if string(prompts) != ">>>\n" {
+		t.Errorf("expected prompts %q on stderr but instead received: %q", ">>>\n", prompts)
+	}
+	if stdout.String() != ">a\n>b\n" {
+		t.Errorf("expected only engine output %q but instead received: %q", ">a\n>b\n", stdout.String())
+	}
+}
+
+func TestRunWithHandledSignals(t *testing.T) {
+	// Keep SIGUSR2 from terminating the test, while the UI ignores it
+	ignored := make(chan os.Signal, 1)
+	signal.Notify(ignored, syscall.SIGUSR2)
+	defer signal.Stop(ignored)
+
+	handled := make(chan os.Signal, 2)
+	record := func(sig os.Signal) os.Signal {
+		handled <- sig
+		return sig
+	}
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Run(nil, new(testEchoEngine), WithIO(pr, ioutil.Discard),
+			WithHandledSignals(syscall.SIGUSR1),
+			WithSignalHandlers(map[os.Signal]SignalHandler{
+				syscall.SIGUSR1: record,
+				syscall.SIGUSR2: record,
+			}),
+		)
+	}()
+
+	time.Sleep(100 * time.Millisecond) // Give the UI a little time to start up
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	<-ignored
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+
+	select {
+	case sig := <-handled:
+		if sig != syscall.SIGUSR1 {
+			t.Errorf("expected only %s to be handled but instead received: %s", syscall.SIGUSR1, sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected SIGUSR1 to be handled")
+	}
+
+	pw.Close()
+	<-errCh
+	select {
+	case sig := <-handled:
+		t.Errorf("expected unregistered signal to not be delivered but instead received: %s", sig)
+	default:
+	}
+}
+
+func TestRunWithOutputFilter(t *testing.T) {
+	secret := regexp.MustCompile(`token=\w+`)
+	redact := func(b []byte) []byte {
+		return secret.ReplaceAll(b, []byte("token=****"))
+	}
+
+	in := &testLineReader{lines: []string{"login token=hunter2\n"}}
+	var out, tee bytes.Buffer
+	err := Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(in, &out), WithTee(&tee), WithOutputFilter(redact))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := ">>login token=****\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if tee.String() != ex {
+		t.Errorf("expected tee to receive filtered output %q but instead received: %q", ex, tee.String())
+	}
+}
+
+func TestUI_SuppressPrefix(t *testing.T) {
+	var out bytes.Buffer
+	ui := &UI{prefix: []byte(">"), ctx: context.Background()}
+	ui.out = &out
+
+	func() {
+		defer func() { recover() }()
+		ui.SuppressPrefix(func() {
+			ui.Write([]byte("board\n"))
+			panic("render failed")
+		})
+	}()
+	ui.Write([]byte("after\n"))
+
+	if ex := "board\n>after\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if string(ui.prefix) != ">" {
+		t.Errorf("expected prefix to be untouched but instead received: %q", ui.prefix)
+	}
+}
+
+// testRetainWriter retains the slices written to it, without copying them.
+type testRetainWriter struct {
+	writes [][]byte
+}
+
+func (w *testRetainWriter) Write(b []byte) (int, error) {
+	w.writes = append(w.writes, b)
+	return len(b), nil
+}
+
+func TestUI_Write_Prefix(t *testing.T) {
+	var out testRetainWriter
+	ui := &UI{ctx: context.Background()}
+	ui.out = &out
+
+	var ex []string
+	for _, prefix := range []string{"> long prefix ", "> ", ">"} {
+		ui.SetPrefix(prefix)
+		// Leave spare capacity, the same as after growing the prefix
+		ui.prefix = append(make([]byte, 0, 64), ui.prefix...)
+
+		for _, body := range []string{"ls\n", "a much longer body\n", "x\n"} {
+			ui.Write([]byte(body))
+			ex = append(ex, prefix+body)
+			if p := string(ui.linePrefix()); p != prefix {
+				t.Errorf("expected prefix %q but instead received: %q", prefix, p)
+			}
+		}
+	}
+
+	var writes []string
+	for _, b := range out.writes {
+		writes = append(writes, string(b))
+	}
+	if !reflect.DeepEqual(writes, ex) {
+		t.Errorf("expected %q but instead received: %q", ex, writes)
+	}
+}
+
+func TestRunWithEchoInput(t *testing.T) {
+	in := &testLineReader{lines: []string{"a\n", "b"}}
+	var out bytes.Buffer
+
+	err := Run(nil, new(testEchoEngine), WithPrefix("> "), WithIO(in, &out), WithEchoInput())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := "> a\n> a\n> b\n> b\n> \n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
+
+func TestRunWithInteractivePromptOnly(t *testing.T) {
+	testCases := []struct {
+		Name string
+		TTY  bool
+		Ex   string
+	}{
+		{Name: "NotTerminal", Ex: "> a\n> b\n"},
+		{Name: "Terminal", TTY: true, Ex: "> > a\n> > b\n> \n"},
+	}
+
+	defer func(f func(interface{}) bool) { isTerminal = f }(isTerminal)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			isTerminal = func(interface{}) bool { return tc.TTY }
+
+			in := &testLineReader{lines: []string{"a\n", "b\n"}}
+			var out bytes.Buffer
+			err := Run(nil, new(testEchoEngine), WithPrefix("> "), WithIO(in, &out), WithTheme(MonochromeTheme), WithInteractivePromptOnly())
+			if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+				subT.Error(err)
+			}
+
+			if out.String() != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out.String())
+			}
+		})
+	}
+}
+
+func TestRunRedrawsPromptOnCancelRead(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	var out bytes.Buffer
+
+	ui := new(UI)
+	ui.SetPrefix(">")
+	ui.SetIO(pr, &out)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- ui.Run(nil, new(testEchoEngine)) }()
+
+	// waitForRead waits for a read other than the one on prev
+	waitForRead := func(prev chan struct{}) chan struct{} {
+		for {
+			ui.readMu.Lock()
+			cancel := ui.readCancel
+			ui.readMu.Unlock()
+			if cancel != nil && cancel != prev {
+				return cancel
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	cancel := waitForRead(nil)
+	ui.CancelRead()
+	waitForRead(cancel)
+	pw.Close()
+
+	err := <-errCh
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if ex := ">>\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
+
+func TestUI_RenderPrompt(t *testing.T) {
+	deadline, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+
+	testCases := []struct {
+		Name  string
+		Ctx   context.Context
+		Opts  []Option
+		Width int
+		Ex    string
+	}{
+		{Name: "Empty", Ex: ""},
+		{Name: "Static", Opts: []Option{WithPrefix("> ")}, Ex: "> "},
+		{Name: "Themed", Opts: []Option{WithPrefix("> "), WithTheme(DefaultTheme)}, Ex: "\x1b[1;32m> \x1b[0m"},
+		{Name: "ReadPrefix", Opts: []Option{WithPrefix("| "), WithReadPrefix("$ ")}, Ex: "$ "},
+		{Name: "EmptyReadPrefix", Opts: []Option{WithPrefix("| "), WithReadPrefix("")}, Ex: ""},
+		{Name: "PrefixFunc", Opts: []Option{WithPrefix("| "), WithReadPrefix("$ "), WithPrefixFunc(func() string { return "db> " })}, Ex: "db> "},
+		{Name: "NilPrefixFunc", Opts: []Option{WithPrefix("> "), WithPrefixFunc(nil)}, Ex: "> "},
+		{Name: "Countdown", Ctx: deadline, Opts: []Option{WithPrefix("> "), WithCountdown()}, Ex: "[2m left] > "},
+		{Name: "NoDeadline", Opts: []Option{WithPrefix("> "), WithCountdown()}, Ex: "> "},
+		{
+			Name:  "RightPrompt",
+			Opts:  []Option{WithPrefix("> "), WithRightPrompt(func() string { return "ok" })},
+			Width: 10,
+			Ex:    "> \x1b7" + strings.Repeat(" ", 5) + "ok\x1b8",
+		},
+	}
+
+	defer func(f func(interface{}) int) { termWidth = f }(termWidth)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			termWidth = func(interface{}) int { return tc.Width }
+
+			ui := new(UI)
+			for _, opt := range tc.Opts {
+				opt(ui)
+			}
+			ctx := tc.Ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			if prompt := string(ui.renderPrompt(ctx)); prompt != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, prompt)
+			}
+		})
+	}
+}
+
+// testShortWriter writes at most one byte per call, without an error.
+type testShortWriter struct {
+	bytes.Buffer
+}
+
+func (w *testShortWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return w.Buffer.Write(b[:1])
+}
+
+func TestRun_ShortWrites(t *testing.T) {
+	in := &testLineReader{lines: []string{"hello\n", "sand\n"}}
+	out := new(testShortWriter)
+
+	err := Run(nil, new(testEchoEngine), WithPrefix(">"), WithIO(in, out))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	if ex := ">>hello\n>>sand\n>\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
+
+// testFailAfterWriter fails every Write after the first n.
+type testFailAfterWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *testFailAfterWriter) Write(b []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errTestWrite
+	}
+	w.n--
+	return w.Buffer.Write(b)
+}
+
+func TestRunWithEchoInput_WriteError(t *testing.T) {
+	in := &testLineReader{lines: []string{"a\n", "b\n"}}
+	eng := new(testEchoEngine)
+	out := &testFailAfterWriter{n: 1}
+
+	err := Run(nil, eng, WithPrefix("> "), WithIO(in, out), WithEchoInput())
+	if errors.Cause(err) != errTestWrite {
+		t.Fatalf("expected write error but instead received: %v", err)
+	}
+	if out.String() != "> " {
+		t.Errorf("expected only the prefix but instead received: %q", out.String())
+	}
+	if eng.execs != 0 {
+		t.Errorf("expected no commands to be executed but instead executed: %d", eng.execs)
+	}
+}
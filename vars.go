@@ -0,0 +1,112 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// varStore is the per session key-value store, see Set and Get.
+type varStore struct {
+	sync.RWMutex
+	vars map[string]string
+}
+
+// StatusVar is the session variable holding the status of the most
+// recent command, like a shell's $?, e.g. for showing it in the right
+// prompt, see WithRightPrompt, or expanding it, see WithVarExpansion.
+// Since a non-zero status ends Run, it's mostly of interest to a UI
+// which is run again, e.g. after the Engine failed, whose prompt can
+// then tell the user the previous session failed. Incomplete lines,
+// see StatusNeedMore, don't update it, and "env" doesn't list it.
+//
+const StatusVar = "status"
+
+// Set sets the session variable key to value. Session variables
+// are meant for configuring engines from the interpreter itself,
+// see WithVarBuiltins. It is safe to call concurrently.
+//
+func (ui *UI) Set(key, value string) {
+	ui.vars.Lock()
+	defer ui.vars.Unlock()
+	if ui.vars.vars == nil {
+		ui.vars.vars = make(map[string]string)
+	}
+	ui.vars.vars[key] = value
+}
+
+// Get returns the session variable key, if it is set. It is safe
+// to call concurrently.
+//
+func (ui *UI) Get(key string) (value string, ok bool) {
+	ui.vars.RLock()
+	defer ui.vars.RUnlock()
+	value, ok = ui.vars.vars[key]
+	return
+}
+
+// Unset removes the session variable key. It is safe to call concurrently.
+//
+func (ui *UI) Unset(key string) {
+	ui.vars.Lock()
+	delete(ui.vars.vars, key)
+	ui.vars.Unlock()
+}
+
+// WithVarBuiltins installs builtins for managing the session
+// variables: "set KEY VALUE" or "set KEY=VALUE" sets a variable,
+// "unset KEY..." removes variables and "env" lists them all, except
+// for StatusVar.
+//
+func WithVarBuiltins() Option {
+	return func(ui *UI) {
+		ui.addBuiltin("set", "set a session variable, set KEY VALUE", setBuiltin)
+		ui.addBuiltin("unset", "remove session variables, unset KEY...", unsetBuiltin)
+		ui.addBuiltin("env", "list session variables", envBuiltin)
+	}
+}
+
+func setBuiltin(ctx context.Context, args []string, ui *UI) int {
+	if len(args) == 0 {
+		return envBuiltin(ctx, args, ui)
+	}
+
+	key, value := args[0], strings.Join(args[1:], " ")
+	if i := strings.IndexByte(key, '='); i > 0 && len(args) == 1 {
+		key, value = key[:i], key[i+1:]
+	}
+	ui.Set(key, value)
+	return 0
+}
+
+func unsetBuiltin(ctx context.Context, args []string, ui *UI) int {
+	for _, key := range args {
+		ui.Unset(key)
+	}
+	return 0
+}
+
+func envBuiltin(ctx context.Context, args []string, ui *UI) int {
+	ui.vars.RLock()
+	lines := make([]string, 0, len(ui.vars.vars))
+	for key, value := range ui.vars.vars {
+		if key == StatusVar {
+			continue
+		}
+		lines = append(lines, key+"="+value)
+	}
+	ui.vars.RUnlock()
+	sort.Strings(lines)
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := ui.write(buf.Bytes()); err != nil {
+		return 1
+	}
+	return 0
+}
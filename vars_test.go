@@ -0,0 +1,115 @@
+package sand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUI_SetGet(t *testing.T) {
+	ui := new(UI)
+	if _, ok := ui.Get("x"); ok {
+		t.Errorf("expected unset variable")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ui.Set("x", "1")
+			ui.Get("x")
+		}()
+	}
+	wg.Wait()
+
+	if v, ok := ui.Get("x"); !ok || v != "1" {
+		t.Errorf("expected %q but instead received: %q", "1", v)
+	}
+	ui.Unset("x")
+	if _, ok := ui.Get("x"); ok {
+		t.Errorf("expected variable to be unset")
+	}
+}
+
+func TestWithVarBuiltins(t *testing.T) {
+	in := &testLineReader{lines: []string{"set b two words\n", "set a=1\n", "env\n", "unset b\n", "env\n"}}
+	var out bytes.Buffer
+
+	eng := new(testEchoEngine)
+	err := Run(nil, eng, WithPrefix(">"), WithIO(in, &out), WithVarBuiltins())
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+
+	ex := ">>>a=1\nb=two words\n>>a=1\n>\n"
+	if out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+	if eng.execs != 0 {
+		t.Errorf("expected builtins to not be dispatched to engine")
+	}
+}
+
+// testStatusEngine fails with status 3 for "fail" and echos anything else.
+type testStatusEngine struct {
+	execs int
+}
+
+func (eng *testStatusEngine) Exec(ctx context.Context, line string, ui io.ReadWriter) int {
+	eng.execs++
+	if strings.TrimSpace(line) == "fail" {
+		return 3
+	}
+	ui.Write([]byte(line + "\n"))
+	return 0
+}
+
+func TestRun_StatusVar(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Lines  []string
+		Status string
+		Out    string
+	}{
+		{Name: "Success", Lines: []string{"a\n"}, Status: "0", Out: "a\n\n"},
+		{Name: "Failure", Lines: []string{"a\n", "fail\n"}, Status: "3", Out: "a\n\n"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(subT *testing.T) {
+			in := &testLineReader{lines: testCase.Lines}
+			var out bytes.Buffer
+
+			ui := new(UI)
+			ui.Run(nil, new(testStatusEngine), WithIO(in, &out), WithVarExpansion(VarExpansion{}))
+			if v, _ := ui.Get(StatusVar); v != testCase.Status {
+				subT.Errorf("expected status %q but instead received: %q", testCase.Status, v)
+			}
+			if out.String() != testCase.Out {
+				subT.Errorf("expected %q but instead received: %q", testCase.Out, out.String())
+			}
+		})
+	}
+}
+
+func TestRun_StatusVarNextRun(t *testing.T) {
+	ui := new(UI)
+	eng := new(testStatusEngine)
+	ui.Run(nil, eng, WithIO(&testLineReader{lines: []string{"fail\n"}}, ioutil.Discard))
+
+	// The next session sees the status the previous one ended with
+	var out bytes.Buffer
+	in := &testLineReader{lines: []string{"echo $status\n"}}
+	err := ui.Run(nil, eng, WithIO(in, &out), WithVarExpansion(VarExpansion{}))
+	if err, ok := IsRecoverable(err); !ok || err != nil && err != io.EOF {
+		t.Error(err)
+	}
+	if ex := "echo 3\n\n"; out.String() != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out.String())
+	}
+}
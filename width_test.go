@@ -0,0 +1,59 @@
+package sand
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	testCases := []struct {
+		Name string
+		S    string
+		W    int
+	}{
+		{Name: "ASCII", S: "hello", W: 5},
+		{Name: "Empty", S: "", W: 0},
+		{Name: "Colored", S: "\x1b[31mred\x1b[0m", W: 3},
+		{Name: "Bold256", S: "\x1b[1;38;5;208mhi\x1b[m", W: 2},
+		{Name: "OSC", S: "\x1b]0;title\ahi", W: 2},
+		{Name: "CJK", S: "世界", W: 4},
+		{Name: "Hangul", S: "한국어", W: 6},
+		{Name: "ColoredCJK", S: "\x1b[32m日本\x1b[0m!", W: 5},
+		{Name: "Combining", S: "é", W: 1},
+		{Name: "Fullwidth", S: "ＡＢ", W: 4},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			if w := displayWidth(tc.S); w != tc.W {
+				subT.Errorf("expected width %d but instead received: %d", tc.W, w)
+			}
+		})
+	}
+}
+
+func TestWriteTableAlignment(t *testing.T) {
+	rows := [][]string{
+		{"\x1b[1mname\x1b[0m", "desc"},
+		{"世界", "cjk"},
+		{"ab", "ascii"},
+	}
+
+	var buf bytes.Buffer
+	writeTable(&buf, rows)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(rows) {
+		t.Fatalf("expected %d lines but instead received: %d", len(rows), len(lines))
+	}
+
+	// The second column must start at the same display column on every line
+	for i, line := range lines {
+		col := displayWidth(strings.TrimSuffix(line, rows[i][1]))
+		if col != 6 {
+			t.Errorf("expected second column at 6 on line %d but instead received: %d (%q)", i, col, line)
+		}
+	}
+}
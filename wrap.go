@@ -0,0 +1,149 @@
+package sand
+
+import (
+	"bytes"
+	"sync"
+	"unicode/utf8"
+)
+
+// WithWordWrap wraps the output of engines at the width of the
+// terminal, on word boundaries, instead of leaving it to the terminal
+// to break lines in the middle of words. Words longer than a line
+// are broken wherever the line ends. ANSI escape sequences take up no
+// columns and wide runes take up two. Output which isn't written to a
+// terminal is left as is.
+//
+func WithWordWrap() Option {
+	return func(ui *UI) {
+		ui.wrap = new(wordWrapper)
+	}
+}
+
+// wordWrapper keeps track of the column output ends at, so words
+// written by separate Write calls are wrapped as a whole.
+type wordWrapper struct {
+	sync.Mutex
+	col int
+}
+
+// wrapOutput returns b wrapped at the width of the output terminal,
+// continuing from the column w is at, see WithWordWrap, or b itself
+// if it needn't be wrapped.
+//
+func (ui *UI) wrapOutput(w *wordWrapper, b []byte) []byte {
+	if w == nil || len(b) == 0 {
+		return b
+	}
+	width := termWidth(ui.output())
+	if width <= 0 {
+		return b
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	return w.wrap(b, width)
+}
+
+// resetWrap starts wrapping from the first column, e.g. once the
+// user has pressed enter after typing the next command.
+//
+func (ui *UI) resetWrap() {
+	if ui.wrap == nil {
+		return
+	}
+	ui.wrap.Lock()
+	ui.wrap.col = 0
+	ui.wrap.Unlock()
+}
+
+// wrap breaks lines of b, which would otherwise exceed width, at the
+// last space before the word not fitting, dropping the space.
+//
+func (w *wordWrapper) wrap(b []byte, width int) []byte {
+	var buf bytes.Buffer
+	var spaces []byte
+	for i := 0; i < len(b); {
+		switch c := b[i]; c {
+		case '\n', '\r':
+			buf.Write(spaces)
+			buf.WriteByte(c)
+			spaces = spaces[:0]
+			w.col = 0
+			i++
+			continue
+		case ' ', '\t':
+			spaces = append(spaces, c)
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(b) && !isWrapSpace(b[j]) {
+			if b[j] == 0x1B {
+				j += skipEscape(string(b[j:]))
+				continue
+			}
+			j++
+		}
+		word := b[i:j]
+		i = j
+
+		sw := spacesWidth(spaces, w.col)
+		if w.col > 0 && w.col+sw+displayWidth(string(word)) > width {
+			buf.WriteByte('\n')
+			w.col = 0
+		} else {
+			buf.Write(spaces)
+			w.col += sw
+		}
+		spaces = spaces[:0]
+		w.writeWord(&buf, word, width)
+	}
+	buf.Write(spaces)
+	w.col += spacesWidth(spaces, w.col)
+	return buf.Bytes()
+}
+
+// writeWord writes word, breaking it wherever the line ends, which
+// only happens for a word longer than a line.
+//
+func (w *wordWrapper) writeWord(buf *bytes.Buffer, word []byte, width int) {
+	for i := 0; i < len(word); {
+		if word[i] == 0x1B {
+			n := skipEscape(string(word[i:]))
+			buf.Write(word[i : i+n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRune(word[i:])
+		rw := runeWidth(r)
+		if w.col > 0 && w.col+rw > width {
+			buf.WriteByte('\n')
+			w.col = 0
+		}
+		buf.Write(word[i : i+size])
+		w.col += rw
+		i += size
+	}
+}
+
+// isWrapSpace reports whether c separates words, see WithWordWrap.
+func isWrapSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// spacesWidth returns the number of columns spaces take up, starting
+// at col, with tabs stopping at every eighth column.
+//
+func spacesWidth(spaces []byte, col int) int {
+	w := 0
+	for _, c := range spaces {
+		if c == '\t' {
+			w += 8 - (col+w)%8
+			continue
+		}
+		w++
+	}
+	return w
+}
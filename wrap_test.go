@@ -0,0 +1,45 @@
+package sand
+
+import (
+	"testing"
+)
+
+func TestWithWordWrap(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Width int
+		In    string
+		Ex    string
+	}{
+		{Name: "NotTerminal", Width: 0, In: "the quick brown fox\n", Ex: ">>the quick brown fox\n>\n"},
+		{Name: "Fits", Width: 20, In: "the quick brown fox\n", Ex: ">>the quick brown fox\n>\n"},
+		{Name: "Words", Width: 10, In: "the quick brown fox\n", Ex: ">>the quick\nbrown fox\n>\n"},
+		{Name: "LongWord", Width: 6, In: "ab abcdefghij\n", Ex: ">>ab\nabcdef\nghij\n>\n"},
+		{Name: "ANSI", Width: 10, In: "\x1b[1mthe\x1b[0m quick brown\n", Ex: ">>\x1b[1mthe\x1b[0m quick\nbrown\n>\n"},
+		{Name: "Wide", Width: 7, In: "世界 世界\n", Ex: ">>世界\n世界\n>\n"},
+		{Name: "Newlines", Width: 8, In: "one two\nthree four\n", Ex: ">>one two\n>>three\nfour\n>\n"},
+	}
+
+	defer func(f func(interface{}) int) { termWidth = f }(termWidth)
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.Name, func(subT *testing.T) {
+			termWidth = func(interface{}) int { return tc.Width }
+
+			out := runBuiltinTest(subT, new(testEchoEngine), tc.In, WithWordWrap())
+			if out != tc.Ex {
+				subT.Errorf("expected %q but instead received: %q", tc.Ex, out)
+			}
+		})
+	}
+}
+
+func TestWordWrapper_AcrossWrites(t *testing.T) {
+	w := new(wordWrapper)
+	out := string(w.wrap([]byte("one two "), 10))
+	out += string(w.wrap([]byte("three\n"), 10))
+
+	if ex := "one two \nthree\n"; out != ex {
+		t.Errorf("expected %q but instead received: %q", ex, out)
+	}
+}
@@ -0,0 +1,48 @@
+package sand
+
+import (
+	"github.com/pkg/errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriteTimeout is returned by a write which didn't complete within
+// the write timeout, see WithWriteTimeout, and by Run, which then ends
+// the session.
+//
+var ErrWriteTimeout = errors.New("sand: write timed out")
+
+// WithWriteTimeout gives up on a write to the output, or the prompt
+// Writer, which doesn't complete within d, e.g. to a stuck client
+// of a network session, and treats it as a disconnect: the write, and
+// every write after it, fails with ErrWriteTimeout and Run ends the
+// session with it once the current command is done.
+//
+// If the Writer has a SetWriteDeadline method, e.g. a net.Conn, the
+// deadline is set to the past when a write times out, so the blocked
+// Write returns instead of being left running.
+//
+func WithWriteTimeout(d time.Duration) Option {
+	return func(ui *UI) {
+		ui.writeTimeout = d
+	}
+}
+
+// deadlineWriter is implemented by Writers whose blocked writes can be
+// interrupted, e.g. net.Conn.
+//
+type deadlineWriter interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// abandonWrites fails every further write, after one timed out, and
+// interrupts any blocked writes to the output and prompt Writers.
+//
+func (ui *UI) abandonWrites() {
+	atomic.StoreInt32(&ui.writeStuck, 1)
+	for _, w := range []interface{}{ui.output(), ui.promptW} {
+		if dw, ok := w.(deadlineWriter); ok {
+			dw.SetWriteDeadline(time.Now())
+		}
+	}
+}
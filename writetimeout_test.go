@@ -0,0 +1,50 @@
+package sand
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStuckWriter blocks every Write until its write deadline is set.
+type testStuckWriter struct {
+	once     sync.Once
+	deadline chan struct{}
+}
+
+func (w *testStuckWriter) Write(b []byte) (int, error) {
+	<-w.deadline
+	return 0, errors.New("i/o timeout")
+}
+
+func (w *testStuckWriter) SetWriteDeadline(t time.Time) error {
+	w.once.Do(func() { close(w.deadline) })
+	return nil
+}
+
+func TestWithWriteTimeout(t *testing.T) {
+	w := &testStuckWriter{deadline: make(chan struct{})}
+	in := strings.NewReader("hello\nworld\n")
+
+	eng := new(testEchoEngine)
+	start := time.Now()
+	err := Run(nil, eng, WithIO(in, w), WithWriteTimeout(50*time.Millisecond))
+	if errors.Cause(err) != ErrWriteTimeout {
+		t.Fatalf("expected %v but instead received: %v", ErrWriteTimeout, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected write to time out after %s but instead took: %s", 50*time.Millisecond, d)
+	}
+	if eng.execs != 1 {
+		t.Errorf("expected %d execs but instead received: %d", 1, eng.execs)
+	}
+
+	// The blocked write was interrupted, instead of being left running
+	select {
+	case <-w.deadline:
+	default:
+		t.Error("expected write deadline to be set")
+	}
+}